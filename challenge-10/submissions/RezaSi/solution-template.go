@@ -2,9 +2,11 @@
 package challenge10
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"reflect"
 	"sort"
 	// Add any necessary imports here
 )
@@ -121,6 +123,170 @@ func (t *Triangle) String() string {
 	return fmt.Sprintf("Triangle with sides: %f, %f, %f", t.SideA, t.SideB, t.SideC)
 }
 
+// CompositeShape is a shape made up of other shapes, which may themselves be
+// composites. The same composite may appear more than once in a tree, but a
+// composite that contains itself is a cycle and is skipped when measuring.
+type CompositeShape struct {
+	Parts []Shape
+}
+
+// NewCompositeShape creates a new CompositeShape with validation
+func NewCompositeShape(parts ...Shape) (*CompositeShape, error) {
+	if len(parts) == 0 {
+		return nil, errors.New("composite shape needs at least one part")
+	}
+	for _, part := range parts {
+		if part == nil {
+			return nil, errors.New("composite shape part is nil")
+		}
+	}
+
+	return &CompositeShape{Parts: parts}, nil
+}
+
+// Area returns the sum of the areas of the parts
+func (cs *CompositeShape) Area() float64 {
+	return cs.sum(Shape.Area, map[*CompositeShape]bool{})
+}
+
+// Perimeter returns the sum of the perimeters of the parts, which is an upper
+// bound on the outline since touching edges are not merged
+func (cs *CompositeShape) Perimeter() float64 {
+	return cs.sum(Shape.Perimeter, map[*CompositeShape]bool{})
+}
+
+// sum adds measure over all parts, skipping composites already on the path
+// from the root so a cycle can't recurse forever
+func (cs *CompositeShape) sum(measure func(Shape) float64, path map[*CompositeShape]bool) float64 {
+	if path[cs] {
+		return 0
+	}
+	path[cs] = true
+	defer delete(path, cs)
+
+	total := 0.0
+	for _, part := range cs.Parts {
+		if nested, ok := part.(*CompositeShape); ok {
+			total += nested.sum(measure, path)
+		} else {
+			total += measure(part)
+		}
+	}
+
+	return total
+}
+
+// String returns a string representation of the composite shape
+func (cs *CompositeShape) String() string {
+	return fmt.Sprintf("Composite shape with %d parts", len(cs.Parts))
+}
+
+// maxShapeDepth limits how deeply composites may nest when marshaling
+const maxShapeDepth = 32
+
+var (
+	// ErrShapeCycle is returned when a composite shape contains itself
+	ErrShapeCycle = errors.New("shape contains a cycle")
+	// ErrShapeTooDeep is returned when composites nest deeper than maxShapeDepth
+	ErrShapeTooDeep = errors.New("shape nesting too deep")
+)
+
+// MarshalShape encodes a shape as JSON with a "type" field naming its
+// concrete type. Composites are encoded with their parts, and an error is
+// returned instead of recursing forever on a cycle or an overly deep tree.
+func MarshalShape(s Shape) ([]byte, error) {
+	value, err := shapeToJSON(s, 0, map[*CompositeShape]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(value)
+}
+
+// shapeToJSON converts a shape to a JSON-ready map, tracking the composites
+// on the current path to detect cycles
+func shapeToJSON(s Shape, depth int, path map[*CompositeShape]bool) (map[string]interface{}, error) {
+	if s == nil {
+		return nil, errors.New("cannot marshal nil shape")
+	}
+	if depth > maxShapeDepth {
+		return nil, ErrShapeTooDeep
+	}
+
+	value := map[string]interface{}{"type": shapeTypeName(s)}
+	switch x := s.(type) {
+	case *Rectangle:
+		value["width"] = x.Width
+		value["height"] = x.Height
+	case *Circle:
+		value["radius"] = x.Radius
+	case *Triangle:
+		value["sides"] = []float64{x.SideA, x.SideB, x.SideC}
+	case *CompositeShape:
+		if path[x] {
+			return nil, ErrShapeCycle
+		}
+		path[x] = true
+		defer delete(path, x)
+
+		parts := make([]interface{}, 0, len(x.Parts))
+		for _, part := range x.Parts {
+			encoded, err := shapeToJSON(part, depth+1, path)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, encoded)
+		}
+		value["parts"] = parts
+	default:
+		value["area"] = s.Area()
+		value["perimeter"] = s.Perimeter()
+	}
+
+	return value, nil
+}
+
+// shapeEpsilon is the relative tolerance used when comparing shape dimensions
+const shapeEpsilon = 1e-9
+
+// approxEqual reports whether two dimensions are equal within shapeEpsilon,
+// scaled by their magnitude so large shapes are compared fairly
+func approxEqual(x, y float64) bool {
+	scale := math.Max(1, math.Max(math.Abs(x), math.Abs(y)))
+	return math.Abs(x-y) <= shapeEpsilon*scale
+}
+
+// Equals reports whether two shapes have the same concrete type and
+// dimensions. Triangles are compared by their sorted side lengths, so
+// congruent triangles are equal regardless of side order.
+func Equals(a, b Shape) bool {
+	switch x := a.(type) {
+	case *Rectangle:
+		y, ok := b.(*Rectangle)
+		return ok && approxEqual(x.Width, y.Width) && approxEqual(x.Height, y.Height)
+	case *Circle:
+		y, ok := b.(*Circle)
+		return ok && approxEqual(x.Radius, y.Radius)
+	case *Triangle:
+		y, ok := b.(*Triangle)
+		if !ok {
+			return false
+		}
+		xs := []float64{x.SideA, x.SideB, x.SideC}
+		ys := []float64{y.SideA, y.SideB, y.SideC}
+		sort.Float64s(xs)
+		sort.Float64s(ys)
+		for i := range xs {
+			if !approxEqual(xs[i], ys[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
 // ShapeCalculator provides utility functions for shapes
 type ShapeCalculator struct{}
 
@@ -167,3 +333,52 @@ func (sc *ShapeCalculator) SortByArea(shapes []Shape, ascending bool) []Shape {
 
 	return shapes
 }
+
+// Contains reports whether shapes holds a shape equal to target
+func (sc *ShapeCalculator) Contains(shapes []Shape, target Shape) bool {
+	for _, shape := range shapes {
+		if Equals(shape, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nilShapeTypeName is the type name nil shapes are grouped and counted under
+const nilShapeTypeName = "<nil>"
+
+// shapeTypeName returns the name of a shape's concrete type, ignoring pointers.
+// A nil shape has no concrete type and is named nilShapeTypeName.
+func shapeTypeName(s Shape) string {
+	t := reflect.TypeOf(s)
+	if t == nil {
+		return nilShapeTypeName
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Name()
+}
+
+// GroupByType groups shapes by concrete type name, keeping input order within each group
+func (sc *ShapeCalculator) GroupByType(shapes []Shape) map[string][]Shape {
+	groups := make(map[string][]Shape)
+	for _, shape := range shapes {
+		name := shapeTypeName(shape)
+		groups[name] = append(groups[name], shape)
+	}
+
+	return groups
+}
+
+// CountByType counts shapes by concrete type name
+func (sc *ShapeCalculator) CountByType(shapes []Shape) map[string]int {
+	counts := make(map[string]int)
+	for _, shape := range shapes {
+		counts[shapeTypeName(shape)]++
+	}
+
+	return counts
+}
@@ -0,0 +1,176 @@
+package challenge10
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// TestEquals tests shape equality within a floating point tolerance
+func TestEquals(t *testing.T) {
+	circle, _ := NewCircle(2.0)
+	nearCircle, _ := NewCircle(2.0 + 1e-12)
+	otherCircle, _ := NewCircle(2.1)
+	rect, _ := NewRectangle(2.0, 2.0)
+	triangle, _ := NewTriangle(3.0, 4.0, 5.0)
+	rotated, _ := NewTriangle(5.0, 3.0, 4.0)
+
+	tests := []struct {
+		name string
+		a, b Shape
+		want bool
+	}{
+		{"Same circle", circle, circle, true},
+		{"Radii differing by 1e-12", circle, nearCircle, true},
+		{"Radii differing by 0.1", circle, otherCircle, false},
+		{"Different types", circle, rect, false},
+		{"Congruent triangles", triangle, rotated, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Equals(tt.a, tt.b); got != tt.want {
+				t.Errorf("Equals(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestShapeCalculatorContains tests the Contains method of ShapeCalculator
+func TestShapeCalculatorContains(t *testing.T) {
+	rect, _ := NewRectangle(5.0, 3.0)
+	circle, _ := NewCircle(2.0)
+	triangle, _ := NewTriangle(3.0, 4.0, 5.0)
+
+	calculator := NewShapeCalculator()
+	shapes := []Shape{rect, circle, triangle}
+
+	target, _ := NewCircle(2.0 + 1e-12)
+	if !calculator.Contains(shapes, target) {
+		t.Errorf("Expected shapes to contain %v", target)
+	}
+
+	missing, _ := NewRectangle(3.0, 5.0)
+	if calculator.Contains(shapes, missing) {
+		t.Errorf("Expected shapes to not contain %v", missing)
+	}
+}
+
+// TestShapeCalculatorGroupByType tests the GroupByType and CountByType methods of ShapeCalculator
+func TestShapeCalculatorGroupByType(t *testing.T) {
+	rect1, _ := NewRectangle(5.0, 3.0)
+	rect2, _ := NewRectangle(1.0, 2.0)
+	circle1, _ := NewCircle(2.0)
+	circle2, _ := NewCircle(1.0)
+	triangle, _ := NewTriangle(3.0, 4.0, 5.0)
+
+	calculator := NewShapeCalculator()
+	shapes := []Shape{circle1, rect1, triangle, circle2, rect2}
+
+	groups := calculator.GroupByType(shapes)
+	expectedGroups := map[string][]Shape{
+		"Rectangle": {rect1, rect2},
+		"Circle":    {circle1, circle2},
+		"Triangle":  {triangle},
+	}
+	if !reflect.DeepEqual(groups, expectedGroups) {
+		t.Errorf("Expected groups %v, got %v", expectedGroups, groups)
+	}
+
+	counts := calculator.CountByType(shapes)
+	expectedCounts := map[string]int{"Rectangle": 2, "Circle": 2, "Triangle": 1}
+	if !reflect.DeepEqual(counts, expectedCounts) {
+		t.Errorf("Expected counts %v, got %v", expectedCounts, counts)
+	}
+
+	if len(calculator.GroupByType(nil)) != 0 {
+		t.Error("Expected no groups for an empty slice")
+	}
+
+	// A nil shape is grouped under "<nil>" instead of panicking
+	withNil := []Shape{rect1, nil, circle1, nil}
+	if groups := calculator.GroupByType(withNil); len(groups["<nil>"]) != 2 || len(groups["Rectangle"]) != 1 {
+		t.Errorf("Expected two nil shapes and one rectangle, got %v", groups)
+	}
+	if counts := calculator.CountByType(withNil); counts["<nil>"] != 2 || counts["Circle"] != 1 {
+		t.Errorf("Expected two nil shapes and one circle, got %v", counts)
+	}
+}
+
+// TestCompositeShape tests area and marshaling of nested composite shapes
+func TestCompositeShape(t *testing.T) {
+	rect, _ := NewRectangle(2.0, 3.0)
+	circle, _ := NewCircle(1.0)
+	triangle, _ := NewTriangle(3.0, 4.0, 5.0)
+
+	inner, _ := NewCompositeShape(circle, triangle)
+	outer, _ := NewCompositeShape(rect, inner)
+
+	expectedArea := 6.0 + math.Pi + 6.0
+	if !approxEqual(outer.Area(), expectedArea) {
+		t.Errorf("Expected area %f, got %f", expectedArea, outer.Area())
+	}
+
+	calculator := NewShapeCalculator()
+	total := calculator.TotalArea([]Shape{outer, rect})
+	if !approxEqual(total, expectedArea+6.0) {
+		t.Errorf("Expected total area %f, got %f", expectedArea+6.0, total)
+	}
+	if counts := calculator.CountByType([]Shape{outer, rect}); counts["CompositeShape"] != 1 {
+		t.Errorf("Expected the composite to count as one shape, got %v", counts)
+	}
+
+	data, err := MarshalShape(outer)
+	if err != nil {
+		t.Fatalf("MarshalShape failed: %v", err)
+	}
+	var decoded struct {
+		Type  string `json:"type"`
+		Parts []struct {
+			Type  string            `json:"type"`
+			Parts []json.RawMessage `json:"parts"`
+		} `json:"parts"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", data, err)
+	}
+	if decoded.Type != "CompositeShape" || len(decoded.Parts) != 2 ||
+		decoded.Parts[0].Type != "Rectangle" || len(decoded.Parts[1].Parts) != 2 {
+		t.Errorf("Unexpected encoding: %s", data)
+	}
+
+	// The same composite used twice is shared, not a cycle
+	shared, _ := NewCompositeShape(inner, inner)
+	if _, err := MarshalShape(shared); err != nil {
+		t.Errorf("Expected shared parts to marshal, got %v", err)
+	}
+
+	if _, err := NewCompositeShape(); err == nil {
+		t.Error("Expected error for an empty composite")
+	}
+}
+
+// TestCompositeShapeCycle tests that cyclic and overly deep composites fail cleanly
+func TestCompositeShapeCycle(t *testing.T) {
+	rect, _ := NewRectangle(2.0, 3.0)
+	a, _ := NewCompositeShape(rect)
+	b, _ := NewCompositeShape(a)
+	a.Parts = append(a.Parts, b)
+
+	if _, err := MarshalShape(a); !errors.Is(err, ErrShapeCycle) {
+		t.Errorf("Expected ErrShapeCycle, got %v", err)
+	}
+	if area := a.Area(); !approxEqual(area, 6.0) {
+		t.Errorf("Expected the cycle to be skipped with area 6, got %f", area)
+	}
+
+	var deep Shape = rect
+	for i := 0; i <= maxShapeDepth; i++ {
+		deep, _ = NewCompositeShape(deep)
+	}
+	if _, err := MarshalShape(deep); !errors.Is(err, ErrShapeTooDeep) {
+		t.Errorf("Expected ErrShapeTooDeep, got %v", err)
+	}
+}
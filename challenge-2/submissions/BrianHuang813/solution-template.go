@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"unicode/utf8"
 )
 
 func main() {
@@ -39,3 +40,35 @@ func ReverseString(s string) string {
 	
 	return reversedString
 }
+
+// ReverseStringFast returns the same result as ReverseString. It checks the
+// string in one pass and sends pure ASCII to ReverseASCII, and anything else
+// to the rune path so multi-byte runes stay intact.
+func ReverseStringFast(s string) string {
+	if isASCII(s) {
+		return ReverseASCII(s)
+	}
+	return ReverseString(s)
+}
+
+// ReverseASCII reverses s byte by byte over a []byte copy. It is only
+// correct for ASCII input, where every byte is a whole character; multi-byte
+// runes in other input come out split. Use ReverseStringFast when the input
+// may not be ASCII.
+func ReverseASCII(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+// isASCII reports whether every byte of s is below utf8.RuneSelf
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
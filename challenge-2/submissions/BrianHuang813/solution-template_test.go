@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var reverseInputs = []struct {
+	name  string
+	input string
+}{
+	{"Empty string", ""},
+	{"Single byte", "a"},
+	{"ASCII word", "hello"},
+	{"ASCII sentence", "Go is fun!"},
+	{"Accented", "héllo wörld"},
+	{"CJK", "反轉字串"},
+	{"Emoji", "go 🚀 fast"},
+	{"Mixed", "abc日本語xyz"},
+	{"Invalid UTF-8", "ab\xffcd"},
+}
+
+func TestReverseStringFastMatchesRunes(t *testing.T) {
+	for _, tt := range reverseInputs {
+		t.Run(tt.name, func(t *testing.T) {
+			want := ReverseString(tt.input)
+			if got := ReverseStringFast(tt.input); got != want {
+				t.Errorf("ReverseStringFast(%q) = %q, want %q", tt.input, got, want)
+			}
+			if isASCII(tt.input) {
+				if got := ReverseASCII(tt.input); got != want {
+					t.Errorf("ReverseASCII(%q) = %q, want %q", tt.input, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReverseASCIIWorksOnBytes(t *testing.T) {
+	// Only ASCII is safe: the two bytes of é swap places and stop being valid UTF-8
+	if got := ReverseASCII("é"); got != "\xa9\xc3" {
+		t.Errorf("expected the bytes of é reversed, got %q", got)
+	}
+}
+
+func TestReverseStringFastKeepsRunesIntact(t *testing.T) {
+	if got := ReverseStringFast("a🚀b"); got != "b🚀a" {
+		t.Errorf("expected %q, got %q", "b🚀a", got)
+	}
+	if got := ReverseStringFast(ReverseStringFast("héllo")); got != "héllo" {
+		t.Errorf("expected reversing twice to round-trip, got %q", got)
+	}
+}
+
+func BenchmarkReverse(b *testing.B) {
+	inputs := []struct {
+		name  string
+		input string
+	}{
+		{"ASCII", strings.Repeat("The quick brown fox. ", 50)},
+		{"Unicode", strings.Repeat("快速的棕色狐狸 🦊 ", 50)},
+	}
+	impls := []struct {
+		name string
+		fn   func(string) string
+	}{
+		{"Runes", ReverseString},
+		{"Fast", ReverseStringFast},
+	}
+
+	for _, in := range inputs {
+		for _, impl := range impls {
+			b.Run(in.name+"/"+impl.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					impl.fn(in.input)
+				}
+			})
+		}
+	}
+}
@@ -2,6 +2,15 @@ package main
 
 import (
 	"fmt"
+	"sort"
+)
+
+// Algorithm names accepted by Search
+const (
+	AlgoNaive     = "naive"
+	AlgoKMP       = "kmp"
+	AlgoRabinKarp = "rabin-karp"
+	AlgoBMH       = "bmh"
 )
 
 func main() {
@@ -34,168 +43,523 @@ func main() {
 		rkResults := RabinKarpSearch(tc.text, tc.pattern)
 		fmt.Printf("Rabin-Karp Search: %v\n", rkResults)
 
+		// Test Boyer-Moore-Horspool algorithm
+		bmhResults := BoyerMooreHorspoolSearch(tc.text, tc.pattern)
+		fmt.Printf("Boyer-Moore-Horspool Search: %v\n", bmhResults)
+
 		fmt.Println("------------------------------")
 	}
 }
 
+// comparisonCounter counts character comparisons between text and pattern, so
+// SearchWithStats can report how much work an algorithm did. Preprocessing
+// the pattern and Rabin-Karp's hash checks aren't counted.
+type comparisonCounter int
+
+// equal compares two characters and counts the comparison
+func (c *comparisonCounter) equal(a, b byte) bool {
+	*c++
+	return a == b
+}
+
 // NaivePatternMatch performs a brute force search for pattern in text.
 // Returns a slice of all starting indices where the pattern is found.
 func NaivePatternMatch(text, pattern string) []int {
-    startingIndices := make([]int, 0)
-    if len(pattern) == 0 {
-        return startingIndices
-    }
+	var c comparisonCounter
+	return naiveSearch(text, pattern, &c)
+}
 
-	for i := 0; i < len(text); i++ {
-	    if i + len(pattern) > len(text) {
-	        break
-	    }
+func naiveSearch(text, pattern string, c *comparisonCounter) []int {
+	startingIndices := make([]int, 0)
+	if len(pattern) == 0 {
+		return startingIndices
+	}
 
-	    if pattern == text[i: i + len(pattern)] {
-	        startingIndices = append(startingIndices, i)
-	    }
+	for i := 0; i+len(pattern) <= len(text); i++ {
+		// Compare left to right, stopping at the first mismatch
+		j := 0
+		for j < len(pattern) && c.equal(text[i+j], pattern[j]) {
+			j++
+		}
+		if j == len(pattern) {
+			startingIndices = append(startingIndices, i)
+		}
 	}
-	
+
 	return startingIndices
 }
 
 // KMPSearch implements the Knuth-Morris-Pratt algorithm to find pattern in text.
 // Returns a slice of all starting indices where the pattern is found.
 func KMPSearch(text, pattern string) []int {
-    matches := []int{}
-    
-    // Handle edge cases
-    if len(pattern) == 0 || len(text) < len(pattern) {
-        return matches
-    }
-    
-    n := len(text)
-    m := len(pattern)
-    
-    // Preprocess the pattern
-    lps := computeLPSArray(pattern)
-    
-    i := 0 // Index for text
-    j := 0 // Index for pattern
-    
-    for i < n {
-        // Current characters match, move both pointers forward
-        if pattern[j] == text[i] {
-            i++
-            j++
-        }
-        
-        // Found a complete match
-        if j == m {
-            matches = append(matches, i-j)
-            // Use lps to shift pattern for next match
-            j = lps[j-1]
-        } else if i < n && pattern[j] != text[i] {
-            // Mismatch after j matches
-            if j != 0 {
-                // Use lps to shift pattern
-                j = lps[j-1]
-            } else {
-                // No match found, move to next character in text
-                i++
-            }
-        }
-    }
-    
-    return matches
+	var c comparisonCounter
+	return kmpSearch(text, pattern, &c)
+}
+
+func kmpSearch(text, pattern string, c *comparisonCounter) []int {
+	matches := []int{}
+
+	// Handle edge cases
+	if len(pattern) == 0 || len(text) < len(pattern) {
+		return matches
+	}
+
+	n := len(text)
+	m := len(pattern)
+
+	// Preprocess the pattern
+	lps := computeLPSArray(pattern)
+
+	i := 0 // Index for text
+	j := 0 // Index for pattern
+
+	for i < n {
+		if c.equal(text[i], pattern[j]) {
+			// Current characters match, move both pointers forward
+			i++
+			j++
+
+			// Found a complete match, use lps to shift pattern for next match
+			if j == m {
+				matches = append(matches, i-j)
+				j = lps[j-1]
+			}
+		} else if j != 0 {
+			// Mismatch after j matches, use lps to shift pattern
+			j = lps[j-1]
+		} else {
+			// No match found, move to next character in text
+			i++
+		}
+	}
+
+	return matches
 }
 
 func computeLPSArray(pattern string) []int {
-    m := len(pattern)
-    lps := make([]int, m)
-    
-    // Length of the previous longest prefix suffix
-    length := 0
-    i := 1
-    
-    // The loop calculates lps[i] for i = 1 to m-1
-    for i < m {
-        if pattern[i] == pattern[length] {
-            length++
-            lps[i] = length
-            i++
-        } else {
-            // This is the tricky part
-            if length != 0 {
-                length = lps[length-1]
-                // Note: We do not increment i here
-            } else {
-                lps[i] = 0
-                i++
-            }
-        }
-    }
-    
-    return lps
+	m := len(pattern)
+	lps := make([]int, m)
+
+	// Length of the previous longest prefix suffix
+	length := 0
+	i := 1
+
+	// The loop calculates lps[i] for i = 1 to m-1
+	for i < m {
+		if pattern[i] == pattern[length] {
+			length++
+			lps[i] = length
+			i++
+		} else {
+			// This is the tricky part
+			if length != 0 {
+				length = lps[length-1]
+				// Note: We do not increment i here
+			} else {
+				lps[i] = 0
+				i++
+			}
+		}
+	}
+
+	return lps
 }
 
 // RabinKarpSearch implements the Rabin-Karp algorithm to find pattern in text.
 // Returns a slice of all starting indices where the pattern is found.
 func RabinKarpSearch(text, pattern string) []int {
-    matches := []int{}
-    
-    // Handle edge cases
-    if len(pattern) == 0 || len(text) < len(pattern) {
-        return matches
-    }
-    
-    n := len(text)
-    m := len(pattern)
-    
-    // Large prime number to avoid hash collisions
-    prime := 101
-    
-    // Base value for the hash function
-    base := 256
-    
-    // Hash value for pattern and initial window
-    patternHash := 0
-    windowHash := 0
-    
-    // Highest power of base that we need
-    h := 1
-    for i := 0; i < m-1; i++ {
-        h = (h * base) % prime
-    }
-    
-    // Calculate initial hash values
-    for i := 0; i < m; i++ {
-        patternHash = (base*patternHash + int(pattern[i])) % prime
-        windowHash = (base*windowHash + int(text[i])) % prime
-    }
-    
-    // Slide the pattern over text one by one
-    for i := 0; i <= n-m; i++ {
-        // Check if hash values match
-        if patternHash == windowHash {
-            // Verify the match character by character
-            match := true
-            for j := 0; j < m; j++ {
-                if text[i+j] != pattern[j] {
-                    match = false
-                    break
-                }
-            }
-            if match {
-                matches = append(matches, i)
-            }
-        }
-        
-        // Calculate hash value for next window
-        if i < n-m {
-            windowHash = (base*(windowHash-int(text[i])*h) + int(text[i+m])) % prime
-            
-            // Ensure we only have positive hash values
-            if windowHash < 0 {
-                windowHash += prime
-            }
-        }
-    }
-    
-    return matches
+	var c comparisonCounter
+	return rabinKarpSearch(text, pattern, &c)
+}
+
+func rabinKarpSearch(text, pattern string, c *comparisonCounter) []int {
+	matches := []int{}
+
+	// Handle edge cases
+	if len(pattern) == 0 || len(text) < len(pattern) {
+		return matches
+	}
+
+	n := len(text)
+	m := len(pattern)
+
+	// Large prime number to avoid hash collisions
+	prime := 101
+
+	// Base value for the hash function
+	base := 256
+
+	// Hash value for pattern and initial window
+	patternHash := 0
+	windowHash := 0
+
+	// Highest power of base that we need
+	h := 1
+	for i := 0; i < m-1; i++ {
+		h = (h * base) % prime
+	}
+
+	// Calculate initial hash values
+	for i := 0; i < m; i++ {
+		patternHash = (base*patternHash + int(pattern[i])) % prime
+		windowHash = (base*windowHash + int(text[i])) % prime
+	}
+
+	// Slide the pattern over text one by one
+	for i := 0; i <= n-m; i++ {
+		// Check if hash values match
+		if patternHash == windowHash {
+			// Verify the match character by character
+			match := true
+			for j := 0; j < m; j++ {
+				if !c.equal(text[i+j], pattern[j]) {
+					match = false
+					break
+				}
+			}
+			if match {
+				matches = append(matches, i)
+			}
+		}
+
+		// Calculate hash value for next window
+		if i < n-m {
+			windowHash = (base*(windowHash-int(text[i])*h) + int(text[i+m])) % prime
+
+			// Ensure we only have positive hash values
+			if windowHash < 0 {
+				windowHash += prime
+			}
+		}
+	}
+
+	return matches
+}
+
+// BoyerMooreHorspoolSearch implements the Boyer-Moore-Horspool algorithm to find pattern in text.
+// Returns a slice of all starting indices where the pattern is found.
+func BoyerMooreHorspoolSearch(text, pattern string) []int {
+	var c comparisonCounter
+	return bmhSearch(text, pattern, &c)
+}
+
+func bmhSearch(text, pattern string, c *comparisonCounter) []int {
+	matches := []int{}
+
+	// Handle edge cases
+	if len(pattern) == 0 || len(text) < len(pattern) {
+		return matches
+	}
+
+	n := len(text)
+	m := len(pattern)
+
+	// Bad character table: how far the window can shift when its last
+	// byte is b. Bytes absent from the pattern shift by m.
+	var shift [256]int
+	for b := range shift {
+		shift[b] = m
+	}
+	for i := 0; i < m-1; i++ {
+		shift[pattern[i]] = m - 1 - i
+	}
+
+	// Compare the window right to left, then shift by the last character
+	for i := 0; i <= n-m; i += shift[text[i+m-1]] {
+		j := m - 1
+		for j >= 0 && c.equal(text[i+j], pattern[j]) {
+			j--
+		}
+		if j < 0 {
+			matches = append(matches, i)
+		}
+	}
+
+	return matches
+}
+
+// Search finds pattern in text using the named algorithm
+// ("naive", "kmp", "rabin-karp" or "bmh").
+// Returns an error for an unknown algorithm name.
+func Search(text, pattern string, algo string) ([]int, error) {
+	switch algo {
+	case AlgoNaive:
+		return NaivePatternMatch(text, pattern), nil
+	case AlgoKMP:
+		return KMPSearch(text, pattern), nil
+	case AlgoRabinKarp:
+		return RabinKarpSearch(text, pattern), nil
+	case AlgoBMH:
+		return BoyerMooreHorspoolSearch(text, pattern), nil
+	default:
+		return nil, fmt.Errorf("unknown pattern matching algorithm %q", algo)
+	}
+}
+
+// SearchWithStats is like Search but also returns how many character
+// comparisons the algorithm made, to show how KMP and Boyer-Moore-Horspool
+// skip work the naive search repeats. An unknown algorithm name returns
+// no indices and zero comparisons.
+func SearchWithStats(text, pattern string, algo string) (indices []int, comparisons int) {
+	var search func(text, pattern string, c *comparisonCounter) []int
+	switch algo {
+	case AlgoNaive:
+		search = naiveSearch
+	case AlgoKMP:
+		search = kmpSearch
+	case AlgoRabinKarp:
+		search = rabinKarpSearch
+	case AlgoBMH:
+		search = bmhSearch
+	default:
+		return nil, 0
+	}
+
+	var c comparisonCounter
+	indices = search(text, pattern, &c)
+	return indices, int(c)
+}
+
+// trieNode is a node of a Trie; the path from the root spells a prefix
+type trieNode struct {
+	children map[rune]*trieNode
+	isWord   bool // a word ends at this node
+}
+
+// Trie stores a set of words for exact and prefix lookups. It works on runes,
+// so a prefix never splits a multi-byte character.
+type Trie struct {
+	root trieNode
+	size int
+}
+
+// NewTrie creates an empty trie
+func NewTrie() *Trie {
+	return &Trie{}
+}
+
+// Insert adds word to the trie. Inserting a word twice has no effect.
+func (t *Trie) Insert(word string) {
+	node := &t.root
+	for _, r := range word {
+		if node.children == nil {
+			node.children = make(map[rune]*trieNode)
+		}
+		child, ok := node.children[r]
+		if !ok {
+			child = &trieNode{}
+			node.children[r] = child
+		}
+		node = child
+	}
+	if !node.isWord {
+		node.isWord = true
+		t.size++
+	}
+}
+
+// Contains reports whether word was inserted
+func (t *Trie) Contains(word string) bool {
+	node := t.find(word)
+	return node != nil && node.isWord
+}
+
+// HasPrefix reports whether any inserted word starts with prefix. Every word
+// starts with the empty prefix, so HasPrefix("") is true only when the trie
+// holds at least one word, the empty word included.
+func (t *Trie) HasPrefix(prefix string) bool {
+	node := t.find(prefix)
+	return node != nil && (node.isWord || len(node.children) > 0)
+}
+
+// WordsWithPrefix returns every inserted word starting with prefix, sorted.
+// An empty prefix returns all words.
+func (t *Trie) WordsWithPrefix(prefix string) []string {
+	words := []string{}
+	node := t.find(prefix)
+	if node == nil {
+		return words
+	}
+
+	// Walk the subtree with an explicit stack, carrying the word spelled so far
+	type frame struct {
+		node *trieNode
+		word string
+	}
+	stack := []frame{{node, prefix}}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if top.node.isWord {
+			words = append(words, top.word)
+		}
+		for r, child := range top.node.children {
+			stack = append(stack, frame{child, top.word + string(r)})
+		}
+	}
+
+	sort.Strings(words)
+	return words
+}
+
+// Size returns the number of distinct words in the trie
+func (t *Trie) Size() int {
+	return t.size
+}
+
+// find returns the node reached by following s from the root, or nil
+func (t *Trie) find(s string) *trieNode {
+	node := &t.root
+	for _, r := range s {
+		node = node.children[r]
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// The similarity metrics below support fuzzy matching alongside the exact
+// searches above. They compare runes, not bytes, so multi-byte characters
+// count as a single character.
+//
+// They live in this file rather than in a similarity package because a
+// submission is graded by copying solution-template.go alone into a fresh
+// module (see run_tests.sh), so an import of a sibling package can't
+// resolve there. Other submissions that need fuzzy matching copy these
+// functions, the same way every solution here is self-contained.
+
+// Levenshtein returns the minimum number of single-character insertions,
+// deletions and substitutions needed to turn a into b
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < len(rb) {
+		ra, rb = rb, ra
+	}
+
+	// Only the previous row of the DP table is needed, sized by the shorter string
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// Jaro returns the Jaro similarity of a and b, from 0 (nothing in common)
+// to 1 (identical). Two empty strings are identical.
+func Jaro(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+
+	// Characters match if equal and no further apart than the window
+	window := max(max(len(ra), len(rb))/2-1, 0)
+	matchedA := make([]bool, len(ra))
+	matchedB := make([]bool, len(rb))
+	matches := 0
+	for i, r := range ra {
+		for j := max(0, i-window); j <= min(len(rb)-1, i+window); j++ {
+			if !matchedB[j] && rb[j] == r {
+				matchedA[i], matchedB[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	// Count matched characters that appear in a different order
+	transpositions := 0
+	j := 0
+	for i, r := range ra {
+		if !matchedA[i] {
+			continue
+		}
+		for !matchedB[j] {
+			j++
+		}
+		if r != rb[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	return (m/float64(len(ra)) + m/float64(len(rb)) + (m-float64(transpositions)/2)/m) / 3
+}
+
+// Winkler's parameters: the prefix bonus applies above the boost threshold,
+// for up to winklerMaxPrefix leading characters, each worth winklerPrefixScale
+const (
+	winklerBoostThreshold = 0.7
+	winklerMaxPrefix      = 4
+	winklerPrefixScale    = 0.1
+)
+
+// JaroWinkler returns the Jaro-Winkler similarity of a and b, from 0 to 1.
+// It is the Jaro similarity raised for strings sharing a common prefix, which
+// suits short strings such as names where typos are rarer at the start.
+func JaroWinkler(a, b string) float64 {
+	jaro := Jaro(a, b)
+	if jaro <= winklerBoostThreshold {
+		return jaro
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	prefix := 0
+	for prefix < min(len(ra), len(rb), winklerMaxPrefix) && ra[prefix] == rb[prefix] {
+		prefix++
+	}
+
+	return jaro + float64(prefix)*winklerPrefixScale*(1-jaro)
+}
+
+// DiceCoefficient returns the Sørensen-Dice coefficient of the character
+// bigrams of a and b, from 0 to 1. Repeated bigrams are counted as many
+// times as they occur. Strings too short to have a bigram score 1 if they
+// are equal and 0 otherwise.
+func DiceCoefficient(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < 2 || len(rb) < 2 {
+		if a == b {
+			return 1
+		}
+		return 0
+	}
+
+	bigrams := make(map[[2]rune]int, len(ra)-1)
+	for i := 0; i < len(ra)-1; i++ {
+		bigrams[[2]rune{ra[i], ra[i+1]}]++
+	}
+
+	shared := 0
+	for i := 0; i < len(rb)-1; i++ {
+		bigram := [2]rune{rb[i], rb[i+1]}
+		if bigrams[bigram] > 0 {
+			bigrams[bigram]--
+			shared++
+		}
+	}
+
+	return 2 * float64(shared) / float64(len(ra)-1+len(rb)-1)
 }
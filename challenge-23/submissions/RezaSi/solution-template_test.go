@@ -0,0 +1,280 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var searchTestCases = []struct {
+	name     string
+	text     string
+	pattern  string
+	expected []int
+}{
+	{"Basic case", "ABABDABACDABABCABAB", "ABABCABAB", []int{10}},
+	{"Multiple occurrences", "AABAACAADAABAABA", "AABA", []int{0, 9, 12}},
+	{"Occurrence at the beginning", "GEEKSFORGEEKS", "GEEK", []int{0, 8}},
+	{"Overlapping occurrences", "AAAAAA", "AA", []int{0, 1, 2, 3, 4}},
+	{"No occurrences", "ABCDEFG", "XYZ", []int{}},
+	{"Empty pattern", "ABCDEFG", "", []int{}},
+	{"Empty text", "", "ABC", []int{}},
+	{"Both empty", "", "", []int{}},
+	{"Pattern longer than text", "ABC", "ABCDEF", []int{}},
+	{"Pattern is the entire text", "ABCDEF", "ABCDEF", []int{0}},
+	{"Complex pattern", "ACACACACGTACACACA", "ACACACA", []int{0, 10}},
+	{"Single character", "ABACADA", "A", []int{0, 2, 4, 6}},
+}
+
+func TestBoyerMooreHorspoolSearch(t *testing.T) {
+	for _, tt := range searchTestCases {
+		t.Run(tt.name, func(t *testing.T) {
+			result := BoyerMooreHorspoolSearch(tt.text, tt.pattern)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("BoyerMooreHorspoolSearch(%s, %s) = %v, expected %v",
+					tt.text, tt.pattern, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSearchAlgorithmsAgree(t *testing.T) {
+	algorithms := []string{AlgoNaive, AlgoKMP, AlgoRabinKarp, AlgoBMH}
+
+	for _, tt := range searchTestCases {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, algo := range algorithms {
+				result, err := Search(tt.text, tt.pattern, algo)
+				if err != nil {
+					t.Fatalf("Search with %s returned error: %v", algo, err)
+				}
+				if !reflect.DeepEqual(result, tt.expected) {
+					t.Errorf("Search(%s, %s, %s) = %v, expected %v",
+						tt.text, tt.pattern, algo, result, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchUnknownAlgorithm(t *testing.T) {
+	if _, err := Search("ABC", "B", "boyer-moore"); err == nil {
+		t.Error("Expected an error for an unknown algorithm")
+	}
+}
+
+func TestSearchWithStats(t *testing.T) {
+	algorithms := []string{AlgoNaive, AlgoKMP, AlgoRabinKarp, AlgoBMH}
+
+	for _, tt := range searchTestCases {
+		for _, algo := range algorithms {
+			result, _ := SearchWithStats(tt.text, tt.pattern, algo)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("SearchWithStats(%s, %s, %s) = %v, expected %v",
+					tt.text, tt.pattern, algo, result, tt.expected)
+			}
+		}
+	}
+
+	// Worst case for the naive search: every window matches until the last character
+	text := strings.Repeat("A", 1000)
+	pattern := strings.Repeat("A", 9) + "B"
+
+	naive, naiveComparisons := SearchWithStats(text, pattern, AlgoNaive)
+	kmp, kmpComparisons := SearchWithStats(text, pattern, AlgoKMP)
+	if !reflect.DeepEqual(naive, kmp) {
+		t.Errorf("Expected identical indices, naive %v, kmp %v", naive, kmp)
+	}
+	if naiveComparisons != 991*10 {
+		t.Errorf("Expected %d naive comparisons, got %d", 991*10, naiveComparisons)
+	}
+	if kmpComparisons > 2*len(text) {
+		t.Errorf("Expected at most %d KMP comparisons, got %d", 2*len(text), kmpComparisons)
+	}
+	if kmpComparisons >= naiveComparisons {
+		t.Errorf("Expected KMP (%d) to compare less than naive (%d)", kmpComparisons, naiveComparisons)
+	}
+	if _, bmhComparisons := SearchWithStats(text, pattern, AlgoBMH); bmhComparisons >= naiveComparisons {
+		t.Errorf("Expected BMH (%d) to compare less than naive (%d)", bmhComparisons, naiveComparisons)
+	}
+
+	if indices, comparisons := SearchWithStats("ABC", "B", "boyer-moore"); indices != nil || comparisons != 0 {
+		t.Errorf("Expected no result for an unknown algorithm, got %v and %d", indices, comparisons)
+	}
+}
+
+func TestTrie(t *testing.T) {
+	trie := NewTrie()
+	for _, word := range []string{"go", "gopher", "golang", "gone", "rust", "go", "über", "übung"} {
+		trie.Insert(word)
+	}
+
+	if trie.Size() != 7 {
+		t.Errorf("Expected 7 distinct words, got %d", trie.Size())
+	}
+
+	t.Run("Exact and prefix matches", func(t *testing.T) {
+		tests := []struct {
+			query     string
+			contains  bool
+			hasPrefix bool
+		}{
+			{"go", true, true},
+			{"gopher", true, true},
+			{"gop", false, true},
+			{"gophers", false, false},
+			{"r", false, true},
+			{"python", false, false},
+			{"üb", false, true},
+			{"übung", true, true},
+			{"", false, true},
+		}
+		for _, tt := range tests {
+			if got := trie.Contains(tt.query); got != tt.contains {
+				t.Errorf("Contains(%q) = %v, expected %v", tt.query, got, tt.contains)
+			}
+			if got := trie.HasPrefix(tt.query); got != tt.hasPrefix {
+				t.Errorf("HasPrefix(%q) = %v, expected %v", tt.query, got, tt.hasPrefix)
+			}
+		}
+	})
+
+	t.Run("Words with prefix", func(t *testing.T) {
+		tests := []struct {
+			prefix   string
+			expected []string
+		}{
+			{"go", []string{"go", "golang", "gone", "gopher"}},
+			{"gon", []string{"gone"}},
+			{"ü", []string{"über", "übung"}},
+			{"x", []string{}},
+			{"", []string{"go", "golang", "gone", "gopher", "rust", "über", "übung"}},
+		}
+		for _, tt := range tests {
+			if got := trie.WordsWithPrefix(tt.prefix); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("WordsWithPrefix(%q) = %v, expected %v", tt.prefix, got, tt.expected)
+			}
+		}
+	})
+
+	t.Run("Empty trie", func(t *testing.T) {
+		empty := NewTrie()
+		if empty.Contains("") || empty.Size() != 0 {
+			t.Error("Expected an empty trie to contain nothing")
+		}
+		if empty.HasPrefix("") {
+			t.Error("Expected no word in an empty trie to have the empty prefix")
+		}
+		if got := empty.WordsWithPrefix(""); len(got) != 0 {
+			t.Errorf("Expected no words, got %v", got)
+		}
+
+		// The empty string is a word once inserted
+		empty.Insert("")
+		if !empty.Contains("") || !reflect.DeepEqual(empty.WordsWithPrefix(""), []string{""}) {
+			t.Error("Expected the empty word to be stored")
+		}
+		if !empty.HasPrefix("") || empty.HasPrefix("a") {
+			t.Error("Expected only the empty prefix to match the empty word")
+		}
+	})
+}
+
+// approxEqual compares similarity scores to the precision reference values are published with
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 0.001
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want int
+	}{
+		{"Both empty", "", "", 0},
+		{"Empty first", "", "abc", 3},
+		{"Empty second", "abc", "", 3},
+		{"Identical", "gopher", "gopher", 0},
+		{"Kitten sitting", "kitten", "sitting", 3},
+		{"Saturday sunday", "saturday", "sunday", 3},
+		{"Flaw lawn", "flaw", "lawn", 2},
+		{"Single substitution", "book", "back", 2},
+		{"Case sensitive", "Go", "go", 1},
+		{"Multi-byte runes", "café", "cafe", 1},
+		{"CJK", "你好世界", "你好", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Levenshtein(tt.a, tt.b); got != tt.want {
+				t.Errorf("Levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := Levenshtein(tt.b, tt.a); got != tt.want {
+				t.Errorf("Levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJaroWinkler(t *testing.T) {
+	tests := []struct {
+		name        string
+		a, b        string
+		jaro, jaroW float64
+	}{
+		{"Both empty", "", "", 1, 1},
+		{"One empty", "abc", "", 0, 0},
+		{"Identical", "gopher", "gopher", 1, 1},
+		{"No common characters", "abc", "xyz", 0, 0},
+		{"Martha", "MARTHA", "MARHTA", 0.944, 0.961},
+		{"Dwayne", "DWAYNE", "DUANE", 0.822, 0.840},
+		{"Dixon", "DIXON", "DICKSONX", 0.767, 0.813},
+		{"Below boost threshold", "CRATE", "TRACE", 0.733, 0.733},
+		{"Multi-byte runes", "résumé", "resume", 0.778, 0.800},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Jaro(tt.a, tt.b); !approxEqual(got, tt.jaro) {
+				t.Errorf("Jaro(%q, %q) = %.3f, want %.3f", tt.a, tt.b, got, tt.jaro)
+			}
+			if got := JaroWinkler(tt.a, tt.b); !approxEqual(got, tt.jaroW) {
+				t.Errorf("JaroWinkler(%q, %q) = %.3f, want %.3f", tt.a, tt.b, got, tt.jaroW)
+			}
+			if got := JaroWinkler(tt.b, tt.a); !approxEqual(got, tt.jaroW) {
+				t.Errorf("JaroWinkler(%q, %q) = %.3f, want %.3f", tt.b, tt.a, got, tt.jaroW)
+			}
+		})
+	}
+}
+
+func TestDiceCoefficient(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{"Both empty", "", "", 1},
+		{"One empty", "night", "", 0},
+		{"Identical", "gopher", "gopher", 1},
+		{"Single equal characters", "a", "a", 1},
+		{"Single different characters", "a", "b", 0},
+		{"Night nacht", "night", "nacht", 0.25},
+		{"Context contact", "context", "contact", 0.5},
+		{"Repeated bigrams", "aaaa", "aa", 0.5},
+		{"No shared bigrams", "abc", "xyz", 0},
+		{"Multi-byte runes", "日本語", "日本人", 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiceCoefficient(tt.a, tt.b); !approxEqual(got, tt.want) {
+				t.Errorf("DiceCoefficient(%q, %q) = %.3f, want %.3f", tt.a, tt.b, got, tt.want)
+			}
+			if got := DiceCoefficient(tt.b, tt.a); !approxEqual(got, tt.want) {
+				t.Errorf("DiceCoefficient(%q, %q) = %.3f, want %.3f", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"sort"
 )
 
 const inf = 2000000000
@@ -62,7 +63,12 @@ func DPLongestIncreasingSubsequence(nums []int) int {
 }
 
 func find(a *[]int, val, ind int) int {
-	L, R := 0, ind
+	return findIn(a, val, 0, ind)
+}
+
+// findIn binary searches (*a)[L:R] for the last index holding a value below
+// val, given (*a)[L] < val and that R is either ind or holds a value >= val
+func findIn(a *[]int, val, L, R int) int {
 	var M int
 
 	for R-L > 1 {
@@ -77,6 +83,19 @@ func find(a *[]int, val, ind int) int {
 	return L
 }
 
+// exponentialFind has the same result as find, but first doubles a bound
+// from the front until it passes val and only binary searches the last
+// doubling. This costs O(log k) where k is the answer, which is faster than
+// a full binary search when most values land in the lower piles.
+func exponentialFind(a *[]int, val, ind int) int {
+	bound := 1
+	for bound < ind && (*a)[bound] < val {
+		bound *= 2
+	}
+
+	return findIn(a, val, bound/2, min(bound, ind))
+}
+
 // OptimizedLIS finds the length of the longest increasing subsequence
 // using an optimized approach with O(n log n) time complexity.
 func OptimizedLIS(nums []int) int {
@@ -91,7 +110,7 @@ func OptimizedLIS(nums []int) int {
 	var ind int
 
 	for i, num := range nums {
-		ind = find(&minVal, num, i+1)
+		ind = exponentialFind(&minVal, num, i+1)
 		answer = max(answer, ind+1)
 		minVal[ind+1] = min(minVal[ind+1], num)
 	}
@@ -125,7 +144,7 @@ func GetLISElements(nums []int) []int {
 	var ind int
 
 	for i, num := range nums {
-		ind = find(&minVal, num, i+1)
+		ind = exponentialFind(&minVal, num, i+1)
 		if ind+1 > answer {
 			answer = ind + 1
 			bestInd = i
@@ -141,3 +160,111 @@ func GetLISElements(nums []int) []int {
 	buildLIS(&seq, &nums, &pre, bestInd)
 	return seq
 }
+
+// GetAllLIS returns every distinct longest increasing subsequence, sorted
+// lexicographically. Subsequences taken from different positions but with the
+// same values are reported once. The output can grow exponentially with the
+// input, as there can be that many distinct optimal subsequences.
+func GetAllLIS(nums []int) [][]int {
+	if len(nums) == 0 {
+		return [][]int{}
+	}
+
+	// LIS[i] is the LIS length ending at i, ending[i] the distinct subsequences of that length ending at i
+	LIS := make([]int, len(nums))
+	ending := make([][][]int, len(nums))
+	answer := 0
+
+	for i, num := range nums {
+		LIS[i] = 1
+		for j := 0; j < i; j++ {
+			if nums[j] < num {
+				LIS[i] = max(LIS[i], LIS[j]+1)
+			}
+		}
+
+		if LIS[i] == 1 {
+			ending[i] = [][]int{{num}}
+		} else {
+			var seqs [][]int
+			for j := 0; j < i; j++ {
+				if nums[j] < num && LIS[j] == LIS[i]-1 {
+					for _, seq := range ending[j] {
+						extended := make([]int, len(seq), len(seq)+1)
+						copy(extended, seq)
+						seqs = append(seqs, append(extended, num))
+					}
+				}
+			}
+			ending[i] = uniqueSequences(seqs)
+		}
+		answer = max(answer, LIS[i])
+	}
+
+	var all [][]int
+	for i := range nums {
+		if LIS[i] == answer {
+			all = append(all, ending[i]...)
+		}
+	}
+
+	all = uniqueSequences(all)
+	sort.Slice(all, func(i, j int) bool {
+		for k := range all[i] {
+			if all[i][k] != all[j][k] {
+				return all[i][k] < all[j][k]
+			}
+		}
+		return false
+	})
+	return all
+}
+
+// uniqueSequences drops repeated sequences, keeping the first of each
+func uniqueSequences(seqs [][]int) [][]int {
+	seen := make(map[string]bool, len(seqs))
+	unique := make([][]int, 0, len(seqs))
+
+	for _, seq := range seqs {
+		key := fmt.Sprint(seq)
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, seq)
+		}
+	}
+
+	return unique
+}
+
+// MaxSumIncreasingSubsequence returns the largest sum of a strictly increasing
+// subsequence along with its elements, using O(n²) dynamic programming.
+// For a non-empty slice the subsequence is never empty, so an all-negative
+// input yields its largest element. An empty slice yields 0 and no elements.
+func MaxSumIncreasingSubsequence(nums []int) (sum int, elements []int) {
+	if len(nums) == 0 {
+		return 0, []int{}
+	}
+
+	best := make([]int, len(nums))
+	pre := make([]int, len(nums))
+	bestInd := 0
+
+	for i, num := range nums {
+		best[i] = num
+		pre[i] = -1
+		for j := 0; j < i; j++ {
+			// Only extend a prefix that adds to the sum
+			if nums[j] < num && best[j] > 0 && best[j]+num > best[i] {
+				best[i] = best[j] + num
+				pre[i] = j
+			}
+		}
+		if best[i] > best[bestInd] {
+			bestInd = i
+		}
+	}
+
+	seq := make([]int, 0)
+	buildLIS(&seq, &nums, &pre, bestInd)
+	return best[bestInd], seq
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestMaxSumIncreasingSubsequence(t *testing.T) {
+	testCases := []struct {
+		name     string
+		nums     []int
+		sum      int
+		elements []int
+	}{
+		{"Example", []int{1, 101, 2, 3, 100}, 106, []int{1, 2, 3, 100}},
+		{"Sum beats length", []int{3, 4, 5, 10}, 22, []int{3, 4, 5, 10}},
+		{"Long chain loses to large element", []int{1, 2, 3, 50, 4, 5}, 56, []int{1, 2, 3, 50}},
+		{"Skips negative prefix", []int{-5, -1, 2, 3}, 5, []int{2, 3}},
+		{"All negative", []int{-3, -1, -2}, -1, []int{-1}},
+		{"Decreasing", []int{5, 4, 3}, 5, []int{5}},
+		{"Equal values", []int{7, 7, 7}, 7, []int{7}},
+		{"Single element", []int{4}, 4, []int{4}},
+		{"Empty", []int{}, 0, []int{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sum, elements := MaxSumIncreasingSubsequence(tc.nums)
+			if sum != tc.sum {
+				t.Errorf("Expected sum %d, got %d", tc.sum, sum)
+			}
+			if !reflect.DeepEqual(elements, tc.elements) {
+				t.Errorf("Expected elements %v, got %v", tc.elements, elements)
+			}
+		})
+	}
+}
+
+func TestGetAllLIS(t *testing.T) {
+	testCases := []struct {
+		name     string
+		nums     []int
+		expected [][]int
+	}{
+		{"Duplicate paths", []int{1, 2, 1, 2}, [][]int{{1, 2}}},
+		{"Two choices", []int{1, 3, 2, 4}, [][]int{{1, 2, 4}, {1, 3, 4}}},
+		{"Branching twice", []int{1, 3, 2, 5, 4}, [][]int{{1, 2, 4}, {1, 2, 5}, {1, 3, 4}, {1, 3, 5}}},
+		{"Example 1", []int{10, 9, 2, 5, 3, 7, 101, 18}, [][]int{
+			{2, 3, 7, 18}, {2, 3, 7, 101}, {2, 5, 7, 18}, {2, 5, 7, 101},
+		}},
+		{"Decreasing", []int{3, 2, 1}, [][]int{{1}, {2}, {3}}},
+		{"All same numbers", []int{7, 7, 7}, [][]int{{7}}},
+		{"Single element", []int{5}, [][]int{{5}}},
+		{"Empty", []int{}, [][]int{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := GetAllLIS(tc.nums)
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestExponentialFind(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 1; n <= 40; n++ {
+		// Sorted piles with -inf in front, as OptimizedLIS builds them
+		piles := []int{-inf}
+		for i := 1; i < n; i++ {
+			piles = append(piles, piles[i-1]+1+r.Intn(3))
+		}
+		for val := -2; val <= piles[n-1]+2; val++ {
+			if got, want := exponentialFind(&piles, val, n), find(&piles, val, n); got != want {
+				t.Fatalf("piles %v, val %d: expected %d, got %d", piles, val, want, got)
+			}
+		}
+	}
+}
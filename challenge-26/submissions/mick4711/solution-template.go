@@ -1,6 +1,10 @@
 package regex
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
 	"regexp"
 	"strings"
 )
@@ -33,50 +37,154 @@ func ValidatePhone(phone string) bool {
 // MaskCreditCard replaces all but the last 4 digits of a credit card number with "X"
 // Example: "1234-5678-9012-3456" -> "XXXX-XXXX-XXXX-3456"
 func MaskCreditCard(cardNumber string) string {
-	// 1. Create a regular expression to identify the parts of the card number to mask
-	re := regexp.MustCompile(`\w{4}`)
-	groups := re.FindAllString(cardNumber, -1)
-
-	// 2. Use ReplaceAllString or similar method to perform the replacement
-	mask := "XXXX"
-	for i, group := range groups {
-		if i == len(groups)-1 {
-			break
-		}
-		if group == mask {
-			continue
+	// 1. Mask everything but the separators, keeping the last 4 characters
+	isCardChar := func(r rune) bool { return !reCardSeparator.MatchString(string(r)) }
+
+	// 2. Return the masked card number with the separators in place
+	return maskFormatted(cardNumber, isCardChar, 0, 4, 'X')
+}
+
+// MaskEmail masks the local part of an email address, keeping its first and
+// last character. The domain is left as is.
+// Example: "john.doe@example.com" -> "j******e@example.com"
+// A string without an "@" is masked completely.
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return MaskMiddle(email, 0, 0, '*')
+	}
+	return MaskMiddle(email[:at], 1, 1, '*') + email[at:]
+}
+
+// MaskPhone replaces all but the last 4 digits of a phone number with "X",
+// keeping the formatting characters.
+// Example: "(555) 123-4567" -> "(XXX) XXX-4567"
+func MaskPhone(phone string) string {
+	isDigit := func(r rune) bool { return r >= '0' && r <= '9' }
+	return maskFormatted(phone, isDigit, 0, 4, 'X')
+}
+
+// MaskMiddle replaces every rune of s with maskChar except the first keepStart
+// and the last keepEnd runes. Negative counts are treated as 0.
+// If s is not longer than keepStart+keepEnd every rune is masked, so a value
+// is never returned in full just because it is short.
+// Example: MaskMiddle("secret", 1, 2, '*') -> "s***et"
+func MaskMiddle(s string, keepStart, keepEnd int, maskChar rune) string {
+	keepStart = max(keepStart, 0)
+	keepEnd = max(keepEnd, 0)
+
+	runes := []rune(s)
+	if len(runes) <= keepStart+keepEnd {
+		keepStart, keepEnd = 0, 0
+	}
+	for i := keepStart; i < len(runes)-keepEnd; i++ {
+		runes[i] = maskChar
+	}
+	return string(runes)
+}
+
+// maskFormatted runs MaskMiddle over only the runes for which maskable returns
+// true, leaving the others (separators, brackets, spaces) where they are
+func maskFormatted(s string, maskable func(rune) bool, keepStart, keepEnd int, maskChar rune) string {
+	// 1. Collect the runes to mask
+	var chars []rune
+	for _, r := range s {
+		if maskable(r) {
+			chars = append(chars, r)
 		}
-		cardNumber = strings.Replace(cardNumber, group, mask, 1)
 	}
+	masked := []rune(MaskMiddle(string(chars), keepStart, keepEnd, maskChar))
 
-	// 3. Return the masked card number
-	return cardNumber
+	// 2. Put the masked runes back in their original positions
+	var b strings.Builder
+	next := 0
+	for _, r := range s {
+		if maskable(r) {
+			r = masked[next]
+			next++
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
 
+// log line shape: date, time, level, message
+var reLogEntry = regexp.MustCompile(`^(\d{4}\-\d{2}\-\d{2})\s(\d{2}:\d{2}:\d{2})\s(\w+)\s(.+)$`)
+
 // ParseLogEntry parses a log entry with format:
 // "YYYY-MM-DD HH:MM:SS LEVEL Message"
 // Returns a map with keys: "date", "time", "level", "message"
 func ParseLogEntry(logLine string) map[string]string {
-	// 1. Create a regular expression with capture groups for each component
-	re := regexp.MustCompile(`^(\d{4}\-\d{2}\-\d{2})\s(\d{2}:\d{2}:\d{2})\s(\w+)\s(.+)$`)
-
-	// 2. Use FindStringSubmatch to extract the components
-	subs := re.FindStringSubmatch(logLine)
+	// 1. Use FindStringSubmatch with the log line regex to extract the components
+	subs := reLogEntry.FindStringSubmatch(logLine)
 	if len(subs) != 5 {
 		return nil
 	}
 
-	// 3. Populate a map with the extracted values
+	// 2. Populate a map with the extracted values
 	comps := make(map[string]string)
 	comps["date"] = subs[1]
 	comps["time"] = subs[2]
 	comps["level"] = subs[3]
 	comps["message"] = subs[4]
 
-	// 4. Return the populated map
+	// 3. Return the populated map
 	return comps
 }
 
+// maxLogLineLength is the longest line ParseLogStream will read
+const maxLogLineLength = 1 << 20
+
+// ErrMalformedLogLine is reported for a line that doesn't match the log format
+var ErrMalformedLogLine = errors.New("malformed log line")
+
+// LogLineError reports a line of a log stream that could not be parsed
+type LogLineError struct {
+	Line int // 1-based line number
+	Err  error
+}
+
+func (e *LogLineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LogLineError) Unwrap() error {
+	return e.Err
+}
+
+// ParseLogStream parses every line of r with ParseLogEntry. Entries holds the
+// lines that parsed, in order; errs holds a *LogLineError for each malformed
+// line, so one bad line doesn't fail the batch. Blank lines are skipped.
+// Lines may be up to maxLogLineLength bytes; a longer line or a read error
+// is reported and ends the stream.
+func ParseLogStream(r io.Reader) (entries []map[string]string, errs []error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineLength)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		entry := ParseLogEntry(text)
+		if entry == nil {
+			errs = append(errs, &LogLineError{Line: line, Err: ErrMalformedLogLine})
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	// the line that failed to scan is the one after the last one read
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, &LogLineError{Line: line + 1, Err: err})
+	}
+
+	return entries, errs
+}
+
 // ExtractURLs extracts all valid URLs from a text
 func ExtractURLs(text string) []string {
 	// 1. Create a regular expression to match URLs (both http and https)
@@ -91,3 +199,58 @@ func ExtractURLs(text string) []string {
 	// 3. Return the matched URLs as a slice of strings
 	return matches
 }
+
+// separators allowed between card number digit groups
+var reCardSeparator = regexp.MustCompile(`[\s-]`)
+
+// number shapes (prefix and length) for the supported card networks
+var reCardNetworks = []*regexp.Regexp{
+	regexp.MustCompile(`^4(\d{12}|\d{15}|\d{18})$`),                                          // Visa
+	regexp.MustCompile(`^(5[1-5]\d{2}|222[1-9]|22[3-9]\d|2[3-6]\d{2}|27[01]\d|2720)\d{12}$`), // Mastercard
+	regexp.MustCompile(`^3[47]\d{13}$`),                                                      // American Express
+	regexp.MustCompile(`^(6011|65\d{2}|64[4-9]\d)\d{12,15}$`),                                // Discover
+}
+
+// ValidateCreditCard checks a card number has the shape of a known card
+// network and passes the Luhn checksum. Digit groups may be separated by
+// spaces or dashes, any other character makes the number invalid.
+func ValidateCreditCard(cardNumber string) bool {
+	// 1. Strip the separators, leaving only the digits
+	digits := reCardSeparator.ReplaceAllString(cardNumber, "")
+
+	// 2. Check the prefix and length match a card network
+	shapeOK := false
+	for _, re := range reCardNetworks {
+		if re.MatchString(digits) {
+			shapeOK = true
+			break
+		}
+	}
+	if !shapeOK {
+		return false
+	}
+
+	// 3. Run the Luhn checksum
+	return luhnValid(digits)
+}
+
+// luhnValid runs the Luhn checksum over a string of digits: double every
+// second digit from the right, subtract 9 from any result over 9, and the
+// sum of all digits must be a multiple of 10
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
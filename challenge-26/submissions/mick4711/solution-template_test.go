@@ -0,0 +1,193 @@
+package regex
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateCreditCard(t *testing.T) {
+	tests := []struct {
+		name       string
+		cardNumber string
+		want       bool
+	}{
+		{"valid visa with dashes", "4111-1111-1111-1111", true},
+		{"valid visa with spaces", "4111 1111 1111 1111", true},
+		{"valid visa no separators", "4111111111111111", true},
+		{"valid mastercard", "5555 5555 5555 4444", true},
+		{"valid mastercard 2-series", "2223003122003222", true},
+		{"valid amex", "3782 822463 10005", true},
+		{"valid discover", "6011-1111-1111-1117", true},
+		{"fails luhn", "4111-1111-1111-1112", false},
+		{"non-digit junk", "4111-1111-abcd-1111", false},
+		{"masked", "XXXX-XXXX-XXXX-1111", false},
+		{"unknown network", "9111 1111 1111 1111", false},
+		{"too short", "4111 1111 1111", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateCreditCard(tt.cardNumber); got != tt.want {
+				t.Errorf("ValidateCreditCard(%q) = %v, want %v", tt.cardNumber, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskMiddle(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		keepStart int
+		keepEnd   int
+		want      string
+	}{
+		{"keep both ends", "secret", 1, 2, "sXXXet"},
+		{"keep end only", "1234567890", 0, 4, "XXXXXX7890"},
+		{"keep nothing", "abc", 0, 0, "XXX"},
+		{"multibyte runes", "пароль", 1, 1, "пXXXXь"},
+		{"exactly keepStart+keepEnd", "abcd", 2, 2, "XXXX"},
+		{"shorter than keepStart+keepEnd", "ab", 2, 2, "XX"},
+		{"negative counts", "abc", -1, -5, "XXX"},
+		{"empty", "", 1, 1, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaskMiddle(tt.input, tt.keepStart, tt.keepEnd, 'X'); got != tt.want {
+				t.Errorf("MaskMiddle(%q, %d, %d) = %q, want %q", tt.input, tt.keepStart, tt.keepEnd, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskEmail(t *testing.T) {
+	tests := []struct {
+		name  string
+		email string
+		want  string
+	}{
+		{"regular address", "john.doe@example.com", "j******e@example.com"},
+		{"two character local part", "jo@example.com", "**@example.com"},
+		{"one character local part", "j@example.com", "*@example.com"},
+		{"plus addressing", "a+tag@mail.org", "a***g@mail.org"},
+		{"no at sign", "johndoe", "*******"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaskEmail(tt.email); got != tt.want {
+				t.Errorf("MaskEmail(%q) = %q, want %q", tt.email, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskPhone(t *testing.T) {
+	tests := []struct {
+		name  string
+		phone string
+		want  string
+	}{
+		{"formatted", "(555) 123-4567", "(XXX) XXX-4567"},
+		{"digits only", "5551234567", "XXXXXX4567"},
+		{"international", "+1 555 123 4567", "+X XXX XXX 4567"},
+		{"short number", "1234", "XXXX"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaskPhone(tt.phone); got != tt.want {
+				t.Errorf("MaskPhone(%q) = %q, want %q", tt.phone, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskCreditCardFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		cardNumber string
+		want       string
+	}{
+		{"spaces", "4111 1111 1111 1111", "XXXX XXXX XXXX 1111"},
+		{"amex grouping", "3782 822463 10005", "XXXX XXXXXX X0005"},
+		{"uneven groups", "41111-11111-1111", "XXXXX-XXXXX-1111"},
+		{"short number", "123", "XXX"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaskCreditCard(tt.cardNumber); got != tt.want {
+				t.Errorf("MaskCreditCard(%q) = %q, want %q", tt.cardNumber, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLogStream(t *testing.T) {
+	log := strings.Join([]string{
+		"2023-01-15 14:30:45 INFO Server started",
+		"not a log line",
+		"",
+		"2023-01-15 14:31:02 ERROR Database connection failed\r",
+		"2023-01-15 ERROR missing time",
+		"2023-01-15 14:32:10 WARN Disk usage at 91%",
+	}, "\n")
+
+	entries, errs := ParseLogStream(strings.NewReader(log))
+
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d: %v", len(entries), entries)
+	}
+	wantLevels := []string{"INFO", "ERROR", "WARN"}
+	for i, entry := range entries {
+		if entry["level"] != wantLevels[i] {
+			t.Errorf("entry %d: expected level %s, got %s", i, wantLevels[i], entry["level"])
+		}
+	}
+	if entries[1]["message"] != "Database connection failed" {
+		t.Errorf("expected the CRLF line ending to be stripped, got %q", entries[1]["message"])
+	}
+
+	wantLines := []int{2, 5}
+	if len(errs) != len(wantLines) {
+		t.Fatalf("expected %d errors, got %d: %v", len(wantLines), len(errs), errs)
+	}
+	for i, err := range errs {
+		var lineErr *LogLineError
+		if !errors.As(err, &lineErr) || lineErr.Line != wantLines[i] {
+			t.Errorf("error %d: expected line %d, got %v", i, wantLines[i], err)
+		}
+		if !errors.Is(err, ErrMalformedLogLine) {
+			t.Errorf("error %d: expected ErrMalformedLogLine, got %v", i, err)
+		}
+	}
+}
+
+func TestParseLogStreamLongLines(t *testing.T) {
+	// longer than bufio's default 64KB token limit, but within maxLogLineLength
+	long := "2023-01-15 14:30:45 INFO " + strings.Repeat("x", 100*1024)
+	entries, errs := ParseLogStream(strings.NewReader(long + "\n2023-01-15 14:30:46 INFO after"))
+	if len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+	if len(entries) != 2 || len(entries[0]["message"]) != 100*1024 {
+		t.Fatalf("expected the long line and the one after it to parse, got %d entries", len(entries))
+	}
+
+	// a line over the limit is reported and ends the stream
+	tooLong := "2023-01-15 14:30:45 INFO " + strings.Repeat("x", maxLogLineLength)
+	entries, errs = ParseLogStream(strings.NewReader("2023-01-15 14:30:44 INFO before\n" + tooLong))
+	if len(entries) != 1 {
+		t.Errorf("expected the line before the long line to parse, got %d entries", len(entries))
+	}
+	var lineErr *LogLineError
+	if len(errs) != 1 || !errors.As(errs[0], &lineErr) || lineErr.Line != 2 {
+		t.Errorf("expected an error for line 2, got %v", errs)
+	}
+}
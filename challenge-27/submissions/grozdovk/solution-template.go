@@ -1,6 +1,16 @@
 package generics
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 // ErrEmptyCollection is returned when an operation cannot be performed on an empty collection
 var ErrEmptyCollection = errors.New("collection is empty")
@@ -67,6 +77,8 @@ func (s *Stack[T]) Pop() (T, error) {
 	}
 	lastIndex:= len(s.elements)-1
 	element:= s.elements[lastIndex]
+	// Clear the slot so the backing array doesn't keep the element alive
+	s.elements[lastIndex] = zero
 	s.elements = s.elements[:lastIndex]
 	return element, nil
 }
@@ -129,6 +141,8 @@ func (q *Queue[T]) Dequeue() (T, error) {
 		return zero, ErrEmptyCollection
 	}
 	element:= q.elements[0]
+	// Clear the slot so the backing array doesn't keep the element alive
+	q.elements[0] = zero
 	q.elements = q.elements[1:]
 	return element, nil
 }
@@ -288,6 +302,27 @@ func Reduce[T, U any](slice []T, initial U, reducer func(U, T) U) U {
 	return initial
 }
 
+// ReduceIndexed is like Reduce but also passes the index of each element
+func ReduceIndexed[T, U any](slice []T, initial U, fn func(acc U, i int, v T) U) U {
+	acc := initial
+	for i, v := range slice {
+		acc = fn(acc, i, v)
+	}
+	return acc
+}
+
+// Scan is like Reduce but returns every intermediate accumulation, one per element.
+// The initial value itself is not included, so Scan of [1 2 3] with + and 0 is [1 3 6]
+func Scan[T, U any](slice []T, initial U, fn func(U, T) U) []U {
+	result := make([]U, 0, len(slice))
+	acc := initial
+	for _, v := range slice {
+		acc = fn(acc, v)
+		result = append(result, acc)
+	}
+	return result
+}
+
 // Contains returns true if the slice contains the given element
 func Contains[T comparable](slice []T, element T) bool {
 	// TODO: Implement this function
@@ -322,4 +357,936 @@ func RemoveDuplicates[T comparable](slice []T) []T {
 		}
 	}
 	return result
-}
\ No newline at end of file
+}
+//
+// 6. Generic Deque
+//
+
+// minDequeCapacity is the initial size of a deque's ring buffer
+const minDequeCapacity = 8
+
+// Deque is a generic double-ended queue backed by a ring buffer,
+// giving O(1) amortized operations at both ends
+type Deque[T any] struct {
+	buf   []T
+	head  int // index of the front element in buf
+	count int
+}
+
+// NewDeque creates a new empty deque
+func NewDeque[T any]() *Deque[T] {
+	return &Deque[T]{
+		buf: make([]T, minDequeCapacity),
+	}
+}
+
+// PushFront adds an element to the front of the deque
+func (d *Deque[T]) PushFront(value T) {
+	d.grow()
+	d.head = (d.head - 1 + len(d.buf)) % len(d.buf)
+	d.buf[d.head] = value
+	d.count++
+}
+
+// PushBack adds an element to the back of the deque
+func (d *Deque[T]) PushBack(value T) {
+	d.grow()
+	d.buf[(d.head+d.count)%len(d.buf)] = value
+	d.count++
+}
+
+// PopFront removes and returns the front element of the deque
+// Returns an error if the deque is empty
+func (d *Deque[T]) PopFront() (T, error) {
+	var zero T
+	if d.count == 0 {
+		return zero, ErrEmptyCollection
+	}
+	element := d.buf[d.head]
+	d.buf[d.head] = zero
+	d.head = (d.head + 1) % len(d.buf)
+	d.count--
+	return element, nil
+}
+
+// PopBack removes and returns the back element of the deque
+// Returns an error if the deque is empty
+func (d *Deque[T]) PopBack() (T, error) {
+	var zero T
+	if d.count == 0 {
+		return zero, ErrEmptyCollection
+	}
+	index := (d.head + d.count - 1) % len(d.buf)
+	element := d.buf[index]
+	d.buf[index] = zero
+	d.count--
+	return element, nil
+}
+
+// PeekFront returns the front element without removing it
+// Returns an error if the deque is empty
+func (d *Deque[T]) PeekFront() (T, error) {
+	var zero T
+	if d.count == 0 {
+		return zero, ErrEmptyCollection
+	}
+	return d.buf[d.head], nil
+}
+
+// PeekBack returns the back element without removing it
+// Returns an error if the deque is empty
+func (d *Deque[T]) PeekBack() (T, error) {
+	var zero T
+	if d.count == 0 {
+		return zero, ErrEmptyCollection
+	}
+	return d.buf[(d.head+d.count-1)%len(d.buf)], nil
+}
+
+// Size returns the number of elements in the deque
+func (d *Deque[T]) Size() int {
+	return d.count
+}
+
+// IsEmpty returns true if the deque contains no elements
+func (d *Deque[T]) IsEmpty() bool {
+	return d.count == 0
+}
+
+// grow doubles the ring buffer when it is full, unwrapping the elements
+// so that the front element ends up at index 0
+func (d *Deque[T]) grow() {
+	if d.count < len(d.buf) {
+		return
+	}
+	newBuf := make([]T, max(2*len(d.buf), minDequeCapacity))
+	for i := 0; i < d.count; i++ {
+		newBuf[i] = d.buf[(d.head+i)%len(d.buf)]
+	}
+	d.buf = newBuf
+	d.head = 0
+}
+
+//
+// 7. Generic Ordered Set
+//
+
+// orderedSetEntry is a slot in an OrderedSet's insertion order;
+// removed entries stay in place as tombstones until compaction
+type orderedSetEntry[T comparable] struct {
+	value   T
+	removed bool
+}
+
+// OrderedSet is a generic collection of unique elements that remembers
+// the order in which elements were inserted
+type OrderedSet[T comparable] struct {
+	index      map[T]int // element -> position in order
+	order      []orderedSetEntry[T]
+	tombstones int
+}
+
+// NewOrderedSet creates a new empty ordered set
+func NewOrderedSet[T comparable]() *OrderedSet[T] {
+	return &OrderedSet[T]{
+		index: make(map[T]int),
+	}
+}
+
+// Add adds an element to the end of the set if it's not already present
+func (s *OrderedSet[T]) Add(value T) {
+	if _, ok := s.index[value]; ok {
+		return
+	}
+	s.index[value] = len(s.order)
+	s.order = append(s.order, orderedSetEntry[T]{value: value})
+}
+
+// Remove removes an element from the set if it exists
+func (s *OrderedSet[T]) Remove(value T) {
+	pos, ok := s.index[value]
+	if !ok {
+		return
+	}
+	delete(s.index, value)
+	s.order[pos] = orderedSetEntry[T]{removed: true}
+	s.tombstones++
+	if s.tombstones > len(s.order)/2 {
+		s.compact()
+	}
+}
+
+// Contains returns true if the set contains the given element
+func (s *OrderedSet[T]) Contains(value T) bool {
+	_, ok := s.index[value]
+	return ok
+}
+
+// Size returns the number of elements in the set
+func (s *OrderedSet[T]) Size() int {
+	return len(s.index)
+}
+
+// Elements returns a slice containing all elements in insertion order
+func (s *OrderedSet[T]) Elements() []T {
+	result := make([]T, 0, len(s.index))
+	for _, entry := range s.order {
+		if !entry.removed {
+			result = append(result, entry.value)
+		}
+	}
+	return result
+}
+
+// compact drops tombstones from the insertion order and reindexes the
+// remaining elements
+func (s *OrderedSet[T]) compact() {
+	live := make([]orderedSetEntry[T], 0, len(s.index))
+	for _, entry := range s.order {
+		if !entry.removed {
+			s.index[entry.value] = len(live)
+			live = append(live, entry)
+		}
+	}
+	s.order = live
+	s.tombstones = 0
+}
+
+//
+// 8. Concurrent Set
+//
+
+// ConcurrentSet is a Set that is safe for concurrent use
+type ConcurrentSet[T comparable] struct {
+	mu  sync.RWMutex
+	set *Set[T]
+}
+
+// NewConcurrentSet creates a new empty concurrent set
+func NewConcurrentSet[T comparable]() *ConcurrentSet[T] {
+	return &ConcurrentSet[T]{
+		set: NewSet[T](),
+	}
+}
+
+// Add adds an element to the set if it's not already present
+func (s *ConcurrentSet[T]) Add(value T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set.Add(value)
+}
+
+// Remove removes an element from the set if it exists
+func (s *ConcurrentSet[T]) Remove(value T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set.Remove(value)
+}
+
+// Contains returns true if the set contains the given element
+func (s *ConcurrentSet[T]) Contains(value T) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Contains(value)
+}
+
+// Size returns the number of elements in the set
+func (s *ConcurrentSet[T]) Size() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Size()
+}
+
+// Elements returns a slice containing all elements in the set
+func (s *ConcurrentSet[T]) Elements() []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.set.Elements()
+}
+
+// Snapshot returns a point-in-time copy of the set as a plain Set
+func (s *ConcurrentSet[T]) Snapshot() *Set[T] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Union(s.set, NewSet[T]())
+}
+
+// Union returns a new concurrent set containing all elements from both sets
+func (s *ConcurrentSet[T]) Union(other *ConcurrentSet[T]) *ConcurrentSet[T] {
+	return &ConcurrentSet[T]{set: Union(s.Snapshot(), other.Snapshot())}
+}
+
+// Intersection returns a new concurrent set containing only elements that exist in both sets
+func (s *ConcurrentSet[T]) Intersection(other *ConcurrentSet[T]) *ConcurrentSet[T] {
+	return &ConcurrentSet[T]{set: Intersection(s.Snapshot(), other.Snapshot())}
+}
+
+// Difference returns a new concurrent set with elements in s that are not in other
+func (s *ConcurrentSet[T]) Difference(other *ConcurrentSet[T]) *ConcurrentSet[T] {
+	return &ConcurrentSet[T]{set: Difference(s.Snapshot(), other.Snapshot())}
+}
+
+//
+// 9. Generic Tree
+//
+
+// Tree is a generic n-ary tree node; the traversals are iterative so
+// deep trees don't grow the call stack
+type Tree[T any] struct {
+	Value    T
+	children []*Tree[T]
+}
+
+// NewTree creates a new tree with a single root node
+func NewTree[T any](value T) *Tree[T] {
+	return &Tree[T]{Value: value}
+}
+
+// AddChild appends a new child node holding value and returns it
+func (t *Tree[T]) AddChild(value T) *Tree[T] {
+	child := NewTree(value)
+	t.children = append(t.children, child)
+	return child
+}
+
+// Children returns the direct children of the node in insertion order
+func (t *Tree[T]) Children() []*Tree[T] {
+	result := make([]*Tree[T], len(t.children))
+	copy(result, t.children)
+	return result
+}
+
+// PreOrder returns the node values, visiting each node before its children
+func (t *Tree[T]) PreOrder() []T {
+	result := make([]T, 0)
+	stack := NewStack[*Tree[T]]()
+	stack.Push(t)
+	for !stack.IsEmpty() {
+		node, _ := stack.Pop()
+		result = append(result, node.Value)
+		// Push children in reverse so the first child is visited first
+		for i := len(node.children) - 1; i >= 0; i-- {
+			stack.Push(node.children[i])
+		}
+	}
+	return result
+}
+
+// PostOrder returns the node values, visiting each node after its children
+func (t *Tree[T]) PostOrder() []T {
+	// Visiting node, then children right to left, yields the reverse post-order
+	result := make([]T, 0)
+	stack := NewStack[*Tree[T]]()
+	stack.Push(t)
+	for !stack.IsEmpty() {
+		node, _ := stack.Pop()
+		result = append(result, node.Value)
+		for _, child := range node.children {
+			stack.Push(child)
+		}
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// LevelOrder returns the node values level by level, left to right
+func (t *Tree[T]) LevelOrder() []T {
+	result := make([]T, 0)
+	queue := NewQueue[*Tree[T]]()
+	queue.Enqueue(t)
+	for !queue.IsEmpty() {
+		node, _ := queue.Dequeue()
+		result = append(result, node.Value)
+		for _, child := range node.children {
+			queue.Enqueue(child)
+		}
+	}
+	return result
+}
+
+// MapTree returns a new tree with the same shape whose values are transformed by mapper
+func MapTree[T, U any](t *Tree[T], mapper func(T) U) *Tree[U] {
+	root := NewTree(mapper(t.Value))
+	stack := NewStack[Pair[*Tree[T], *Tree[U]]]()
+	stack.Push(NewPair(t, root))
+	for !stack.IsEmpty() {
+		pair, _ := stack.Pop()
+		for _, child := range pair.First.children {
+			stack.Push(NewPair(child, pair.Second.AddChild(mapper(child.Value))))
+		}
+	}
+	return root
+}
+
+//
+// 10. Number Parsing
+//
+
+// Number is satisfied by the built-in integer and floating point types and any type based on them
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// NumberParseError reports a token that could not be parsed and where it was
+type NumberParseError struct {
+	Index int
+	Token string
+	Err   error
+}
+
+func (e *NumberParseError) Error() string {
+	return fmt.Sprintf("token %d (%q): %v", e.Index, e.Token, e.Err)
+}
+
+func (e *NumberParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseNumbers parses each token, ignoring surrounding whitespace, into T.
+// Tokens must fit T: "1.5" or "300" fail for uint8, "-1" fails for unsigned types.
+// If any token fails, it returns nil and every failure joined as *NumberParseError values.
+func ParseNumbers[T Number](tokens []string) ([]T, error) {
+	var zero T
+	kind := reflect.TypeOf(zero).Kind()
+	bits := reflect.TypeOf(zero).Bits()
+
+	result := make([]T, len(tokens))
+	var errs []error
+	for i, token := range tokens {
+		trimmed := strings.TrimSpace(token)
+		var err error
+		switch kind {
+		case reflect.Float32, reflect.Float64:
+			var f float64
+			f, err = strconv.ParseFloat(trimmed, bits)
+			result[i] = T(f)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			var u uint64
+			u, err = strconv.ParseUint(trimmed, 10, bits)
+			result[i] = T(u)
+		default:
+			var n int64
+			n, err = strconv.ParseInt(trimmed, 10, bits)
+			result[i] = T(n)
+		}
+		if err != nil {
+			// Report the strconv reason without repeating the token
+			var numErr *strconv.NumError
+			if errors.As(err, &numErr) {
+				err = numErr.Err
+			}
+			errs = append(errs, &NumberParseError{Index: i, Token: token, Err: err})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return result, nil
+}
+
+//
+// 11. Event Bus
+//
+
+// defaultEventBufferSize is the per-subscriber buffer used when none is given
+const defaultEventBufferSize = 16
+
+// EventBus fans out published events to every current subscriber. Each
+// subscriber has its own buffered channel; when a buffer is full the event
+// is dropped for that subscriber so a slow consumer never blocks Publish.
+type EventBus[T any] struct {
+	mu          sync.RWMutex
+	subscribers map[uint64]chan T
+	nextID      uint64
+	bufferSize  int
+	dropped     atomic.Uint64
+}
+
+// NewEventBus creates an event bus whose subscribers buffer up to bufferSize
+// events, using defaultEventBufferSize if bufferSize is not positive
+func NewEventBus[T any](bufferSize int) *EventBus[T] {
+	if bufferSize <= 0 {
+		bufferSize = defaultEventBufferSize
+	}
+	return &EventBus[T]{
+		subscribers: make(map[uint64]chan T),
+		bufferSize:  bufferSize,
+	}
+}
+
+// Subscribe registers a new subscriber and returns its channel along with a
+// function that removes the subscriber and closes the channel. The function
+// is safe to call more than once.
+func (b *EventBus[T]) Subscribe() (<-chan T, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	ch := make(chan T, b.bufferSize)
+	b.subscribers[id] = ch
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			// Publish sends under the read lock, so closing under the write
+			// lock can never race with a send
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers, id)
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// Publish delivers event to every subscriber with room in its buffer and
+// drops it for the rest
+func (b *EventBus[T]) Publish(event T) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribers returns the number of current subscribers
+func (b *EventBus[T]) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
+
+// Dropped returns how many deliveries have been dropped because a subscriber's buffer was full
+func (b *EventBus[T]) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+//
+// 12. Parallel Map
+//
+
+// ParallelMap is a concurrent, context-aware version of Map. It applies fn to
+// every item using at most numWorkers goroutines and returns the results in
+// input order. The first error returned by fn, or the cancellation of ctx,
+// cancels the context passed to the remaining calls and is returned with a
+// nil slice. Items not yet started when that happens are skipped.
+func ParallelMap[T, U any](ctx context.Context, items []T, numWorkers int, fn func(context.Context, T) (U, error)) ([]U, error) {
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	numWorkers = min(numWorkers, len(items))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]U, len(items))
+	indexes := make(chan int)
+
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		failOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if ctx.Err() != nil {
+					continue
+				}
+				// each worker writes a distinct index, so no lock is needed
+				u, err := fn(ctx, items[i])
+				if err != nil {
+					fail(err)
+					continue
+				}
+				results[i] = u
+			}
+		}()
+	}
+
+feed:
+	for i := range items {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	// the parent context may have been cancelled without fn returning an error
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+//
+// 13. TTL Cache
+//
+
+// ttlEntry is a cached value and the time it expires, zero meaning never
+type ttlEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// expired reports whether the entry has expired at now
+func (e ttlEntry[V]) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// TTLCache is a map whose entries expire after a per-entry time to live. It
+// is safe for concurrent use. Expired entries are removed when Get finds
+// them, or in bulk by the sweeper started with StartSweeper.
+type TTLCache[K comparable, V any] struct {
+	mu    sync.Mutex
+	items map[K]ttlEntry[V]
+	now   func() time.Time // time source, replaced in tests
+}
+
+// NewTTLCache creates a new empty TTL cache
+func NewTTLCache[K comparable, V any]() *TTLCache[K, V] {
+	return &TTLCache[K, V]{
+		items: make(map[K]ttlEntry[V]),
+		now:   time.Now,
+	}
+}
+
+// Set stores value under key for ttl, replacing any existing entry.
+// A ttl that is not positive means the entry never expires.
+func (c *TTLCache[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := ttlEntry[V]{value: value}
+	if ttl > 0 {
+		entry.expiresAt = c.now().Add(ttl)
+	}
+	c.items[key] = entry
+}
+
+// Get returns the value stored under key, or false if there is none or it
+// has expired. An expired entry is removed.
+func (c *TTLCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if entry.expired(c.now()) {
+		delete(c.items, key)
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// Delete removes the entry stored under key, if any
+func (c *TTLCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+}
+
+// Len returns the number of stored entries, including expired entries that
+// have not been removed yet
+func (c *TTLCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Sweep removes every expired entry and returns how many were removed
+func (c *TTLCache[K, V]) Sweep() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	removed := 0
+	for key, entry := range c.items {
+		if entry.expired(now) {
+			delete(c.items, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// StartSweeper calls Sweep every interval in a background goroutine until
+// the returned stop function is called. Stop is safe to call more than once.
+func (c *TTLCache[K, V]) StartSweeper(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.Sweep()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+//
+// 14. LRU Cache
+//
+
+// ErrInvalidCapacity is returned when a cache is created with a capacity below 1
+var ErrInvalidCapacity = errors.New("capacity must be at least 1")
+
+// lruNode is an entry in an LRUCache's recency list
+type lruNode[K comparable, V any] struct {
+	key        K
+	value      V
+	prev, next *lruNode[K, V]
+}
+
+// LRUCache is a fixed-capacity cache that evicts the least recently used
+// entry when full. Entries are kept in a doubly-linked list, most recently
+// used first, with a map from key to node, so Get and Put are O(1).
+// It is not safe for concurrent use; see ConcurrentLRUCache.
+type LRUCache[K comparable, V any] struct {
+	capacity int
+	items    map[K]*lruNode[K, V]
+	head     lruNode[K, V] // sentinel: head.next is the most recently used
+}
+
+// NewLRUCache creates a new empty LRU cache holding at most capacity entries.
+// Returns ErrInvalidCapacity if capacity is less than 1.
+func NewLRUCache[K comparable, V any](capacity int) (*LRUCache[K, V], error) {
+	if capacity < 1 {
+		return nil, fmt.Errorf("%w, got %d", ErrInvalidCapacity, capacity)
+	}
+	c := &LRUCache[K, V]{
+		capacity: capacity,
+		items:    make(map[K]*lruNode[K, V], capacity),
+	}
+	c.head.prev = &c.head
+	c.head.next = &c.head
+	return c, nil
+}
+
+// Get returns the value stored under key and marks it as most recently used,
+// or false if the key is not in the cache
+func (c *LRUCache[K, V]) Get(key K) (V, bool) {
+	node, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.moveToFront(node)
+	return node.value, true
+}
+
+// Put stores value under key and marks it as most recently used. If the
+// cache is full and key is new, the least recently used entry is evicted.
+func (c *LRUCache[K, V]) Put(key K, value V) {
+	if node, ok := c.items[key]; ok {
+		node.value = value
+		c.moveToFront(node)
+		return
+	}
+
+	if len(c.items) == c.capacity {
+		oldest := c.head.prev
+		c.unlink(oldest)
+		delete(c.items, oldest.key)
+	}
+
+	node := &lruNode[K, V]{key: key, value: value}
+	c.items[key] = node
+	c.pushFront(node)
+}
+
+// Len returns the number of entries in the cache
+func (c *LRUCache[K, V]) Len() int {
+	return len(c.items)
+}
+
+// Capacity returns the maximum number of entries the cache holds
+func (c *LRUCache[K, V]) Capacity() int {
+	return c.capacity
+}
+
+// Keys returns the keys from most to least recently used
+func (c *LRUCache[K, V]) Keys() []K {
+	result := make([]K, 0, len(c.items))
+	for node := c.head.next; node != &c.head; node = node.next {
+		result = append(result, node.key)
+	}
+	return result
+}
+
+func (c *LRUCache[K, V]) moveToFront(node *lruNode[K, V]) {
+	c.unlink(node)
+	c.pushFront(node)
+}
+
+func (c *LRUCache[K, V]) pushFront(node *lruNode[K, V]) {
+	node.prev = &c.head
+	node.next = c.head.next
+	c.head.next.prev = node
+	c.head.next = node
+}
+
+func (c *LRUCache[K, V]) unlink(node *lruNode[K, V]) {
+	node.prev.next = node.next
+	node.next.prev = node.prev
+	node.prev, node.next = nil, nil
+}
+
+// ConcurrentLRUCache is an LRUCache that is safe for concurrent use. Get
+// updates recency, so every operation takes the same exclusive lock.
+type ConcurrentLRUCache[K comparable, V any] struct {
+	mu    sync.Mutex
+	cache *LRUCache[K, V]
+}
+
+// NewConcurrentLRUCache creates a new empty concurrent LRU cache holding at
+// most capacity entries. Returns ErrInvalidCapacity if capacity is less than 1.
+func NewConcurrentLRUCache[K comparable, V any](capacity int) (*ConcurrentLRUCache[K, V], error) {
+	cache, err := NewLRUCache[K, V](capacity)
+	if err != nil {
+		return nil, err
+	}
+	return &ConcurrentLRUCache[K, V]{cache: cache}, nil
+}
+
+// Get returns the value stored under key and marks it as most recently used
+func (c *ConcurrentLRUCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Get(key)
+}
+
+// Put stores value under key, evicting the least recently used entry if full
+func (c *ConcurrentLRUCache[K, V]) Put(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache.Put(key, value)
+}
+
+// Len returns the number of entries in the cache
+func (c *ConcurrentLRUCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Len()
+}
+
+// Keys returns the keys from most to least recently used
+func (c *ConcurrentLRUCache[K, V]) Keys() []K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Keys()
+}
+
+//
+// 15. Debounce and Throttle
+//
+
+// Debounce returns a function that delays calling fn until wait has passed
+// without another call, so a burst of calls results in a single call of fn
+// after the burst ends. cancel drops the pending call, if any; calls made
+// after cancel are debounced again. fn runs on its own goroutine.
+func Debounce(fn func(), wait time.Duration) (debounced func(), cancel func()) {
+	var (
+		mu    sync.Mutex
+		timer *time.Timer
+	)
+
+	debounced = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer == nil {
+			timer = time.AfterFunc(wait, fn)
+			return
+		}
+		// Reset re-arms the timer whether it is pending, fired or stopped
+		timer.Stop()
+		timer.Reset(wait)
+	}
+
+	cancel = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+
+	return debounced, cancel
+}
+
+// Throttle returns a function that calls fn at most once per interval. The
+// first call runs fn immediately on the caller's goroutine; calls within the
+// interval after it are coalesced into a single trailing call at the end of
+// the interval, so the last call of a burst is never lost. fn can overlap
+// itself if it runs for longer than interval.
+func Throttle(fn func(), interval time.Duration) func() {
+	var (
+		mu      sync.Mutex
+		last    time.Time // when fn was last started
+		pending bool      // a trailing call is scheduled
+	)
+
+	return func() {
+		mu.Lock()
+		if pending {
+			mu.Unlock()
+			return
+		}
+
+		now := time.Now()
+		if wait := interval - now.Sub(last); wait > 0 {
+			pending = true
+			time.AfterFunc(wait, func() {
+				mu.Lock()
+				pending = false
+				last = time.Now()
+				mu.Unlock()
+				fn()
+			})
+			mu.Unlock()
+			return
+		}
+
+		last = now
+		mu.Unlock()
+		fn()
+	}
+}
@@ -0,0 +1,1043 @@
+package generics
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestDeque tests the Deque implementation
+func TestDeque(t *testing.T) {
+	t.Run("NewDeque", func(t *testing.T) {
+		deque := NewDeque[int]()
+		if !deque.IsEmpty() {
+			t.Error("Expected new deque to be empty")
+		}
+		if deque.Size() != 0 {
+			t.Errorf("Expected size of new deque to be 0, got %d", deque.Size())
+		}
+	})
+
+	t.Run("EmptyOperations", func(t *testing.T) {
+		deque := NewDeque[string]()
+		if _, err := deque.PopFront(); err != ErrEmptyCollection {
+			t.Errorf("Expected ErrEmptyCollection from PopFront, got %v", err)
+		}
+		if _, err := deque.PopBack(); err != ErrEmptyCollection {
+			t.Errorf("Expected ErrEmptyCollection from PopBack, got %v", err)
+		}
+		if _, err := deque.PeekFront(); err != ErrEmptyCollection {
+			t.Errorf("Expected ErrEmptyCollection from PeekFront, got %v", err)
+		}
+		if _, err := deque.PeekBack(); err != ErrEmptyCollection {
+			t.Errorf("Expected ErrEmptyCollection from PeekBack, got %v", err)
+		}
+	})
+
+	t.Run("MixedOperations", func(t *testing.T) {
+		deque := NewDeque[int]()
+		deque.PushBack(2)
+		deque.PushFront(1)
+		deque.PushBack(3)
+		deque.PushFront(0)
+
+		if front, _ := deque.PeekFront(); front != 0 {
+			t.Errorf("Expected front to be 0, got %d", front)
+		}
+		if back, _ := deque.PeekBack(); back != 3 {
+			t.Errorf("Expected back to be 3, got %d", back)
+		}
+
+		var got []int
+		for !deque.IsEmpty() {
+			front, _ := deque.PopFront()
+			got = append(got, front)
+			if deque.IsEmpty() {
+				break
+			}
+			back, _ := deque.PopBack()
+			got = append(got, back)
+		}
+		if want := []int{0, 3, 1, 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("Growth", func(t *testing.T) {
+		deque := NewDeque[int]()
+		for i := 0; i < 100; i++ {
+			if i%2 == 0 {
+				deque.PushBack(i)
+			} else {
+				deque.PushFront(i)
+			}
+		}
+		if deque.Size() != 100 {
+			t.Errorf("Expected size to be 100, got %d", deque.Size())
+		}
+		if len(deque.buf) > 200 {
+			t.Errorf("Expected capacity to be at most 200, got %d", len(deque.buf))
+		}
+		for i := 98; i >= 0; i -= 2 {
+			if back, _ := deque.PopBack(); back != i {
+				t.Fatalf("Expected back to be %d, got %d", i, back)
+			}
+		}
+	})
+
+	t.Run("BoundedCapacity", func(t *testing.T) {
+		deque := NewDeque[int]()
+		for i := 0; i < 10000; i++ {
+			deque.PushBack(i)
+			if _, err := deque.PopFront(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+		if len(deque.buf) != minDequeCapacity {
+			t.Errorf("Expected capacity to stay at %d, got %d", minDequeCapacity, len(deque.buf))
+		}
+	})
+}
+
+// TestOrderedSet tests the OrderedSet implementation
+func TestOrderedSet(t *testing.T) {
+	t.Run("InsertionOrder", func(t *testing.T) {
+		set := NewOrderedSet[string]()
+		for _, v := range []string{"c", "a", "b", "a", "c"} {
+			set.Add(v)
+		}
+		if set.Size() != 3 {
+			t.Errorf("Expected size to be 3, got %d", set.Size())
+		}
+		if want := []string{"c", "a", "b"}; !reflect.DeepEqual(set.Elements(), want) {
+			t.Errorf("Expected %v, got %v", want, set.Elements())
+		}
+	})
+
+	t.Run("RemoveAndReAdd", func(t *testing.T) {
+		set := NewOrderedSet[int]()
+		for _, v := range []int{1, 2, 3, 4} {
+			set.Add(v)
+		}
+		set.Remove(2)
+		if set.Contains(2) {
+			t.Error("Expected set to not contain 2 after Remove")
+		}
+		if want := []int{1, 3, 4}; !reflect.DeepEqual(set.Elements(), want) {
+			t.Errorf("Expected %v, got %v", want, set.Elements())
+		}
+
+		set.Add(2)
+		if want := []int{1, 3, 4, 2}; !reflect.DeepEqual(set.Elements(), want) {
+			t.Errorf("Expected %v after re-adding, got %v", want, set.Elements())
+		}
+	})
+
+	t.Run("Compaction", func(t *testing.T) {
+		set := NewOrderedSet[int]()
+		for i := 0; i < 100; i++ {
+			set.Add(i)
+		}
+		for i := 0; i < 90; i++ {
+			set.Remove(i)
+		}
+		if len(set.order) > 2*set.Size() {
+			t.Errorf("Expected tombstones to be compacted, order has %d slots for %d elements", len(set.order), set.Size())
+		}
+		want := []int{90, 91, 92, 93, 94, 95, 96, 97, 98, 99}
+		if !reflect.DeepEqual(set.Elements(), want) {
+			t.Errorf("Expected %v, got %v", want, set.Elements())
+		}
+		set.Remove(95)
+		set.Add(95)
+		if !set.Contains(99) || set.Elements()[len(set.Elements())-1] != 95 {
+			t.Errorf("Expected 95 to move to the end, got %v", set.Elements())
+		}
+	})
+}
+
+// TestConcurrentSet tests the ConcurrentSet implementation
+func TestConcurrentSet(t *testing.T) {
+	t.Run("ConcurrentAccess", func(t *testing.T) {
+		set := NewConcurrentSet[int]()
+		const goroutines = 50
+		const perGoroutine = 200
+
+		var wg sync.WaitGroup
+		for g := 0; g < goroutines; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < perGoroutine; i++ {
+					value := g*perGoroutine + i
+					set.Add(value)
+					set.Add(value)
+					set.Contains(value)
+					if i%2 == 1 {
+						set.Remove(value)
+					}
+					set.Size()
+				}
+				set.Elements()
+			}(g)
+		}
+		wg.Wait()
+
+		if want := goroutines * perGoroutine / 2; set.Size() != want {
+			t.Errorf("Expected size to be %d, got %d", want, set.Size())
+		}
+	})
+
+	t.Run("SetOperations", func(t *testing.T) {
+		a := NewConcurrentSet[int]()
+		b := NewConcurrentSet[int]()
+		for _, v := range []int{1, 2, 3} {
+			a.Add(v)
+		}
+		for _, v := range []int{2, 3, 4} {
+			b.Add(v)
+		}
+
+		tests := []struct {
+			name string
+			set  *ConcurrentSet[int]
+			want []int
+		}{
+			{"Union", a.Union(b), []int{1, 2, 3, 4}},
+			{"Intersection", a.Intersection(b), []int{2, 3}},
+			{"Difference", a.Difference(b), []int{1}},
+		}
+		for _, tt := range tests {
+			got := tt.set.Elements()
+			sort.Ints(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+			}
+		}
+	})
+
+	t.Run("SnapshotIsIndependent", func(t *testing.T) {
+		set := NewConcurrentSet[string]()
+		set.Add("a")
+		snapshot := set.Snapshot()
+		set.Add("b")
+		if snapshot.Contains("b") {
+			t.Error("Expected snapshot to not observe later additions")
+		}
+	})
+}
+
+// leakProbe is large enough to bypass the tiny allocator, so finalizers run reliably
+type leakProbe struct {
+	payload [64]byte
+}
+
+// newCollectableProbe returns a probe and a channel closed when it is garbage collected
+func newCollectableProbe() (*leakProbe, chan struct{}) {
+	collected := make(chan struct{})
+	probe := &leakProbe{}
+	runtime.SetFinalizer(probe, func(*leakProbe) { close(collected) })
+	return probe, collected
+}
+
+// waitCollected forces garbage collection until the probe's finalizer has run
+func waitCollected(t *testing.T, collected chan struct{}) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		runtime.GC()
+		select {
+		case <-collected:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Error("Expected removed element to be garbage collected")
+}
+
+// TestRemovedElementsAreCollectable tests that Pop and Dequeue release their elements
+func TestRemovedElementsAreCollectable(t *testing.T) {
+	t.Run("StackPop", func(t *testing.T) {
+		stack := NewStack[*leakProbe]()
+		stack.Push(&leakProbe{})
+		probe, collected := newCollectableProbe()
+		stack.Push(probe)
+		probe = nil
+		if _, err := stack.Pop(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		waitCollected(t, collected)
+		runtime.KeepAlive(stack)
+	})
+
+	t.Run("QueueDequeue", func(t *testing.T) {
+		queue := NewQueue[*leakProbe]()
+		probe, collected := newCollectableProbe()
+		queue.Enqueue(probe)
+		queue.Enqueue(&leakProbe{})
+		probe = nil
+		if _, err := queue.Dequeue(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		waitCollected(t, collected)
+		runtime.KeepAlive(queue)
+	})
+}
+
+// TestTree tests the Tree implementation
+func TestTree(t *testing.T) {
+	//        1
+	//      / | \
+	//     2  3  4
+	//    / \    |
+	//   5   6   7
+	root := NewTree(1)
+	two := root.AddChild(2)
+	root.AddChild(3)
+	four := root.AddChild(4)
+	two.AddChild(5)
+	two.AddChild(6)
+	four.AddChild(7)
+
+	t.Run("Children", func(t *testing.T) {
+		children := root.Children()
+		if len(children) != 3 || children[0] != two || children[2] != four {
+			t.Errorf("Expected children [2 3 4], got %v", children)
+		}
+	})
+
+	t.Run("Traversals", func(t *testing.T) {
+		tests := []struct {
+			name string
+			got  []int
+			want []int
+		}{
+			{"PreOrder", root.PreOrder(), []int{1, 2, 5, 6, 3, 4, 7}},
+			{"PostOrder", root.PostOrder(), []int{5, 6, 2, 3, 7, 4, 1}},
+			{"LevelOrder", root.LevelOrder(), []int{1, 2, 3, 4, 5, 6, 7}},
+		}
+		for _, tt := range tests {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, tt.got)
+			}
+		}
+	})
+
+	t.Run("SingleNode", func(t *testing.T) {
+		leaf := NewTree("leaf")
+		if got := leaf.PostOrder(); !reflect.DeepEqual(got, []string{"leaf"}) {
+			t.Errorf("Expected [leaf], got %v", got)
+		}
+	})
+
+	t.Run("MapTree", func(t *testing.T) {
+		mapped := MapTree(root, func(v int) string { return strconv.Itoa(v * 10) })
+		want := []string{"10", "20", "50", "60", "30", "40", "70"}
+		if got := mapped.PreOrder(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("DeepTree", func(t *testing.T) {
+		deep := NewTree(0)
+		node := deep
+		for i := 1; i < 100000; i++ {
+			node = node.AddChild(i)
+		}
+		if got := len(deep.PostOrder()); got != 100000 {
+			t.Errorf("Expected 100000 values, got %d", got)
+		}
+	})
+}
+
+// TestParseNumbers tests parsing strings into numeric slices
+func TestParseNumbers(t *testing.T) {
+	t.Run("Clean parse", func(t *testing.T) {
+		got, err := ParseNumbers[int]([]string{"1", " -2 ", "30"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, []int{1, -2, 30}) {
+			t.Errorf("Expected [1 -2 30], got %v", got)
+		}
+	})
+
+	t.Run("Float vs int targets", func(t *testing.T) {
+		tokens := []string{"1.5", "2"}
+
+		floats, err := ParseNumbers[float64](tokens)
+		if err != nil || !reflect.DeepEqual(floats, []float64{1.5, 2}) {
+			t.Errorf("Expected [1.5 2], got %v (%v)", floats, err)
+		}
+
+		if _, err := ParseNumbers[int](tokens); err == nil {
+			t.Error("Expected an error parsing 1.5 as int")
+		}
+
+		type celsius float32
+		temps, err := ParseNumbers[celsius]([]string{"-3.25"})
+		if err != nil || temps[0] != -3.25 {
+			t.Errorf("Expected [-3.25], got %v (%v)", temps, err)
+		}
+	})
+
+	t.Run("Mixed slice with bad tokens", func(t *testing.T) {
+		got, err := ParseNumbers[uint8]([]string{"7", "abc", "255", "256", "-1"})
+		if got != nil {
+			t.Errorf("Expected nil result on error, got %v", got)
+		}
+
+		var indexes []int
+		for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+			var parseErr *NumberParseError
+			if !errors.As(e, &parseErr) {
+				t.Fatalf("Expected *NumberParseError, got %T", e)
+			}
+			indexes = append(indexes, parseErr.Index)
+		}
+		if !reflect.DeepEqual(indexes, []int{1, 3, 4}) {
+			t.Errorf("Expected failures at [1 3 4], got %v", indexes)
+		}
+
+		var parseErr *NumberParseError
+		errors.As(err, &parseErr)
+		if parseErr.Token != "abc" || !errors.Is(err, strconv.ErrSyntax) {
+			t.Errorf("Expected first failure to be a syntax error on abc, got %v", err)
+		}
+		if !errors.Is(err, strconv.ErrRange) {
+			t.Errorf("Expected 256 to be reported as out of range, got %v", err)
+		}
+	})
+
+	t.Run("Empty input", func(t *testing.T) {
+		got, err := ParseNumbers[int64](nil)
+		if err != nil || len(got) != 0 {
+			t.Errorf("Expected empty result, got %v (%v)", got, err)
+		}
+	})
+}
+
+// TestEventBus tests fan-out, the drop policy and unsubscribing
+func TestEventBus(t *testing.T) {
+	t.Run("FanOut", func(t *testing.T) {
+		bus := NewEventBus[string](4)
+		first, unsubFirst := bus.Subscribe()
+		second, unsubSecond := bus.Subscribe()
+		defer unsubFirst()
+		defer unsubSecond()
+
+		bus.Publish("hello")
+		if got := <-first; got != "hello" {
+			t.Errorf("Expected first subscriber to receive hello, got %q", got)
+		}
+		if got := <-second; got != "hello" {
+			t.Errorf("Expected second subscriber to receive hello, got %q", got)
+		}
+	})
+
+	t.Run("SlowSubscriberDrops", func(t *testing.T) {
+		bus := NewEventBus[int](2)
+		slow, unsubscribe := bus.Subscribe()
+		defer unsubscribe()
+
+		for i := 0; i < 5; i++ {
+			bus.Publish(i)
+		}
+		if bus.Dropped() != 3 {
+			t.Errorf("Expected 3 dropped events, got %d", bus.Dropped())
+		}
+		if a, b := <-slow, <-slow; a != 0 || b != 1 {
+			t.Errorf("Expected the first two events to be kept, got %d and %d", a, b)
+		}
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		bus := NewEventBus[int](0)
+		ch, unsubscribe := bus.Subscribe()
+		unsubscribe()
+		unsubscribe()
+
+		if _, ok := <-ch; ok {
+			t.Error("Expected the channel to be closed")
+		}
+		if bus.Subscribers() != 0 {
+			t.Errorf("Expected no subscribers, got %d", bus.Subscribers())
+		}
+		bus.Publish(1)
+	})
+
+	t.Run("ConcurrentPublishAndSubscribe", func(t *testing.T) {
+		bus := NewEventBus[int](8)
+		stop := make(chan struct{})
+
+		var publishers sync.WaitGroup
+		for p := 0; p < 4; p++ {
+			publishers.Add(1)
+			go func(p int) {
+				defer publishers.Done()
+				for i := 0; ; i++ {
+					select {
+					case <-stop:
+						return
+					default:
+						bus.Publish(p*1000 + i)
+						// Give subscribers a chance to run on a single CPU
+						runtime.Gosched()
+					}
+				}
+			}(p)
+		}
+
+		// Subscribers join, read a few events and leave mid-stream
+		var subscribers sync.WaitGroup
+		for s := 0; s < 8; s++ {
+			subscribers.Add(1)
+			go func() {
+				defer subscribers.Done()
+				for round := 0; round < 20; round++ {
+					ch, unsubscribe := bus.Subscribe()
+					for i := 0; i < 3; i++ {
+						<-ch
+					}
+					unsubscribe()
+					// Drain whatever was buffered until the channel is closed
+					for range ch {
+					}
+				}
+			}()
+		}
+
+		done := make(chan struct{})
+		go func() {
+			subscribers.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Subscribers did not finish")
+		}
+		close(stop)
+		publishers.Wait()
+
+		if bus.Subscribers() != 0 {
+			t.Errorf("Expected no subscribers left, got %d", bus.Subscribers())
+		}
+	})
+}
+
+// TestScan tests running accumulations with Scan
+func TestScan(t *testing.T) {
+	sums := Scan([]int{1, 2, 3, 4}, 0, func(acc, v int) int { return acc + v })
+	if !reflect.DeepEqual(sums, []int{1, 3, 6, 10}) {
+		t.Errorf("Expected running sums [1 3 6 10], got %v", sums)
+	}
+
+	products := Scan([]int{2, 3, 4}, 1, func(acc, v int) int { return acc * v })
+	if !reflect.DeepEqual(products, []int{2, 6, 24}) {
+		t.Errorf("Expected running products [2 6 24], got %v", products)
+	}
+
+	words := Scan([]string{"a", "b", "c"}, "", func(acc string, v string) string { return acc + v })
+	if !reflect.DeepEqual(words, []string{"a", "ab", "abc"}) {
+		t.Errorf("Expected [a ab abc], got %v", words)
+	}
+
+	if empty := Scan([]int{}, 5, func(acc, v int) int { return acc + v }); len(empty) != 0 {
+		t.Errorf("Expected no accumulations for an empty slice, got %v", empty)
+	}
+}
+
+// TestReduceIndexed tests that ReduceIndexed passes element indexes
+func TestReduceIndexed(t *testing.T) {
+	// Weighted sum: 0*5 + 1*6 + 2*7
+	weighted := ReduceIndexed([]int{5, 6, 7}, 0, func(acc, i, v int) int { return acc + i*v })
+	if weighted != 20 {
+		t.Errorf("Expected weighted sum 20, got %d", weighted)
+	}
+
+	// Keep the index of the largest element
+	values := []int{3, 9, 2, 9}
+	best := ReduceIndexed(values, -1, func(acc, i, v int) int {
+		if acc == -1 || v > values[acc] {
+			return i
+		}
+		return acc
+	})
+	if best != 1 {
+		t.Errorf("Expected index 1, got %d", best)
+	}
+}
+
+// TestParallelMap tests ordering, error short-circuiting and cancellation
+func TestParallelMap(t *testing.T) {
+	t.Run("Preserves order", func(t *testing.T) {
+		items := make([]int, 50)
+		for i := range items {
+			items[i] = i
+		}
+
+		got, err := ParallelMap(context.Background(), items, 8, func(ctx context.Context, v int) (string, error) {
+			// later items finish first
+			time.Sleep(time.Duration(len(items)-v) * 50 * time.Microsecond)
+			return strconv.Itoa(v * v), nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for i, v := range got {
+			if v != strconv.Itoa(i*i) {
+				t.Fatalf("Expected %d at index %d, got %s", i*i, i, v)
+			}
+		}
+
+		empty, err := ParallelMap(context.Background(), []int{}, 4, func(ctx context.Context, v int) (int, error) { return v, nil })
+		if err != nil || len(empty) != 0 {
+			t.Errorf("Expected an empty result, got %v, %v", empty, err)
+		}
+	})
+
+	t.Run("Stops on first error", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		var calls atomic.Int32
+
+		got, err := ParallelMap(context.Background(), make([]int, 1000), 4, func(ctx context.Context, v int) (int, error) {
+			if calls.Add(1) == 5 {
+				return 0, errBoom
+			}
+			select {
+			case <-time.After(time.Millisecond):
+				return v, nil
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		})
+		if !errors.Is(err, errBoom) {
+			t.Errorf("Expected errBoom, got %v", err)
+		}
+		if got != nil {
+			t.Errorf("Expected nil results on error, got %d items", len(got))
+		}
+		if n := calls.Load(); n >= 1000 {
+			t.Errorf("Expected remaining items to be skipped, fn ran %d times", n)
+		}
+	})
+
+	t.Run("Cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		started := make(chan struct{}, 2)
+
+		go func() {
+			<-started
+			cancel()
+		}()
+
+		start := time.Now()
+		_, err := ParallelMap(ctx, make([]int, 100), 2, func(ctx context.Context, v int) (int, error) {
+			started <- struct{}{}
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected cancellation to stop the workers promptly, took %v", elapsed)
+		}
+
+		// An already cancelled context never calls fn
+		called := false
+		if _, err := ParallelMap(ctx, []int{1}, 1, func(ctx context.Context, v int) (int, error) {
+			called = true
+			return v, nil
+		}); !errors.Is(err, context.Canceled) || called {
+			t.Errorf("Expected context.Canceled without calling fn, got %v (called=%v)", err, called)
+		}
+	})
+}
+
+// fakeClock is a time source for TTLCache tests that only moves when advanced
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// newTestTTLCache creates a cache driven by a fake clock
+func newTestTTLCache() (*TTLCache[string, int], *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	cache := NewTTLCache[string, int]()
+	cache.now = clock.Now
+	return cache, clock
+}
+
+// TestTTLCache tests storing, expiring and deleting entries
+func TestTTLCache(t *testing.T) {
+	t.Run("Lazy expiry", func(t *testing.T) {
+		cache, clock := newTestTTLCache()
+		cache.Set("a", 1, time.Minute)
+		cache.Set("b", 2, 2*time.Minute)
+		cache.Set("forever", 3, 0)
+
+		if v, ok := cache.Get("a"); !ok || v != 1 {
+			t.Errorf("Expected (1, true), got (%d, %v)", v, ok)
+		}
+
+		clock.Advance(time.Minute)
+		if _, ok := cache.Get("a"); ok {
+			t.Error("Expected a to have expired")
+		}
+		if v, ok := cache.Get("b"); !ok || v != 2 {
+			t.Errorf("Expected (2, true), got (%d, %v)", v, ok)
+		}
+		// a was removed by Get, b has not expired yet
+		if cache.Len() != 2 {
+			t.Errorf("Expected 2 entries, got %d", cache.Len())
+		}
+
+		clock.Advance(24 * time.Hour)
+		if v, ok := cache.Get("forever"); !ok || v != 3 {
+			t.Errorf("Expected an entry without a ttl to never expire, got (%d, %v)", v, ok)
+		}
+	})
+
+	t.Run("Set replaces and Delete removes", func(t *testing.T) {
+		cache, clock := newTestTTLCache()
+		cache.Set("a", 1, time.Second)
+		cache.Set("a", 2, time.Minute)
+
+		clock.Advance(30 * time.Second)
+		if v, ok := cache.Get("a"); !ok || v != 2 {
+			t.Errorf("Expected the replacement to keep its own ttl, got (%d, %v)", v, ok)
+		}
+
+		cache.Delete("a")
+		cache.Delete("missing")
+		if _, ok := cache.Get("a"); ok {
+			t.Error("Expected a to be deleted")
+		}
+	})
+
+	t.Run("Sweep", func(t *testing.T) {
+		cache, clock := newTestTTLCache()
+		for i := 0; i < 10; i++ {
+			cache.Set(strconv.Itoa(i), i, time.Duration(i+1)*time.Second)
+		}
+
+		clock.Advance(4 * time.Second)
+		if removed := cache.Sweep(); removed != 4 {
+			t.Errorf("Expected 4 entries swept, got %d", removed)
+		}
+		if cache.Len() != 6 {
+			t.Errorf("Expected 6 entries left, got %d", cache.Len())
+		}
+	})
+}
+
+// TestTTLCacheSweeper tests that the background sweeper removes expired entries
+func TestTTLCacheSweeper(t *testing.T) {
+	cache, clock := newTestTTLCache()
+	cache.Set("short", 1, time.Second)
+	cache.Set("long", 2, time.Hour)
+
+	stop := cache.StartSweeper(time.Millisecond)
+	defer stop()
+
+	clock.Advance(time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for cache.Len() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the sweeper to remove the expired entry, %d entries left", cache.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := cache.Get("long"); !ok {
+		t.Error("Expected the unexpired entry to survive the sweeper")
+	}
+
+	// Stopping twice is safe
+	stop()
+	stop()
+}
+
+// TestTTLCacheConcurrent exercises the cache from many goroutines, run with -race
+func TestTTLCacheConcurrent(t *testing.T) {
+	cache := NewTTLCache[int, int]()
+	stop := cache.StartSweeper(time.Millisecond)
+	defer stop()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := (g*500 + i) % 50
+				cache.Set(key, i, time.Duration(i%3)*time.Millisecond)
+				cache.Get(key)
+				if i%10 == 0 {
+					cache.Delete(key)
+				}
+				cache.Len()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if cache.Len() > 50 {
+		t.Errorf("Expected at most 50 entries, got %d", cache.Len())
+	}
+}
+
+// TestLRUCache tests eviction order, recency updates and capacity limits
+func TestLRUCache(t *testing.T) {
+	t.Run("Evicts least recently used", func(t *testing.T) {
+		cache, err := NewLRUCache[string, int](3)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		cache.Put("a", 1)
+		cache.Put("b", 2)
+		cache.Put("c", 3)
+
+		// Reading a makes b the least recently used
+		if v, ok := cache.Get("a"); !ok || v != 1 {
+			t.Errorf("Expected (1, true), got (%d, %v)", v, ok)
+		}
+		cache.Put("d", 4)
+		if _, ok := cache.Get("b"); ok {
+			t.Error("Expected b to be evicted")
+		}
+		if !reflect.DeepEqual(cache.Keys(), []string{"d", "a", "c"}) {
+			t.Errorf("Expected keys [d a c], got %v", cache.Keys())
+		}
+
+		cache.Put("e", 5)
+		cache.Put("f", 6)
+		if !reflect.DeepEqual(cache.Keys(), []string{"f", "e", "d"}) {
+			t.Errorf("Expected keys [f e d], got %v", cache.Keys())
+		}
+		if cache.Len() != 3 {
+			t.Errorf("Expected 3 entries, got %d", cache.Len())
+		}
+	})
+
+	t.Run("Update moves to front", func(t *testing.T) {
+		cache, _ := NewLRUCache[string, int](3)
+		cache.Put("a", 1)
+		cache.Put("b", 2)
+		cache.Put("c", 3)
+
+		cache.Put("a", 10)
+		if !reflect.DeepEqual(cache.Keys(), []string{"a", "c", "b"}) {
+			t.Errorf("Expected keys [a c b], got %v", cache.Keys())
+		}
+		if cache.Len() != 3 {
+			t.Errorf("Expected updating a key not to add an entry, got %d", cache.Len())
+		}
+
+		cache.Put("d", 4)
+		if _, ok := cache.Get("b"); ok {
+			t.Error("Expected b to be evicted")
+		}
+		if v, ok := cache.Get("a"); !ok || v != 10 {
+			t.Errorf("Expected (10, true), got (%d, %v)", v, ok)
+		}
+	})
+
+	t.Run("Capacity one", func(t *testing.T) {
+		cache, err := NewLRUCache[int, string](1)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		cache.Put(1, "one")
+		cache.Put(1, "uno")
+		if v, ok := cache.Get(1); !ok || v != "uno" {
+			t.Errorf("Expected (uno, true), got (%q, %v)", v, ok)
+		}
+
+		cache.Put(2, "two")
+		if _, ok := cache.Get(1); ok {
+			t.Error("Expected 1 to be evicted")
+		}
+		if v, ok := cache.Get(2); !ok || v != "two" {
+			t.Errorf("Expected (two, true), got (%q, %v)", v, ok)
+		}
+		if cache.Len() != 1 || cache.Capacity() != 1 {
+			t.Errorf("Expected 1 entry of capacity 1, got %d of %d", cache.Len(), cache.Capacity())
+		}
+	})
+
+	t.Run("Invalid capacity", func(t *testing.T) {
+		for _, capacity := range []int{0, -1} {
+			if _, err := NewLRUCache[int, int](capacity); !errors.Is(err, ErrInvalidCapacity) {
+				t.Errorf("Capacity %d: expected ErrInvalidCapacity, got %v", capacity, err)
+			}
+			if _, err := NewConcurrentLRUCache[int, int](capacity); !errors.Is(err, ErrInvalidCapacity) {
+				t.Errorf("Capacity %d: expected ErrInvalidCapacity, got %v", capacity, err)
+			}
+		}
+	})
+
+	t.Run("Missing key", func(t *testing.T) {
+		cache, _ := NewLRUCache[string, int](2)
+		if v, ok := cache.Get("missing"); ok || v != 0 {
+			t.Errorf("Expected (0, false), got (%d, %v)", v, ok)
+		}
+		if len(cache.Keys()) != 0 {
+			t.Errorf("Expected no keys, got %v", cache.Keys())
+		}
+	})
+}
+
+// TestConcurrentLRUCache exercises the cache from many goroutines, run with -race
+func TestConcurrentLRUCache(t *testing.T) {
+	cache, err := NewConcurrentLRUCache[int, int](16)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := (g*500 + i) % 40
+				cache.Put(key, i)
+				cache.Get(key)
+				cache.Keys()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if cache.Len() != 16 {
+		t.Errorf("Expected 16 entries, got %d", cache.Len())
+	}
+	if len(cache.Keys()) != 16 {
+		t.Errorf("Expected 16 keys, got %d", len(cache.Keys()))
+	}
+}
+
+// TestDebounce tests that a burst of calls results in a single call
+func TestDebounce(t *testing.T) {
+	const wait = 20 * time.Millisecond
+
+	t.Run("Burst calls once", func(t *testing.T) {
+		var calls atomic.Int32
+		debounced, cancel := Debounce(func() { calls.Add(1) }, wait)
+		defer cancel()
+
+		for i := 0; i < 10; i++ {
+			debounced()
+			time.Sleep(wait / 10)
+		}
+		if calls.Load() != 0 {
+			t.Errorf("Expected no call during the burst, got %d", calls.Load())
+		}
+
+		time.Sleep(3 * wait)
+		if calls.Load() != 1 {
+			t.Errorf("Expected 1 call after the burst, got %d", calls.Load())
+		}
+
+		// A later burst calls again
+		debounced()
+		debounced()
+		time.Sleep(3 * wait)
+		if calls.Load() != 2 {
+			t.Errorf("Expected 2 calls after the second burst, got %d", calls.Load())
+		}
+	})
+
+	t.Run("Cancel drops the pending call", func(t *testing.T) {
+		var calls atomic.Int32
+		debounced, cancel := Debounce(func() { calls.Add(1) }, wait)
+
+		debounced()
+		cancel()
+		cancel()
+		time.Sleep(3 * wait)
+		if calls.Load() != 0 {
+			t.Errorf("Expected no call after cancel, got %d", calls.Load())
+		}
+
+		debounced()
+		time.Sleep(3 * wait)
+		if calls.Load() != 1 {
+			t.Errorf("Expected a call made after cancel to run, got %d", calls.Load())
+		}
+	})
+}
+
+// TestThrottle tests that throttled calls are at least an interval apart
+func TestThrottle(t *testing.T) {
+	const interval = 50 * time.Millisecond
+
+	var (
+		mu    sync.Mutex
+		times []time.Time
+	)
+	throttled := Throttle(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		times = append(times, time.Now())
+	}, interval)
+	called := func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Time(nil), times...)
+	}
+
+	// The first call runs immediately, the rest of the burst becomes one trailing call
+	for i := 0; i < 10; i++ {
+		throttled()
+	}
+	if got := len(called()); got != 1 {
+		t.Fatalf("Expected the first call to run immediately, got %d calls", got)
+	}
+
+	time.Sleep(3 * interval)
+	got := called()
+	if len(got) != 2 {
+		t.Fatalf("Expected 1 leading and 1 trailing call, got %d", len(got))
+	}
+
+	// A steady stream of calls runs at most once per interval
+	deadline := time.Now().Add(4 * interval)
+	for time.Now().Before(deadline) {
+		throttled()
+		time.Sleep(interval / 10)
+	}
+	time.Sleep(2 * interval)
+
+	got = called()
+	if len(got) < 4 || len(got) > 7 {
+		t.Errorf("Expected about one call per interval, got %d calls", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		// allow for timer jitter
+		if gap := got[i].Sub(got[i-1]); gap < interval-5*time.Millisecond {
+			t.Errorf("Calls %d and %d were only %v apart", i-1, i, gap)
+		}
+	}
+}
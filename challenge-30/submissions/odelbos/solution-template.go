@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"time"
 )
 
@@ -23,6 +25,9 @@ type ContextManager interface {
 	// Execute a task with context cancellation support
 	ExecuteWithContext(ctx context.Context, task func() error) error
 
+	// Execute a task that must finish within a timeout
+	ExecuteWithTimeout(parent context.Context, timeout time.Duration, task func() error) error
+
 	// Wait for context cancellation or completion
 	WaitForCompletion(ctx context.Context, duration time.Duration) error
 }
@@ -74,6 +79,16 @@ func (cm *simpleContextManager) ExecuteWithContext(ctx context.Context, task fun
 	}
 }
 
+// ExecuteWithTimeout executes a task that must finish within timeout of the
+// call. A timeout is reported as context.DeadlineExceeded, a task error is
+// returned as is. The derived context is always cancelled before returning.
+func (cm *simpleContextManager) ExecuteWithTimeout(parent context.Context, timeout time.Duration, task func() error) error {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	return cm.ExecuteWithContext(ctx, task)
+}
+
 // WaitForCompletion waits for a duration or until context is cancelled
 func (cm *simpleContextManager) WaitForCompletion(ctx context.Context, duration time.Duration) error {
 	select {
@@ -113,6 +128,81 @@ func ProcessItems(ctx context.Context, items []string) ([]string, error) {
 	return processed, nil
 }
 
+// RetryOptions configures DoWithContext
+type RetryOptions struct {
+	MaxAttempts    int           // 0 retries until the context ends
+	InitialBackoff time.Duration // delay after the first failure
+	MaxBackoff     time.Duration // cap on a single delay, 0 means no cap
+	Multiplier     float64       // backoff growth per attempt, defaults to 2
+	Jitter         float64       // fraction of each delay that is randomised, 0 to 1
+	AttemptTimeout time.Duration // timeout for a single attempt, 0 means none
+}
+
+// backoff returns the delay before the given retry, attempt starting at 1
+func (o RetryOptions) backoff(attempt int) time.Duration {
+	multiplier := o.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	delay := float64(o.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		delay *= multiplier
+		if o.MaxBackoff > 0 && delay >= float64(o.MaxBackoff) {
+			delay = float64(o.MaxBackoff)
+			break
+		}
+	}
+
+	// Take a random part off the delay so concurrent callers don't retry in lockstep
+	if o.Jitter > 0 {
+		delay -= delay * o.Jitter * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// DoWithContext calls fn until it succeeds, waiting with jittered exponential
+// backoff between attempts. It stops early when the context is cancelled, or
+// when the next attempt could not start before the context deadline.
+func DoWithContext(ctx context.Context, fn func(context.Context) error, opts RetryOptions) error {
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(err, lastErr)
+		}
+
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if opts.AttemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, opts.AttemptTimeout)
+		}
+		lastErr = fn(attemptCtx)
+		cancel()
+
+		if lastErr == nil {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return errors.Join(err, lastErr)
+		}
+		if opts.MaxAttempts > 0 && attempt >= opts.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, lastErr)
+		}
+
+		delay := opts.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+			return fmt.Errorf("giving up after %d attempts, deadline too close to retry: %w", attempt, lastErr)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(ctx.Err(), lastErr)
+		case <-timer.C:
+		}
+	}
+}
+
 // Example usage
 func main() {
 	fmt.Println("Context Management Challenge")
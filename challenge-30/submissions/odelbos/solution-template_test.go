@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errFlaky = errors.New("flaky")
+
+func TestDoWithContextRetriesUntilSuccess(t *testing.T) {
+	attempts := 0
+	err := DoWithContext(context.Background(), func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errFlaky
+		}
+		return nil
+	}, RetryOptions{InitialBackoff: time.Millisecond, Jitter: 0.5})
+
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestDoWithContextMaxAttempts(t *testing.T) {
+	attempts := 0
+	err := DoWithContext(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return errFlaky
+	}, RetryOptions{MaxAttempts: 4, InitialBackoff: time.Millisecond})
+
+	if !errors.Is(err, errFlaky) {
+		t.Errorf("Expected the last error to be wrapped, got %v", err)
+	}
+	if attempts != 4 {
+		t.Errorf("Expected 4 attempts, got %d", attempts)
+	}
+}
+
+func TestDoWithContextRespectsDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	start := time.Now()
+	err := DoWithContext(ctx, func(ctx context.Context) error {
+		attempts++
+		return errFlaky
+	}, RetryOptions{InitialBackoff: 20 * time.Millisecond})
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, errFlaky) {
+		t.Errorf("Expected the last error to be wrapped, got %v", err)
+	}
+	// Delays of 20ms and 40ms fit, the next 80ms would pass the deadline
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+	if elapsed >= 100*time.Millisecond {
+		t.Errorf("Expected to give up before the deadline, took %v", elapsed)
+	}
+}
+
+func TestDoWithContextAbortsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	err := DoWithContext(ctx, func(ctx context.Context) error {
+		attempts++
+		return errFlaky
+	}, RetryOptions{InitialBackoff: time.Second})
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", attempts)
+	}
+	if elapsed > 200*time.Millisecond {
+		t.Errorf("Expected to abort the backoff immediately, took %v", elapsed)
+	}
+
+	// An already cancelled context never calls fn
+	called := false
+	DoWithContext(ctx, func(ctx context.Context) error {
+		called = true
+		return nil
+	}, RetryOptions{})
+	if called {
+		t.Error("Expected fn not to be called with a cancelled context")
+	}
+}
+
+func TestDoWithContextAttemptTimeout(t *testing.T) {
+	attempts := 0
+	err := DoWithContext(context.Background(), func(ctx context.Context) error {
+		attempts++
+		<-ctx.Done()
+		return ctx.Err()
+	}, RetryOptions{MaxAttempts: 2, InitialBackoff: time.Millisecond, AttemptTimeout: 10 * time.Millisecond})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	opts := RetryOptions{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for i, want := range expected {
+		if got := opts.backoff(i + 1); got != want*time.Millisecond {
+			t.Errorf("Attempt %d: expected %v, got %v", i+1, want*time.Millisecond, got)
+		}
+	}
+
+	opts.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := opts.backoff(1); got < 5*time.Millisecond || got > 10*time.Millisecond {
+			t.Fatalf("Expected jittered delay in [5ms, 10ms], got %v", got)
+		}
+	}
+}
+
+func TestExecuteWithTimeoutTaskFirst(t *testing.T) {
+	cm := NewContextManager()
+
+	err := cm.ExecuteWithTimeout(context.Background(), time.Second, func() error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	taskErr := errors.New("task failed")
+	err = cm.ExecuteWithTimeout(context.Background(), time.Second, func() error {
+		return taskErr
+	})
+	if err != taskErr {
+		t.Errorf("Expected the task error, got %v", err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected a task error not to be reported as a timeout")
+	}
+}
+
+func TestExecuteWithTimeoutTimeoutFirst(t *testing.T) {
+	cm := NewContextManager()
+
+	start := time.Now()
+	err := cm.ExecuteWithTimeout(context.Background(), 20*time.Millisecond, func() error {
+		time.Sleep(500 * time.Millisecond)
+		return errors.New("too late")
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("Expected to return at the timeout, took %v", elapsed)
+	}
+
+	// Cancelling the parent still ends the call before the timeout
+	parent, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = cm.ExecuteWithTimeout(parent, time.Second, func() error {
+		time.Sleep(500 * time.Millisecond)
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
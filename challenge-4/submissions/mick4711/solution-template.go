@@ -1,6 +1,8 @@
 package main
 
 import (
+	"container/heap"
+	"errors"
 	"fmt"
 	"sync"
 )
@@ -18,46 +20,74 @@ type path struct {
 	nodes []int
 }
 
+// bfsQuery is the BFS used by the workers, swapped out in tests to count calls
+var bfsQuery = BFSQuery
+
 func ConcurrentBFSQueries(graph map[int][]int, queries []int, numWorkers int) map[int][]int {
-	// initialise
-	queryCount := len(queries)
-	res := make(map[int][]int, queryCount) // output result map
-	chQueries := make(chan int, queryCount) // in channel
-	chPaths := make(chan path, queryCount)  // out channel
-	var wg sync.WaitGroup
+	return concurrentQueries(graph, queries, numWorkers, bfsQuery)
+}
 
-	// push queries to an in channel
-	for _, q := range queries {
-		chQueries <- q
-	}
-	close(chQueries)
+// ConcurrentDFSQueries is like ConcurrentBFSQueries but returns the DFS
+// preorder from each start node instead of the BFS order.
+func ConcurrentDFSQueries(graph map[int][]int, queries []int, numWorkers int) map[int][]int {
+	return concurrentQueries(graph, queries, numWorkers, DFSQuery)
+}
 
-	// Start workers
-	for range numWorkers {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			// peel a query off in channel and push result path to out channel
-			for query := range chQueries {
-				chPaths <- path{
-					root:  query,
-					nodes: BFSQuery(graph, query),
-				}
-			}
-		}()
+// run search from each distinct start node using a pool of numWorkers goroutines.
+// each search keeps its own visited set, and only this goroutine writes the result map
+func concurrentQueries(graph map[int][]int, queries []int, numWorkers int, search func(map[int][]int, int) []int) map[int][]int {
+	// each distinct start node is only computed once
+	distinct := uniqueQueries(queries)
+
+	// no workers means no queries are run
+	if numWorkers < 1 {
+		return map[int][]int{}
 	}
 
-	wg.Wait()
-	close(chPaths)
+	// queries in, paths out through a single worker pool stage
+	paths := Stage(Generator(distinct), numWorkers, func(query int) path {
+		return path{root: query, nodes: search(graph, query)}
+	})
 
 	// peel paths off out channel and add to result map
-	for p := range chPaths {
+	res := make(map[int][]int, len(distinct))
+	for p := range paths {
 		res[p.root] = p.nodes
 	}
 
 	return res
 }
 
+// ConcurrentBFSQueriesOrdered is like ConcurrentBFSQueries but returns one
+// result per query position, so duplicate queries each get their BFS order.
+// duplicates are computed once and share the same result slice.
+func ConcurrentBFSQueriesOrdered(graph map[int][]int, queries []int, numWorkers int) [][]int {
+	memo := ConcurrentBFSQueries(graph, queries, numWorkers)
+
+	// fan the memoised results out to every query position
+	res := make([][]int, len(queries))
+	for i, q := range queries {
+		res[i] = memo[q]
+	}
+
+	return res
+}
+
+// remove duplicate queries, keeping the order of first appearance
+func uniqueQueries(queries []int) []int {
+	seen := make(map[int]bool, len(queries))
+	distinct := make([]int, 0, len(queries))
+
+	for _, q := range queries {
+		if !seen[q] {
+			seen[q] = true
+			distinct = append(distinct, q)
+		}
+	}
+
+	return distinct
+}
+
 // process list of queries sequentially
 func SerialBFSQueries(graph map[int][]int, queries []int, numWorkers int) map[int][]int {
 	// initialise result slice
@@ -119,6 +149,254 @@ func BFSQuery(graph map[int][]int, root int) []int {
 	*/
 }
 
+// get the DFS preorder of all nodes reachable from the root, visiting
+// adjacent nodes in the order they are listed
+func DFSQuery(graph map[int][]int, root int) []int {
+	// explicit stack rather than recursion so long chains can't blow the stack
+	stack := []int{root}
+	visited := make(map[int]bool)
+	var path []int
+
+	for len(stack) > 0 {
+		// pop the top of the stack
+		current_item := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		// a node can be pushed more than once before it is visited
+		if visited[current_item] {
+			continue
+		}
+		visited[current_item] = true
+		path = append(path, current_item)
+
+		// push adjacent items in reverse so the first one is visited first
+		adjacent := graph[current_item]
+		for i := len(adjacent) - 1; i >= 0; i-- {
+			if !visited[adjacent[i]] {
+				stack = append(stack, adjacent[i])
+			}
+		}
+	}
+
+	return path
+}
+
+// DFS colours for HasCycle
+const (
+	white = iota // not visited yet
+	grey         // on the current DFS path
+	black        // fully explored
+)
+
+// HasCycle reports whether the directed graph contains a cycle, including a
+// node with an edge to itself. A DFS that reaches a node still on its
+// current path (grey) has found a back edge, and so a cycle.
+func HasCycle(graph map[int][]int) bool {
+	colour := make(map[int]int, len(graph))
+
+	var visit func(node int) bool
+	visit = func(node int) bool {
+		colour[node] = grey
+		for _, v := range graph[node] {
+			switch colour[v] {
+			case grey:
+				return true
+			case white:
+				if visit(v) {
+					return true
+				}
+			}
+		}
+		colour[node] = black
+		return false
+	}
+
+	// start from every node so disconnected parts are checked too
+	for node := range graph {
+		if colour[node] == white && visit(node) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ErrNegativeWeight is returned by Dijkstra when the graph has a negative edge,
+// since the algorithm can't produce correct shortest paths with one
+var ErrNegativeWeight = errors.New("graph has a negative edge weight")
+
+// ShortestPaths holds the result of Dijkstra from a single source.
+// Dist maps each reachable node to its distance, Prev maps each reachable
+// node except the source to its predecessor on a shortest path.
+type ShortestPaths struct {
+	Dist map[int]int
+	Prev map[int]int
+}
+
+// heap item, a node and the tentative distance it was pushed with
+type distItem struct {
+	node int
+	dist int
+}
+
+// min-heap of distItems ordered by distance, for container/heap
+type distHeap []distItem
+
+func (h distHeap) Len() int           { return len(h) }
+func (h distHeap) Less(i, j int) bool { return h[i].dist < h[j].dist }
+func (h distHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *distHeap) Push(x any)        { *h = append(*h, x.(distItem)) }
+func (h *distHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// check every edge weight is non-negative
+func validateWeights(graph map[int]map[int]int) error {
+	for u, edges := range graph {
+		for v, w := range edges {
+			if w < 0 {
+				return fmt.Errorf("%w: %d -> %d has weight %d", ErrNegativeWeight, u, v, w)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Dijkstra finds the shortest paths from start over a weighted graph, where
+// graph[u][v] is the weight of the edge u -> v. Unreachable nodes are absent
+// from dist. Returns ErrNegativeWeight if any edge weight is negative.
+func Dijkstra(graph map[int]map[int]int, start int) (dist map[int]int, prev map[int]int, err error) {
+	if err = validateWeights(graph); err != nil {
+		return nil, nil, err
+	}
+
+	dist, prev = dijkstra(graph, start)
+	return dist, prev, nil
+}
+
+// dijkstra assumes the weights have already been validated
+func dijkstra(graph map[int]map[int]int, start int) (map[int]int, map[int]int) {
+	dist := map[int]int{start: 0}
+	prev := make(map[int]int)
+	done := make(map[int]bool)
+	h := &distHeap{{node: start, dist: 0}}
+
+	for h.Len() > 0 {
+		current := heap.Pop(h).(distItem)
+
+		// skip stale entries left behind by a later, shorter push
+		if done[current.node] {
+			continue
+		}
+		done[current.node] = true
+
+		// relax the edges out of the current node
+		for v, w := range graph[current.node] {
+			next := current.dist + w
+			if d, seen := dist[v]; !seen || next < d {
+				dist[v] = next
+				prev[v] = current.node
+				heap.Push(h, distItem{node: v, dist: next})
+			}
+		}
+	}
+
+	return dist, prev
+}
+
+// ConcurrentDijkstraQueries runs Dijkstra from each distinct source using a
+// pool of numWorkers goroutines, like ConcurrentBFSQueries.
+// Returns ErrNegativeWeight without running any query if a weight is negative.
+func ConcurrentDijkstraQueries(graph map[int]map[int]int, sources []int, numWorkers int) (map[int]ShortestPaths, error) {
+	// validate once up front rather than in every worker
+	if err := validateWeights(graph); err != nil {
+		return nil, err
+	}
+
+	// initialise
+	distinct := uniqueQueries(sources)
+	res := make(map[int]ShortestPaths, len(distinct))
+	chSources := make(chan int, len(distinct))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	// push sources to an in channel
+	for _, s := range distinct {
+		chSources <- s
+	}
+	close(chSources)
+
+	// start workers, each writing its results into the map under the lock
+	for range numWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for source := range chSources {
+				dist, prev := dijkstra(graph, source)
+				mu.Lock()
+				res[source] = ShortestPaths{Dist: dist, Prev: prev}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return res, nil
+}
+
+// Generator is a pipeline source: it sends each item on the returned channel,
+// then closes it.
+func Generator[T any](items []T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for _, item := range items {
+			out <- item
+		}
+	}()
+	return out
+}
+
+// Stage is a pipeline step: workers goroutines (at least one) apply fn to
+// values from in and send the results, in no particular order, on the
+// returned channel. The output is closed once in is closed and drained, so
+// stages chain and the pipeline ends when its source does.
+func Stage[T, U any](in <-chan T, workers int, fn func(T) U) <-chan U {
+	out := make(chan U)
+	var wg sync.WaitGroup
+
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range in {
+				out <- fn(v)
+			}
+		}()
+	}
+
+	// close out once every worker has finished sending
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// Collect is a pipeline sink: it gathers every value from in until in is closed.
+func Collect[T any](in <-chan T) []T {
+	var res []T
+	for v := range in {
+		res = append(res, v)
+	}
+	return res
+}
+
 func main() {
 	// You can insert optional local tests here if desired.
 	graph := map[int][]int{
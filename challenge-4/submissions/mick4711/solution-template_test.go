@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+var dedupeGraph = map[int][]int{
+	0: {1, 2},
+	1: {2, 3},
+	2: {3},
+	3: {4},
+	4: {},
+}
+
+// countBFSCalls swaps in a BFS that records how often each start node is computed
+func countBFSCalls(t *testing.T) map[int]int {
+	t.Helper()
+	var mu sync.Mutex
+	calls := make(map[int]int)
+
+	original := bfsQuery
+	bfsQuery = func(graph map[int][]int, root int) []int {
+		mu.Lock()
+		calls[root]++
+		mu.Unlock()
+		return original(graph, root)
+	}
+	t.Cleanup(func() { bfsQuery = original })
+
+	return calls
+}
+
+func TestDuplicateQueriesComputedOnce(t *testing.T) {
+	calls := countBFSCalls(t)
+	queries := []int{0, 1, 0, 2, 1, 0, 2, 2}
+
+	res := ConcurrentBFSQueriesOrdered(dedupeGraph, queries, 3)
+
+	if len(res) != len(queries) {
+		t.Fatalf("expected %d results, got %d", len(queries), len(res))
+	}
+	for i, q := range queries {
+		want := BFSQuery(dedupeGraph, q)
+		if !reflect.DeepEqual(res[i], want) {
+			t.Errorf("query %d (start %d): expected %v, got %v", i, q, want, res[i])
+		}
+	}
+
+	for _, q := range []int{0, 1, 2} {
+		if calls[q] != 1 {
+			t.Errorf("start node %d computed %d times, want 1", q, calls[q])
+		}
+	}
+}
+
+func TestDuplicateQueriesMap(t *testing.T) {
+	calls := countBFSCalls(t)
+
+	res := ConcurrentBFSQueries(dedupeGraph, []int{3, 3, 3, 4}, 2)
+
+	if len(res) != 2 {
+		t.Errorf("expected 2 distinct results, got %d", len(res))
+	}
+	if !reflect.DeepEqual(res[3], []int{3, 4}) {
+		t.Errorf("expected [3 4], got %v", res[3])
+	}
+	if calls[3] != 1 || calls[4] != 1 {
+		t.Errorf("expected one computation per start node, got %v", calls)
+	}
+}
+
+func BenchmarkDuplicateQueries(b *testing.B) {
+	// a long chain so each BFS does real work
+	graph := make(map[int][]int)
+	for i := 0; i < 1000; i++ {
+		graph[i] = []int{i + 1}
+	}
+	graph[1000] = []int{}
+
+	// 10 distinct start nodes, each repeated 100 times
+	queries := make([]int, 0, 1000)
+	for i := 0; i < 100; i++ {
+		for j := 0; j < 10; j++ {
+			queries = append(queries, j)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ConcurrentBFSQueriesOrdered(graph, queries, 4)
+	}
+}
+
+// weighted graph with a known shortest-path tree from 0:
+// 0 -> 2 (1), 2 -> 1 (2), 1 -> 3 (1), 3 -> 4 (3); node 5 is unreachable
+var weightedGraph = map[int]map[int]int{
+	0: {1: 4, 2: 1},
+	1: {3: 1},
+	2: {1: 2, 3: 5},
+	3: {4: 3},
+	4: {},
+	5: {0: 1},
+}
+
+func TestDijkstra(t *testing.T) {
+	dist, prev, err := Dijkstra(weightedGraph, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantDist := map[int]int{0: 0, 1: 3, 2: 1, 3: 4, 4: 7}
+	if !reflect.DeepEqual(dist, wantDist) {
+		t.Errorf("expected dist %v, got %v", wantDist, dist)
+	}
+	wantPrev := map[int]int{1: 2, 2: 0, 3: 1, 4: 3}
+	if !reflect.DeepEqual(prev, wantPrev) {
+		t.Errorf("expected prev %v, got %v", wantPrev, prev)
+	}
+	if _, ok := dist[5]; ok {
+		t.Error("expected unreachable node 5 to be absent from dist")
+	}
+}
+
+func TestDijkstraNegativeWeight(t *testing.T) {
+	graph := map[int]map[int]int{
+		0: {1: 2},
+		1: {2: -1},
+	}
+
+	if _, _, err := Dijkstra(graph, 0); !errors.Is(err, ErrNegativeWeight) {
+		t.Errorf("expected ErrNegativeWeight, got %v", err)
+	}
+	if _, err := ConcurrentDijkstraQueries(graph, []int{0}, 2); !errors.Is(err, ErrNegativeWeight) {
+		t.Errorf("expected ErrNegativeWeight, got %v", err)
+	}
+}
+
+func TestConcurrentDijkstraQueries(t *testing.T) {
+	sources := []int{0, 2, 5, 0, 4}
+
+	res, err := ConcurrentDijkstraQueries(weightedGraph, sources, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(res) != 4 {
+		t.Errorf("expected 4 distinct results, got %d", len(res))
+	}
+	for _, s := range sources {
+		wantDist, wantPrev, _ := Dijkstra(weightedGraph, s)
+		if !reflect.DeepEqual(res[s].Dist, wantDist) || !reflect.DeepEqual(res[s].Prev, wantPrev) {
+			t.Errorf("source %d: expected %v %v, got %v %v", s, wantDist, wantPrev, res[s].Dist, res[s].Prev)
+		}
+	}
+}
+
+func TestDFSQueryOrder(t *testing.T) {
+	//   0
+	//  / \
+	// 1   2
+	// |   |
+	// 3   4
+	graph := map[int][]int{
+		0: {1, 2},
+		1: {3},
+		2: {4},
+		3: {},
+		4: {},
+	}
+
+	if got := DFSQuery(graph, 0); !reflect.DeepEqual(got, []int{0, 1, 3, 2, 4}) {
+		t.Errorf("expected DFS order [0 1 3 2 4], got %v", got)
+	}
+	if got := BFSQuery(graph, 0); !reflect.DeepEqual(got, []int{0, 1, 2, 3, 4}) {
+		t.Errorf("expected BFS order [0 1 2 3 4], got %v", got)
+	}
+
+	// a node reachable by two routes is only visited once
+	if got := DFSQuery(dedupeGraph, 0); !reflect.DeepEqual(got, []int{0, 1, 2, 3, 4}) {
+		t.Errorf("expected DFS order [0 1 2 3 4], got %v", got)
+	}
+}
+
+func TestConcurrentDFSQueries(t *testing.T) {
+	graph := map[int][]int{
+		0: {1, 4},
+		1: {2},
+		2: {0, 3},
+		3: {},
+		4: {3},
+	}
+	queries := []int{0, 1, 2, 3, 4, 0, 2}
+
+	res := ConcurrentDFSQueries(graph, queries, 3)
+
+	if len(res) != 5 {
+		t.Errorf("expected 5 distinct results, got %d", len(res))
+	}
+	for _, q := range queries {
+		want := DFSQuery(graph, q)
+		if !reflect.DeepEqual(res[q], want) {
+			t.Errorf("start %d: expected %v, got %v", q, want, res[q])
+		}
+	}
+	if !reflect.DeepEqual(res[0], []int{0, 1, 2, 3, 4}) {
+		t.Errorf("expected [0 1 2 3 4], got %v", res[0])
+	}
+}
+
+func TestHasCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph map[int][]int
+		want  bool
+	}{
+		{"dag", dedupeGraph, false},
+		{"diamond", map[int][]int{0: {1, 2}, 1: {3}, 2: {3}, 3: {}}, false},
+		{"three node cycle", map[int][]int{0: {1}, 1: {2}, 2: {0}}, true},
+		{"self loop", map[int][]int{0: {0}}, true},
+		{"cycle in disconnected part", map[int][]int{0: {1}, 1: {}, 2: {3}, 3: {2}}, true},
+		{"edge to unlisted node", map[int][]int{0: {1}}, false},
+		{"empty", map[int][]int{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasCycle(tt.graph); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	items := make([]int, 100)
+	for i := range items {
+		items[i] = i
+	}
+
+	// two stages: square, then format
+	squares := Stage(Generator(items), 4, func(n int) int { return n * n })
+	out := Collect(Stage(squares, 3, strconv.Itoa))
+
+	want := make([]string, len(items))
+	for i, n := range items {
+		want[i] = strconv.Itoa(n * n)
+	}
+	slices.Sort(out)
+	slices.Sort(want)
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("expected %v, got %v", want, out)
+	}
+}
+
+func TestPipelineEdgeCases(t *testing.T) {
+	// an empty source still closes every stage
+	if got := Collect(Stage(Generator([]int{}), 2, func(n int) int { return n })); len(got) != 0 {
+		t.Errorf("expected no output, got %v", got)
+	}
+
+	// fewer than one worker still runs a single worker
+	got := Collect(Stage(Generator([]int{1, 2, 3}), 0, func(n int) int { return n + 1 }))
+	slices.Sort(got)
+	if !reflect.DeepEqual(got, []int{2, 3, 4}) {
+		t.Errorf("expected [2 3 4], got %v", got)
+	}
+
+	// with one worker per stage the order is preserved
+	got = Collect(Stage(Stage(Generator([]int{3, 1, 2}), 1, func(n int) int { return n * 10 }), 1, func(n int) int { return n + 1 }))
+	if !reflect.DeepEqual(got, []int{31, 11, 21}) {
+		t.Errorf("expected [31 11 21], got %v", got)
+	}
+}
@@ -2,6 +2,7 @@
 package challenge7
 
 import (
+	"sort"
 	"sync"
     "fmt"
 )
@@ -137,13 +138,121 @@ func (a *BankAccount) Withdraw(amount float64) error {
 // Transfer moves the specified amount from this account to the target account.
 // It returns an error if the amount is invalid, exceeds the transaction limit,
 // or would bring the balance below the minimum required balance.
+// Both accounts are locked for the whole transfer, so the money is never
+// seen missing from both of them.
 func (a *BankAccount) Transfer(amount float64, target *BankAccount) error {
-    err := a.Withdraw(amount)
-    if err != nil {
-        return err
+    if amount > MaxTransactionAmount {
+        return &ExceedsLimitError{a.ID, "transfer", amount, fmt.Sprintf("exceed the limit of: %f", MaxTransactionAmount)}
+    }
+    if amount < 0 {
+        return &NegativeAmountError{a.ID, "transfer", amount, "amount cannot be negative"}
+    }
+
+    unlock := lockAccounts([]*BankAccount{a, target})
+    defer unlock()
+
+    if a.Balance-amount < a.MinBalance {
+        return &InsufficientFundsError{a.ID, "transfer", amount, "balance - amount < minimum balance"}
     }
-    target.mu.Lock()
+    a.Balance -= amount
     target.Balance += amount
-    target.mu.Unlock()
     return nil
-} 
\ No newline at end of file
+}
+
+// lockAccounts locks each distinct account in ID order and returns a function
+// that unlocks them. Taking locks in one global order is what keeps
+// concurrent transfers and Bank snapshots from deadlocking, which relies on
+// account IDs being unique.
+func lockAccounts(accounts []*BankAccount) (unlock func()) {
+    sorted := make([]*BankAccount, 0, len(accounts))
+    seen := make(map[*BankAccount]bool, len(accounts))
+    for _, account := range accounts {
+        if !seen[account] {
+            seen[account] = true
+            sorted = append(sorted, account)
+        }
+    }
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+    for _, account := range sorted {
+        account.mu.Lock()
+    }
+    return func() {
+        for i := len(sorted) - 1; i >= 0; i-- {
+            sorted[i].mu.Unlock()
+        }
+    }
+}
+
+// Bank is a registry of accounts for portfolio-level reporting. The registry
+// has its own lock, and each account keeps its own lock for its balance.
+type Bank struct {
+    accounts map[string]*BankAccount
+    mu       sync.RWMutex
+}
+
+// NewBank creates an empty bank
+func NewBank() *Bank {
+    return &Bank{accounts: make(map[string]*BankAccount)}
+}
+
+// Register adds an account to the bank. It returns an AccountError if the
+// account is nil or another account with the same ID is registered.
+func (b *Bank) Register(account *BankAccount) error {
+    if account == nil {
+        return &AccountError{"", "register", "cannot register a nil account"}
+    }
+
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    if _, ok := b.accounts[account.ID]; ok {
+        return &AccountError{account.ID, "register", "account ID already registered"}
+    }
+    b.accounts[account.ID] = account
+    return nil
+}
+
+// AccountCount returns the number of registered accounts
+func (b *Bank) AccountCount() int {
+    b.mu.RLock()
+    defer b.mu.RUnlock()
+
+    return len(b.accounts)
+}
+
+// FindByOwner returns the owner's accounts ordered by ID
+func (b *Bank) FindByOwner(owner string) []*BankAccount {
+    b.mu.RLock()
+    defer b.mu.RUnlock()
+
+    var found []*BankAccount
+    for _, account := range b.accounts {
+        if account.Owner == owner {
+            found = append(found, account)
+        }
+    }
+    sort.Slice(found, func(i, j int) bool { return found[i].ID < found[j].ID })
+    return found
+}
+
+// TotalAssets returns the sum of every registered account's balance. All
+// accounts are locked at once, so the total is a consistent snapshot that
+// never counts a transfer between registered accounts half done.
+func (b *Bank) TotalAssets() float64 {
+    b.mu.RLock()
+    accounts := make([]*BankAccount, 0, len(b.accounts))
+    for _, account := range b.accounts {
+        accounts = append(accounts, account)
+    }
+    b.mu.RUnlock()
+
+    unlock := lockAccounts(accounts)
+    defer unlock()
+
+    total := 0.0
+    for _, account := range accounts {
+        total += account.Balance
+    }
+    return total
+}
@@ -0,0 +1,123 @@
+package challenge7
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func newTestBank(t *testing.T, n int, balance float64) (*Bank, []*BankAccount) {
+	t.Helper()
+	bank := NewBank()
+	accounts := make([]*BankAccount, n)
+	for i := range accounts {
+		account, err := NewBankAccount(fmt.Sprintf("ACC%02d", i), fmt.Sprintf("owner%d", i%3), balance, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := bank.Register(account); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		accounts[i] = account
+	}
+	return bank, accounts
+}
+
+func TestBankRegistry(t *testing.T) {
+	bank, accounts := newTestBank(t, 6, 100)
+
+	if got := bank.AccountCount(); got != 6 {
+		t.Errorf("expected 6 accounts, got %d", got)
+	}
+	if got := bank.TotalAssets(); got != 600 {
+		t.Errorf("expected total assets 600, got %f", got)
+	}
+
+	found := bank.FindByOwner("owner1")
+	if len(found) != 2 || found[0] != accounts[1] || found[1] != accounts[4] {
+		t.Errorf("expected ACC01 and ACC04, got %v", found)
+	}
+	if found := bank.FindByOwner("nobody"); len(found) != 0 {
+		t.Errorf("expected no accounts, got %v", found)
+	}
+
+	duplicate, _ := NewBankAccount("ACC00", "someone", 50, 0)
+	var accountErr *AccountError
+	if err := bank.Register(duplicate); !errors.As(err, &accountErr) {
+		t.Errorf("expected an AccountError for a duplicate ID, got %v", err)
+	}
+	if err := bank.Register(nil); !errors.As(err, &accountErr) {
+		t.Errorf("expected an AccountError for a nil account, got %v", err)
+	}
+	if got := bank.AccountCount(); got != 6 {
+		t.Errorf("expected 6 accounts after rejected registrations, got %d", got)
+	}
+}
+
+func TestBankConcurrentTransfersConserveAssets(t *testing.T) {
+	bank, accounts := newTestBank(t, 8, 1000)
+	const total = 8000.0
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+
+	// Readers check the total is never caught mid-transfer
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if got := bank.TotalAssets(); got != total {
+					t.Errorf("expected total assets %f, got %f", total, got)
+					return
+				}
+			}
+		}()
+	}
+
+	// Transfers in both directions between every pair, some of which fail
+	// for insufficient funds
+	var transfers sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		transfers.Add(1)
+		go func(w int) {
+			defer transfers.Done()
+			for i := 0; i < 500; i++ {
+				from := accounts[(w+i)%len(accounts)]
+				to := accounts[(w+2*i+1)%len(accounts)]
+				from.Transfer(float64(i%300), to)
+			}
+		}(w)
+	}
+	transfers.Wait()
+	close(done)
+	wg.Wait()
+
+	if got := bank.TotalAssets(); got != total {
+		t.Errorf("expected total assets %f after transfers, got %f", total, got)
+	}
+}
+
+func TestTransferInsufficientFundsReleasesLocks(t *testing.T) {
+	a, _ := NewBankAccount("A", "alice", 10, 0)
+	b, _ := NewBankAccount("B", "bob", 10, 0)
+
+	var fundsErr *InsufficientFundsError
+	if err := a.Transfer(50, b); !errors.As(err, &fundsErr) {
+		t.Fatalf("expected InsufficientFundsError, got %v", err)
+	}
+
+	// Both accounts are usable again after the failure
+	if err := b.Transfer(5, a); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.Balance != 15 || b.Balance != 5 {
+		t.Errorf("expected balances 15 and 5, got %f and %f", a.Balance, b.Balance)
+	}
+}
@@ -3,7 +3,10 @@ package challenge8
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Common errors that can be returned by the Chat Server
@@ -11,12 +14,66 @@ var (
 	ErrUsernameAlreadyTaken = errors.New("username already taken")
 	ErrRecipientNotFound    = errors.New("recipient not found")
 	ErrClientDisconnected   = errors.New("client disconnected")
+	ErrServerFull           = errors.New("server full")
+	ErrRecipientBusy        = errors.New("recipient busy")
 )
 
+// BroadcastRoom is the history room that broadcast messages are recorded in
+const BroadcastRoom = "broadcast"
+
+// maxRoomHistory is how many messages are kept per room, oldest dropped first
+const maxRoomHistory = 1000
+
+// Message is a chat message recorded in a room's history
+type Message struct {
+	Room   string
+	Sender string
+	Text   string
+	Time   time.Time
+	Seq    uint64 // set when recorded, orders messages that share a Time
+}
+
+// HistoryCursor is a position in a room's history, which is ordered by Time
+// and then by Seq. A cursor with only Time set sits before every message
+// sent at that time, since sequence numbers start at one.
+type HistoryCursor struct {
+	Time time.Time
+	Seq  uint64
+}
+
+// Cursor returns the position of m, for paging further back from it
+func (m Message) Cursor() HistoryCursor {
+	return HistoryCursor{Time: m.Time, Seq: m.Seq}
+}
+
+// isBefore reports whether m comes before the cursor in history order
+func (m Message) isBefore(c HistoryCursor) bool {
+	return m.Time.Before(c.Time) || m.Time.Equal(c.Time) && m.Seq < c.Seq
+}
+
+// envelope wraps a message queued for a client. The ack channel is set only
+// for private messages and is closed once the recipient receives the message.
+type envelope struct {
+	id   uint64
+	text string
+	ack  chan struct{}
+}
+
+// Delivery tracks the delivery of a private message
+type Delivery struct {
+	ID   uint64
+	done chan struct{}
+}
+
+// Done returns a channel closed once the recipient has received the message
+func (d *Delivery) Done() <-chan struct{} {
+	return d.done
+}
+
 // Client represents a connected chat client
 type Client struct {
 	username     string
-	incoming     chan string
+	incoming     chan envelope
 	outgoing     chan string
 	disconnect   chan struct{}
 	disconnected bool
@@ -25,36 +82,58 @@ type Client struct {
 
 // Send sends a message to the client (non-blocking)
 func (c *Client) Send(message string) {
-	if c.disconnected {
-		return
-	}
+	c.deliver(envelope{text: message})
+}
 
+// deliver queues an envelope for the client, reporting whether it was queued.
+// The disconnected check and the send happen under the read lock, and
+// do_disconnect closes incoming under the write lock, so a send can never
+// hit a closed channel. A full buffer drops the message rather than blocking.
+func (c *Client) deliver(env envelope) bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	if c.disconnected {
+		return false
+	}
+
 	select {
-	case c.incoming <- message:
+	case c.incoming <- env:
+		return true
 	default:
 		// Do not block
+		return false
 	}
 }
 
 // Receive returns the next message for the client (blocking)
 func (c *Client) Receive() string {
-	if msg, ok := <-c.incoming; ok {
-		return msg
+	if env, ok := <-c.incoming; ok {
+		if env.ack != nil {
+			close(env.ack)
+		}
+		return env.text
 	}
 	return ""
 }
 
+// isDisconnected reports whether the client has been disconnected
+func (c *Client) isDisconnected() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.disconnected
+}
+
+// do_disconnect closes the client's channels, it is safe to call more than once
 func (c *Client) do_disconnect() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.disconnected {
 		return
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	close(c.incoming)
 	close(c.disconnect)
 	c.disconnected = true
@@ -62,13 +141,29 @@ func (c *Client) do_disconnect() {
 
 // ChatServer manages client connections and message routing
 type ChatServer struct {
-	clients map[string]*Client
-	mu      sync.RWMutex
+	clients    map[string]*Client
+	maxClients int // 0 means unlimited
+	mu         sync.RWMutex
+	lastID     atomic.Uint64
+
+	history    map[string][]Message // per room, oldest first
+	historySeq uint64               // last sequence number given to a message
+	historyMu  sync.RWMutex
 }
 
 // NewChatServer creates a new chat server instance
 func NewChatServer() *ChatServer {
-	return &ChatServer{clients: make(map[string]*Client)}
+	return NewChatServerWithCapacity(0)
+}
+
+// NewChatServerWithCapacity creates a chat server accepting at most
+// maxClients connected clients (0 means unlimited)
+func NewChatServerWithCapacity(maxClients int) *ChatServer {
+	return &ChatServer{
+		clients:    make(map[string]*Client),
+		maxClients: maxClients,
+		history:    make(map[string][]Message),
+	}
 }
 
 // Connect adds a new client to the chat server
@@ -79,10 +174,13 @@ func (s *ChatServer) Connect(username string) (*Client, error) {
 	if _, ok := s.clients[username]; ok {
 		return nil, ErrUsernameAlreadyTaken
 	}
+	if s.maxClients > 0 && len(s.clients) >= s.maxClients {
+		return nil, ErrServerFull
+	}
 
 	client := &Client{
 		username:   username,
-		incoming:   make(chan string, 100),
+		incoming:   make(chan envelope, 100),
 		outgoing:   make(chan string, 100),
 		disconnect: make(chan struct{}),
 	}
@@ -93,17 +191,16 @@ func (s *ChatServer) Connect(username string) (*Client, error) {
 	return client, nil
 }
 
-// Disconnect removes a client from the chat server
+// Disconnect removes a client from the chat server. It is idempotent, and a
+// stale client never removes a newer client that reused its username.
 func (s *ChatServer) Disconnect(client *Client) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, ok := s.clients[client.username]; ! ok {
-		return
-	}
-
 	client.do_disconnect()
-	delete(s.clients, client.username)
+	if s.clients[client.username] == client {
+		delete(s.clients, client.username)
+	}
 }
 
 // Broadcast sends a message to all connected clients
@@ -111,6 +208,8 @@ func (s *ChatServer) Broadcast(sender *Client, message string) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	s.record(Message{Room: BroadcastRoom, Sender: sender.username, Text: message, Time: time.Now()})
+
 	msg := fmt.Sprintf("%s: %s", sender.username, message)
 	for _, client := range(s.clients) {
 		if client.username != sender.username {
@@ -121,8 +220,17 @@ func (s *ChatServer) Broadcast(sender *Client, message string) {
 
 // PrivateMessage sends a message to a specific client
 func (s *ChatServer) PrivateMessage(sender *Client, recipient string, message string) error {
-	if sender.disconnected {
-		return ErrClientDisconnected
+	_, err := s.PrivateMessageWithAck(sender, recipient, message)
+	return err
+}
+
+// PrivateMessageWithAck sends a message to a specific client and returns a
+// Delivery whose Done channel is closed once the recipient receives it.
+// If the recipient's buffer is full the message is dropped and
+// ErrRecipientBusy is returned, so no caller waits on an ack that never comes.
+func (s *ChatServer) PrivateMessageWithAck(sender *Client, recipient string, message string) (*Delivery, error) {
+	if sender.isDisconnected() {
+		return nil, ErrClientDisconnected
 	}
 
 	s.mu.RLock()
@@ -130,15 +238,73 @@ func (s *ChatServer) PrivateMessage(sender *Client, recipient string, message st
 
 	target, ok := s.clients[recipient]
 	if ! ok {
-		return ErrRecipientNotFound
+		return nil, ErrRecipientNotFound
 	}
-	if target.disconnected {
-		return ErrClientDisconnected
+	if target.isDisconnected() {
+		return nil, ErrClientDisconnected
 	}
 
+	delivery := &Delivery{ID: s.lastID.Add(1), done: make(chan struct{})}
 	msg := fmt.Sprintf("(pm) %s: %s", sender.username, message)
-	target.Send(msg)
-	return nil
+	if !target.deliver(envelope{id: delivery.ID, text: msg, ack: delivery.done}) {
+		// deliver also fails if the recipient disconnected after the check above
+		if target.isDisconnected() {
+			return nil, ErrClientDisconnected
+		}
+		return nil, ErrRecipientBusy
+	}
+	return delivery, nil
+}
+
+// record numbers a message and adds it to its room's history, keeping the
+// history in (Time, Seq) order and at most maxRoomHistory messages long
+func (s *ChatServer) record(msg Message) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	s.historySeq++
+	msg.Seq = s.historySeq
+
+	msgs := s.history[msg.Room]
+	// Insert after any message with the same or an earlier time
+	i := sort.Search(len(msgs), func(i int) bool { return msgs[i].Time.After(msg.Time) })
+	msgs = append(msgs, Message{})
+	copy(msgs[i+1:], msgs[i:])
+	msgs[i] = msg
+
+	if len(msgs) > maxRoomHistory {
+		msgs = append([]Message(nil), msgs[len(msgs)-maxRoomHistory:]...)
+	}
+	s.history[msg.Room] = msgs
+}
+
+// History returns up to limit messages of the room that come before the
+// cursor, newest first. For the first page pass HistoryCursor{Time: t} to get
+// messages sent strictly before t; to scroll back, pass the Cursor of the
+// oldest message from the previous page, which pages correctly through
+// messages sharing a timestamp. An unknown room or a limit below one returns
+// an empty slice.
+func (s *ChatServer) History(room string, before HistoryCursor, limit int) []Message {
+	s.historyMu.RLock()
+	defer s.historyMu.RUnlock()
+
+	msgs := s.history[room]
+	if limit <= 0 {
+		return []Message{}
+	}
+
+	// msgs[:end] are the messages before the cursor
+	end := sort.Search(len(msgs), func(i int) bool { return !msgs[i].isBefore(before) })
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+
+	page := make([]Message, 0, end-start)
+	for i := end - 1; i >= start; i-- {
+		page = append(page, msgs[i])
+	}
+	return page
 }
 
 // handleClient processes outgoing messages and disconnection for a client
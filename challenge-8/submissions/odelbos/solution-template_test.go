@@ -0,0 +1,333 @@
+package challenge8
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrivateMessageAck(t *testing.T) {
+	server := NewChatServer()
+	alice, _ := server.Connect("alice")
+	bob, _ := server.Connect("bob")
+
+	delivery, err := server.PrivateMessageWithAck(alice, "bob", "hello")
+	if err != nil {
+		t.Fatalf("PrivateMessageWithAck failed: %v", err)
+	}
+
+	select {
+	case <-delivery.Done():
+		t.Fatal("Message acknowledged before the recipient received it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if msg := bob.Receive(); msg != "(pm) alice: hello" {
+		t.Errorf("Unexpected message: %q", msg)
+	}
+
+	select {
+	case <-delivery.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Message not acknowledged after the recipient received it")
+	}
+}
+
+func TestPrivateMessageAckIDs(t *testing.T) {
+	server := NewChatServer()
+	alice, _ := server.Connect("alice")
+	server.Connect("bob")
+
+	first, _ := server.PrivateMessageWithAck(alice, "bob", "one")
+	second, _ := server.PrivateMessageWithAck(alice, "bob", "two")
+	if first.ID == second.ID {
+		t.Errorf("Expected distinct delivery IDs, got %d twice", first.ID)
+	}
+
+	if _, err := server.PrivateMessageWithAck(alice, "carol", "hi"); err != ErrRecipientNotFound {
+		t.Errorf("Expected ErrRecipientNotFound but got: %v", err)
+	}
+}
+
+func TestPrivateMessageRecipientBusy(t *testing.T) {
+	server := NewChatServer()
+	alice, _ := server.Connect("alice")
+	bob, _ := server.Connect("bob")
+
+	// fill bob's buffer without receiving anything
+	for i := 0; i < cap(bob.incoming); i++ {
+		if _, err := server.PrivateMessageWithAck(alice, "bob", fmt.Sprint(i)); err != nil {
+			t.Fatalf("message %d: unexpected error %v", i, err)
+		}
+	}
+
+	delivery, err := server.PrivateMessageWithAck(alice, "bob", "one too many")
+	if err != ErrRecipientBusy {
+		t.Fatalf("Expected ErrRecipientBusy but got: %v", err)
+	}
+	if delivery != nil {
+		t.Error("Expected no Delivery for a dropped message")
+	}
+	if err := server.PrivateMessage(alice, "bob", "still full"); err != ErrRecipientBusy {
+		t.Errorf("Expected ErrRecipientBusy from PrivateMessage but got: %v", err)
+	}
+
+	// once bob reads a message there is room again
+	bob.Receive()
+	if _, err := server.PrivateMessageWithAck(alice, "bob", "fits"); err != nil {
+		t.Errorf("Expected delivery after the buffer drained, got: %v", err)
+	}
+}
+
+func TestMaxClients(t *testing.T) {
+	server := NewChatServerWithCapacity(2)
+
+	alice, err := server.Connect("alice")
+	if err != nil {
+		t.Fatalf("Failed to connect alice: %v", err)
+	}
+	if _, err := server.Connect("bob"); err != nil {
+		t.Fatalf("Failed to connect bob: %v", err)
+	}
+
+	if _, err := server.Connect("carol"); err != ErrServerFull {
+		t.Errorf("Expected ErrServerFull but got: %v", err)
+	}
+
+	server.Disconnect(alice)
+	if _, err := server.Connect("carol"); err != nil {
+		t.Errorf("Failed to connect after a disconnect: %v", err)
+	}
+}
+
+func TestUnlimitedClients(t *testing.T) {
+	server := NewChatServer()
+	for i := 0; i < 200; i++ {
+		if _, err := server.Connect(fmt.Sprintf("user%d", i)); err != nil {
+			t.Fatalf("Unexpected error on connect %d: %v", i, err)
+		}
+	}
+}
+
+func TestBroadcastDuringDisconnect(t *testing.T) {
+	for round := 0; round < 10; round++ {
+		server := NewChatServer()
+		sender, _ := server.Connect("sender")
+		leaving, _ := server.Connect("leaving")
+		staying, _ := server.Connect("staying")
+
+		// Broadcast, and send to the leaving client directly, until the disconnects have finished
+		stop := make(chan struct{})
+		var broadcasters sync.WaitGroup
+		broadcasters.Add(1)
+		go func() {
+			defer broadcasters.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					leaving.Send("direct")
+				}
+			}
+		}()
+		for i := 0; i < 4; i++ {
+			broadcasters.Add(1)
+			go func(i int) {
+				defer broadcasters.Done()
+				for j := 0; ; j++ {
+					select {
+					case <-stop:
+						return
+					default:
+						server.Broadcast(sender, fmt.Sprintf("msg %d-%d", i, j))
+					}
+				}
+			}(i)
+		}
+
+		// Drain the leaving client until its channel is closed
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for leaving.Receive() != "" {
+			}
+		}()
+
+		// Wait until broadcasts are flowing, then disconnect twice concurrently
+		staying.Receive()
+		wg.Add(2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				defer wg.Done()
+				server.Disconnect(leaving)
+			}()
+		}
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(stop)
+			broadcasters.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Broadcast or Disconnect deadlocked")
+		}
+
+		if err := server.PrivateMessage(sender, "leaving", "hi"); err != ErrRecipientNotFound {
+			t.Errorf("Expected ErrRecipientNotFound after disconnect but got: %v", err)
+		}
+	}
+}
+
+func TestDisconnectStaleClient(t *testing.T) {
+	server := NewChatServer()
+	old, _ := server.Connect("alice")
+	server.Disconnect(old)
+
+	current, err := server.Connect("alice")
+	if err != nil {
+		t.Fatalf("Failed to reconnect: %v", err)
+	}
+
+	// Disconnecting the old client again must not remove the new one
+	server.Disconnect(old)
+	bob, _ := server.Connect("bob")
+	if err := server.PrivateMessage(bob, "alice", "still there?"); err != nil {
+		t.Fatalf("Expected alice to still be connected, got: %v", err)
+	}
+	if msg := current.Receive(); msg != "(pm) bob: still there?" {
+		t.Errorf("Unexpected message: %q", msg)
+	}
+}
+
+func TestHistoryPaging(t *testing.T) {
+	server := NewChatServer()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 25; i++ {
+		server.record(Message{Room: "general", Sender: "alice", Text: fmt.Sprintf("msg %d", i), Time: base.Add(time.Duration(i) * time.Second)})
+	}
+	server.record(Message{Room: "other", Sender: "bob", Text: "elsewhere", Time: base})
+
+	// Everything before the last message, newest first
+	first := server.History("general", HistoryCursor{Time: base.Add(20*time.Second)}, 10)
+	if len(first) != 10 {
+		t.Fatalf("expected 10 messages, got %d", len(first))
+	}
+	for i, msg := range first {
+		if want := fmt.Sprintf("msg %d", 19-i); msg.Text != want {
+			t.Errorf("first page %d: expected %q, got %q", i, want, msg.Text)
+		}
+	}
+
+	// Scroll back from the oldest message of the first page
+	second := server.History("general", first[len(first)-1].Cursor(), 10)
+	if len(second) != 10 {
+		t.Fatalf("expected 10 messages, got %d", len(second))
+	}
+	for i, msg := range second {
+		if want := fmt.Sprintf("msg %d", 9-i); msg.Text != want {
+			t.Errorf("second page %d: expected %q, got %q", i, want, msg.Text)
+		}
+	}
+
+	// Past the start of the history there is nothing left
+	if rest := server.History("general", second[len(second)-1].Cursor(), 10); len(rest) != 0 {
+		t.Errorf("expected no older messages, got %d", len(rest))
+	}
+}
+
+func TestHistoryPagingSameTimestamp(t *testing.T) {
+	server := NewChatServer()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	server.record(Message{Room: "general", Text: "earlier", Time: base.Add(-time.Second)})
+	for i := 0; i < 5; i++ {
+		server.record(Message{Room: "general", Text: fmt.Sprintf("msg %d", i), Time: base})
+	}
+
+	// A page boundary falls between messages sharing a timestamp
+	var got []string
+	cursor := HistoryCursor{Time: base.Add(time.Second)}
+	for {
+		page := server.History("general", cursor, 2)
+		if len(page) == 0 {
+			break
+		}
+		for _, msg := range page {
+			got = append(got, msg.Text)
+		}
+		cursor = page[len(page)-1].Cursor()
+	}
+
+	want := []string{"msg 4", "msg 3", "msg 2", "msg 1", "msg 0", "earlier"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// A time-only cursor still excludes every message sent at that time
+	if page := server.History("general", HistoryCursor{Time: base}, 10); len(page) != 1 || page[0].Text != "earlier" {
+		t.Errorf("expected only the earlier message, got %v", page)
+	}
+}
+
+func TestHistoryEdgeCases(t *testing.T) {
+	server := NewChatServer()
+	base := time.Now()
+	for i := 0; i < 5; i++ {
+		server.record(Message{Room: "general", Text: fmt.Sprintf("msg %d", i), Time: base.Add(time.Duration(i) * time.Millisecond)})
+	}
+
+	if got := server.History("unknown", HistoryCursor{Time: time.Now()}, 10); got == nil || len(got) != 0 {
+		t.Errorf("expected an empty slice for an unknown room, got %v", got)
+	}
+	if got := server.History("general", HistoryCursor{Time: time.Now()}, 0); len(got) != 0 {
+		t.Errorf("expected no messages for a zero limit, got %d", len(got))
+	}
+
+	// A cutoff in the future returns the most recent messages
+	got := server.History("general", HistoryCursor{Time: base.Add(time.Hour)}, 3)
+	if len(got) != 3 || got[0].Text != "msg 4" || got[2].Text != "msg 2" {
+		t.Errorf("expected the three newest messages, got %v", got)
+	}
+}
+
+func TestBroadcastRecordsHistory(t *testing.T) {
+	server := NewChatServer()
+	alice, _ := server.Connect("alice")
+	bob, _ := server.Connect("bob")
+	defer server.Disconnect(alice)
+	defer server.Disconnect(bob)
+
+	server.Broadcast(alice, "hello")
+	server.Broadcast(bob, "hi alice")
+
+	got := server.History(BroadcastRoom, HistoryCursor{Time: time.Now().Add(time.Second)}, 10)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(got))
+	}
+	if got[0].Sender != "bob" || got[0].Text != "hi alice" || got[1].Sender != "alice" {
+		t.Errorf("expected newest first, got %v", got)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	server := NewChatServer()
+	base := time.Now()
+	for i := 0; i < maxRoomHistory+10; i++ {
+		server.record(Message{Room: "general", Text: fmt.Sprintf("msg %d", i), Time: base.Add(time.Duration(i) * time.Millisecond)})
+	}
+
+	got := server.History("general", HistoryCursor{Time: base.Add(time.Hour)}, maxRoomHistory+10)
+	if len(got) != maxRoomHistory {
+		t.Fatalf("expected %d messages, got %d", maxRoomHistory, len(got))
+	}
+	if got[len(got)-1].Text != "msg 10" {
+		t.Errorf("expected the oldest messages to be dropped, oldest kept is %q", got[len(got)-1].Text)
+	}
+}
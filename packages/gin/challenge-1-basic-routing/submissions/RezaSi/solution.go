@@ -1,31 +1,90 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
 	"errors"
+	"fmt"
+	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 // User represents a user in our system
 type User struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	Age   int    `json:"age"`
+	ID    int    `json:"id" xml:"id"`
+	Name  string `json:"name" xml:"name"`
+	Email string `json:"email" xml:"email"`
+	Age   int    `json:"age" xml:"age"`
+	// DeletedAt is set when the user is soft-deleted, and is ignored in request bodies
+	DeletedAt *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty"`
 }
 
 // Response represents a standard API response
 type Response struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Message string      `json:"message,omitempty"`
-	Error   string      `json:"error,omitempty"`
-	Code    int         `json:"code,omitempty"`
+	XMLName xml.Name    `json:"-" xml:"response"`
+	Success bool        `json:"success" xml:"success"`
+	Data    interface{} `json:"data,omitempty" xml:"data,omitempty"`
+	Message string      `json:"message,omitempty" xml:"message,omitempty"`
+	Error   string      `json:"error,omitempty" xml:"error,omitempty"`
+	Code    int         `json:"code,omitempty" xml:"code,omitempty"`
+	Meta    *PageMeta   `json:"meta,omitempty" xml:"meta,omitempty"`
+	Errors  FieldErrors `json:"errors,omitempty" xml:"errors>error,omitempty"`
 }
 
+// FieldError describes why one field of a request body was rejected.
+// Field is the JSON name, or "body" when the body couldn't be decoded.
+type FieldError struct {
+	Field   string `json:"field" xml:"field"`
+	Message string `json:"message" xml:"message"`
+}
+
+// FieldErrors is every field error found in a request body
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// PageMeta describes the page returned by a paginated list
+type PageMeta struct {
+	Total      int `json:"total" xml:"total"`
+	Page       int `json:"page" xml:"page"`
+	Limit      int `json:"limit" xml:"limit"`
+	TotalPages int `json:"total_pages" xml:"total_pages"`
+}
+
+// Allowed range for User.Age, inclusive
+const (
+	minAge = 1
+	maxAge = 150
+)
+
+// Pagination defaults for list endpoints
+const (
+	defaultPage  = 1
+	defaultLimit = 20
+)
+
 // In-memory storage
 var users = []User{
 	{ID: 1, Name: "John Doe", Email: "john@example.com", Age: 30},
@@ -34,37 +93,393 @@ var users = []User{
 }
 var nextID = 4
 
+// usersMutex guards users and nextID
+var usersMutex sync.RWMutex
+
 func main() {
 	// Create Gin router
 	router := gin.Default()
 
 	// Setup routes
-	router.GET("/users/search", searchUsers) // Specific route first
-	router.GET("/users", getAllUsers)
-	router.GET("/users/:id", getUserByID)
-	router.POST("/users", createUser)
-	router.PUT("/users/:id", updateUser)
-	router.DELETE("/users/:id", deleteUser)
+	registerRoutes(router)
 
 	// Start server on port 8080
-	router.Run(":8080")
+	runServer(router, ":8080")
+}
+
+// shutdownTimeout is how long in-flight requests get to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
+// runServer serves r on addr until SIGINT or SIGTERM, then shuts down
+// gracefully so in-flight requests aren't cut off
+func runServer(r *gin.Engine, addr string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("Failed to start server: ", err)
+	}
+	if err := serveUntil(ctx, &http.Server{Handler: r}, listener, shutdownTimeout); err != nil {
+		log.Fatal("Server failed: ", err)
+	}
+}
+
+// serveUntil serves on listener until ctx is done, then stops accepting
+// connections and gives in-flight requests up to timeout to finish before
+// closing them. It returns an error only if serving fails before shutdown.
+func serveUntil(ctx context.Context, server *http.Server, listener net.Listener, timeout time.Duration) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Server shutdown timed out, closing remaining connections: ", err)
+		server.Close()
+	} else {
+		log.Println("Server shutdown complete")
+	}
+	<-served
+	return nil
+}
+
+// route is a single method and path handled by the API
+type route struct {
+	method  string
+	path    string
+	handler gin.HandlerFunc
+}
+
+// routes lists every resource route the API serves
+var routes = []route{
+	{http.MethodGet, "/users/search", searchUsers}, // Specific route first
+	{http.MethodGet, "/users/export", exportUsers},
+	{http.MethodGet, "/users", getAllUsers},
+	{http.MethodGet, "/users/:id", getUserByID},
+	{http.MethodPost, "/users", createUser},
+	{http.MethodPost, "/users/batch", createUsersBatch},
+	{http.MethodPut, "/users/:id", updateUser},
+	{http.MethodPut, "/users/by-email/:email", upsertUserByEmail},
+	{http.MethodDelete, "/users/:id", deleteUser},
+	{http.MethodPost, "/users/:id/restore", restoreUser},
+}
+
+// registerProbes registers the load balancer health and readiness checks.
+// They sit outside routes so they skip Accept negotiation and any
+// middleware later added to the resource routes, such as auth.
+func registerProbes(router *gin.Engine) {
+	router.GET("/healthz", healthz)
+	router.HEAD("/healthz", healthz)
+	router.GET("/readyz", readyz)
+	router.HEAD("/readyz", readyz)
+}
+
+// healthz handles GET /healthz, answering 200 while the process is serving
+func healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// readyz handles GET /readyz, answering 503 until the user store is initialized
+func readyz(c *gin.Context) {
+	usersMutex.RLock()
+	ready := users != nil
+	usersMutex.RUnlock()
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+// acceptChecks replaces requireAcceptable for routes that write their own
+// format rather than the JSON or XML negotiated by respond
+var acceptChecks = map[string]gin.HandlerFunc{
+	"/users/export": requireCSVAcceptable,
+}
+
+// registerRoutes registers routes, plus HEAD for every GET route and
+// OPTIONS for every path advertising its methods in the Allow header
+func registerRoutes(router *gin.Engine) {
+	registerProbes(router)
+
+	allowed := make(map[string][]string)
+	var paths []string
+
+	for _, r := range routes {
+		if _, seen := allowed[r.path]; !seen {
+			paths = append(paths, r.path)
+		}
+		acceptable := requireAcceptable
+		if check, ok := acceptChecks[r.path]; ok {
+			acceptable = check
+		}
+		router.Handle(r.method, r.path, acceptable, r.handler)
+		allowed[r.path] = append(allowed[r.path], r.method)
+
+		if r.method == http.MethodGet {
+			router.HEAD(r.path, acceptable, headHandler(r.handler))
+			allowed[r.path] = append(allowed[r.path], http.MethodHead)
+		}
+	}
+
+	for _, path := range paths {
+		methods := append(allowed[path], http.MethodOptions)
+		router.OPTIONS(path, optionsHandler(methods))
+	}
+}
+
+// Response formats that can be negotiated with the Accept header
+const (
+	formatJSON = "json"
+	formatXML  = "xml"
+)
+
+// mediaTypeFormat maps a media type from an Accept header to a response
+// format, or "" if it can't be produced. text/* isn't treated as XML, since
+// a client asking for it may only handle text/plain or text/html.
+func mediaTypeFormat(mediaType string) string {
+	switch mediaType {
+	case "application/json", "application/*", "*/*":
+		return formatJSON
+	case "application/xml", "text/xml":
+		return formatXML
+	}
+	return ""
+}
+
+// parseMediaRange splits a media range from an Accept header into its
+// lowercased media type and q value, which defaults to 1
+func parseMediaRange(mediaRange string) (mediaType string, q float64) {
+	params := strings.Split(mediaRange, ";")
+	q = 1.0
+	for _, param := range params[1:] {
+		name, value, found := strings.Cut(param, "=")
+		if found && strings.TrimSpace(name) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(params[0])), q
+}
+
+// negotiateFormat picks the response format for an Accept header. The
+// supported media range with the highest q value wins, the first listed on
+// a tie; an empty header gets JSON. ok is false when nothing requested can
+// be produced.
+func negotiateFormat(accept string) (format string, ok bool) {
+	if strings.TrimSpace(accept) == "" {
+		return formatJSON, true
+	}
+
+	bestQ := 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, q := parseMediaRange(mediaRange)
+		candidate := mediaTypeFormat(mediaType)
+		if candidate == "" {
+			continue
+		}
+		if q > bestQ {
+			format, bestQ = candidate, q
+		}
+	}
+	return format, format != ""
+}
+
+// notAcceptable answers 406 in JSON, since no requested format is available
+func notAcceptable(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusNotAcceptable, Response{
+		Success: false,
+		Error:   "Unsupported response format, supported: application/json, application/xml",
+		Code:    http.StatusNotAcceptable,
+	})
+}
+
+// respond writes payload as JSON or XML depending on the Accept header
+func respond(c *gin.Context, status int, payload interface{}) {
+	format, ok := negotiateFormat(c.GetHeader("Accept"))
+	if !ok {
+		notAcceptable(c)
+		return
+	}
+	if format == formatXML {
+		c.XML(status, payload)
+		return
+	}
+	c.JSON(status, payload)
+}
+
+// requireAcceptable rejects a request before its handler runs when the
+// response format can't be negotiated, so writes aren't applied and then
+// answered with 406
+func requireAcceptable(c *gin.Context) {
+	if _, ok := negotiateFormat(c.GetHeader("Accept")); !ok {
+		notAcceptable(c)
+	}
+}
+
+// requireCSVAcceptable rejects a request whose Accept header rules out CSV
+func requireCSVAcceptable(c *gin.Context) {
+	accept := strings.TrimSpace(c.GetHeader("Accept"))
+	if accept == "" {
+		return
+	}
+	for _, mediaRange := range strings.Split(accept, ",") {
+		switch mediaType, q := parseMediaRange(mediaRange); mediaType {
+		case "text/csv", "text/*", "*/*":
+			if q > 0 {
+				return
+			}
+		}
+	}
+	c.AbortWithStatusJSON(http.StatusNotAcceptable, Response{
+		Success: false,
+		Error:   "Unsupported response format, supported: text/csv",
+		Code:    http.StatusNotAcceptable,
+	})
+}
+
+// bodylessWriter discards the response body but keeps the status and headers
+type bodylessWriter struct {
+	gin.ResponseWriter
+}
+
+func (w *bodylessWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w *bodylessWriter) WriteString(s string) (int, error) {
+	return len(s), nil
+}
+
+// headHandler answers HEAD by running the GET handler without sending its body
+func headHandler(get gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer = &bodylessWriter{ResponseWriter: c.Writer}
+		get(c)
+	}
+}
+
+// optionsHandler answers OPTIONS with the methods allowed on the path
+func optionsHandler(methods []string) gin.HandlerFunc {
+	allow := strings.Join(methods, ", ")
+	return func(c *gin.Context) {
+		c.Header("Allow", allow)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// positiveQuery returns the query parameter as an int, or def when it is
+// missing, not a number or below 1
+func positiveQuery(c *gin.Context, name string, def int) int {
+	n, err := strconv.Atoi(c.Query(name))
+	if err != nil || n < 1 {
+		return def
+	}
+	return n
+}
+
+// paginateUsers returns a copy of one page of list and its metadata. A page
+// past the end is empty rather than nil.
+func paginateUsers(list []User, page, limit int) ([]User, PageMeta) {
+	total := len(list)
+	meta := PageMeta{Total: total, Page: page, Limit: limit, TotalPages: total / limit}
+	if total%limit != 0 {
+		meta.TotalPages++
+	}
+
+	// Compared page by page so a huge page or limit can't overflow
+	start, end := total, total
+	if page-1 < meta.TotalPages {
+		start = (page - 1) * limit
+		if total-start > limit {
+			end = start + limit
+		}
+	}
+
+	return append([]User{}, list[start:end]...), meta
+}
+
+// userSortKeys maps each key accepted by ?sort= to its ascending order
+var userSortKeys = map[string]func(a, b User) bool{
+	"name": func(a, b User) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) },
+	"age":  func(a, b User) bool { return a.Age < b.Age },
+}
+
+// parseUserSort parses a sort key such as "age" or "-name", where a leading
+// "-" means descending, into an ordering function
+func parseUserSort(key string) (func(a, b User) bool, error) {
+	desc := strings.HasPrefix(key, "-")
+	less, ok := userSortKeys[strings.TrimPrefix(key, "-")]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort key %q, supported: name, -name, age, -age", key)
+	}
+	if desc {
+		return func(a, b User) bool { return less(b, a) }, nil
+	}
+	return less, nil
 }
 
-// getAllUsers handles GET /users
+// sortedUsers returns a copy of list in the given order, leaving list
+// untouched. Users that compare equal keep their stored order.
+func sortedUsers(list []User, less func(a, b User) bool) []User {
+	sorted := append([]User(nil), list...)
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	return sorted
+}
+
+// getAllUsers handles GET /users?page=&limit=&sort=&include_deleted=
 func getAllUsers(c *gin.Context) {
-	c.JSON(http.StatusOK, Response{
+	page := positiveQuery(c, "page", defaultPage)
+	limit := positiveQuery(c, "limit", defaultLimit)
+
+	var less func(a, b User) bool
+	if key := c.Query("sort"); key != "" {
+		var err error
+		if less, err = parseUserSort(key); err != nil {
+			respond(c, http.StatusBadRequest, Response{
+				Success: false,
+				Error:   err.Error(),
+				Code:    http.StatusBadRequest,
+			})
+			return
+		}
+	}
+
+	usersMutex.RLock()
+	list := visibleUsers(includeDeleted(c))
+	if less != nil {
+		list = sortedUsers(list, less)
+	}
+	pageUsers, meta := paginateUsers(list, page, limit)
+	usersMutex.RUnlock()
+
+	respond(c, http.StatusOK, Response{
 		Success: true,
-		Data:    users,
+		Data:    pageUsers,
 		Message: "Users retrieved successfully",
+		Meta:    &meta,
 	})
 }
 
-// getUserByID handles GET /users/:id
+// getUserByID handles GET /users/:id, answering 304 when If-None-Match
+// holds the user's current ETag
 func getUserByID(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
+		respond(c, http.StatusBadRequest, Response{
 			Success: false,
 			Error:   "Invalid ID format",
 			Code:    http.StatusBadRequest,
@@ -72,9 +487,14 @@ func getUserByID(c *gin.Context) {
 		return
 	}
 
-	user, _ := findUserByID(id)
+	usersMutex.RLock()
+	user, _ := findActiveUserByID(id)
+	if includeDeleted(c) {
+		user, _ = findUserByID(id)
+	}
+	usersMutex.RUnlock()
 	if user == nil {
-		c.JSON(http.StatusNotFound, Response{
+		respond(c, http.StatusNotFound, Response{
 			Success: false,
 			Error:   "User not found",
 			Code:    http.StatusNotFound,
@@ -82,53 +502,159 @@ func getUserByID(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, Response{
+	etag := userETag(*user)
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	respond(c, http.StatusOK, Response{
 		Success: true,
 		Data:    user,
 		Message: "User retrieved successfully",
 	})
 }
 
+// userETag returns a weak ETag hashed from every field of the user, so it
+// changes whenever the user is updated, deleted or restored
+func userETag(user User) string {
+	data, _ := json.Marshal(user)
+	sum := sha256.Sum256(data)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches the ETag,
+// using the weak comparison that ignores the W/ prefix
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
 // createUser handles POST /users
 func createUser(c *gin.Context) {
 	var newUser User
 	if err := c.ShouldBindJSON(&newUser); err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   err.Error(),
-			Code:    http.StatusBadRequest,
-		})
+		invalidUser(c, bindFieldErrors(err))
 		return
 	}
 
 	// Validate user data
 	if err := validateUser(newUser); err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   err.Error(),
-			Code:    http.StatusBadRequest,
-		})
+		invalidUser(c, err)
 		return
 	}
 
-	// Assign ID and add to storage
-	newUser.ID = nextID
-	nextID++
+	// Check the email and add the user under one lock so two creates
+	// can't both claim it
+	usersMutex.Lock()
+	if _, index := findUserByEmail(newUser.Email); index != -1 {
+		usersMutex.Unlock()
+		emailConflict(c)
+		return
+	}
+	newUser.ID = takeNextID()
+	newUser.DeletedAt = nil
 	users = append(users, newUser)
+	usersMutex.Unlock()
 
-	c.JSON(http.StatusCreated, Response{
+	c.Header("Location", userLocation(newUser.ID))
+	respond(c, http.StatusCreated, Response{
 		Success: true,
 		Data:    newUser,
 		Message: "User created successfully",
 	})
 }
 
+// maxBatchSize is the most users POST /users/batch accepts in one request
+const maxBatchSize = 100
+
+// BatchResult is the outcome of one record of a batch create. Status is the
+// code the record would have got from POST /users on its own.
+type BatchResult struct {
+	Index  int    `json:"index" xml:"index"`
+	Status int    `json:"status" xml:"status"`
+	User   *User  `json:"user,omitempty" xml:"user,omitempty"`
+	Error  string `json:"error,omitempty" xml:"error,omitempty"`
+}
+
+// createUsersBatch handles POST /users/batch. Each record is validated on
+// its own and the valid ones are added together with sequential IDs, so a
+// bad record is reported without dropping the rest. Responds 201 if any
+// user was created and 400 if none were, with a result per record.
+func createUsersBatch(c *gin.Context) {
+	var batch []User
+	if err := c.ShouldBindJSON(&batch); err != nil {
+		invalidUser(c, bindFieldErrors(err))
+		return
+	}
+	if len(batch) == 0 || len(batch) > maxBatchSize {
+		respond(c, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   fmt.Sprintf("batch must contain between 1 and %d users", maxBatchSize),
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	results := make([]BatchResult, len(batch))
+	created := 0
+
+	// Check emails and add the users under one lock, so the batch lands
+	// all at once and can't race a create for the same email
+	usersMutex.Lock()
+	claimed := make(map[string]bool, len(batch))
+	for i, user := range batch {
+		results[i] = BatchResult{Index: i, Status: http.StatusBadRequest}
+		if err := validateUser(user); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		email := normalizeEmail(user.Email)
+		if _, index := findUserByEmail(email); index != -1 || claimed[email] {
+			results[i].Status = http.StatusConflict
+			results[i].Error = "Email already in use"
+			continue
+		}
+		claimed[email] = true
+
+		stored := user
+		stored.ID = takeNextID()
+		stored.DeletedAt = nil
+		users = append(users, stored)
+		results[i] = BatchResult{Index: i, Status: http.StatusCreated, User: &stored}
+		created++
+	}
+	usersMutex.Unlock()
+
+	if created == 0 {
+		respond(c, http.StatusBadRequest, Response{
+			Success: false,
+			Data:    results,
+			Error:   "No users were created",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	respond(c, http.StatusCreated, Response{
+		Success: true,
+		Data:    results,
+		Message: fmt.Sprintf("Created %d of %d users", created, len(batch)),
+	})
+}
+
 // updateUser handles PUT /users/:id
 func updateUser(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
+		respond(c, http.StatusBadRequest, Response{
 			Success: false,
 			Error:   "Invalid ID format",
 			Code:    http.StatusBadRequest,
@@ -138,28 +664,23 @@ func updateUser(c *gin.Context) {
 
 	var updatedUser User
 	if err := c.ShouldBindJSON(&updatedUser); err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   err.Error(),
-			Code:    http.StatusBadRequest,
-		})
+		invalidUser(c, bindFieldErrors(err))
 		return
 	}
 
 	// Validate user data
 	if err := validateUser(updatedUser); err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Success: false,
-			Error:   err.Error(),
-			Code:    http.StatusBadRequest,
-		})
+		invalidUser(c, err)
 		return
 	}
 
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
 	// Find user and update
-	_, index := findUserByID(id)
+	_, index := findActiveUserByID(id)
 	if index == -1 {
-		c.JSON(http.StatusNotFound, Response{
+		respond(c, http.StatusNotFound, Response{
 			Success: false,
 			Error:   "User not found",
 			Code:    http.StatusNotFound,
@@ -167,23 +688,30 @@ func updateUser(c *gin.Context) {
 		return
 	}
 
+	// The user may keep their own email but not take another user's
+	if _, other := findUserByEmail(updatedUser.Email); other != -1 && other != index {
+		emailConflict(c)
+		return
+	}
+
 	// Keep the original ID
 	updatedUser.ID = id
+	updatedUser.DeletedAt = nil
 	users[index] = updatedUser
 
-	c.JSON(http.StatusOK, Response{
+	respond(c, http.StatusOK, Response{
 		Success: true,
 		Data:    updatedUser,
 		Message: "User updated successfully",
 	})
 }
 
-// deleteUser handles DELETE /users/:id
+// deleteUser handles DELETE /users/:id by soft-deleting the user
 func deleteUser(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{
+		respond(c, http.StatusBadRequest, Response{
 			Success: false,
 			Error:   "Invalid ID format",
 			Code:    http.StatusBadRequest,
@@ -191,10 +719,13 @@ func deleteUser(c *gin.Context) {
 		return
 	}
 
-	// Find user and remove
-	_, index := findUserByID(id)
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	// Find user and mark it deleted, a deleted user is not found again
+	_, index := findActiveUserByID(id)
 	if index == -1 {
-		c.JSON(http.StatusNotFound, Response{
+		respond(c, http.StatusNotFound, Response{
 			Success: false,
 			Error:   "User not found",
 			Code:    http.StatusNotFound,
@@ -202,41 +733,281 @@ func deleteUser(c *gin.Context) {
 		return
 	}
 
-	// Remove user from slice
-	users = append(users[:index], users[index+1:]...)
+	now := time.Now()
+	users[index].DeletedAt = &now
 
-	c.JSON(http.StatusOK, Response{
+	respond(c, http.StatusOK, Response{
 		Success: true,
+		Data:    users[index],
 		Message: "User deleted successfully",
 	})
 }
 
-// searchUsers handles GET /users/search?name=value
-func searchUsers(c *gin.Context) {
-	name := c.Query("name")
-	if name == "" {
-		c.JSON(http.StatusBadRequest, Response{
+// restoreUser handles POST /users/:id/restore, undoing a soft delete.
+// Restoring a user that isn't deleted changes nothing.
+func restoreUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Invalid ID format",
+			Code:    http.StatusBadRequest,
+		})
+		return
+	}
+
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	_, index := findUserByID(id)
+	if index == -1 {
+		respond(c, http.StatusNotFound, Response{
+			Success: false,
+			Error:   "User not found",
+			Code:    http.StatusNotFound,
+		})
+		return
+	}
+
+	message := "User was not deleted"
+	if users[index].isDeleted() {
+		users[index].DeletedAt = nil
+		message = "User restored successfully"
+	}
+
+	respond(c, http.StatusOK, Response{
+		Success: true,
+		Data:    users[index],
+		Message: message,
+	})
+}
+
+// upsertUserByEmail handles PUT /users/by-email/:email
+// Creates the user if no one has that email yet, otherwise updates them
+func upsertUserByEmail(c *gin.Context) {
+	email := normalizeEmail(c.Param("email"))
+
+	var user User
+	if err := c.ShouldBindJSON(&user); err != nil {
+		invalidUser(c, bindFieldErrors(err))
+		return
+	}
+
+	// The email in the URL is the key, the body may omit it but can't change it
+	if user.Email != "" && normalizeEmail(user.Email) != email {
+		respond(c, http.StatusBadRequest, Response{
 			Success: false,
-			Error:   "Name parameter is required",
+			Error:   "email in body does not match URL",
 			Code:    http.StatusBadRequest,
 		})
 		return
 	}
+	user.Email = email
+
+	// Validate user data
+	if err := validateUser(user); err != nil {
+		invalidUser(c, err)
+		return
+	}
+
+	// Lookup and write under one lock so two upserts can't both create
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	if _, index := findUserByEmail(email); index != -1 {
+		// The email stays taken while its user is deleted
+		if users[index].isDeleted() {
+			respond(c, http.StatusConflict, Response{
+				Success: false,
+				Error:   "Email belongs to a deleted user, restore it first",
+				Code:    http.StatusConflict,
+			})
+			return
+		}
+
+		// Keep the original ID
+		user.ID = users[index].ID
+		user.DeletedAt = nil
+		users[index] = user
+
+		respond(c, http.StatusOK, Response{
+			Success: true,
+			Data:    user,
+			Message: "User updated successfully",
+		})
+		return
+	}
+
+	user.ID = takeNextID()
+	user.DeletedAt = nil
+	users = append(users, user)
+
+	c.Header("Location", userLocation(user.ID))
+	respond(c, http.StatusCreated, Response{
+		Success: true,
+		Data:    user,
+		Message: "User created successfully",
+	})
+}
+
+// ageQuery parses an optional age query parameter; set is false when it is
+// missing or empty
+func ageQuery(c *gin.Context, name string) (age int, set bool, err error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return 0, false, nil
+	}
+	age, err = strconv.Atoi(raw)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s must be a number", name)
+	}
+	return age, true, nil
+}
+
+// userFilter holds the optional filters shared by GET /users/search and
+// GET /users/export. A user must match every filter that is set.
+type userFilter struct {
+	name           string // lowercased name substring, "" for any
+	minAge, maxAge int
+	hasMin, hasMax bool
+	includeDeleted bool
+}
+
+// parseUserFilter reads ?name=&min_age=&max_age=&include_deleted=
+func parseUserFilter(c *gin.Context) (userFilter, error) {
+	filter := userFilter{
+		name:           strings.ToLower(c.Query("name")),
+		includeDeleted: includeDeleted(c),
+	}
+
+	var err error
+	if filter.minAge, filter.hasMin, err = ageQuery(c, "min_age"); err != nil {
+		return filter, err
+	}
+	if filter.maxAge, filter.hasMax, err = ageQuery(c, "max_age"); err != nil {
+		return filter, err
+	}
+	if filter.hasMin && filter.hasMax && filter.minAge > filter.maxAge {
+		return filter, errors.New("min_age cannot be greater than max_age")
+	}
+	return filter, nil
+}
+
+// empty reports whether no name or age filter is set
+func (f userFilter) empty() bool {
+	return f.name == "" && !f.hasMin && !f.hasMax
+}
 
+// matches reports whether the user passes every filter that is set
+func (f userFilter) matches(user User) bool {
+	if f.name != "" && !strings.Contains(strings.ToLower(user.Name), f.name) {
+		return false
+	}
+	return (!f.hasMin || user.Age >= f.minAge) && (!f.hasMax || user.Age <= f.maxAge)
+}
+
+// filterUsers returns a copy of the users matching the filter, in stored
+// order. Caller must hold usersMutex.
+func filterUsers(filter userFilter) []User {
 	results := make([]User, 0)
-	for _, user := range users {
-		if strings.Contains(strings.ToLower(user.Name), strings.ToLower(name)) {
+	for _, user := range visibleUsers(filter.includeDeleted) {
+		if filter.matches(user) {
 			results = append(results, user)
 		}
 	}
+	return results
+}
+
+// searchUsers handles GET /users/search?name=&min_age=&max_age=&include_deleted=
+// At least one of name, min_age or max_age is required.
+func searchUsers(c *gin.Context) {
+	badRequest := func(message string) {
+		respond(c, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   message,
+			Code:    http.StatusBadRequest,
+		})
+	}
+
+	filter, err := parseUserFilter(c)
+	if err != nil {
+		badRequest(err.Error())
+		return
+	}
+	if filter.empty() {
+		badRequest("Name, min_age or max_age parameter is required")
+		return
+	}
 
-	c.JSON(http.StatusOK, Response{
+	usersMutex.RLock()
+	results := filterUsers(filter)
+	usersMutex.RUnlock()
+
+	respond(c, http.StatusOK, Response{
 		Success: true,
 		Data:    results,
 		Message: "Search completed successfully",
 	})
 }
 
+// exportUsers handles GET /users/export, writing users as CSV with the
+// same filters as search plus ?sort=, none of them required. The matching
+// users are copied under the lock and the CSV is streamed to the client
+// after it is released, so a slow download doesn't block writers.
+func exportUsers(c *gin.Context) {
+	badRequest := func(message string) {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Error:   message,
+			Code:    http.StatusBadRequest,
+		})
+	}
+
+	filter, err := parseUserFilter(c)
+	if err != nil {
+		badRequest(err.Error())
+		return
+	}
+	var less func(a, b User) bool
+	if key := c.Query("sort"); key != "" {
+		if less, err = parseUserSort(key); err != nil {
+			badRequest(err.Error())
+			return
+		}
+	}
+
+	usersMutex.RLock()
+	list := filterUsers(filter)
+	usersMutex.RUnlock()
+	if less != nil {
+		list = sortedUsers(list, less)
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="users.csv"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "name", "email", "age"})
+	for _, user := range list {
+		w.Write([]string{strconv.Itoa(user.ID), user.Name, user.Email, strconv.Itoa(user.Age)})
+	}
+	w.Flush()
+}
+
+// takeNextID reserves the next user ID. Caller must hold usersMutex for
+// writing, which is what keeps concurrent creates from sharing an ID.
+func takeNextID() int {
+	id := nextID
+	nextID++
+	return id
+}
+
+// userLocation is the path a created user can be fetched from
+func userLocation(id int) string {
+	return "/users/" + strconv.Itoa(id)
+}
+
 // Helper function to find user by ID
 func findUserByID(id int) (*User, int) {
 	for i, user := range users {
@@ -247,16 +1018,148 @@ func findUserByID(id int) (*User, int) {
 	return nil, -1
 }
 
-// Helper function to validate user data
+// Helper function to find user by email, ignoring case and surrounding spaces
+func findUserByEmail(email string) (*User, int) {
+	email = normalizeEmail(email)
+	for i, user := range users {
+		if normalizeEmail(user.Email) == email {
+			return &user, i
+		}
+	}
+	return nil, -1
+}
+
+// emailConflict answers 409 when an email already belongs to another user
+func emailConflict(c *gin.Context) {
+	respond(c, http.StatusConflict, Response{
+		Success: false,
+		Error:   "Email already in use",
+		Code:    http.StatusConflict,
+	})
+}
+
+// isDeleted reports whether the user has been soft-deleted
+func (u User) isDeleted() bool {
+	return u.DeletedAt != nil
+}
+
+// includeDeleted reports whether the request asks for soft-deleted users
+// with ?include_deleted=true
+func includeDeleted(c *gin.Context) bool {
+	include, _ := strconv.ParseBool(c.Query("include_deleted"))
+	return include
+}
+
+// visibleUsers returns the users a listing shows, leaving out soft-deleted
+// ones unless includeDeleted is set. Caller must hold usersMutex.
+func visibleUsers(includeDeleted bool) []User {
+	if includeDeleted {
+		return users
+	}
+	visible := make([]User, 0, len(users))
+	for _, user := range users {
+		if !user.isDeleted() {
+			visible = append(visible, user)
+		}
+	}
+	return visible
+}
+
+// Helper function to find a user by ID that hasn't been soft-deleted
+func findActiveUserByID(id int) (*User, int) {
+	user, index := findUserByID(id)
+	if user == nil || user.isDeleted() {
+		return nil, -1
+	}
+	return user, index
+}
+
+// Helper function to normalize an email for comparison
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// fieldError builds the error for a field failing a validation rule. The
+// rules are named like validator tags so the messages read the same
+// wherever a field is checked.
+func fieldError(field, rule string) FieldError {
+	var message string
+	switch rule {
+	case "required":
+		message = field + " is required"
+	case "email":
+		message = "invalid email format"
+	case "range":
+		message = fmt.Sprintf("%s must be between %d and %d", field, minAge, maxAge)
+	default:
+		message = field + " is invalid"
+	}
+	return FieldError{Field: field, Message: message}
+}
+
+// Helper function to validate user data. The error is a FieldErrors
+// listing every failed field, or nil.
 func validateUser(user User) error {
+	var errs FieldErrors
 	if user.Name == "" {
-		return errors.New("name is required")
+		errs = append(errs, fieldError("name", "required"))
 	}
 	if user.Email == "" {
-		return errors.New("email is required")
+		errs = append(errs, fieldError("email", "required"))
+	} else if !strings.Contains(user.Email, "@") {
+		errs = append(errs, fieldError("email", "email"))
 	}
-	if !strings.Contains(user.Email, "@") {
-		return errors.New("invalid email format")
+	// Age is required, so the zero value of a missing age is rejected too
+	if user.Age < minAge || user.Age > maxAge {
+		errs = append(errs, fieldError("age", "range"))
+	}
+
+	if len(errs) > 0 {
+		return errs
 	}
 	return nil
 }
+
+// bindFieldErrors turns a JSON binding error into field errors, naming the
+// field when a value has the wrong type
+func bindFieldErrors(err error) FieldErrors {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return FieldErrors{{
+			Field:   typeErr.Field,
+			Message: fmt.Sprintf("%s must be a %s, not a %s", typeErr.Field, jsonTypeName(typeErr.Type.Kind()), typeErr.Value),
+		}}
+	}
+	return FieldErrors{{Field: "body", Message: "malformed JSON body"}}
+}
+
+// jsonTypeName names a Go kind the way a JSON client would know it
+func jsonTypeName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	default:
+		return "string"
+	}
+}
+
+// invalidUser answers 400 for a rejected user body, listing the field
+// errors when err has them
+func invalidUser(c *gin.Context, err error) {
+	var fieldErrs FieldErrors
+	errors.As(err, &fieldErrs)
+	respond(c, http.StatusBadRequest, Response{
+		Success: false,
+		Error:   err.Error(),
+		Code:    http.StatusBadRequest,
+		Errors:  fieldErrs,
+	})
+}
@@ -0,0 +1,968 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRouter resets the user store and registers the same routes as main
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	users = []User{
+		{ID: 1, Name: "John Doe", Email: "john@example.com", Age: 30},
+		{ID: 2, Name: "Jane Smith", Email: "jane@example.com", Age: 25},
+		{ID: 3, Name: "Bob Wilson", Email: "bob@example.com", Age: 35},
+	}
+	nextID = 4
+
+	router := gin.New()
+	registerRoutes(router)
+
+	return router
+}
+
+// performRequest sends a JSON request and decodes the Response
+func performRequest(router *gin.Engine, method, path string, body interface{}) (*httptest.ResponseRecorder, Response) {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response Response
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func TestUpsertUserByEmail(t *testing.T) {
+	t.Run("Creates new user", func(t *testing.T) {
+		router := newTestRouter()
+
+		w, response := performRequest(router, "PUT", "/users/by-email/alice@example.com",
+			User{Name: "Alice", Age: 28})
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.True(t, response.Success)
+		data := response.Data.(map[string]interface{})
+		assert.Equal(t, float64(4), data["id"])
+		assert.Equal(t, "alice@example.com", data["email"])
+		assert.Len(t, users, 4)
+	})
+
+	t.Run("Updates existing user", func(t *testing.T) {
+		router := newTestRouter()
+
+		w, response := performRequest(router, "PUT", "/users/by-email/JANE@example.com",
+			User{Name: "Jane Doe", Age: 26})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		data := response.Data.(map[string]interface{})
+		assert.Equal(t, float64(2), data["id"])
+		assert.Equal(t, "Jane Doe", data["name"])
+		assert.Len(t, users, 3)
+	})
+
+	t.Run("Rejects mismatched or invalid data", func(t *testing.T) {
+		router := newTestRouter()
+
+		w, _ := performRequest(router, "PUT", "/users/by-email/jane@example.com",
+			User{Name: "Jane", Email: "other@example.com"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w, _ = performRequest(router, "PUT", "/users/by-email/jane@example.com", User{Age: 20})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w, _ = performRequest(router, "PUT", "/users/by-email/not-an-email", User{Name: "Nobody"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Concurrent upserts create one user", func(t *testing.T) {
+		router := newTestRouter()
+
+		var wg sync.WaitGroup
+		codes := make([]int, 10)
+		for i := range codes {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				w, _ := performRequest(router, "PUT", "/users/by-email/race@example.com",
+					User{Name: "Racer", Age: 30})
+				codes[i] = w.Code
+			}(i)
+		}
+		wg.Wait()
+
+		created := 0
+		for _, code := range codes {
+			if code == http.StatusCreated {
+				created++
+			}
+		}
+		assert.Equal(t, 1, created)
+
+		matches := 0
+		for _, user := range users {
+			if user.Email == "race@example.com" {
+				matches++
+			}
+		}
+		assert.Equal(t, 1, matches)
+	})
+}
+
+func TestHeadAndOptions(t *testing.T) {
+	router := newTestRouter()
+
+	t.Run("HEAD existing user", func(t *testing.T) {
+		w, _ := performRequest(router, "HEAD", "/users/1", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	})
+
+	t.Run("HEAD missing user", func(t *testing.T) {
+		w, _ := performRequest(router, "HEAD", "/users/999", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("HEAD collection", func(t *testing.T) {
+		w, _ := performRequest(router, "HEAD", "/users", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("OPTIONS lists allowed methods", func(t *testing.T) {
+		tests := []struct {
+			path  string
+			allow string
+		}{
+			{"/users", "GET, HEAD, POST, OPTIONS"},
+			{"/users/1", "GET, HEAD, PUT, DELETE, OPTIONS"},
+			{"/users/by-email/a@example.com", "PUT, OPTIONS"},
+		}
+		for _, test := range tests {
+			w, _ := performRequest(router, "OPTIONS", test.path, nil)
+			assert.Equal(t, http.StatusNoContent, w.Code, test.path)
+			assert.Equal(t, test.allow, w.Header().Get("Allow"), test.path)
+			assert.Empty(t, w.Body.String())
+		}
+	})
+}
+
+func TestDeleteUserReturnsDeleted(t *testing.T) {
+	router := newTestRouter()
+
+	w, response := performRequest(router, "DELETE", "/users/2", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	data := response.Data.(map[string]interface{})
+	assert.Equal(t, float64(2), data["id"])
+	assert.Equal(t, "Jane Smith", data["name"])
+	assert.Equal(t, "jane@example.com", data["email"])
+	assert.NotEmpty(t, data["deleted_at"])
+
+	w, _ = performRequest(router, "DELETE", "/users/2", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestContentNegotiation(t *testing.T) {
+	router := newTestRouter()
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		for _, accept := range []string{"", "application/json", "*/*", "application/xml;q=0.5, application/json"} {
+			w := get("/users/1", accept)
+			assert.Equal(t, http.StatusOK, w.Code, accept)
+			assert.Contains(t, w.Header().Get("Content-Type"), "application/json", accept)
+
+			var response Response
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), accept)
+			assert.Equal(t, "John Doe", response.Data.(map[string]interface{})["name"], accept)
+		}
+	})
+
+	t.Run("XML", func(t *testing.T) {
+		for _, accept := range []string{"application/xml", "text/xml", "application/json;q=0.1, application/xml"} {
+			w := get("/users/1", accept)
+			assert.Equal(t, http.StatusOK, w.Code, accept)
+			assert.Contains(t, w.Header().Get("Content-Type"), "application/xml", accept)
+
+			var response struct {
+				XMLName xml.Name `xml:"response"`
+				Success bool     `xml:"success"`
+				Data    User     `xml:"data"`
+			}
+			assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &response), accept)
+			assert.True(t, response.Success)
+			assert.Equal(t, User{ID: 1, Name: "John Doe", Email: "john@example.com", Age: 30}, response.Data)
+		}
+
+		w := get("/users", "application/xml")
+		var list struct {
+			Users []User `xml:"data"`
+		}
+		assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &list))
+		assert.Len(t, list.Users, 3)
+	})
+
+	t.Run("Unsupported Format", func(t *testing.T) {
+		for _, accept := range []string{"text/html", "text/plain", "text/*", "application/xml;q=0"} {
+			w := get("/users/1", accept)
+			assert.Equal(t, http.StatusNotAcceptable, w.Code, accept)
+			assert.Contains(t, w.Header().Get("Content-Type"), "application/json", accept)
+		}
+
+		// Rejected before the handler runs, so nothing is created
+		req, _ := http.NewRequest("POST", "/users", strings.NewReader(`{"name":"Alice","email":"alice@example.com","age":28}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/html")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Len(t, users, 3)
+	})
+}
+
+func TestGetAllUsersPagination(t *testing.T) {
+	router := newTestRouter()
+	for i := 4; i <= 45; i++ {
+		users = append(users, User{ID: i, Name: "User " + strconv.Itoa(i), Email: "user" + strconv.Itoa(i) + "@example.com", Age: 20})
+	}
+	nextID = 46
+
+	list := func(query string) (*httptest.ResponseRecorder, []User, map[string]interface{}) {
+		w, _ := performRequest(router, "GET", "/users"+query, nil)
+		var response struct {
+			Data []User                 `json:"data"`
+			Meta map[string]interface{} `json:"meta"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), query)
+		return w, response.Data, response.Meta
+	}
+
+	t.Run("Defaults to the first page of 20", func(t *testing.T) {
+		w, data, meta := list("")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, data, 20)
+		assert.Equal(t, 1, data[0].ID)
+		assert.Equal(t, map[string]interface{}{
+			"total": float64(45), "page": float64(1), "limit": float64(20), "total_pages": float64(3),
+		}, meta)
+	})
+
+	t.Run("Later and partial pages", func(t *testing.T) {
+		_, data, meta := list("?page=2&limit=20")
+		assert.Len(t, data, 20)
+		assert.Equal(t, 21, data[0].ID)
+		assert.Equal(t, float64(2), meta["page"])
+
+		_, data, _ = list("?page=3")
+		assert.Len(t, data, 5)
+		assert.Equal(t, 41, data[0].ID)
+
+		_, data, meta = list("?page=5&limit=10")
+		assert.Len(t, data, 5)
+		assert.Equal(t, float64(5), meta["total_pages"])
+	})
+
+	t.Run("Out of range page is empty, not null", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/users?page=9", nil)
+		assert.Contains(t, w.Body.String(), `"data":[]`)
+
+		w, _ = performRequest(router, "GET", "/users?page=9223372036854775807&limit=9223372036854775807", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"data":[]`)
+	})
+
+	t.Run("Invalid page and limit fall back to defaults", func(t *testing.T) {
+		for _, query := range []string{"?limit=0", "?limit=-5", "?limit=abc&page=0", "?page=-1"} {
+			_, data, meta := list(query)
+			assert.Len(t, data, 20, query)
+			assert.Equal(t, float64(1), meta["page"], query)
+			assert.Equal(t, float64(20), meta["limit"], query)
+		}
+	})
+}
+
+func TestGetAllUsersSort(t *testing.T) {
+	router := newTestRouter()
+	users = append(users, User{ID: 4, Name: "alice Cooper", Email: "alice@example.com", Age: 30})
+	nextID = 5
+	original := append([]User(nil), users...)
+
+	ids := func(query string) []int {
+		w, _ := performRequest(router, "GET", "/users"+query, nil)
+		assert.Equal(t, http.StatusOK, w.Code, query)
+		var response struct {
+			Data []User `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), query)
+		var ids []int
+		for _, user := range response.Data {
+			ids = append(ids, user.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []int{1, 2, 3, 4}, ids(""))
+	assert.Equal(t, []int{4, 3, 2, 1}, ids("?sort=name"))
+	assert.Equal(t, []int{1, 2, 3, 4}, ids("?sort=-name"))
+	// Equal ages keep their stored order in both directions
+	assert.Equal(t, []int{2, 1, 4, 3}, ids("?sort=age"))
+	assert.Equal(t, []int{3, 1, 4, 2}, ids("?sort=-age"))
+	assert.Equal(t, []int{4, 2}, ids("?sort=-age&page=2&limit=2"))
+
+	// The store itself is never reordered
+	assert.Equal(t, original, users)
+
+	for _, key := range []string{"email", "-", "--age", "Name"} {
+		w, response := performRequest(router, "GET", "/users?sort="+key, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, key)
+		assert.False(t, response.Success, key)
+		assert.Contains(t, response.Error, "invalid sort key", key)
+	}
+}
+
+func TestUniqueEmail(t *testing.T) {
+	router := newTestRouter()
+
+	w, first := performRequest(router, "POST", "/users", User{Name: "Alice", Email: "alice@example.com", Age: 28})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w, second := performRequest(router, "POST", "/users", User{Name: "Bob", Email: "bob2@example.com", Age: 31})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	firstID := int(first.Data.(map[string]interface{})["id"].(float64))
+	secondID := int(second.Data.(map[string]interface{})["id"].(float64))
+
+	t.Run("Create with a taken email", func(t *testing.T) {
+		w, response := performRequest(router, "POST", "/users", User{Name: "Other Alice", Email: " ALICE@example.com", Age: 40})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "Email already in use", response.Error)
+		assert.Len(t, users, 5)
+	})
+
+	t.Run("Update to another user's email", func(t *testing.T) {
+		w, response := performRequest(router, "PUT", "/users/"+strconv.Itoa(secondID),
+			User{Name: "Bob", Email: "alice@example.com", Age: 31})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "Email already in use", response.Error)
+
+		_, index := findUserByID(secondID)
+		assert.Equal(t, "bob2@example.com", users[index].Email)
+	})
+
+	t.Run("Update keeping your own email", func(t *testing.T) {
+		w, _ := performRequest(router, "PUT", "/users/"+strconv.Itoa(firstID),
+			User{Name: "Alice Smith", Email: "alice@example.com", Age: 29})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestConcurrentCreatesGetDistinctIDs(t *testing.T) {
+	router := newTestRouter()
+	const creates = 100
+
+	var wg sync.WaitGroup
+	ids := make([]int, creates)
+	for i := 0; i < creates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w, response := performRequest(router, "POST", "/users", User{
+				Name:  "User " + strconv.Itoa(i),
+				Email: "concurrent" + strconv.Itoa(i) + "@example.com",
+				Age:   20,
+			})
+			if w.Code == http.StatusCreated {
+				ids[i] = int(response.Data.(map[string]interface{})["id"].(float64))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[int]bool, creates)
+	for _, id := range ids {
+		assert.NotZero(t, id)
+		assert.False(t, seen[id], "ID %d was assigned twice", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, creates)
+	assert.Len(t, users, 3+creates)
+	assert.Equal(t, 4+creates, nextID)
+}
+
+func TestSearchUsersAgeRange(t *testing.T) {
+	router := newTestRouter()
+	users = append(users,
+		User{ID: 4, Name: "Johnny Cash", Email: "johnny@example.com", Age: 45},
+		User{ID: 5, Name: "Joanna Lee", Email: "joanna@example.com", Age: 27},
+	)
+	nextID = 6
+
+	search := func(query string) []int {
+		w, _ := performRequest(router, "GET", "/users/search?"+query, nil)
+		assert.Equal(t, http.StatusOK, w.Code, query)
+		var response struct {
+			Data []User `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), query)
+		ids := []int{}
+		for _, user := range response.Data {
+			ids = append(ids, user.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []int{1, 4, 5}, search("name=jo"))
+	assert.Equal(t, []int{1, 5}, search("name=jo&min_age=25&max_age=40"))
+	assert.Equal(t, []int{1, 3, 4}, search("min_age=30"))
+	assert.Equal(t, []int{2, 5}, search("max_age=27"))
+	assert.Equal(t, []int{1}, search("min_age=30&max_age=30"))
+	assert.Equal(t, []int{}, search("name=jo&min_age=50"))
+
+	for _, query := range []string{"min_age=abc", "name=jo&max_age=4.5", "min_age=40&max_age=30", ""} {
+		w, response := performRequest(router, "GET", "/users/search?"+query, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.NotEmpty(t, response.Error, query)
+	}
+}
+
+func TestCreateUsersBatch(t *testing.T) {
+	postBatch := func(router *gin.Engine, body interface{}) (*httptest.ResponseRecorder, []BatchResult, Response) {
+		w, response := performRequest(router, "POST", "/users/batch", body)
+		var results struct {
+			Data []BatchResult `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &results)
+		return w, results.Data, response
+	}
+
+	t.Run("Partial success", func(t *testing.T) {
+		router := newTestRouter()
+
+		w, results, response := postBatch(router, []User{
+			{Name: "Alice", Email: "alice@example.com", Age: 28},
+			{Name: "", Email: "noname@example.com", Age: 20},
+			{Name: "Carol", Email: "carol@example.com", Age: 41},
+			{Name: "Dup", Email: "john@example.com", Age: 50},
+			{Name: "Alice Again", Email: "ALICE@example.com", Age: 28},
+			{Name: "Bad Email", Email: "not-an-email", Age: 20},
+		})
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.True(t, response.Success)
+		assert.Equal(t, "Created 2 of 6 users", response.Message)
+		if assert.Len(t, results, 6) {
+			assert.Equal(t, http.StatusCreated, results[0].Status)
+			assert.Equal(t, 4, results[0].User.ID)
+			assert.Equal(t, http.StatusBadRequest, results[1].Status)
+			assert.Equal(t, "name is required", results[1].Error)
+			assert.Equal(t, http.StatusCreated, results[2].Status)
+			assert.Equal(t, 5, results[2].User.ID)
+			assert.Equal(t, http.StatusConflict, results[3].Status)
+			assert.Equal(t, http.StatusConflict, results[4].Status)
+			assert.Equal(t, "invalid email format", results[5].Error)
+			for i, result := range results {
+				assert.Equal(t, i, result.Index)
+			}
+		}
+		assert.Len(t, users, 5)
+		assert.Equal(t, 6, nextID)
+	})
+
+	t.Run("All invalid", func(t *testing.T) {
+		router := newTestRouter()
+
+		w, results, response := postBatch(router, []User{{Name: "No Email"}, {Email: "x@example.com"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.False(t, response.Success)
+		assert.Len(t, results, 2)
+		assert.Len(t, users, 3)
+		assert.Equal(t, 4, nextID)
+	})
+
+	t.Run("Bad batches", func(t *testing.T) {
+		router := newTestRouter()
+
+		tooMany := make([]User, maxBatchSize+1)
+		for _, body := range []interface{}{[]User{}, User{Name: "Not", Email: "an@array.com"}, tooMany} {
+			w, _, response := postBatch(router, body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.NotEmpty(t, response.Error)
+		}
+		assert.Len(t, users, 3)
+	})
+}
+
+func TestCreateUserLocation(t *testing.T) {
+	router := newTestRouter()
+
+	w, response := performRequest(router, "POST", "/users", User{Name: "Alice", Email: "alice@example.com", Age: 28})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/users/4", w.Header().Get("Location"))
+	assert.Equal(t, float64(4), response.Data.(map[string]interface{})["id"])
+
+	// IDs keep counting up after a delete instead of reusing one in use
+	performRequest(router, "DELETE", "/users/2", nil)
+	w, _ = performRequest(router, "POST", "/users", User{Name: "Bob", Email: "bob2@example.com", Age: 31})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/users/5", w.Header().Get("Location"))
+
+	// The header points at the created user
+	w, response = performRequest(router, "GET", w.Header().Get("Location"), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Bob", response.Data.(map[string]interface{})["name"])
+
+	// Upserts that create set it too, updates don't
+	w, _ = performRequest(router, "PUT", "/users/by-email/carol@example.com", User{Name: "Carol", Age: 40})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "/users/6", w.Header().Get("Location"))
+	w, _ = performRequest(router, "PUT", "/users/by-email/carol@example.com", User{Name: "Carol B", Age: 40})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+
+	// Failed creates don't
+	w, _ = performRequest(router, "POST", "/users", User{Name: "Nobody"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	router := newTestRouter()
+
+	listIDs := func(path string) []int {
+		w, _ := performRequest(router, "GET", path, nil)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		var response struct {
+			Data []User `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), path)
+		ids := []int{}
+		for _, user := range response.Data {
+			ids = append(ids, user.ID)
+		}
+		return ids
+	}
+
+	w, _ := performRequest(router, "DELETE", "/users/1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, users, 3, "the user is kept in the store")
+
+	t.Run("Deleted users are hidden by default", func(t *testing.T) {
+		assert.Equal(t, []int{2, 3}, listIDs("/users"))
+		assert.Equal(t, []int{}, listIDs("/users/search?name=john"))
+
+		w, _ := performRequest(router, "GET", "/users/1", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w, _ = performRequest(router, "PUT", "/users/1", User{Name: "John", Email: "john@example.com", Age: 30})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w, response := performRequest(router, "GET", "/users", nil)
+		assert.Equal(t, 200, w.Code)
+		assert.Equal(t, 2, response.Meta.Total)
+	})
+
+	t.Run("include_deleted surfaces them", func(t *testing.T) {
+		assert.Equal(t, []int{1, 2, 3}, listIDs("/users?include_deleted=true"))
+		assert.Equal(t, []int{1}, listIDs("/users/search?name=john&include_deleted=true"))
+
+		w, response := performRequest(router, "GET", "/users/1?include_deleted=true", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, response.Data.(map[string]interface{})["deleted_at"])
+	})
+
+	t.Run("The email stays taken", func(t *testing.T) {
+		w, _ := performRequest(router, "POST", "/users", User{Name: "New John", Email: "john@example.com", Age: 30})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w, _ = performRequest(router, "PUT", "/users/by-email/john@example.com", User{Name: "New John", Age: 30})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		w, response := performRequest(router, "POST", "/users/1/restore", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "User restored successfully", response.Message)
+		assert.Nil(t, response.Data.(map[string]interface{})["deleted_at"])
+		assert.Equal(t, []int{1, 2, 3}, listIDs("/users"))
+
+		// Restoring a user that isn't deleted is a no-op
+		w, response = performRequest(router, "POST", "/users/2/restore", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "User was not deleted", response.Message)
+
+		w, _ = performRequest(router, "POST", "/users/999/restore", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w, _ = performRequest(router, "POST", "/users/abc/restore", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Clients can't set deleted_at", func(t *testing.T) {
+		w, response := performRequest(router, "POST", "/users", map[string]interface{}{
+			"name": "Ghost", "email": "ghost@example.com", "age": 99, "deleted_at": "2024-01-01T00:00:00Z",
+		})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Nil(t, response.Data.(map[string]interface{})["deleted_at"])
+		assert.Contains(t, listIDs("/users"), 4)
+
+		// Nor through an upsert of an existing user
+		w, response = performRequest(router, "PUT", "/users/by-email/ghost@example.com", map[string]interface{}{
+			"name": "Ghost", "age": 99, "deleted_at": "2024-01-01T00:00:00Z",
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Nil(t, response.Data.(map[string]interface{})["deleted_at"])
+		assert.Contains(t, listIDs("/users"), 4)
+	})
+}
+
+func TestValidateUserAge(t *testing.T) {
+	tests := []struct {
+		age   int
+		valid bool
+	}{
+		{-1, false},
+		{0, false},
+		{1, true},
+		{150, true},
+		{151, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.age), func(t *testing.T) {
+			err := validateUser(User{Name: "Alice", Email: "alice@example.com", Age: tt.age})
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, "age must be between 1 and 150")
+			}
+
+			router := newTestRouter()
+			w, _ := performRequest(router, "POST", "/users", User{Name: "Alice", Email: "alice@example.com", Age: tt.age})
+			w2, _ := performRequest(router, "PUT", "/users/1", User{Name: "John Doe", Email: "john@example.com", Age: tt.age})
+			if tt.valid {
+				assert.Equal(t, http.StatusCreated, w.Code)
+				assert.Equal(t, http.StatusOK, w2.Code)
+			} else {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Equal(t, http.StatusBadRequest, w2.Code)
+			}
+		})
+	}
+}
+
+func TestExportUsersCSV(t *testing.T) {
+	router := newTestRouter()
+	users = append(users, User{ID: 4, Name: "Cash, Johnny", Email: "johnny@example.com", Age: 45})
+	nextID = 5
+	performRequest(router, "DELETE", "/users/3", nil)
+
+	export := func(query, accept string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/users/export"+query, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	rows := func(w *httptest.ResponseRecorder) [][]string {
+		records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+		assert.NoError(t, err)
+		return records
+	}
+
+	t.Run("All users", func(t *testing.T) {
+		w := export("", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="users.csv"`, w.Header().Get("Content-Disposition"))
+		assert.Contains(t, w.Body.String(), `4,"Cash, Johnny",johnny@example.com,45`)
+		assert.Equal(t, [][]string{
+			{"id", "name", "email", "age"},
+			{"1", "John Doe", "john@example.com", "30"},
+			{"2", "Jane Smith", "jane@example.com", "25"},
+			{"4", "Cash, Johnny", "johnny@example.com", "45"},
+		}, rows(w))
+	})
+
+	t.Run("Filtered and sorted", func(t *testing.T) {
+		w := export("?name=jo&sort=-age", "text/csv")
+		assert.Equal(t, [][]string{
+			{"id", "name", "email", "age"},
+			{"4", "Cash, Johnny", "johnny@example.com", "45"},
+			{"1", "John Doe", "john@example.com", "30"},
+		}, rows(w))
+
+		w = export("?min_age=30&include_deleted=true", "*/*")
+		assert.Len(t, rows(w), 4)
+
+		w = export("?name=nobody", "")
+		assert.Equal(t, [][]string{{"id", "name", "email", "age"}}, rows(w))
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, query := range []string{"?min_age=old", "?sort=email", "?min_age=50&max_age=20"} {
+			w := export(query, "")
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+		for _, accept := range []string{"application/json", "text/csv;q=0"} {
+			w := export("", accept)
+			assert.Equal(t, http.StatusNotAcceptable, w.Code, accept)
+		}
+	})
+}
+
+func TestGetUserETag(t *testing.T) {
+	router := newTestRouter()
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/users/1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"`), etag)
+	assert.NotEqual(t, etag, get("/users/2", "").Header().Get("ETag"))
+
+	// A matching ETag gets 304 with no body
+	w = get("/users/1", etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	for _, header := range []string{`"other", ` + etag, strings.TrimPrefix(etag, "W/"), "*"} {
+		assert.Equal(t, http.StatusNotModified, get("/users/1", header).Code, header)
+	}
+	assert.Equal(t, http.StatusOK, get("/users/1", `W/"stale"`).Code)
+
+	// Updating the user changes its ETag
+	w, _ = performRequest(router, "PUT", "/users/1", User{Name: "John Updated", Email: "john@example.com", Age: 31})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = get("/users/1", etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), "John Updated")
+}
+
+func TestStructuredValidationErrors(t *testing.T) {
+	router := newTestRouter()
+
+	send := func(method, path, body string) (*httptest.ResponseRecorder, Response) {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response Response
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		errors FieldErrors
+	}{
+		{"Every failed field is listed", "POST", "/users", `{"email": "not-an-email", "age": 200}`, FieldErrors{
+			{Field: "name", Message: "name is required"},
+			{Field: "email", Message: "invalid email format"},
+			{Field: "age", Message: "age must be between 1 and 150"},
+		}},
+		{"Missing email", "PUT", "/users/1", `{"name": "John", "age": 30}`, FieldErrors{
+			{Field: "email", Message: "email is required"},
+		}},
+		{"Wrong type", "POST", "/users", `{"name": "John", "email": "j@example.com", "age": "thirty"}`, FieldErrors{
+			{Field: "age", Message: "age must be a number, not a string"},
+		}},
+		{"Malformed JSON", "PUT", "/users/1", `{"name": "John",`, FieldErrors{
+			{Field: "body", Message: "malformed JSON body"},
+		}},
+		{"Batch wrong type", "POST", "/users/batch", `[{"name": "John", "email": "j@example.com", "age": "thirty"}]`, FieldErrors{
+			// The field path starts with the index of the bad record
+			{Field: "0.age", Message: "0.age must be a number, not a string"},
+		}},
+		{"Batch malformed JSON", "POST", "/users/batch", `[{"name": "John",`, FieldErrors{
+			{Field: "body", Message: "malformed JSON body"},
+		}},
+		{"Upsert", "PUT", "/users/by-email/new@example.com", `{"age": 0}`, FieldErrors{
+			{Field: "name", Message: "name is required"},
+			{Field: "age", Message: "age must be between 1 and 150"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, response := send(tt.method, tt.path, tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.False(t, response.Success)
+			assert.Equal(t, tt.errors, response.Errors)
+			assert.Equal(t, tt.errors.Error(), response.Error)
+		})
+	}
+
+	// Successful responses carry no errors field
+	w, _ := send("POST", "/users", `{"name": "Alice", "email": "alice@example.com", "age": 28}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), `"errors"`)
+}
+
+func TestHealthAndReadiness(t *testing.T) {
+	router := newTestRouter()
+
+	probe := func(method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		// Probes answer regardless of what the caller accepts
+		req.Header.Set("Accept", "text/html")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := probe("GET", "/healthz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+
+	w = probe("GET", "/readyz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ready"}`, w.Body.String())
+	assert.Equal(t, http.StatusOK, probe("HEAD", "/readyz").Code)
+
+	// Not ready until the store exists, while health is unaffected
+	users = nil
+	w = probe("GET", "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"status":"not ready"}`, w.Body.String())
+	assert.Equal(t, http.StatusOK, probe("GET", "/healthz").Code)
+
+	// An empty store is still initialized
+	users = []User{}
+	assert.Equal(t, http.StatusOK, probe("GET", "/readyz").Code)
+}
+
+// startSlowServer serves a route that holds each request until release is
+// closed, returning the server's URL and serveUntil's result channel
+func startSlowServer(t *testing.T, ctx context.Context, timeout time.Duration, started chan<- struct{}, release <-chan struct{}) (string, <-chan error) {
+	t.Helper()
+	router := gin.New()
+	router.GET("/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.String(http.StatusOK, "done")
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(chan error, 1)
+	go func() {
+		result <- serveUntil(ctx, &http.Server{Handler: router}, listener, timeout)
+	}()
+	return "http://" + listener.Addr().String(), result
+}
+
+func TestServeUntilGracefulShutdown(t *testing.T) {
+	t.Run("In-flight requests finish", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		started, release := make(chan struct{}), make(chan struct{})
+		url, result := startSlowServer(t, ctx, 5*time.Second, started, release)
+
+		type reply struct {
+			status int
+			body   string
+			err    error
+		}
+		replies := make(chan reply, 1)
+		go func() {
+			resp, err := http.Get(url + "/slow")
+			if err != nil {
+				replies <- reply{err: err}
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			replies <- reply{status: resp.StatusCode, body: string(body)}
+		}()
+
+		<-started
+		cancel()
+		// The server stops accepting while the first request is still running
+		assert.Eventually(t, func() bool {
+			_, err := net.DialTimeout("tcp", strings.TrimPrefix(url, "http://"), 100*time.Millisecond)
+			return err != nil
+		}, 2*time.Second, 10*time.Millisecond)
+		close(release)
+
+		r := <-replies
+		assert.NoError(t, r.err)
+		assert.Equal(t, http.StatusOK, r.status)
+		assert.Equal(t, "done", r.body)
+		assert.NoError(t, <-result)
+	})
+
+	t.Run("Shutdown times out", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		started, release := make(chan struct{}), make(chan struct{})
+		defer close(release)
+		url, result := startSlowServer(t, ctx, 50*time.Millisecond, started, release)
+
+		go http.Get(url + "/slow")
+		<-started
+		cancel()
+
+		select {
+		case err := <-result:
+			assert.NoError(t, err)
+		case <-time.After(2 * time.Second):
+			t.Fatal("serveUntil did not return after the shutdown timeout")
+		}
+	})
+
+	t.Run("Listen failure is returned", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listener.Close()
+		err = serveUntil(context.Background(), &http.Server{Handler: gin.New()}, listener, time.Second)
+		assert.Error(t, err)
+	})
+}
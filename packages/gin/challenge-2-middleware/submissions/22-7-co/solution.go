@@ -1,15 +1,38 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"html"
+	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -18,30 +41,104 @@ import (
 
 // Article represents a blog article
 type Article struct {
-	ID        int       `json:"id"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	Author    string    `json:"author"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int       `json:"id" xml:"id"`
+	Title     string    `json:"title" xml:"title"`
+	Content   string    `json:"content" xml:"content"`
+	Author    string    `json:"author" xml:"author"`
+	Tags      []string  `json:"tags,omitempty" xml:"tags,omitempty"`
+	Version   int       `json:"version" xml:"version"` // 每次更新加一，用于乐观并发控制
+	CreatedAt time.Time `json:"created_at" xml:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" xml:"updated_at"`
+}
+
+// ArticleInput 客户端可以写入的文章字段，创建和更新文章时解析请求体用
+// 📌 id、created_at、updated_at 由服务端维护，请求体中带上这些字段会被忽略，不能伪造时间戳
+// 📌 version 不会写入文章，更新时表示客户端读到的版本，和当前版本不一致返回 409
+type ArticleInput struct {
+	Title   string   `json:"title"`
+	Content string   `json:"content"`
+	Author  string   `json:"author"`
+	Tags    []string `json:"tags,omitempty"`
+	Version int      `json:"version,omitempty"` // 版本从 1 开始，0 表示没有带版本
+}
+
+// article 转换为 Article，服务端字段留空由处理器填写
+func (in ArticleInput) article() Article {
+	return Article{
+		Title:   in.Title,
+		Content: in.Content,
+		Author:  in.Author,
+		Tags:    in.Tags,
+	}
 }
 
 // APIResponse represents a standard API response
 type APIResponse struct {
-	Success   bool        `json:"success"`
-	Data      interface{} `json:"data,omitempty"`
-	Message   string      `json:"message,omitempty"`
-	Error     string      `json:"error,omitempty"`
-	RequestID string      `json:"request_id,omitempty"`
+	Success    bool        `json:"success" xml:"success"`
+	Data       interface{} `json:"data,omitempty" xml:"data,omitempty"`
+	Message    string      `json:"message,omitempty" xml:"message,omitempty"`
+	Error      string      `json:"error,omitempty" xml:"error,omitempty"`
+	RequestID  string      `json:"request_id,omitempty" xml:"request_id,omitempty"`
+	APIVersion string      `json:"api_version" xml:"api_version"`
 }
 
-// In-memory storage
-var articles = []Article{
-	{ID: 1, Title: "Getting Started with Go", Content: "Go is a programming language...", Author: "John Doe", CreatedAt: time.Now(), UpdatedAt: time.Now()},
-	{ID: 2, Title: "Web Development with Gin", Content: "Gin is a web framework...", Author: "Jane Smith", CreatedAt: time.Now(), UpdatedAt: time.Now()},
+// MarshalJSON 序列化时自动补上当前 API 版本
+func (r APIResponse) MarshalJSON() ([]byte, error) {
+	// 使用别名类型，避免递归调用 MarshalJSON
+	type plainResponse APIResponse
+	if r.APIVersion == "" {
+		r.APIVersion = apiVersion
+	}
+	return json.Marshal(plainResponse(r))
+}
+
+// MarshalXML 与 MarshalJSON 一样补上 API 版本，根元素为 <response>
+func (r APIResponse) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plainResponse APIResponse
+	if r.APIVersion == "" {
+		r.APIVersion = apiVersion
+	}
+	start.Name = xml.Name{Local: "response"}
+	return e.EncodeElement(plainResponse(r), start)
 }
+
+// apiVersion 响应信封的版本号
+// 📌 构建时可覆盖：go build -ldflags "-X main.apiVersion=v1"
+var apiVersion = "v1"
+
+// 用于指定 API 版本的厂商媒体类型前缀
+const apiMediaTypePrefix = "application/vnd.blog."
+
+// In-memory storage
+var articles = seedArticles()
 var nextID = 3
 
+// seedArticles 返回一份新的初始文章数据
+func seedArticles() []Article {
+	return []Article{
+		{ID: 1, Title: "Getting Started with Go", Content: "Go is a programming language...", Author: "John Doe", Version: 1, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+		{ID: 2, Title: "Web Development with Gin", Content: "Gin is a web framework...", Author: "Jane Smith", Version: 1, CreatedAt: time.Now(), UpdatedAt: time.Now()},
+	}
+}
+
+// testMode 为 true 时开放 POST /test/reset，通过环境变量 TEST_MODE=1 开启
+// 📌 生产环境不要开启
+var testMode = false
+
+// debugCapture 为 true 时 DebugCaptureMiddleware 记录请求体和响应体，通过环境变量 DEBUG_CAPTURE=1 开启
+// 📌 只用于排查客户端对接问题，生产环境不要长期开启
+var debugCapture = false
+
+// problemDetails 为 true 时错误响应使用 RFC 7807 格式（application/problem+json），通过环境变量 PROBLEM_DETAILS=1 开启
+// 📌 默认关闭，保持原来的 APIResponse 错误格式
+var problemDetails = false
+
+// requireArticleVersion 为 true 时 PUT /articles/:id 必须带上读取时的 version，
+// 否则返回 428，通过环境变量 REQUIRE_ARTICLE_VERSION=1 开启
+// 📌 默认关闭：挑战的 TestUpdateArticle 和旧客户端更新时都不带 version，强制要求会让它们全部失败；
+// 关闭时不带 version 的更新仍会直接覆盖，可能丢失别人的修改
+var requireArticleVersion = false
+
 // 用于保护 articles 切片的并发访问
 var articlesMutex sync.RWMutex
 
@@ -51,27 +148,121 @@ func main() {
 	// gin.New() 让我们可以完全控制中间件的添加顺序
 	r := gin.New()
 
+	// 加载签名 API Key 的公钥（可选）
+	if pemKey := os.Getenv("API_KEY_PUBLIC_KEY"); pemKey != "" {
+		publicKey, err := parseECDSAPublicKeyPEM([]byte(pemKey))
+		if err != nil {
+			log.Fatal("Invalid API_KEY_PUBLIC_KEY:", err)
+		}
+		apiKeyPublicKey = publicKey
+	}
+
+	testMode = os.Getenv("TEST_MODE") == "1"
+	debugCapture = os.Getenv("DEBUG_CAPTURE") == "1"
+	problemDetails = os.Getenv("PROBLEM_DETAILS") == "1"
+	requireArticleVersion = os.Getenv("REQUIRE_ARTICLE_VERSION") == "1"
+
+	// 单个请求的超时时间，如 REQUEST_TIMEOUT=5s
+	if timeout := os.Getenv("REQUEST_TIMEOUT"); timeout != "" {
+		parsed, err := time.ParseDuration(timeout)
+		if err != nil || parsed <= 0 {
+			log.Fatal("Invalid REQUEST_TIMEOUT:", timeout)
+		}
+		requestTimeout = parsed
+	}
+
+	// 访问日志格式：text（默认）或 json
+	if format := os.Getenv("LOG_FORMAT"); format != "" {
+		parsed, err := parseLogFormat(format)
+		if err != nil {
+			log.Fatal("Invalid LOG_FORMAT:", err)
+		}
+		logFormat = parsed
+	}
+
+	// 覆盖默认的 Content-Security-Policy，设为 "-" 时不发送该响应头
+	if csp, ok := os.LookupEnv("CONTENT_SECURITY_POLICY"); ok {
+		if csp == "-" {
+			csp = ""
+		}
+		contentSecurityPolicy = csp
+	}
+
+	// 允许跨域的来源，逗号分隔，如 CORS_ALLOWED_ORIGINS=https://myblog.com,https://*.myblog.com
+	if origins := os.Getenv("CORS_ALLOWED_ORIGINS"); origins != "" {
+		parsed, err := parseCORSOrigins(origins)
+		if err != nil {
+			log.Fatal("Invalid CORS_ALLOWED_ORIGINS:", err)
+		}
+		defaultCORSConfig.AllowOrigins = parsed
+	}
+
+	// 文本清洗方式：escape（默认）或 strip
+	if mode := os.Getenv("SANITIZE_MODE"); mode != "" {
+		parsed, err := parseSanitizeMode(mode)
+		if err != nil {
+			log.Fatal("Invalid SANITIZE_MODE:", err)
+		}
+		sanitizeMode = parsed
+	}
+
 	// 📌 中间件执行顺序很重要！
 	// 中间件按照添加的顺序执行，像洋葱模型：
 	// Request -> Middleware1 -> Middleware2 -> Handler -> Middleware2 -> Middleware1 -> Response
 
-	// 1. ErrorHandlerMiddleware (最外层，捕获所有 panic)
+	// 0. MetricsMiddleware (最外层，统计所有请求，包括 panic 后返回的 500)
+	r.Use(MetricsMiddleware())
+
+	// 0.1 GzipMiddleware (压缩响应，放在 ErrorHandlerMiddleware 外层，500 响应也会压缩)
+	r.Use(GzipMiddleware())
+
+	// 1. ErrorHandlerMiddleware (捕获所有 panic)
 	r.Use(ErrorHandlerMiddleware())
 
+	// 1.1 SecurityHeadersMiddleware (安全响应头，放在前面，限流、熔断等提前返回的响应也会带上)
+	r.Use(SecurityHeadersMiddleware())
+
 	// 2. RequestIDMiddleware (为每个请求生成唯一ID)
 	r.Use(RequestIDMiddleware())
 
+	// 2.1 APIVersionMiddleware (协商 API 版本)
+	r.Use(APIVersionMiddleware())
+
+	// 2.2 ContentNegotiationMiddleware (协商响应格式：JSON 或 XML)
+	r.Use(ContentNegotiationMiddleware())
+
 	// 3. LoggingMiddleware (记录请求日志)
 	r.Use(LoggingMiddleware())
 
+	// 3.1 EndpointStatsMiddleware (按接口统计调用次数)
+	r.Use(EndpointStatsMiddleware())
+
+	// 3.2 BodySizeLimitMiddleware (限制请求体大小，放在所有读取请求体的中间件前面)
+	r.Use(BodySizeLimitMiddleware(maxRequestBodyBytes))
+
+	// 3.3 DebugCaptureMiddleware (调试时记录请求体和响应体，默认关闭)
+	// 放在 Sanitize500Middleware 外层，记录的是客户端最终收到的响应
+	r.Use(DebugCaptureMiddleware())
+
 	// 4. CORSMiddleware (处理跨域请求)
 	r.Use(CORSMiddleware())
 
-	// 5. RateLimitMiddleware (限制请求频率)
+	// 4.1 DrainMiddleware (排空模式下拒绝写请求)
+	r.Use(DrainMiddleware())
+
+	// 4.2 APIKeyRoleMiddleware (提前识别 API Key 的角色，供限流分级使用)
+	r.Use(APIKeyRoleMiddleware())
+
+	// 5. RateLimitMiddleware (按角色限制请求频率)
 	r.Use(RateLimitMiddleware())
 
+	// 5.1 CircuitBreakerMiddleware (后端持续出错时熔断)
+	r.Use(CircuitBreakerMiddleware())
+
 	// 6. ContentTypeMiddleware (验证内容类型)
 	r.Use(ContentTypeMiddleware())
+	// 6.1 TimeoutMiddleware (处理超时返回 503，必须放在 Sanitize500Middleware 前面)
+	r.Use(TimeoutMiddleware(requestTimeout))
 	// 7. Sanitize500Middleware (兜底，必须放最后)
 	r.Use(Sanitize500Middleware())
 
@@ -79,26 +270,81 @@ func main() {
 	// Public routes (公开路由，不需要认证)
 	public := r.Group("/")
 	{
-		public.GET("/ping", ping)               // 健康检查
-		public.GET("/articles", getArticles)    // 获取所有文章
-		public.GET("/articles/:id", getArticle) // 获取单篇文章
+		public.GET("/ping", ping)                          // 健康检查
+		public.GET("/articles", getArticles)               // 获取所有文章
+		public.GET("/articles/:id", getArticle)            // 获取单篇文章
+		public.GET("/articles/authors/top", getTopAuthors) // 按文章数排名的作者
+		public.GET("/articles/export", streamArticles)     // 流式导出全部文章
+		public.POST("/test/reset", resetTestData)          // 重置数据（仅测试模式）
 	}
 
 	// Protected routes (受保护路由，需要 API Key 认证)
 	protected := r.Group("/")
 	protected.Use(AuthMiddleware()) // 只对这个组应用认证中间件
+	// 带 Idempotency-Key 的创建请求可以安全重试
+	idempotent := IdempotencyMiddleware()
 	{
-		protected.POST("/articles", createArticle)       // 创建文章
-		protected.PUT("/articles/:id", updateArticle)    // 更新文章
-		protected.DELETE("/articles/:id", deleteArticle) // 删除文章
-		protected.GET("/admin/stats", getStats)          // 管理员统计信息
+		protected.POST("/articles", idempotent, createArticle)   // 创建文章
+		protected.PUT("/articles/:id", updateArticle)            // 更新文章
+		protected.DELETE("/articles/:id", deleteArticle)         // 删除文章
+		protected.GET("/admin/stats", getStats)                  // 管理员统计信息
+		protected.GET("/admin/requests", getRequestLog)          // 管理员查询访问日志
+		protected.GET("/admin/endpoint-stats", getEndpointStats) // 管理员查看各接口调用次数
+		protected.GET(metricsPath, getMetrics)                   // 管理员查看 Prometheus 格式的请求指标
+		protected.GET(debugCapturePath, getDebugCaptures)        // 管理员查看调试记录
+		protected.POST("/admin/drain", drainServer)              // 进入排空模式
+		protected.POST("/admin/undrain", undrainServer)          // 退出排空模式
 	}
 
 	// 启动服务器
 	log.Println("🚀 Server starting on http://localhost:8080")
-	if err := r.Run(":8080"); err != nil {
+	runServer(r, ":8080")
+}
+
+// shutdownTimeout 是关闭服务器时留给进行中请求完成的时间
+const shutdownTimeout = 10 * time.Second
+
+// runServer 在 addr 上运行 r，收到 SIGINT 或 SIGTERM 后优雅关闭
+// 📌 不再直接用 r.Run()，否则进程退出时进行中的请求会被直接掐断
+func runServer(r *gin.Engine, addr string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
+	if err := serveUntil(ctx, &http.Server{Handler: r}, listener, shutdownTimeout); err != nil {
+		log.Fatal("Server failed:", err)
+	}
+}
+
+// serveUntil 在 listener 上提供服务直到 ctx 结束，然后停止接收新连接，
+// 给进行中的请求最多 timeout 的时间完成，超时则强制关闭剩余连接
+// 📌 只有在关闭前服务就出错时才返回 error
+func serveUntil(ctx context.Context, server *http.Server, listener net.Listener, timeout time.Duration) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("🛑 Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("⚠️ Server shutdown timed out, closing remaining connections:", err)
+		server.Close()
+	} else {
+		log.Println("✅ Server shutdown complete")
+	}
+	<-served
+	return nil
 }
 
 // ============================================================================
@@ -124,308 +370,2256 @@ func RequestIDMiddleware() gin.HandlerFunc {
 	}
 }
 
-// LoggingMiddleware 记录所有请求的详细信息
-// 📌 用途：监控 API 性能，调试问题
-func LoggingMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 记录开始时间
-		startTime := time.Now()
-
-		// 从 context 获取 request_id
-		requestID, _ := c.Get("request_id")
-
-		// 执行请求（调用后续的中间件和处理器）
-		c.Next()
-
-		// 📌 c.Next() 之后的代码在请求处理完成后执行
-		// 这时我们可以获取响应状态码等信息
-
-		// 计算请求处理时间
-		duration := time.Since(startTime)
-
-		// 格式化日志输出
-		log.Printf("[%s] %s %s | Status: %d | Duration: %v | IP: %s | UserAgent: %s",
-			requestID,
-			c.Request.Method,
-			c.Request.URL.Path,
-			c.Writer.Status(),
-			duration,
-			c.ClientIP(),
-			c.Request.UserAgent(),
-		)
+// negotiateAPIVersion 根据 Accept 头选择 API 版本
+// 厂商媒体类型以外的可接受类型（如 application/json、*/*）都接受当前版本；
+// 可接受的类型全是不支持的版本时 ok 为 false
+// 📌 q=0 表示明确不接受，这样的类型不算
+func negotiateAPIVersion(accept string) (version string, ok bool) {
+	onlyUnsupported := false
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		mediaType := strings.TrimSpace(params[0])
+		if mediaType == "" || qValue(params[1:]) <= 0 {
+			continue
+		}
+		if !strings.HasPrefix(mediaType, apiMediaTypePrefix) || !strings.HasSuffix(mediaType, "+json") {
+			return apiVersion, true
+		}
+		v := strings.TrimSuffix(strings.TrimPrefix(mediaType, apiMediaTypePrefix), "+json")
+		if v == apiVersion {
+			return v, true
+		}
+		onlyUnsupported = true
 	}
+	return apiVersion, !onlyUnsupported
 }
 
-// AuthMiddleware 验证 API Key 并设置用户角色
-// 📌 用途：保护敏感接口，实现权限控制
-func AuthMiddleware() gin.HandlerFunc {
-	// 定义有效的 API Key 和对应的角色
-	// 实际项目中应该从数据库或配置文件读取
-	validAPIKeys := map[string]string{
-		"admin-key-123": "admin",
-		"user-key-456":  "user",
-	}
-
+// APIVersionMiddleware 通过 Accept: application/vnd.blog.v1+json 协商 API 版本
+// 📌 用途：为将来按版本选择响应结构做准备，不支持的版本返回 406
+func APIVersionMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 从请求头获取 API Key
-		apiKey := c.GetHeader("X-API-Key")
-
-		// 检查 API Key 是否为空
-		if apiKey == "" {
-			requestID, _ := c.Get("request_id")
-			c.JSON(http.StatusUnauthorized, APIResponse{
-				Success:   false,
-				Error:     "API Key is required",
-				RequestID: fmt.Sprintf("%v", requestID),
-			})
-			// 📌 关键：调用 c.Abort() 停止后续处理器的执行
-			c.Abort()
-			return
-		}
-
-		// 验证 API Key 是否有效
-		role, exists := validAPIKeys[apiKey]
-		if !exists {
+		version, ok := negotiateAPIVersion(c.GetHeader("Accept"))
+		if !ok {
 			requestID, _ := c.Get("request_id")
-			c.JSON(http.StatusUnauthorized, APIResponse{
+			respond(c, http.StatusNotAcceptable, APIResponse{
 				Success:   false,
-				Error:     "Invalid API Key",
+				Error:     fmt.Sprintf("Unsupported API version, supported: %s%s+json", apiMediaTypePrefix, apiVersion),
 				RequestID: fmt.Sprintf("%v", requestID),
 			})
 			c.Abort()
 			return
 		}
 
-		// 将用户角色存储到 context 中
-		c.Set("user_role", role)
+		// 后续处理器可以通过 c.Get("api_version") 读取协商结果
+		c.Set("api_version", version)
+		c.Header("X-API-Version", version)
 
-		// 继续执行后续处理器
 		c.Next()
 	}
 }
 
-// CORSMiddleware 处理跨域资源共享 (CORS)
-// 📌 用途：允许浏览器从不同域名访问 API
-func CORSMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 设置允许的源（Origin）
-		origin := c.GetHeader("Origin")
-		allowedOrigins := []string{
-			"http://localhost:3000",
-			"https://myblog.com",
-		}
+// 可以通过 Accept 头协商的响应格式
+const (
+	formatJSON = "json"
+	formatXML  = "xml"
+)
 
-		// 检查请求的 Origin 是否在允许列表中
-		isAllowed := false
-		for _, allowed := range allowedOrigins {
-			if origin == allowed {
-				isAllowed = true
-				break
-			}
-		}
+// mediaTypeFormat 把 Accept 中的媒体类型映射为响应格式，无法提供时返回 ""
+// 📌 厂商媒体类型 application/vnd.blog.v1+json 也是 JSON，版本由 APIVersionMiddleware 检查
+// 📌 text/* 不算 XML：客户端可能要的是 text/plain 或 text/html，这两种都提供不了
+func mediaTypeFormat(mediaType string) string {
+	switch mediaType {
+	case "application/json", "application/problem+json", "application/*", "*/*":
+		return formatJSON
+	case "application/xml", "application/problem+xml", "text/xml":
+		return formatXML
+	}
+	if strings.HasPrefix(mediaType, apiMediaTypePrefix) && strings.HasSuffix(mediaType, "+json") {
+		return formatJSON
+	}
+	return ""
+}
 
-		if isAllowed {
-			// 设置 CORS 响应头
-			c.Header("Access-Control-Allow-Origin", origin)
-		}
+// negotiateFormat 根据 Accept 头选择响应格式
+// 取 q 值最高的可用类型，相同时取先出现的；没有 Accept 头时用 JSON
+// 请求的类型都无法提供时 ok 为 false
+func negotiateFormat(accept string) (format string, ok bool) {
+	if strings.TrimSpace(accept) == "" {
+		return formatJSON, true
+	}
 
-		// 允许的 HTTP 方法
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	bestQ := 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		params := strings.Split(mediaRange, ";")
+		candidate := mediaTypeFormat(strings.ToLower(strings.TrimSpace(params[0])))
+		if candidate == "" {
+			continue
+		}
+		if q := qValue(params[1:]); q > bestQ {
+			format, bestQ = candidate, q
+		}
+	}
+	return format, format != ""
+}
 
-		// 允许的请求头
-		c.Header("Access-Control-Allow-Headers", "Content-Type, X-API-Key, X-Request-ID")
+// qValue 从 Accept 类请求头一项的参数中取出 q 值，没有或无法解析时为 1
+func qValue(params []string) float64 {
+	q := 1.0
+	for _, param := range params {
+		name, value, found := strings.Cut(param, "=")
+		if found && strings.TrimSpace(name) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+	}
+	return q
+}
 
-		// 是否允许携带凭证（cookies）
-		c.Header("Access-Control-Allow-Credentials", "true")
+// notAcceptable 返回 406，客户端要的格式都不支持，只能用 JSON
+func notAcceptable(c *gin.Context) {
+	requestID, _ := c.Get("request_id")
+	c.AbortWithStatusJSON(http.StatusNotAcceptable, APIResponse{
+		Success:   false,
+		Error:     "Unsupported response format, supported: application/json, application/xml",
+		RequestID: fmt.Sprintf("%v", requestID),
+	})
+}
 
-		// 预检请求的缓存时间（秒）
-		c.Header("Access-Control-Max-Age", "86400")
+// respond 按 Accept 头用 JSON 或 XML 写出响应
+func respond(c *gin.Context, status int, payload interface{}) {
+	format, ok := negotiateFormat(c.GetHeader("Accept"))
+	if !ok {
+		notAcceptable(c)
+		return
+	}
+	if format == formatXML {
+		c.XML(status, payload)
+		return
+	}
+	c.JSON(status, payload)
+}
 
-		// 📌 处理 OPTIONS 预检请求
-		// 浏览器在发送跨域请求前，会先发送 OPTIONS 请求询问是否允许
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
+// ContentNegotiationMiddleware 在处理器执行前检查响应格式
+// 📌 提前返回 406，避免写请求已经生效后才发现无法响应
+// 📌 指标接口固定输出文本格式，不参与协商
+func ContentNegotiationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.FullPath() == metricsPath {
+			c.Next()
+			return
+		}
+		if _, ok := negotiateFormat(c.GetHeader("Accept")); !ok {
+			notAcceptable(c)
 			return
 		}
-
 		c.Next()
 	}
 }
 
-// RateLimitMiddleware 实现基于 IP 的速率限制
-// 📌 用途：防止 API 被滥用，保护服务器资源
-func RateLimitMiddleware() gin.HandlerFunc {
-	// 使用 map 存储每个 IP 的限流器
-	// key: IP 地址, value: rate.Limiter
-	limiters := make(map[string]*rate.Limiter)
-	var mu sync.Mutex // 保护 map 的并发访问
+// ProblemDetails RFC 7807 错误文档
+// 📌 JSON 格式为 application/problem+json，XML 格式为 application/problem+xml
+type ProblemDetails struct {
+	XMLName   xml.Name `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	Type      string   `json:"type" xml:"type"`
+	Title     string   `json:"title" xml:"title"`
+	Status    int      `json:"status" xml:"status"`
+	Detail    string   `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance  string   `json:"instance,omitempty" xml:"instance,omitempty"`
+	RequestID string   `json:"request_id,omitempty" xml:"request_id,omitempty"` // 扩展字段
+}
 
-	// 限制：每分钟 100 个请求
-	// rate.Every(time.Minute) / 100 = 每 0.6 秒允许一个请求
-	rateLimit := rate.Every(time.Minute / 100)
-	burst := 10 // 突发容量：允许短时间内最多 10 个请求
+// problemResponse 按 Accept 头写出 RFC 7807 错误文档
+// 📌 没有为错误定义专门的类型 URI，type 使用 about:blank
+// 📌 instance 为出错请求的路径
+func problemResponse(c *gin.Context, status int, title, detail string) {
+	requestID, _ := c.Get("request_id")
+	problem := ProblemDetails{
+		Type:      "about:blank",
+		Title:     title,
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		RequestID: fmt.Sprintf("%v", requestID),
+	}
 
-	return func(c *gin.Context) {
-		// 获取客户端 IP
-		ip := c.ClientIP()
+	// 📌 gin 不会覆盖已经设置的 Content-Type
+	format, _ := negotiateFormat(c.GetHeader("Accept"))
+	if format == formatXML {
+		c.Header("Content-Type", "application/problem+xml; charset=utf-8")
+		c.XML(status, problem)
+		return
+	}
+	c.Header("Content-Type", "application/problem+json; charset=utf-8")
+	c.JSON(status, problem)
+}
 
-		// 获取或创建该 IP 的限流器
-		mu.Lock()
-		limiter, exists := limiters[ip]
-		if !exists {
-			// 为新 IP 创建限流器
-			limiter = rate.NewLimiter(rateLimit, burst)
-			limiters[ip] = limiter
-		}
-		mu.Unlock()
+// respondError 写出错误响应，格式由 problemDetails 决定
+// 📌 旧格式的 error 字段为 "title: detail"，没有 detail 时只有 title
+func respondError(c *gin.Context, status int, title, detail string) {
+	if problemDetails {
+		problemResponse(c, status, title, detail)
+		return
+	}
 
-		// 检查是否允许请求
-		if !limiter.Allow() {
-			// 超过速率限制
-			requestID, _ := c.Get("request_id")
+	message := title
+	if detail != "" {
+		message += ": " + detail
+	}
+	requestID, _ := c.Get("request_id")
+	respond(c, status, APIResponse{
+		Success:   false,
+		Error:     message,
+		RequestID: fmt.Sprintf("%v", requestID),
+	})
+}
 
-			// 设置速率限制响应头
-			c.Header("X-RateLimit-Limit", "100")
-			c.Header("X-RateLimit-Remaining", "0")
-			c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(time.Minute).Unix()))
+// AccessLogEntry 访问日志中的一条请求记录
+type AccessLogEntry struct {
+	Timestamp  time.Time `json:"timestamp" xml:"timestamp"`
+	RequestID  string    `json:"request_id" xml:"request_id"`
+	Method     string    `json:"method" xml:"method"`
+	Path       string    `json:"path" xml:"path"`
+	Status     int       `json:"status" xml:"status"`
+	DurationMs float64   `json:"duration_ms" xml:"duration_ms"`
+	User       string    `json:"user,omitempty" xml:"user,omitempty"` // 通过认证的角色，匿名请求为空
+}
 
-			c.JSON(http.StatusTooManyRequests, APIResponse{
-				Success:   false,
-				Error:     "Rate limit exceeded. Try again later.",
-				RequestID: fmt.Sprintf("%v", requestID),
-			})
-			c.Abort()
-			return
-		}
+// accessLogCapacity 访问日志最多保留的条数
+const accessLogCapacity = 1000
 
-		// 计算剩余令牌数（估算）
-		tokens := limiter.Tokens()
-		remaining := int(tokens)
-		if remaining < 0 {
-			remaining = 0
-		}
+// ringBuffer 固定容量的环形缓冲区，写满后覆盖最旧的记录
+// 📌 用途：保留最近的请求用于排查问题，同时限制内存占用
+type ringBuffer[T any] struct {
+	mu      sync.RWMutex
+	entries []T
+	next    int  // 下一条写入的位置
+	full    bool // 是否已经写满一圈
+}
 
-		// 设置速率限制信息头
-		c.Header("X-RateLimit-Limit", "100")
-		c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-		c.Header("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(time.Minute).Unix()))
+func newRingBuffer[T any](capacity int) *ringBuffer[T] {
+	return &ringBuffer[T]{entries: make([]T, capacity)}
+}
 
-		c.Next()
+// newAccessLog 创建存放访问日志的环形缓冲区
+func newAccessLog(capacity int) *ringBuffer[AccessLogEntry] {
+	return newRingBuffer[AccessLogEntry](capacity)
+}
+
+// add 追加一条记录
+func (l *ringBuffer[T]) add(entry T) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
 	}
 }
 
-// ContentTypeMiddleware 验证 POST/PUT 请求的 Content-Type
-// 📌 用途：确保客户端发送正确格式的数据
-func ContentTypeMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 只检查 POST 和 PUT 请求
-		if c.Request.Method == "POST" || c.Request.Method == "PUT" {
-			contentType := c.GetHeader("Content-Type")
+// recent 按从新到旧的顺序返回满足 keep 的记录
+func (l *ringBuffer[T]) recent(keep func(T) bool) []T {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 
-			// 检查是否为 application/json
-			// strings.Contains 因为可能是 "application/json; charset=utf-8"
-			if !strings.Contains(contentType, "application/json") {
-				requestID, _ := c.Get("request_id")
-				c.JSON(http.StatusUnsupportedMediaType, APIResponse{
-					Success:   false,
-					Error:     "Content-Type must be application/json",
-					RequestID: fmt.Sprintf("%v", requestID),
-				})
-				c.Abort()
-				return
-			}
-		}
+	count := l.next
+	if l.full {
+		count = len(l.entries)
+	}
 
-		c.Next()
+	result := make([]T, 0)
+	for i := 1; i <= count; i++ {
+		entry := l.entries[(l.next-i+len(l.entries))%len(l.entries)]
+		if keep(entry) {
+			result = append(result, entry)
+		}
 	}
+	return result
 }
 
-// ErrorHandlerMiddleware 捕获 panic 并返回友好的错误信息
-// 📌 用途：防止服务器崩溃，优雅地处理错误
-func ErrorHandlerMiddleware() gin.HandlerFunc {
-	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
-		// 获取 request ID
-		requestID, _ := c.Get("request_id")
+// requestLog 全局访问日志，由 LoggingMiddleware 写入
+var requestLog = newAccessLog(accessLogCapacity)
 
-		// 记录详细错误（仅服务器日志，包含真实panic信息）
-		log.Printf("[ERROR] [%v] Panic recovered: %v", requestID, recovered)
+// LogFormat 访问日志的输出格式
+type LogFormat int
 
-		// 返回统一的错误响应（完全不暴露panic细节）
-		c.Writer.Header().Set("Content-Type", "application/json; charset=utf-8")
-		c.Writer.WriteHeader(http.StatusInternalServerError)
+const (
+	// LogFormatText 人类可读的单行文本
+	LogFormatText LogFormat = iota
+	// LogFormatJSON 每个请求一行 JSON，方便日志系统解析
+	LogFormatJSON
+)
 
-		recoveredMsg := fmt.Sprint(recovered)
-		response := APIResponse{
-			Success:   false,
-			Message:   recoveredMsg,
-			Error:     "Internal server error",
-			RequestID: fmt.Sprintf("%v", requestID),
-		}
+// logFormat 当前使用的访问日志格式，可通过环境变量 LOG_FORMAT 配置
+var logFormat = LogFormatText
 
-		// 手动序列化JSON
-		jsonBytes, _ := json.Marshal(response)
-		c.Writer.Write(jsonBytes)
-	})
+// parseLogFormat 解析 "text" 或 "json"
+func parseLogFormat(format string) (LogFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "text":
+		return LogFormatText, nil
+	case "json":
+		return LogFormatJSON, nil
+	}
+	return 0, fmt.Errorf("unknown log format %q (want text or json)", format)
 }
 
-// Sanitize500Middleware 兜底清洗 500 响应体，防止泄露 panic 文本
-type sanitizeWriter struct {
-	gin.ResponseWriter
-	status int
-	buf    []byte
+// LoggingConfig 访问日志参数
+type LoggingConfig struct {
+	Format LogFormat
+	Output io.Writer // 为 nil 时写到标准库 log 的输出
 }
 
-func (w *sanitizeWriter) WriteHeader(code int) {
-	w.status = code
-	// 延迟写出，由中间件收尾统一处理
+// accessLogLine JSON 格式的一行访问日志
+type accessLogLine struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	DurationMs float64   `json:"duration_ms"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Bytes      int       `json:"bytes"` // 响应体字节数
 }
 
-func (w *sanitizeWriter) Write(p []byte) (int, error) {
-	w.buf = append(w.buf, p...)
-	return len(p), nil
+// LoggingMiddleware 记录所有请求的详细信息，格式由 LOG_FORMAT 决定
+// 📌 用途：监控 API 性能，调试问题
+func LoggingMiddleware() gin.HandlerFunc {
+	return LoggingMiddlewareWithConfig(LoggingConfig{Format: logFormat})
 }
 
-func Sanitize500Middleware() gin.HandlerFunc {
+// LoggingMiddlewareWithConfig 使用指定格式和输出的日志中间件
+// 📌 每条日志通过 log.Logger 一次写出，并发请求的日志不会交错
+func LoggingMiddlewareWithConfig(config LoggingConfig) gin.HandlerFunc {
+	output := config.Output
+	if output == nil {
+		output = log.Writer()
+	}
+	// JSON 里已经有 time 字段，不再加日志前缀
+	flags := log.LstdFlags
+	if config.Format == LogFormatJSON {
+		flags = 0
+	}
+	logger := log.New(output, "", flags)
+
+	return func(c *gin.Context) {
+		// 记录开始时间
+		startTime := time.Now()
+
+		// 从 context 获取 request_id
+		requestID, _ := c.Get("request_id")
+
+		// 执行请求（调用后续的中间件和处理器）
+		c.Next()
+
+		// 📌 c.Next() 之后的代码在请求处理完成后执行
+		// 这时我们可以获取响应状态码等信息
+
+		// 计算请求处理时间
+		duration := time.Since(startTime)
+
+		// 写入访问日志，供 GET /admin/requests 查询
+		// 认证在后续中间件中完成，所以在 c.Next() 之后读取角色
+		user, _ := c.Get("user_role")
+		userName, _ := user.(string)
+		requestLog.add(AccessLogEntry{
+			Timestamp:  startTime,
+			RequestID:  fmt.Sprintf("%v", requestID),
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			Status:     c.Writer.Status(),
+			DurationMs: float64(duration) / float64(time.Millisecond),
+			User:       userName,
+		})
+
+		if config.Format == LogFormatJSON {
+			// 没有写响应体时 Size() 为 -1
+			bytes := c.Writer.Size()
+			if bytes < 0 {
+				bytes = 0
+			}
+			line, err := json.Marshal(accessLogLine{
+				Time:       startTime,
+				RequestID:  fmt.Sprintf("%v", requestID),
+				Method:     c.Request.Method,
+				Path:       c.Request.URL.Path,
+				Status:     c.Writer.Status(),
+				DurationMs: float64(duration) / float64(time.Millisecond),
+				IP:         c.ClientIP(),
+				UserAgent:  c.Request.UserAgent(),
+				Bytes:      bytes,
+			})
+			if err == nil {
+				logger.Print(string(line))
+			}
+			return
+		}
+
+		// 格式化日志输出
+		logger.Printf("[%s] %s %s | Status: %d | Duration: %v | IP: %s | UserAgent: %s",
+			requestID,
+			c.Request.Method,
+			c.Request.URL.Path,
+			c.Writer.Status(),
+			duration,
+			c.ClientIP(),
+			c.Request.UserAgent(),
+		)
+	}
+}
+
+// EndpointCounts 各接口的调用次数，键为 "METHOD 路由模板"，如 "GET /articles/:id"
+type EndpointCounts map[string]int64
+
+// MarshalXML 键里有空格和斜杠，不能作为元素名，每个接口输出为一个 <endpoint> 元素
+func (counts EndpointCounts) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		endpoint := struct {
+			Key   string `xml:"key,attr"`
+			Count int64  `xml:"count,attr"`
+		}{key, counts[key]}
+		if err := e.EncodeElement(endpoint, xml.StartElement{Name: xml.Name{Local: "endpoint"}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// endpointCounter 并发安全的按接口计数器
+// 📌 每个接口一个原子计数器，热点接口的计数不需要加锁
+type endpointCounter struct {
+	counts sync.Map // string -> *atomic.Int64
+}
+
+// inc 给接口的计数加一
+func (ec *endpointCounter) inc(key string) {
+	counter, _ := ec.counts.LoadOrStore(key, new(atomic.Int64))
+	counter.(*atomic.Int64).Add(1)
+}
+
+// snapshot 返回当前各接口的计数
+func (ec *endpointCounter) snapshot() EndpointCounts {
+	counts := make(EndpointCounts)
+	ec.counts.Range(func(key, counter any) bool {
+		counts[key.(string)] = counter.(*atomic.Int64).Load()
+		return true
+	})
+	return counts
+}
+
+// endpointCounts 全局接口计数器，由 EndpointStatsMiddleware 写入
+var endpointCounts = &endpointCounter{}
+
+// EndpointStatsMiddleware 按方法和路由模板统计调用次数
+// 📌 用 c.FullPath() 而不是原始路径，/articles/1 和 /articles/2 都计入 /articles/:id
+// 📌 没有匹配到路由的请求（404）不计数
+func EndpointStatsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if route := c.FullPath(); route != "" {
+			endpointCounts.inc(c.Request.Method + " " + route)
+		}
+		c.Next()
+	}
+}
+
+// metricsPath 指标接口的路径
+const metricsPath = "/metrics"
+
+// durationBuckets 请求耗时直方图各个桶的上界（秒），最后还有一个 +Inf 桶
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// requestMetrics 并发安全的请求指标：总数、按状态码计数、耗时直方图
+type requestMetrics struct {
+	mu          sync.Mutex
+	total       int64
+	byStatus    map[int]int64
+	buckets     []int64 // 每个桶各自的计数（不累计），比 durationBuckets 多一个 +Inf 桶
+	durationSum float64 // 耗时总和（秒）
+}
+
+func newRequestMetrics() *requestMetrics {
+	return &requestMetrics{
+		byStatus: make(map[int]int64),
+		buckets:  make([]int64, len(durationBuckets)+1),
+	}
+}
+
+// observe 记录一个已完成的请求
+func (m *requestMetrics) observe(status int, duration time.Duration) {
+	seconds := duration.Seconds()
+	bucket := sort.SearchFloat64s(durationBuckets, seconds) // 第一个上界 >= seconds 的桶
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.total++
+	m.byStatus[status]++
+	m.buckets[bucket]++
+	m.durationSum += seconds
+}
+
+// MetricsSnapshot 某一时刻的请求指标
+type MetricsSnapshot struct {
+	TotalRequests int64
+	ByStatus      map[int]int64
+	Buckets       []int64 // 累计计数，Buckets[i] 为耗时 <= durationBuckets[i] 的请求数，最后一个为 +Inf
+	DurationSum   float64
+}
+
+// snapshot 复制当前的指标，调用方可以放心读取
+func (m *requestMetrics) snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	byStatus := make(map[int]int64, len(m.byStatus))
+	for status, count := range m.byStatus {
+		byStatus[status] = count
+	}
+	buckets := make([]int64, len(m.buckets))
+	var cumulative int64
+	for i, count := range m.buckets {
+		cumulative += count
+		buckets[i] = cumulative
+	}
+	return MetricsSnapshot{
+		TotalRequests: m.total,
+		ByStatus:      byStatus,
+		Buckets:       buckets,
+		DurationSum:   m.durationSum,
+	}
+}
+
+// writeText 按 Prometheus 文本格式写出指标
+// 📌 状态码按数字升序输出，保证每次输出顺序一致
+func (s MetricsSnapshot) writeText(w io.Writer) {
+	fmt.Fprintln(w, "# HELP http_requests_total Total number of HTTP requests.")
+	fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	fmt.Fprintf(w, "http_requests_total %d\n", s.TotalRequests)
+
+	statuses := make([]int, 0, len(s.ByStatus))
+	for status := range s.ByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	fmt.Fprintln(w, "# HELP http_requests_by_status_total Number of HTTP requests by response status code.")
+	fmt.Fprintln(w, "# TYPE http_requests_by_status_total counter")
+	for _, status := range statuses {
+		fmt.Fprintf(w, "http_requests_by_status_total{code=\"%d\"} %d\n", status, s.ByStatus[status])
+	}
+
+	fmt.Fprintln(w, "# HELP http_request_duration_seconds HTTP request duration in seconds.")
+	fmt.Fprintln(w, "# TYPE http_request_duration_seconds histogram")
+	for i, bound := range durationBuckets {
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket{le=\"%s\"} %d\n", strconv.FormatFloat(bound, 'g', -1, 64), s.Buckets[i])
+	}
+	fmt.Fprintf(w, "http_request_duration_seconds_bucket{le=\"+Inf\"} %d\n", s.Buckets[len(durationBuckets)])
+	fmt.Fprintf(w, "http_request_duration_seconds_sum %s\n", strconv.FormatFloat(s.DurationSum, 'g', -1, 64))
+	fmt.Fprintf(w, "http_request_duration_seconds_count %d\n", s.TotalRequests)
+}
+
+// metrics 全局请求指标，由 MetricsMiddleware 写入
+var metrics = newRequestMetrics()
+
+// MetricsMiddleware 统计请求总数、各状态码的请求数和请求耗时
+// 📌 和 EndpointStatsMiddleware 不同，没有匹配到路由的请求和被提前拒绝的请求也计入
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		metrics.observe(c.Writer.Status(), time.Since(start))
+	}
+}
+
+// DebugCapture 调试记录：一次请求的请求体和响应体（敏感字段已脱敏）
+type DebugCapture struct {
+	Timestamp    time.Time `json:"timestamp" xml:"timestamp"`
+	RequestID    string    `json:"request_id" xml:"request_id"`
+	Method       string    `json:"method" xml:"method"`
+	Path         string    `json:"path" xml:"path"`
+	Status       int       `json:"status" xml:"status"`
+	RequestBody  string    `json:"request_body,omitempty" xml:"request_body,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty" xml:"response_body,omitempty"`
+}
+
+const (
+	debugCaptureCapacity = 100      // 最多保留的调试记录条数
+	debugCaptureMaxBody  = 64 << 10 // 每个 body 最多保存的字节数
+	debugCapturePath     = "/admin/debug/captures"
+)
+
+// debugCaptures 全局调试记录，由 DebugCaptureMiddleware 写入
+var debugCaptures = newRingBuffer[DebugCapture](debugCaptureCapacity)
+
+// sensitiveFieldParts JSON 字段名（忽略大小写）包含这些片段时，值会被替换
+var sensitiveFieldParts = []string{"password", "token", "secret", "api_key", "apikey"}
+
+const redactedValue = "[REDACTED]"
+
+// redactBody 脱敏 body 后返回要保存的文本
+// 📌 无法解析或超长（截断后无法解析）的 body 不保存原文，因为无法确认里面没有密码
+func redactBody(body []byte) string {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ""
+	}
+	if len(body) > debugCaptureMaxBody {
+		return fmt.Sprintf("[body over %d bytes omitted]", debugCaptureMaxBody)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber() // 保持数字原样，避免转成 float64 丢精度
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Sprintf("[non-JSON body, %d bytes]", len(body))
+	}
+	redacted, _ := json.Marshal(redactValue(value))
+	return string(redacted)
+}
+
+// redactValue 递归替换对象中敏感字段的值
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isSensitiveField(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+func isSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range sensitiveFieldParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// captureWriter 写出响应的同时保留一份副本
+// 📌 与 sanitizeWriter 不同，这里不缓冲也不改写响应，只是旁路复制
+type captureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	w.keep(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// keep 保存副本，最多多留 1 字节用于判断是否需要截断
+func (w *captureWriter) keep(p []byte) {
+	if room := debugCaptureMaxBody + 1 - w.body.Len(); room > 0 {
+		if len(p) > room {
+			p = p[:room]
+		}
+		w.body.Write(p)
+	}
+}
+
+// DebugCaptureMiddleware 在 debugCapture 开启时记录请求体和响应体
+// 📌 请求体读出后会放回 c.Request.Body，后续处理器照常读取
+func DebugCaptureMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 查看调试记录的请求本身不记录，否则每次查看都会把旧记录再存一遍
+		if !debugCapture || c.FullPath() == debugCapturePath {
+			c.Next()
+			return
+		}
+
+		var requestBody []byte
+		if c.Request.Body != nil {
+			var err error
+			requestBody, err = io.ReadAll(c.Request.Body)
+			if err != nil {
+				// 📌 读取出错（如超过 BodySizeLimitMiddleware 的限制）时接上原来的 Body，
+				// 处理器读完已读出的部分后会得到同样的错误，而不是一个被截断的请求体
+				c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(requestBody), c.Request.Body))
+			} else {
+				c.Request.Body = io.NopCloser(bytes.NewReader(requestBody))
+			}
+		}
+
+		cw := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = cw
+
+		c.Next()
+
+		requestID, _ := c.Get("request_id")
+		debugCaptures.add(DebugCapture{
+			Timestamp:    time.Now(),
+			RequestID:    fmt.Sprintf("%v", requestID),
+			Method:       c.Request.Method,
+			Path:         c.Request.URL.Path,
+			Status:       cw.Status(),
+			RequestBody:  redactBody(requestBody),
+			ResponseBody: redactBody(cw.body.Bytes()),
+		})
+	}
+}
+
+// SignedKeyPayload 签名 API Key 中携带的内容
+type SignedKeyPayload struct {
+	Role      string `json:"role"`
+	ExpiresAt int64  `json:"exp"` // Unix 秒
+}
+
+// apiKeyPublicKey 用于验证签名 API Key 的 ECDSA 公钥，为 nil 时只接受静态 Key
+// 📌 启动时从环境变量 API_KEY_PUBLIC_KEY（PEM 格式）加载
+var apiKeyPublicKey *ecdsa.PublicKey
+
+var (
+	errInvalidSignedKey = errors.New("Invalid API Key")
+	errExpiredSignedKey = errors.New("API Key has expired")
+	errInvalidAPIKey    = errors.New("Invalid API Key")
+)
+
+// parseECDSAPublicKeyPEM 解析 PEM 格式的 ECDSA 公钥
+func parseECDSAPublicKeyPEM(data []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not ECDSA")
+	}
+	return ecKey, nil
+}
+
+// signAPIKey 签发形如 base64(payload).base64(sig) 的 API Key
+// 签名是对 payload JSON 的 SHA-256 摘要做 ECDSA 签名
+func signAPIKey(privateKey *ecdsa.PrivateKey, payload SignedKeyPayload) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, privateKey, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data) + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// verifySignedAPIKey 验证签名并检查是否过期，成功时返回 payload
+func verifySignedAPIKey(publicKey *ecdsa.PublicKey, key string, now time.Time) (*SignedKeyPayload, error) {
+	encodedPayload, encodedSig, found := strings.Cut(key, ".")
+	if !found {
+		return nil, errInvalidSignedKey
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, errInvalidSignedKey
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return nil, errInvalidSignedKey
+	}
+
+	// 📌 先验签再解析，避免信任被篡改的内容
+	digest := sha256.Sum256(data)
+	if !ecdsa.VerifyASN1(publicKey, digest[:], sig) {
+		return nil, errInvalidSignedKey
+	}
+
+	var payload SignedKeyPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, errInvalidSignedKey
+	}
+	if payload.Role != "admin" && payload.Role != "user" {
+		return nil, errInvalidSignedKey
+	}
+	if !now.Before(time.Unix(payload.ExpiresAt, 0)) {
+		return nil, errExpiredSignedKey
+	}
+	return &payload, nil
+}
+
+// validAPIKeys 有效的静态 API Key 和对应的角色
+// 实际项目中应该从数据库或配置文件读取
+var validAPIKeys = map[string]string{
+	"admin-key-123": "admin",
+	"user-key-456":  "user",
+}
+
+// authenticateAPIKey 验证 API Key，返回对应的角色
+// 📌 配置了公钥时，带 "." 的 Key 按签名 Key 验证，其余仍查静态表
+func authenticateAPIKey(apiKey string, now time.Time) (string, error) {
+	if apiKeyPublicKey != nil && strings.Contains(apiKey, ".") {
+		payload, err := verifySignedAPIKey(apiKeyPublicKey, apiKey, now)
+		if err != nil {
+			return "", err
+		}
+		return payload.Role, nil
+	}
+
+	role, exists := validAPIKeys[apiKey]
+	if !exists {
+		return "", errInvalidAPIKey
+	}
+	return role, nil
+}
+
+// AuthMiddleware 验证 API Key 并设置用户角色
+// 📌 用途：保护敏感接口，实现权限控制
+func AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 从请求头获取 API Key
+		apiKey := c.GetHeader("X-API-Key")
+
+		// 检查 API Key 是否为空
+		if apiKey == "" {
+			requestID, _ := c.Get("request_id")
+			respond(c, http.StatusUnauthorized, APIResponse{
+				Success:   false,
+				Error:     "API Key is required",
+				RequestID: fmt.Sprintf("%v", requestID),
+			})
+			// 📌 关键：调用 c.Abort() 停止后续处理器的执行
+			c.Abort()
+			return
+		}
+
+		// 验证 API Key 是否有效
+		role, err := authenticateAPIKey(apiKey, time.Now())
+		if err != nil {
+			requestID, _ := c.Get("request_id")
+			respond(c, http.StatusUnauthorized, APIResponse{
+				Success:   false,
+				Error:     err.Error(),
+				RequestID: fmt.Sprintf("%v", requestID),
+			})
+			c.Abort()
+			return
+		}
+
+		// 将用户角色存储到 context 中
+		c.Set("user_role", role)
+
+		// 继续执行后续处理器
+		c.Next()
+	}
+}
+
+// APIKeyRoleMiddleware 带了有效 API Key 时提前设置用户角色，不拦截任何请求
+// 📌 用途：让 RateLimitMiddleware 等在 AuthMiddleware 之前运行的全局中间件也能按角色处理；
+// 没带 Key 或 Key 无效时按匿名请求处理，受保护的接口仍由 AuthMiddleware 拒绝
+func APIKeyRoleMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			if role, err := authenticateAPIKey(apiKey, time.Now()); err == nil {
+				c.Set("user_role", role)
+			}
+		}
+		c.Next()
+	}
+}
+
+// defaultContentSecurityPolicy 纯 JSON API 不需要加载任何资源，也不允许被嵌入页面
+const defaultContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+
+// contentSecurityPolicy 当前使用的 CSP，可通过环境变量 CONTENT_SECURITY_POLICY 配置
+var contentSecurityPolicy = defaultContentSecurityPolicy
+
+// SecurityHeadersConfig 安全响应头参数
+type SecurityHeadersConfig struct {
+	ContentSecurityPolicy string // 为空时不发送 Content-Security-Policy
+}
+
+// SecurityHeadersMiddleware 为所有响应加上常用的安全响应头
+// 📌 用途：禁止 MIME 嗅探、禁止被 iframe 嵌入、不泄露 Referer
+func SecurityHeadersMiddleware() gin.HandlerFunc {
+	return SecurityHeadersMiddlewareWithConfig(SecurityHeadersConfig{ContentSecurityPolicy: contentSecurityPolicy})
+}
+
+// SecurityHeadersMiddlewareWithConfig 使用指定 CSP 的安全响应头中间件
+// 📌 只设置自己的响应头，不碰 Access-Control-*，和 CORSMiddleware 互不影响
+func SecurityHeadersMiddlewareWithConfig(config SecurityHeadersConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 在 c.Next() 之前设置，处理器或后续中间件提前返回时也会带上
+		header := c.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Referrer-Policy", "no-referrer")
+		if config.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", config.ContentSecurityPolicy)
+		}
+
+		c.Next()
+	}
+}
+
+// CORSConfig 跨域资源共享 (CORS) 参数
+// 📌 AllowOrigins 支持三种写法：
+//   - 精确匹配："https://myblog.com"
+//   - 通配符模式："https://*.myblog.com"（匹配任意子域名，不匹配 myblog.com 本身）
+//   - "*"：允许任意来源
+//
+// 📌 AllowCredentials 为 true 时，规范不允许返回 Access-Control-Allow-Origin: *，
+// 此时回显请求的 Origin，并加上 Vary: Origin 避免缓存串用
+type CORSConfig struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string // 允许浏览器 JS 读取的响应头
+	AllowCredentials bool
+	MaxAge           time.Duration // 预检结果的缓存时间
+}
+
+// 默认：只允许前端开发环境和正式站点，AllowOrigins 可在启动时用 CORS_ALLOWED_ORIGINS 覆盖
+var defaultCORSConfig = CORSConfig{
+	AllowOrigins:     []string{"http://localhost:3000", "https://myblog.com"},
+	AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+	AllowHeaders:     []string{"Content-Type", "X-API-Key", "X-Request-ID"},
+	ExposeHeaders:    []string{"X-Request-ID", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
+	AllowCredentials: true,
+	MaxAge:           24 * time.Hour,
+}
+
+// parseCORSOrigins 解析逗号分隔的来源列表，忽略空项和末尾的 /
+// 📌 Origin 头不带路径，"https://myblog.com/" 这样的写法永远匹配不上，所以去掉末尾的 /
+func parseCORSOrigins(value string) ([]string, error) {
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		return nil, fmt.Errorf("no origins in %q", value)
+	}
+	return origins, nil
+}
+
+// matchOrigin 判断 origin 是否匹配 pattern（精确、"*" 或含一个 * 的模式）
+func matchOrigin(pattern, origin string) bool {
+	if pattern == "*" || pattern == origin {
+		return true
+	}
+	prefix, suffix, found := strings.Cut(pattern, "*")
+	return found &&
+		len(origin) > len(prefix)+len(suffix) &&
+		strings.HasPrefix(origin, prefix) &&
+		strings.HasSuffix(origin, suffix)
+}
+
+// allowedOrigin 返回 Access-Control-Allow-Origin 的值，不允许时 ok 为 false
+func (config CORSConfig) allowedOrigin(origin string) (value string, ok bool) {
+	if origin == "" {
+		return "", false // 不是跨域请求
+	}
+	for _, pattern := range config.AllowOrigins {
+		if !matchOrigin(pattern, origin) {
+			continue
+		}
+		// 📌 带凭证时不能用 *，只能回显具体的 Origin
+		if pattern == "*" && !config.AllowCredentials {
+			return "*", true
+		}
+		return origin, true
+	}
+	return "", false
+}
+
+// CORSMiddleware 处理跨域资源共享 (CORS)，使用默认参数
+// 📌 用途：允许浏览器从不同域名访问 API
+func CORSMiddleware() gin.HandlerFunc {
+	return CORSMiddlewareWithConfig(defaultCORSConfig)
+}
+
+// CORSMiddlewareWithConfig 使用指定参数的 CORS 中间件
+func CORSMiddlewareWithConfig(config CORSConfig) gin.HandlerFunc {
+	// 提前拼好固定的响应头
+	methods := strings.Join(config.AllowMethods, ", ")
+	headers := strings.Join(config.AllowHeaders, ", ")
+	exposed := strings.Join(config.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(config.MaxAge / time.Second))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == "OPTIONS"
+
+		if value, ok := config.allowedOrigin(origin); ok {
+			c.Header("Access-Control-Allow-Origin", value)
+			if value != "*" {
+				// 响应随 Origin 变化，告诉缓存按 Origin 区分
+				c.Writer.Header().Add("Vary", "Origin")
+			}
+			if config.AllowCredentials {
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", headers)
+
+			if preflight {
+				if config.MaxAge > 0 {
+					c.Header("Access-Control-Max-Age", maxAge)
+				}
+			} else if exposed != "" {
+				c.Header("Access-Control-Expose-Headers", exposed)
+			}
+		}
+
+		// 📌 处理 OPTIONS 预检请求
+		// 浏览器在发送跨域请求前，会先发送 OPTIONS 请求询问是否允许
+		// 来源不被允许时同样直接返回，只是不带 CORS 头，浏览器会拒绝后续请求
+		if preflight {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// draining 为 true 时服务处于排空模式：写请求返回 503，读请求照常处理
+// 📌 用途：停机维护前先排空写入，再配合优雅关闭
+var draining atomic.Bool
+
+// drainRetryAfter 排空模式下建议客户端重试的等待时间
+var drainRetryAfter = 30 * time.Second
+
+// isWriteMethod 判断请求是否会修改数据
+func isWriteMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// DrainMiddleware 排空模式下拒绝写请求
+// 📌 /admin/ 下的接口不受影响，否则进入排空模式后就无法再调用 /admin/undrain
+func DrainMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !draining.Load() || !isWriteMethod(c.Request.Method) || strings.HasPrefix(c.Request.URL.Path, "/admin/") {
+			c.Next()
+			return
+		}
+
+		requestID, _ := c.Get("request_id")
+		c.Header("Retry-After", strconv.Itoa(int(drainRetryAfter/time.Second)))
+		respond(c, http.StatusServiceUnavailable, APIResponse{
+			Success:   false,
+			Error:     "Server is draining for maintenance, writes are temporarily disabled",
+			RequestID: fmt.Sprintf("%v", requestID),
+		})
+		c.Abort()
+	}
+}
+
+// RateLimitConfig 限流参数（令牌桶）
+// 📌 语义：
+//   - Limit/Window：每个 Window 补充 Limit 个令牌，即长期平均速率
+//   - Burst：桶容量，新客户端一开始就拥有 Burst 个令牌，可连续发出这么多请求
+//   - X-RateLimit-Remaining：此刻还能立即发出的请求数，
+//     即可用令牌数向下取整，并限制在 [0, Limit]，不会超过 X-RateLimit-Limit
+//   - X-RateLimit-Reset：令牌桶重新装满的时间（Unix 秒，向上取整）
+//   - IdleTimeout：IP 超过这么久没有请求就回收它的限流器，0 表示不回收
+type RateLimitConfig struct {
+	Limit       int           // 每个窗口允许的请求数（匿名请求，按 IP）
+	Window      time.Duration // 窗口长度
+	Burst       int           // 突发容量
+	IdleTimeout time.Duration // 空闲多久后回收
+	// RoleLimits 按角色的每窗口请求数，按 API Key 分别限流，突发容量等于该值
+	// 📌 没有列出的角色和匿名请求一样按 IP 使用 Limit
+	RoleLimits map[string]int
+}
+
+// 默认：匿名每分钟 100 个请求，普通用户 300 个，管理员 1000 个，允许一次性用完，空闲 10 分钟回收
+var defaultRateLimitConfig = RateLimitConfig{
+	Limit:       100,
+	Window:      time.Minute,
+	Burst:       100,
+	IdleTimeout: 10 * time.Minute,
+	RoleLimits:  map[string]int{"admin": 1000, "user": 300},
+}
+
+// validate 检查限流参数，Limit、Window、Burst 和 RoleLimits 中的值都必须为正数
+// 📌 Limit 为 0 时 newRateLimiter 会除以 0，Burst 为 0 时所有请求都会被拒绝
+func (config RateLimitConfig) validate() error {
+	if config.Limit <= 0 || config.Window <= 0 || config.Burst <= 0 {
+		return fmt.Errorf("limit, window and burst must be positive, got %d, %s and %d", config.Limit, config.Window, config.Burst)
+	}
+	if config.IdleTimeout < 0 {
+		return fmt.Errorf("idle timeout must not be negative, got %s", config.IdleTimeout)
+	}
+	for role, limit := range config.RoleLimits {
+		if limit <= 0 {
+			return fmt.Errorf("limit for role %q must be positive, got %d", role, limit)
+		}
+	}
+	return nil
+}
+
+// newRateLimiter 按配置创建令牌桶
+// rate.Every(time.Minute / 100) = 每 0.6 秒补充一个令牌
+func newRateLimiter(config RateLimitConfig) *rate.Limiter {
+	return rate.NewLimiter(rate.Every(config.Window/time.Duration(config.Limit)), config.Burst)
+}
+
+// rateLimitRemaining 计算 now 时刻还能立即发出的请求数
+// 📌 用 TokensAt(now) 而不是 Tokens()，与 AllowN(now, 1) 使用同一时刻，避免两次取时间造成偏差
+func rateLimitRemaining(limiter *rate.Limiter, config RateLimitConfig, now time.Time) int {
+	remaining := int(math.Floor(limiter.TokensAt(now)))
+	if remaining < 0 {
+		remaining = 0
+	}
+	if remaining > config.Limit {
+		remaining = config.Limit
+	}
+	return remaining
+}
+
+// rateLimitReset 计算令牌桶重新装满的时间
+func rateLimitReset(limiter *rate.Limiter, config RateLimitConfig, now time.Time) time.Time {
+	missing := float64(config.Burst) - limiter.TokensAt(now)
+	if missing <= 0 {
+		return now
+	}
+	interval := config.Window / time.Duration(config.Limit)
+	return now.Add(time.Duration(missing * float64(interval)))
+}
+
+// ipLimiter 单个 IP 的令牌桶和最后一次请求的时间
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiters 按 IP（或 API Key）保存的限流器
+// 📌 每个出现过的 IP 都占一项，不回收的话 map 会随着 IP 数量一直增长
+type rateLimiters struct {
+	mu       sync.Mutex // 保护 map 的并发访问
+	config   RateLimitConfig
+	limiters map[string]*ipLimiter // key: IP 地址或 API Key
+}
+
+func newRateLimiters(config RateLimitConfig) *rateLimiters {
+	return &rateLimiters{config: config, limiters: make(map[string]*ipLimiter)}
+}
+
+// startRateLimiters 创建限流器集合，配置了 IdleTimeout 时启动后台回收
+// 📌 回收 goroutine 在 ctx 结束时退出
+func startRateLimiters(ctx context.Context, config RateLimitConfig) *rateLimiters {
+	rl := newRateLimiters(config)
+	if config.IdleTimeout > 0 {
+		go rl.sweepEvery(ctx, config.IdleTimeout/2)
+	}
+	return rl
+}
+
+// get 返回 ip 的限流器，没有时创建，并记录这次请求的时间
+func (rl *rateLimiters) get(ip string, now time.Time) *rate.Limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	entry, exists := rl.limiters[ip]
+	if !exists {
+		// 为新 IP 创建限流器
+		entry = &ipLimiter{limiter: newRateLimiter(rl.config)}
+		rl.limiters[ip] = entry
+	}
+	entry.lastSeen = now
+	return entry.limiter
+}
+
+// sweep 回收空闲超过 IdleTimeout 的限流器，返回回收的数量
+// 📌 只回收令牌桶已经装满的：回收后新建的令牌桶也是满的，客户端看不出区别，
+// 不会因为回收而多拿到令牌，X-RateLimit-* 头也保持准确
+func (rl *rateLimiters) sweep(now time.Time) int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	removed := 0
+	for ip, entry := range rl.limiters {
+		if now.Sub(entry.lastSeen) >= rl.config.IdleTimeout && entry.limiter.TokensAt(now) >= float64(rl.config.Burst) {
+			delete(rl.limiters, ip)
+			removed++
+		}
+	}
+	return removed
+}
+
+// sweepEvery 每隔 interval 回收一次空闲的限流器，直到 ctx 结束
+func (rl *rateLimiters) sweepEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			rl.sweep(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// count 当前保存的限流器数量
+func (rl *rateLimiters) count() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return len(rl.limiters)
+}
+
+// rateLimitTiers 匿名请求和各角色各自独立的限流器
+// 📌 分开保存，同一个 key 在不同角色下不会共用令牌桶
+type rateLimitTiers struct {
+	anonymous *rateLimiters            // key: IP 地址
+	roles     map[string]*rateLimiters // key: 角色 → API Key
+}
+
+// startRateLimitTiers 按配置为匿名请求和 RoleLimits 中的每个角色创建限流器
+func startRateLimitTiers(ctx context.Context, config RateLimitConfig) *rateLimitTiers {
+	tiers := &rateLimitTiers{
+		anonymous: startRateLimiters(ctx, config),
+		roles:     make(map[string]*rateLimiters, len(config.RoleLimits)),
+	}
+	for role, limit := range config.RoleLimits {
+		tiers.roles[role] = startRateLimiters(ctx, RateLimitConfig{
+			Limit:       limit,
+			Window:      config.Window,
+			Burst:       limit,
+			IdleTimeout: config.IdleTimeout,
+		})
+	}
+	return tiers
+}
+
+// pick 返回请求使用的限流器组和在组内的 key
+// 📌 user_role 由 APIKeyRoleMiddleware 设置，只有 API Key 有效时才存在
+func (t *rateLimitTiers) pick(c *gin.Context) (*rateLimiters, string) {
+	if role, ok := c.Get("user_role"); ok {
+		if limiters, ok := t.roles[fmt.Sprintf("%v", role)]; ok {
+			return limiters, c.GetHeader("X-API-Key")
+		}
+	}
+	return t.anonymous, c.ClientIP()
+}
+
+// RateLimitMiddleware 实现按角色分级的速率限制，使用默认参数
+// 📌 用途：防止 API 被滥用，保护服务器资源
+// 📌 需要放在 APIKeyRoleMiddleware 之后，否则所有请求都按匿名请求限流
+func RateLimitMiddleware() gin.HandlerFunc {
+	return RateLimitMiddlewareWithConfig(defaultRateLimitConfig)
+}
+
+// RateLimitMiddlewareWithConfig 使用指定参数的限流中间件
+// 📌 回收 goroutine 和中间件一样在进程的整个生命周期内运行
+func RateLimitMiddlewareWithConfig(config RateLimitConfig) gin.HandlerFunc {
+	return RateLimitMiddlewareWithContext(context.Background(), config)
+}
+
+// RateLimitMiddlewareWithContext 使用指定参数的限流中间件，ctx 结束时停止后台回收
+// 📌 每次调用都会为每个限流层启动一个回收 goroutine，测试等短期使用的路由要传入会结束的 ctx，否则会泄漏
+// 📌 参数无效时 panic，和其他配置错误一样在启动时暴露
+func RateLimitMiddlewareWithContext(ctx context.Context, config RateLimitConfig) gin.HandlerFunc {
+	if err := config.validate(); err != nil {
+		panic("invalid RateLimitConfig: " + err.Error())
+	}
+	return rateLimitMiddleware(startRateLimitTiers(ctx, config))
+}
+
+// rateLimitMiddleware 使用 tiers 中的令牌桶限流
+func rateLimitMiddleware(tiers *rateLimitTiers) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limiters, key := tiers.pick(c)
+		config := limiters.config
+
+		// 检查是否允许请求，之后的计算都基于同一个 now
+		now := time.Now()
+		limiter := limiters.get(key, now)
+		allowed := limiter.AllowN(now, 1)
+
+		// 设置速率限制信息头，X-RateLimit-Limit 是这个请求实际适用的上限
+		c.Header("X-RateLimit-Limit", strconv.Itoa(config.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(rateLimitRemaining(limiter, config, now)))
+		// 向上取整到秒，否则还差不到 1 秒装满时会得到一个已经过去的时间
+		resetAt := rateLimitReset(limiter, config, now)
+		resetUnix := resetAt.Unix()
+		if resetAt.Nanosecond() > 0 {
+			resetUnix++
+		}
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(resetUnix, 10))
+
+		if !allowed {
+			// 超过速率限制
+			requestID, _ := c.Get("request_id")
+			respond(c, http.StatusTooManyRequests, APIResponse{
+				Success:   false,
+				Error:     "Rate limit exceeded. Try again later.",
+				RequestID: fmt.Sprintf("%v", requestID),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// maxRequestBodyBytes 请求体大小上限，文章接口的请求体远小于这个值
+const maxRequestBodyBytes int64 = 1 << 20
+
+// payloadTooLarge 返回 413
+func payloadTooLarge(c *gin.Context, limit int64) {
+	respondError(c, http.StatusRequestEntityTooLarge, "Request body too large", fmt.Sprintf("limit is %d bytes", limit))
+	c.Abort()
+}
+
+// BodySizeLimitMiddleware 请求体超过 maxBytes 时返回 413
+// 📌 Content-Length 已经超过上限时直接拒绝，不读取请求体
+// 📌 没有 Content-Length（分块传输）或谎报长度时，用 http.MaxBytesReader 包装请求体，
+// 读到上限就返回 *http.MaxBytesError，ShouldBindJSON 不会把超大的请求体读进内存，
+// 处理器通过 bindJSON 把这个错误转换为 413
+func BodySizeLimitMiddleware(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > maxBytes {
+			payloadTooLarge(c, maxBytes)
+			return
+		}
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
+
+// bindJSON 解析 JSON 请求体，失败时写出错误响应并返回 false
+// 📌 请求体超过 BodySizeLimitMiddleware 的限制时返回 413，其他错误返回 400
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		payloadTooLarge(c, tooLarge.Limit)
+		return false
+	}
+	respondError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+	return false
+}
+
+// idempotencyKeyHeader 客户端重试同一个请求时带上相同的值
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyKeyMaxLength Idempotency-Key 的最大长度
+const idempotencyKeyMaxLength = 255
+
+// IdempotencyConfig 幂等中间件参数
+type IdempotencyConfig struct {
+	TTL time.Duration // 保存响应的时间，过期后同一个 key 会被当作新请求
+}
+
+var defaultIdempotencyConfig = IdempotencyConfig{
+	TTL: 24 * time.Hour,
+}
+
+// cachedResponse 保存下来的响应，重放时原样写回
+type cachedResponse struct {
+	status int
+	header http.Header // 只保存处理器自己设置的响应头
+	body   []byte
+}
+
+// idempotencyEntry response 为 nil 表示第一次请求还在处理中
+type idempotencyEntry struct {
+	response  *cachedResponse
+	bodyHash  [sha256.Size]byte // 第一次请求的请求体摘要，同一个 key 只能用于同一个请求体
+	expiresAt time.Time
+}
+
+// idempotencyStore key → 响应，过期的记录在下次 begin 时清理
+type idempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*idempotencyEntry
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{ttl: ttl, entries: make(map[string]*idempotencyEntry)}
+}
+
+// begin 查找 key：key 已用于其他请求体时 mismatch 为 true；已有响应时返回该响应；
+// 正在处理时 inProgress 为 true；都不是时占住这个 key，调用方处理完后必须调用 finish
+func (s *idempotencyStore) begin(key string, bodyHash [sha256.Size]byte, now time.Time) (response *cachedResponse, inProgress, mismatch bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, k)
+		}
+	}
+
+	if entry, ok := s.entries[key]; ok {
+		if entry.bodyHash != bodyHash {
+			return nil, false, true
+		}
+		return entry.response, entry.response == nil, false
+	}
+	s.entries[key] = &idempotencyEntry{bodyHash: bodyHash, expiresAt: now.Add(s.ttl)}
+	return nil, false, false
+}
+
+// finish 保存响应；response 为 nil 时释放 key，之后的重试会重新处理
+func (s *idempotencyStore) finish(key string, bodyHash [sha256.Size]byte, response *cachedResponse, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if response == nil {
+		delete(s.entries, key)
+		return
+	}
+	s.entries[key] = &idempotencyEntry{response: response, bodyHash: bodyHash, expiresAt: now.Add(s.ttl)}
+}
+
+// recordingWriter 写出响应的同时完整保留一份响应体
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware 让带 Idempotency-Key 的请求可以安全重试，使用默认参数
+// 📌 用途：客户端超时后重试 POST /articles，不会创建出重复的文章
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return IdempotencyMiddlewareWithConfig(defaultIdempotencyConfig)
+}
+
+// IdempotencyMiddlewareWithConfig 使用指定参数的幂等中间件
+// 📌 同一个 key 在 TTL 内再次请求时直接返回第一次的响应（加上 Idempotent-Replayed: true），
+// 第一次请求还没处理完时返回 409
+// 📌 key 按 API Key 区分，不同调用方用了相同的 key 也不会拿到别人的响应
+// 📌 只保存 2xx 响应：失败的请求没有创建任何东西，重试时重新处理即可
+// 📌 同时保存请求体的 SHA-256，同一个 key 换了请求体说明客户端用错了 key，返回 422 而不是重放别的请求的响应
+func IdempotencyMiddlewareWithConfig(config IdempotencyConfig) gin.HandlerFunc {
+	store := newIdempotencyStore(config.TTL)
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > idempotencyKeyMaxLength {
+			respondError(c, http.StatusBadRequest, "Invalid Idempotency-Key", fmt.Sprintf("must be at most %d characters", idempotencyKeyMaxLength))
+			c.Abort()
+			return
+		}
+
+		// 读出请求体计算摘要，再放回去给处理器使用
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			if body, err = io.ReadAll(c.Request.Body); err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					payloadTooLarge(c, tooLarge.Limit)
+					return
+				}
+				respondError(c, http.StatusBadRequest, "Invalid request body", err.Error())
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		bodyHash := sha256.Sum256(body)
+
+		scopedKey := c.GetHeader("X-API-Key") + "\x00" + c.Request.Method + " " + c.FullPath() + "\x00" + key
+		cached, inProgress, mismatch := store.begin(scopedKey, bodyHash, time.Now())
+		if mismatch {
+			respondError(c, http.StatusUnprocessableEntity, "Idempotency-Key reused", "this Idempotency-Key was already used with a different request body")
+			c.Abort()
+			return
+		}
+		if inProgress {
+			respondError(c, http.StatusConflict, "Request in progress", "a request with this Idempotency-Key is still being processed")
+			c.Abort()
+			return
+		}
+		if cached != nil {
+			header := c.Writer.Header()
+			for name, values := range cached.header {
+				header[name] = values
+			}
+			header.Set("Idempotent-Replayed", "true")
+			c.Writer.WriteHeader(cached.status)
+			_, _ = c.Writer.Write(cached.body)
+			c.Abort()
+			return
+		}
+
+		// 记下处理前的响应头，处理完后只保存新增或改动的
+		before := c.Writer.Header().Clone()
+		rw := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = rw
+
+		var response *cachedResponse
+		// 📌 用 defer 释放 key，处理器 panic 时同一个 key 也能重试
+		defer func() {
+			c.Writer = rw.ResponseWriter
+			store.finish(scopedKey, bodyHash, response, time.Now())
+		}()
+
+		c.Next()
+
+		status := rw.Status()
+		if status < 200 || status >= 300 {
+			return
+		}
+		header := make(http.Header)
+		for name, values := range rw.Header() {
+			if !slices.Equal(before[name], values) {
+				header[name] = slices.Clone(values)
+			}
+		}
+		response = &cachedResponse{status: status, header: header, body: bytes.Clone(rw.body.Bytes())}
+	}
+}
+
+// ContentTypeMiddleware 验证 POST/PUT 请求的 Content-Type
+// 📌 用途：确保客户端发送正确格式的数据
+func ContentTypeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 只检查 POST 和 PUT 请求
+		if c.Request.Method == "POST" || c.Request.Method == "PUT" {
+			contentType := c.GetHeader("Content-Type")
+
+			// 检查是否为 application/json
+			// strings.Contains 因为可能是 "application/json; charset=utf-8"
+			if !strings.Contains(contentType, "application/json") {
+				requestID, _ := c.Get("request_id")
+				respond(c, http.StatusUnsupportedMediaType, APIResponse{
+					Success:   false,
+					Error:     "Content-Type must be application/json",
+					RequestID: fmt.Sprintf("%v", requestID),
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// CircuitBreakerConfig 熔断器参数
+type CircuitBreakerConfig struct {
+	FailureThreshold int           // 窗口内 5xx 次数达到该值即熔断
+	Window           time.Duration // 统计 5xx 的滑动窗口
+	Cooldown         time.Duration // 熔断后等待多久再放行探测请求
+}
+
+// 默认：1 分钟内 5 次 5xx 即熔断，30 秒后半开探测
+var defaultCircuitBreakerConfig = CircuitBreakerConfig{
+	FailureThreshold: 5,
+	Window:           time.Minute,
+	Cooldown:         30 * time.Second,
+}
+
+// circuitState 熔断器状态
+type circuitState int
+
+const (
+	circuitClosed   circuitState = iota // 关闭：正常放行
+	circuitOpen                         // 打开：直接返回 503
+	circuitHalfOpen                     // 半开：只放行一个探测请求
+)
+
+// circuitBreaker 单个路由的熔断器
+type circuitBreaker struct {
+	mu       sync.Mutex
+	config   CircuitBreakerConfig
+	state    circuitState
+	failures []time.Time // 窗口内每次 5xx 的时间
+	openedAt time.Time
+	probing  bool // 半开状态下是否已有探测请求在处理
+}
+
+// allow 判断请求能否放行，不能时返回还需等待的时间
+func (cb *circuitBreaker) allow(now time.Time) (bool, time.Duration) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if elapsed := now.Sub(cb.openedAt); elapsed < cb.config.Cooldown {
+			return false, cb.config.Cooldown - elapsed
+		}
+		// 冷却结束，进入半开状态，放行这一个探测请求
+		cb.state = circuitHalfOpen
+		cb.probing = true
+		return true, 0
+	case circuitHalfOpen:
+		if cb.probing {
+			return false, cb.config.Cooldown
+		}
+		cb.probing = true
+		return true, 0
+	default:
+		return true, 0
+	}
+}
+
+// record 记录请求结果并推动状态转换
+func (cb *circuitBreaker) record(now time.Time, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == circuitHalfOpen {
+		// 📌 探测结果决定恢复还是重新熔断
+		cb.probing = false
+		if failed {
+			cb.trip(now)
+		} else {
+			cb.state = circuitClosed
+			cb.failures = nil
+		}
+		return
+	}
+
+	if !failed || cb.state == circuitOpen {
+		return
+	}
+
+	// 丢弃窗口外的失败记录
+	cutoff := now.Add(-cb.config.Window)
+	kept := cb.failures[:0]
+	for _, t := range cb.failures {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	cb.failures = append(kept, now)
+
+	if len(cb.failures) >= cb.config.FailureThreshold {
+		cb.trip(now)
+	}
+}
+
+// trip 打开熔断器，调用方需持有锁
+func (cb *circuitBreaker) trip(now time.Time) {
+	cb.state = circuitOpen
+	cb.openedAt = now
+	cb.failures = nil
+}
+
+// CircuitBreakerMiddleware 使用默认参数的熔断中间件
+func CircuitBreakerMiddleware() gin.HandlerFunc {
+	return CircuitBreakerMiddlewareWithConfig(defaultCircuitBreakerConfig)
+}
+
+// CircuitBreakerMiddlewareWithConfig 按路由统计 5xx，超过阈值后熔断
+// 📌 用途：后端故障时快速失败，避免请求继续压垮它
+// 状态机：closed -> (5xx 过多) -> open -> (冷却结束) -> half-open -> 探测成功 closed / 失败 open
+func CircuitBreakerMiddlewareWithConfig(config CircuitBreakerConfig) gin.HandlerFunc {
+	// key: "METHOD 路由模板"，如 "GET /articles/:id"
+	breakers := make(map[string]*circuitBreaker)
+	var mu sync.Mutex // 保护 map 的并发访问
+
+	return func(c *gin.Context) {
+		// 未匹配的路由（404）不计入熔断
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
+		}
+		key := c.Request.Method + " " + route
+
+		mu.Lock()
+		cb, exists := breakers[key]
+		if !exists {
+			cb = &circuitBreaker{config: config}
+			breakers[key] = cb
+		}
+		mu.Unlock()
+
+		allowed, retryAfter := cb.allow(time.Now())
+		if !allowed {
+			requestID, _ := c.Get("request_id")
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Round(time.Second)/time.Second)))
+			respond(c, http.StatusServiceUnavailable, APIResponse{
+				Success:   false,
+				Error:     "Service temporarily unavailable",
+				RequestID: fmt.Sprintf("%v", requestID),
+			})
+			c.Abort()
+			return
+		}
+
+		// 📌 用 defer 记录结果：处理器 panic 时也算一次失败，再交给外层恢复
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				cb.record(time.Now(), true)
+				panic(recovered)
+			}
+			cb.record(time.Now(), c.Writer.Status() >= http.StatusInternalServerError)
+		}()
+
+		c.Next()
+	}
+}
+
+// ErrorHandlerMiddleware 捕获 panic 并返回友好的错误信息
+// 📌 用途：防止服务器崩溃，优雅地处理错误
+func ErrorHandlerMiddleware() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		// 获取 request ID
+		requestID, _ := c.Get("request_id")
+
+		// 记录详细错误（仅服务器日志，包含真实panic信息）
+		log.Printf("[ERROR] [%v] Panic recovered: %v", requestID, recovered)
+
+		// 返回统一的错误响应（完全不暴露panic细节）
+		c.Writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		c.Writer.WriteHeader(http.StatusInternalServerError)
+
+		recoveredMsg := fmt.Sprint(recovered)
+		response := APIResponse{
+			Success:   false,
+			Message:   recoveredMsg,
+			Error:     "Internal server error",
+			RequestID: fmt.Sprintf("%v", requestID),
+		}
+
+		// 手动序列化JSON
+		jsonBytes, _ := json.Marshal(response)
+		c.Writer.Write(jsonBytes)
+	})
+}
+
+// Sanitize500Middleware 兜底清洗 500 响应体，防止泄露 panic 文本
+// 📌 sanitizeWriter 缓冲状态码和响应体，请求结束时才写出，响应头只写一次：
+// 处理器多次设置状态码时以最后一次为准，Status() 返回的也是缓冲的状态码
+type sanitizeWriter struct {
+	gin.ResponseWriter
+	status      int
+	buf         []byte
+	wroteHeader bool // 响应头已经写到真正的 writer
+	streaming   bool // 调用过 Flush 后直接透传，不再缓冲
+}
+
+func (w *sanitizeWriter) WriteHeader(code int) {
+	if w.wroteHeader || code <= 0 {
+		return // 响应头已经写出
+	}
+	w.status = code
+	// 延迟写出，由中间件收尾统一处理
+}
+
+// WriteHeaderNow 没有响应体的响应（如 204）也会调用，同样延迟到收尾时写出
+func (w *sanitizeWriter) WriteHeaderNow() {
+	if !w.wroteHeader && w.status == 0 {
+		w.status = w.ResponseWriter.Status()
+	}
+}
+
+func (w *sanitizeWriter) Write(p []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(p)
+	}
+	w.WriteHeaderNow()
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+func (w *sanitizeWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Status 返回缓冲的状态码，还没设置时返回真正 writer 的状态码
+func (w *sanitizeWriter) Status() int {
+	if w.status == 0 {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *sanitizeWriter) Written() bool {
+	return w.wroteHeader || w.status != 0
+}
+
+// writeHeaderOnce 把状态码写到真正的 writer，只写一次
+func (w *sanitizeWriter) writeHeaderOnce() {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(w.Status())
+}
+
+// Flush 用于流式响应：写出状态码和已缓冲的内容，之后的写入直接透传
+// 📌 500 仍然保持缓冲，交给中间件收尾时清洗
+func (w *sanitizeWriter) Flush() {
+	if !w.streaming && w.Status() != http.StatusInternalServerError {
+		w.writeHeaderOnce()
+		if len(w.buf) > 0 {
+			_, _ = w.ResponseWriter.Write(w.buf)
+			w.buf = nil
+		}
+		w.streaming = true
+	}
+	if w.streaming {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// finish 请求结束时写出响应：500 替换为统一响应，其余按原样写出缓冲的状态码和内容
+func (w *sanitizeWriter) finish(requestID interface{}) {
+	// 流式响应已经直接写出
+	if w.streaming {
+		return
+	}
+
+	if w.Status() == http.StatusInternalServerError {
+		// 使用捕获的缓冲内容作为 panic 信息（如果有），否则给空字符串
+		resp := APIResponse{
+			Success:   false,
+			Message:   string(w.buf),
+			Error:     "Internal server error",
+			RequestID: fmt.Sprintf("%v", requestID),
+		}
+		w.buf, _ = json.Marshal(resp)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	}
+
+	// 📌 处理器没有写过任何东西时不写状态码，保留 gin 为未匹配路由设置的 404
+	if w.status == 0 && len(w.buf) == 0 {
+		return
+	}
+	w.writeHeaderOnce()
+	if len(w.buf) > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+}
+
+// 📌 收尾后把 c.Writer 换回来，外层中间件读写的都是真正的 writer；
+// panic 时缓冲的内容直接丢弃，ErrorHandlerMiddleware 的 500 响应不会被困在缓冲区里
+func Sanitize500Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 包装 writer 拦截写入
 		sw := &sanitizeWriter{ResponseWriter: c.Writer}
 		c.Writer = sw
+		defer func() {
+			c.Writer = sw.ResponseWriter
+		}()
+
+		c.Next()
+
+		requestID, _ := c.Get("request_id")
+		sw.finish(requestID)
+	}
+}
+
+// requestTimeout 单个请求的处理时间上限，可以通过环境变量 REQUEST_TIMEOUT 修改
+var requestTimeout = 30 * time.Second
+
+// timeoutWriter 缓冲处理器的响应，超时后丢弃处理器之后的所有写入
+// 📌 响应头也单独保存，避免处理器和超时响应同时修改同一个 header map
+type timeoutWriter struct {
+	gin.ResponseWriter
+	mu        sync.Mutex
+	header    http.Header
+	status    int
+	buf       bytes.Buffer
+	committed bool // 流式响应 Flush 过，之后的写入直接透传
+	timedOut  bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || w.committed || w.status != 0 {
+		return
+	}
+	w.status = code
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.committed {
+		return w.ResponseWriter.Write(p)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.buf.Write(p)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Status 没写过状态码时返回真正 writer 的状态码
+// 📌 未匹配的路由 gin 预先设置了 404，不能当成 200
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status != 0 || w.committed
+}
+
+// Flush 用于流式响应：写出缓冲的内容，之后不会再返回 503
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.commit()
+	w.ResponseWriter.Flush()
+}
+
+// commit 把缓冲的响应头、状态码和内容写到真正的 writer，调用方需持有锁
+func (w *timeoutWriter) commit() {
+	if w.committed {
+		return
+	}
+	for key, values := range w.header {
+		w.ResponseWriter.Header()[key] = values
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+	w.committed = true
+}
+
+// TimeoutMiddleware 后续处理超过 d 时返回 503
+// 📌 c.Request 的 context 带有截止时间，处理器里的数据库、HTTP 调用等应该使用它，超时后及时退出
+// 📌 后续处理在 goroutine 里执行，超时后立即写出 503，但要等 goroutine 结束才返回，
+// 否则 gin 会在处理器还在使用 gin.Context 时把它放回池里复用
+// 📌 必须放在 Sanitize500Middleware 前面：超时后处理器通常会因为 context 超时返回 500，
+// 这些写入都被 timeoutWriter 丢弃，客户端收到的是 503；如果放在后面，
+// 503 要等 Sanitize500Middleware 收尾才会写出，处理器卡住时超时就没有意义了
+// 📌 流式响应 Flush 之后响应头已经发出，超时只会取消 context，不会再返回 503
+func TimeoutMiddleware(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 📌 在启动 goroutine 之前读取，避免和处理器并发访问 c
+		requestID, _ := c.Get("request_id")
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		original := c.Writer
+		tw := &timeoutWriter{ResponseWriter: original, header: make(http.Header)}
+		for key, values := range original.Header() {
+			tw.header[key] = values
+		}
+		c.Writer = tw
+
+		done := make(chan struct{})
+		var panicked interface{}
+		go func() {
+			defer close(done)
+			// panic 交回当前 goroutine，由 ErrorHandlerMiddleware 处理
+			defer func() { panicked = recover() }()
+			c.Next()
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				writeTimeout(tw, requestID, d)
+			}
+			<-done
+		}
+		c.Writer = original
+
+		tw.mu.Lock()
+		timedOut := tw.timedOut
+		if !timedOut && panicked == nil {
+			tw.commit()
+		}
+		tw.mu.Unlock()
+
+		if panicked != nil {
+			if timedOut {
+				log.Printf("[ERROR] [%v] Panic after request timed out: %v", requestID, panicked)
+			} else {
+				panic(panicked)
+			}
+		}
+		if timedOut {
+			c.Abort()
+		}
+	}
+}
+
+// writeTimeout 直接向真正的 writer 写出 503 并立即发送
+// 📌 处理器已经 Flush 过（流式响应）时不再写
+func writeTimeout(tw *timeoutWriter, requestID interface{}, d time.Duration) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.committed {
+		return
+	}
+	tw.timedOut = true
+
+	body, _ := json.Marshal(APIResponse{
+		Success:   false,
+		Error:     fmt.Sprintf("Service Unavailable: request timed out after %v", d),
+		RequestID: fmt.Sprintf("%v", requestID),
+	})
+	w := tw.ResponseWriter
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.Write(body)
+	w.Flush()
+}
+
+// GzipConfig 响应压缩参数
+// 📌 ExcludedContentTypes 按前缀匹配，如 "image/" 匹配所有图片
+type GzipConfig struct {
+	MinLength            int      // 响应体小于这个字节数时不压缩，压缩小响应得不偿失
+	Level                int      // 压缩级别，gzip.BestSpeed 到 gzip.BestCompression
+	ExcludedContentTypes []string // 本身已经压缩过的类型，再压缩没有收益
+}
+
+// 默认：1KB 以上压缩，跳过图片、音视频和压缩包
+var defaultGzipConfig = GzipConfig{
+	MinLength: 1024,
+	Level:     gzip.DefaultCompression,
+	ExcludedContentTypes: []string{
+		"image/", "video/", "audio/", "font/woff2",
+		"application/zip", "application/gzip", "application/x-gzip",
+		"application/x-bzip2", "application/x-7z-compressed", "application/zstd",
+	},
+}
+
+// compressible 响应是否应该压缩：没有被编码过，且类型不在排除列表里
+func (config GzipConfig) compressible(header http.Header) bool {
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := strings.ToLower(header.Get("Content-Type"))
+	for _, excluded := range config.ExcludedContentTypes {
+		if strings.HasPrefix(contentType, excluded) {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptsGzip 客户端的 Accept-Encoding 是否接受 gzip（q=0 表示拒绝）
+func acceptsGzip(acceptEncoding string) bool {
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(coding, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if (name == "gzip" || name == "*") && qValue(params[1:]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipWriter 先缓冲响应体，达到 MinLength 后才决定压缩并写出响应头
+// 📌 压缩后长度会变，写出响应头前删除处理器设置的 Content-Length，由 net/http 按分块传输发送
+type gzipWriter struct {
+	gin.ResponseWriter
+	config  GzipConfig
+	status  int
+	buf     []byte
+	decided bool         // 是否已经写出响应头
+	gz      *gzip.Writer // 为 nil 时不压缩
+}
+
+func (w *gzipWriter) WriteHeader(code int) {
+	if !w.decided {
+		w.status = code
+	}
+}
+
+// WriteHeaderNow 没有响应体的响应（如 204）直接写出，不压缩
+func (w *gzipWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(false)
+	}
+}
 
-		c.Next()
+func (w *gzipWriter) Write(p []byte) (int, error) {
+	if w.decided {
+		return w.write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.config.MinLength {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *gzipWriter) Status() int {
+	if w.status == 0 {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *gzipWriter) Written() bool {
+	return w.decided || len(w.buf) > 0
+}
+
+// Flush 用于流式响应：总长度未知，不管已经写了多少都按类型决定是否压缩
+func (w *gzipWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(true)
+	}
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// write 写出响应体，压缩时经过 gzip
+func (w *gzipWriter) write(p []byte) (int, error) {
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
 
-		// 确定最终状态码
-		status := sw.status
-		if status == 0 {
-			status = sw.ResponseWriter.Status()
+// decide 决定是否压缩，写出响应头和已缓冲的内容
+func (w *gzipWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	if compress && w.config.compressible(header) {
+		// 📌 没有 Content-Type 时 net/http 会根据内容猜测，压缩后就猜不出来了，这里先按原文猜
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", http.DetectContentType(w.buf))
+		}
+		gz, err := gzip.NewWriterLevel(w.ResponseWriter, w.config.Level)
+		if err != nil {
+			return err
 		}
+		w.gz = gz
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
 
-		// 500 时强制覆盖为统一响应
-		if status == http.StatusInternalServerError {
-			requestID, _ := c.Get("request_id")
-			// 使用捕获的缓冲内容作为 panic 信息（如果有），否则给空字符串
-			panicMsg := string(sw.buf)
-			resp := APIResponse{
-				Success:   false,
-				Message:   panicMsg,
-				Error:     "Internal server error",
-				RequestID: fmt.Sprintf("%v", requestID),
-			}
-			body, _ := json.Marshal(resp)
-			w := sw.ResponseWriter
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			w.WriteHeader(http.StatusInternalServerError)
-			_, _ = w.Write(body)
-			return
+	buf := w.buf
+	w.buf = nil
+	if len(buf) > 0 {
+		if _, err := w.write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close 请求结束：小于 MinLength 的响应原样写出，压缩的响应写出 gzip 结尾
+func (w *gzipWriter) close() error {
+	if !w.decided {
+		if err := w.decide(false); err != nil {
+			return err
 		}
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// GzipMiddleware 客户端接受 gzip 时压缩响应
+func GzipMiddleware() gin.HandlerFunc {
+	return GzipMiddlewareWithConfig(defaultGzipConfig)
+}
 
-		// 非 500：按原样下发缓冲体
-		w := sw.ResponseWriter
-		if status != 0 {
-			w.WriteHeader(status)
+// GzipMiddlewareWithConfig 使用指定参数的压缩中间件
+// 📌 不管是否压缩都加上 Vary: Accept-Encoding，避免缓存把压缩过的响应发给不支持的客户端
+func GzipMiddlewareWithConfig(config GzipConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if c.Request.Method == http.MethodHead || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
 		}
-		if len(sw.buf) > 0 {
-			_, _ = w.Write(sw.buf)
+
+		gw := &gzipWriter{ResponseWriter: c.Writer, config: config}
+		c.Writer = gw
+
+		c.Next()
+
+		if err := gw.close(); err != nil {
+			_ = c.Error(err)
 		}
+		c.Writer = gw.ResponseWriter
 	}
 }
 
@@ -436,28 +2630,169 @@ func Sanitize500Middleware() gin.HandlerFunc {
 // ping 处理健康检查请求
 func ping(c *gin.Context) {
 	requestID, _ := c.Get("request_id")
-	c.JSON(http.StatusOK, APIResponse{
+	respond(c, http.StatusOK, APIResponse{
 		Success:   true,
 		Message:   "pong",
 		RequestID: fmt.Sprintf("%v", requestID),
 	})
 }
 
+// CursorPage 游标分页的响应数据
+type CursorPage struct {
+	Items      interface{} `json:"items" xml:"items"`
+	NextCursor string      `json:"next_cursor,omitempty" xml:"next_cursor,omitempty"` // 最后一页为空
+}
+
+// encodeCursor 把最后一条记录的 ID 编码成不透明的游标
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+// decodeCursor 解析游标中的 ID，空游标表示从头开始
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	id, err := strconv.Atoi(string(raw))
+	if err != nil || id < 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return id, nil
+}
+
 // getArticles 获取所有文章
+// 📌 带 ?cursor= 时按 ID 游标分页：翻页期间新增或删除文章不会导致重复或遗漏
+// 📌 带 ?page=、?limit= 或 ?sort= 时按页码分页，可排序字段 id、title、author、created_at
+// 📌 带 ?tag= 时只返回有该标签的文章（不区分大小写），先过滤再分页，可以和两种分页一起用
 func getArticles(c *gin.Context) {
 	// 读锁：允许多个并发读取
 	articlesMutex.RLock()
 	defer articlesMutex.RUnlock()
 
 	requestID, _ := c.Get("request_id")
-	c.JSON(http.StatusOK, APIResponse{
+
+	list := articles
+	if tag := strings.TrimSpace(c.Query("tag")); tag != "" {
+		list = filterByTag(articles, tag)
+	}
+
+	cursor, paged := c.GetQuery("cursor")
+	if !paged && (c.Query("page") != "" || c.Query("limit") != "" || c.Query("sort") != "") {
+		params, err := parsePageParams(c, 10, articleSortKeys)
+		if err != nil {
+			respond(c, http.StatusBadRequest, APIResponse{
+				Success:   false,
+				Error:     err.Error(),
+				RequestID: fmt.Sprintf("%v", requestID),
+			})
+			return
+		}
+
+		respond(c, http.StatusOK, APIResponse{
+			Success:   true,
+			Data:      paginate(list, params, articleSortKeys),
+			Message:   "Articles retrieved successfully",
+			RequestID: fmt.Sprintf("%v", requestID),
+		})
+		return
+	}
+	if !paged {
+		// 兼容旧行为：不带分页参数时返回全部文章
+		respond(c, http.StatusOK, APIResponse{
+			Success:   true,
+			Data:      list,
+			Message:   "Articles retrieved successfully",
+			RequestID: fmt.Sprintf("%v", requestID),
+		})
+		return
+	}
+
+	lastID, err := decodeCursor(cursor)
+	if err != nil {
+		respond(c, http.StatusBadRequest, APIResponse{
+			Success:   false,
+			Error:     err.Error(),
+			RequestID: fmt.Sprintf("%v", requestID),
+		})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+
+	// articles 按 ID 递增存储，取 lastID 之后的 limit 篇
+	page := make([]Article, 0, limit)
+	hasMore := false
+	for _, article := range list {
+		if article.ID <= lastID {
+			continue
+		}
+		if len(page) == limit {
+			hasMore = true
+			break
+		}
+		page = append(page, article)
+	}
+
+	result := CursorPage{Items: page}
+	if hasMore {
+		result.NextCursor = encodeCursor(page[len(page)-1].ID)
+	}
+
+	respond(c, http.StatusOK, APIResponse{
 		Success:   true,
-		Data:      articles,
+		Data:      result,
 		Message:   "Articles retrieved successfully",
 		RequestID: fmt.Sprintf("%v", requestID),
 	})
 }
 
+// exportFlushEvery 流式导出时每写多少篇文章刷新一次
+const exportFlushEvery = 100
+
+// streamArticles 以 JSON 数组流式导出全部文章
+// 📌 逐篇编码写入 c.Writer，不在内存里拼出完整的 JSON
+// 📌 读锁下复制一份快照后立即释放，慢客户端不会阻塞写操作
+func streamArticles(c *gin.Context) {
+	articlesMutex.RLock()
+	snapshot := make([]Article, len(articles))
+	copy(snapshot, articles)
+	articlesMutex.RUnlock()
+
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+	c.Writer.Flush() // 先写出响应头
+
+	enc := json.NewEncoder(c.Writer)
+	if _, err := c.Writer.Write([]byte("[")); err != nil {
+		return
+	}
+	for i, article := range snapshot {
+		if i > 0 {
+			if _, err := c.Writer.Write([]byte(",")); err != nil {
+				return
+			}
+		}
+		// 状态码已经写出，出错时只能中断（客户端大多已断开）
+		if err := enc.Encode(article); err != nil {
+			_ = c.Error(err)
+			return
+		}
+		if (i+1)%exportFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+	}
+	if _, err := c.Writer.Write([]byte("]")); err != nil {
+		return
+	}
+	c.Writer.Flush()
+}
+
 // getArticle 获取单篇文章
 func getArticle(c *gin.Context) {
 	// 📌 获取 URL 参数
@@ -465,201 +2800,470 @@ func getArticle(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid article ID", "")
+		return
+	}
+
+	// 查找文章
+	article, _ := findArticleByID(id)
+	if article == nil {
+		respondError(c, http.StatusNotFound, "Article not found", "")
+		return
+	}
+
+	requestID, _ := c.Get("request_id")
+	respond(c, http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      article,
+		Message:   "Article retrieved successfully",
+		RequestID: fmt.Sprintf("%v", requestID),
+	})
+}
+
+// createArticle 创建新文章（需要认证）
+func createArticle(c *gin.Context) {
+	var input ArticleInput
+
+	// 📌 解析 JSON 请求体
+	// ShouldBindJSON 会自动验证 JSON 格式
+	if !bindJSON(c, &input) {
+		return
+	}
+	article := input.article()
+
+	// 验证并清洗文章数据，返回全部违规项
+	if violations := prepareArticle(&article, sanitizeMode); len(violations) > 0 {
+		requestID, _ := c.Get("request_id")
+		respond(c, http.StatusBadRequest, APIResponse{
+			Success:   false,
+			Data:      gin.H{"violations": violations},
+			Error:     violations.Error(),
+			RequestID: fmt.Sprintf("%v", requestID),
+		})
+		return
+	}
+
+	// 写锁：独占访问
+	articlesMutex.Lock()
+	// 设置文章属性
+	article.ID = nextID
+	nextID++
+	article.Version = 1
+	article.CreatedAt = time.Now()
+	article.UpdatedAt = time.Now()
+
+	// 添加到存储
+	articles = append(articles, article)
+	articlesMutex.Unlock()
+
+	requestID, _ := c.Get("request_id")
+	respond(c, http.StatusCreated, APIResponse{
+		Success:   true,
+		Data:      article,
+		Message:   "Article created successfully",
+		RequestID: fmt.Sprintf("%v", requestID),
+	})
+}
+
+// updateArticle 更新文章（需要认证），请求体带 version 时只有和当前版本一致才更新
+func updateArticle(c *gin.Context) {
+	// 获取文章 ID
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid article ID", "")
+		return
+	}
+
+	// 解析更新数据
+	var input ArticleInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	updatedArticle := input.article()
+
+	// 验证并清洗数据
+	if violations := prepareArticle(&updatedArticle, sanitizeMode); len(violations) > 0 {
 		requestID, _ := c.Get("request_id")
-		c.JSON(http.StatusBadRequest, APIResponse{
+		respond(c, http.StatusBadRequest, APIResponse{
 			Success:   false,
-			Error:     "Invalid article ID",
+			Data:      gin.H{"violations": violations},
+			Error:     violations.Error(),
 			RequestID: fmt.Sprintf("%v", requestID),
 		})
 		return
 	}
 
-	// 查找文章
-	article, _ := findArticleByID(id)
+	// 查找并更新文章
+	articlesMutex.Lock()
+	defer articlesMutex.Unlock()
+
+	article, idx := findArticleByID(id)
 	if article == nil {
-		requestID, _ := c.Get("request_id")
-		c.JSON(http.StatusNotFound, APIResponse{
+		respondError(c, http.StatusNotFound, "Article not found", "")
+		return
+	}
+
+	// 📌 乐观并发控制：客户端基于旧版本修改会覆盖别人的更新，返回 409 让客户端重新读取
+	// 不带 version 的请求只有开启 requireArticleVersion 时才拒绝，见其说明
+	if input.Version == 0 {
+		if requireArticleVersion {
+			respondError(c, http.StatusPreconditionRequired, "Version required",
+				fmt.Sprintf("send the version you read, the article is at version %d", article.Version))
+			return
+		}
+	} else if input.Version != article.Version {
+		respondError(c, http.StatusConflict, "Version conflict",
+			fmt.Sprintf("article is at version %d, update is based on version %d", article.Version, input.Version))
+		return
+	}
+
+	// 更新字段（保持 ID 和 CreatedAt 不变）
+	updatedArticle.ID = id
+	updatedArticle.Version = article.Version + 1
+	updatedArticle.CreatedAt = article.CreatedAt
+	updatedArticle.UpdatedAt = time.Now()
+
+	articles[idx] = updatedArticle
+
+	requestID, _ := c.Get("request_id")
+	respond(c, http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      updatedArticle,
+		Message:   "Article updated successfully",
+		RequestID: fmt.Sprintf("%v", requestID),
+	})
+}
+
+// deleteArticle 删除文章（需要认证）
+func deleteArticle(c *gin.Context) {
+	// 获取文章 ID
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "Invalid article ID", "")
+		return
+	}
+
+	// 查找并删除文章
+	articlesMutex.Lock()
+	defer articlesMutex.Unlock()
+
+	article, idx := findArticleByID(id)
+	if article == nil {
+		respondError(c, http.StatusNotFound, "Article not found", "")
+		return
+	}
+
+	// 从切片中删除，先复制一份被删除的文章用于返回
+	deleted := *article
+	articles = removeAt(articles, idx)
+
+	requestID, _ := c.Get("request_id")
+	respond(c, http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      deleted,
+		Message:   "Article deleted successfully",
+		RequestID: fmt.Sprintf("%v", requestID),
+	})
+}
+
+// AuthorCount 作者及其文章数
+type AuthorCount struct {
+	Author string `json:"author" xml:"author"`
+	Count  int    `json:"count" xml:"count"`
+}
+
+// rankAuthors 按文章数从多到少排列作者，文章数相同时按作者名字母序
+func rankAuthors(counts map[string]int) []AuthorCount {
+	ranking := make([]AuthorCount, 0, len(counts))
+	for author, count := range counts {
+		ranking = append(ranking, AuthorCount{Author: author, Count: count})
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if ranking[i].Count != ranking[j].Count {
+			return ranking[i].Count > ranking[j].Count
+		}
+		return ranking[i].Author < ranking[j].Author
+	})
+	return ranking
+}
+
+// getTopAuthors 按文章数从多到少返回作者排名
+// 📌 文章数相同时按作者名字母序排列，保证结果稳定
+// 📌 支持 ?limit=N 只返回前 N 名
+func getTopAuthors(c *gin.Context) {
+	requestID, _ := c.Get("request_id")
+
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			respond(c, http.StatusBadRequest, APIResponse{
+				Success:   false,
+				Error:     "limit must be a positive integer",
+				RequestID: fmt.Sprintf("%v", requestID),
+			})
+			return
+		}
+		limit = n
+	}
+
+	articlesMutex.RLock()
+	counts := make(map[string]int)
+	for _, article := range articles {
+		counts[article.Author]++
+	}
+	articlesMutex.RUnlock()
+
+	ranking := rankAuthors(counts)
+	if limit > 0 && limit < len(ranking) {
+		ranking = ranking[:limit]
+	}
+
+	respond(c, http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      ranking,
+		Message:   "Top authors retrieved successfully",
+		RequestID: fmt.Sprintf("%v", requestID),
+	})
+}
+
+// resetTestData 清空并重新写入初始数据，方便端到端测试之间恢复状态
+// 📌 只有 testMode 开启时可用
+func resetTestData(c *gin.Context) {
+	requestID, _ := c.Get("request_id")
+	if !testMode {
+		respond(c, http.StatusForbidden, APIResponse{
 			Success:   false,
-			Error:     "Article not found",
+			Error:     "Test mode is disabled",
 			RequestID: fmt.Sprintf("%v", requestID),
 		})
 		return
 	}
 
-	requestID, _ := c.Get("request_id")
-	c.JSON(http.StatusOK, APIResponse{
+	articlesMutex.Lock()
+	articles = seedArticles()
+	nextID = len(articles) + 1
+	articlesMutex.Unlock()
+
+	respond(c, http.StatusOK, APIResponse{
 		Success:   true,
-		Data:      article,
-		Message:   "Article retrieved successfully",
+		Message:   "Test data reset",
 		RequestID: fmt.Sprintf("%v", requestID),
 	})
 }
 
-// createArticle 创建新文章（需要认证）
-func createArticle(c *gin.Context) {
-	var article Article
-
-	// 📌 解析 JSON 请求体
-	// ShouldBindJSON 会自动验证 JSON 格式
-	if err := c.ShouldBindJSON(&article); err != nil {
+// getStats 获取统计信息（仅管理员）
+func getStats(c *gin.Context) {
+	// 📌 检查用户角色
+	role, exists := c.Get("user_role")
+	if !exists || role != "admin" {
 		requestID, _ := c.Get("request_id")
-		c.JSON(http.StatusBadRequest, APIResponse{
+		respond(c, http.StatusForbidden, APIResponse{
 			Success:   false,
-			Error:     "Invalid request body: " + err.Error(),
+			Error:     "Admin access required",
 			RequestID: fmt.Sprintf("%v", requestID),
 		})
 		return
 	}
 
-	// 验证文章数据
-	if err := validateArticle(article); err != nil {
-		requestID, _ := c.Get("request_id")
-		c.JSON(http.StatusBadRequest, APIResponse{
-			Success:   false,
-			Error:     err.Error(),
-			RequestID: fmt.Sprintf("%v", requestID),
-		})
-		return
+	// 一次遍历统计作者和正文长度（按字符数，和校验规则一致）
+	articlesMutex.RLock()
+	totalArticles := len(articles)
+	counts := make(map[string]int)
+	totalContentLength := 0
+	for _, article := range articles {
+		counts[article.Author]++
+		totalContentLength += utf8.RuneCountInString(article.Content)
 	}
+	articlesMutex.RUnlock()
 
-	// 写锁：独占访问
-	articlesMutex.Lock()
-	// 设置文章属性
-	article.ID = nextID
-	nextID++
-	article.CreatedAt = time.Now()
-	article.UpdatedAt = time.Now()
+	// 没有文章时 top_author 为 null，平均长度为 0
+	var topAuthor *AuthorCount
+	if ranking := rankAuthors(counts); len(ranking) > 0 {
+		topAuthor = &ranking[0]
+	}
+	avgContentLength := 0.0
+	if totalArticles > 0 {
+		avgContentLength = float64(totalContentLength) / float64(totalArticles)
+	}
 
-	// 添加到存储
-	articles = append(articles, article)
-	articlesMutex.Unlock()
+	// 📌 用 gin.H 而不是 map[string]interface{}：gin.H 实现了 MarshalXML，可以输出 XML
+	stats := gin.H{
+		"total_articles":     totalArticles,
+		"total_requests":     metrics.snapshot().TotalRequests,
+		"total_authors":      len(counts),
+		"top_author":         topAuthor,
+		"avg_content_length": avgContentLength,
+		"uptime":             "24h",
+		"version":            "1.0.0",
+	}
 
 	requestID, _ := c.Get("request_id")
-	c.JSON(http.StatusCreated, APIResponse{
+	respond(c, http.StatusOK, APIResponse{
 		Success:   true,
-		Data:      article,
-		Message:   "Article created successfully",
+		Data:      stats,
+		Message:   "Statistics retrieved successfully",
 		RequestID: fmt.Sprintf("%v", requestID),
 	})
 }
 
-// updateArticle 更新文章（需要认证）
-func updateArticle(c *gin.Context) {
-	// 获取文章 ID
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		requestID, _ := c.Get("request_id")
-		c.JSON(http.StatusBadRequest, APIResponse{
-			Success:   false,
-			Error:     "Invalid article ID",
-			RequestID: fmt.Sprintf("%v", requestID),
-		})
-		return
-	}
+// getEndpointStats 返回各接口的调用次数（需要管理员权限）
+func getEndpointStats(c *gin.Context) {
+	requestID, _ := c.Get("request_id")
 
-	// 解析更新数据
-	var updatedArticle Article
-	if err := c.ShouldBindJSON(&updatedArticle); err != nil {
-		requestID, _ := c.Get("request_id")
-		c.JSON(http.StatusBadRequest, APIResponse{
+	// 📌 检查用户角色
+	role, exists := c.Get("user_role")
+	if !exists || role != "admin" {
+		respond(c, http.StatusForbidden, APIResponse{
 			Success:   false,
-			Error:     "Invalid request body: " + err.Error(),
+			Error:     "Admin access required",
 			RequestID: fmt.Sprintf("%v", requestID),
 		})
 		return
 	}
 
-	// 验证数据
-	if err := validateArticle(updatedArticle); err != nil {
-		requestID, _ := c.Get("request_id")
-		c.JSON(http.StatusBadRequest, APIResponse{
+	respond(c, http.StatusOK, APIResponse{
+		Success:   true,
+		Data:      endpointCounts.snapshot(),
+		Message:   "Endpoint statistics retrieved successfully",
+		RequestID: fmt.Sprintf("%v", requestID),
+	})
+}
+
+// getMetrics 以 Prometheus 文本格式返回请求指标（需要管理员权限）
+// 📌 正在处理的这次请求还没有完成，不会出现在结果里
+func getMetrics(c *gin.Context) {
+	requestID, _ := c.Get("request_id")
+
+	// 📌 检查用户角色
+	role, exists := c.Get("user_role")
+	if !exists || role != "admin" {
+		respond(c, http.StatusForbidden, APIResponse{
 			Success:   false,
-			Error:     err.Error(),
+			Error:     "Admin access required",
 			RequestID: fmt.Sprintf("%v", requestID),
 		})
 		return
 	}
 
-	// 查找并更新文章
-	articlesMutex.Lock()
-	defer articlesMutex.Unlock()
+	var buf bytes.Buffer
+	metrics.snapshot().writeText(&buf)
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
 
-	article, idx := findArticleByID(id)
-	if article == nil {
-		requestID, _ := c.Get("request_id")
-		c.JSON(http.StatusNotFound, APIResponse{
+// drainServer 进入排空模式（需要管理员权限）
+func drainServer(c *gin.Context) {
+	setDraining(c, true)
+}
+
+// undrainServer 退出排空模式，恢复写请求（需要管理员权限）
+func undrainServer(c *gin.Context) {
+	setDraining(c, false)
+}
+
+// setDraining 切换排空模式并返回当前状态
+func setDraining(c *gin.Context, drain bool) {
+	requestID, _ := c.Get("request_id")
+
+	role, exists := c.Get("user_role")
+	if !exists || role != "admin" {
+		respond(c, http.StatusForbidden, APIResponse{
 			Success:   false,
-			Error:     "Article not found",
+			Error:     "Admin access required",
 			RequestID: fmt.Sprintf("%v", requestID),
 		})
 		return
 	}
 
-	// 更新字段（保持 ID 和 CreatedAt 不变）
-	updatedArticle.ID = id
-	updatedArticle.CreatedAt = article.CreatedAt
-	updatedArticle.UpdatedAt = time.Now()
-
-	articles[idx] = updatedArticle
-
-	requestID, _ := c.Get("request_id")
-	c.JSON(http.StatusOK, APIResponse{
+	draining.Store(drain)
+	message := "Server resumed accepting writes"
+	if drain {
+		message = "Server is draining, writes are disabled"
+	}
+	respond(c, http.StatusOK, APIResponse{
 		Success:   true,
-		Data:      updatedArticle,
-		Message:   "Article updated successfully",
+		Data:      gin.H{"draining": drain},
+		Message:   message,
 		RequestID: fmt.Sprintf("%v", requestID),
 	})
 }
 
-// deleteArticle 删除文章（需要认证）
-func deleteArticle(c *gin.Context) {
-	// 获取文章 ID
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		requestID, _ := c.Get("request_id")
-		c.JSON(http.StatusBadRequest, APIResponse{
+// getRequestLog 分页查询最近的请求，默认按从新到旧排列
+// 📌 支持过滤：?status_class=4xx&path_prefix=/articles
+// 📌 支持排序：?sort=status,-timestamp（可用字段 timestamp、user、status）
+func getRequestLog(c *gin.Context) {
+	requestID, _ := c.Get("request_id")
+
+	// 📌 检查用户角色
+	role, exists := c.Get("user_role")
+	if !exists || role != "admin" {
+		respond(c, http.StatusForbidden, APIResponse{
 			Success:   false,
-			Error:     "Invalid article ID",
+			Error:     "Admin access required",
 			RequestID: fmt.Sprintf("%v", requestID),
 		})
 		return
 	}
 
-	// 查找并删除文章
-	articlesMutex.Lock()
-	defer articlesMutex.Unlock()
+	// status_class 形如 "4xx"，表示 400-499
+	statusClass := 0
+	if class := c.Query("status_class"); class != "" {
+		if len(class) != 3 || !strings.HasSuffix(strings.ToLower(class), "xx") || class[0] < '1' || class[0] > '5' {
+			respond(c, http.StatusBadRequest, APIResponse{
+				Success:   false,
+				Error:     "status_class must be one of 1xx, 2xx, 3xx, 4xx, 5xx",
+				RequestID: fmt.Sprintf("%v", requestID),
+			})
+			return
+		}
+		statusClass = int(class[0] - '0')
+	}
+	pathPrefix := c.Query("path_prefix")
 
-	article, idx := findArticleByID(id)
-	if article == nil {
-		requestID, _ := c.Get("request_id")
-		c.JSON(http.StatusNotFound, APIResponse{
+	params, err := parsePageParams(c, 20, requestLogSortKeys)
+	if err != nil {
+		respond(c, http.StatusBadRequest, APIResponse{
 			Success:   false,
-			Error:     "Article not found",
+			Error:     err.Error(),
 			RequestID: fmt.Sprintf("%v", requestID),
 		})
 		return
 	}
 
-	// 从切片中删除
-	articles = append(articles[:idx], articles[idx+1:]...)
+	entries := requestLog.recent(func(entry AccessLogEntry) bool {
+		if statusClass != 0 && entry.Status/100 != statusClass {
+			return false
+		}
+		return strings.HasPrefix(entry.Path, pathPrefix)
+	})
 
-	requestID, _ := c.Get("request_id")
-	c.JSON(http.StatusOK, APIResponse{
+	respond(c, http.StatusOK, APIResponse{
 		Success:   true,
-		Message:   "Article deleted successfully",
+		Data:      paginate(entries, params, requestLogSortKeys),
+		Message:   "Request log retrieved successfully",
 		RequestID: fmt.Sprintf("%v", requestID),
 	})
 }
 
-// getStats 获取统计信息（仅管理员）
-func getStats(c *gin.Context) {
-	// 📌 检查用户角色
+// getDebugCaptures 返回最近的调试记录，从新到旧排列
+// 📌 只有 debugCapture 开启时可用
+func getDebugCaptures(c *gin.Context) {
+	requestID, _ := c.Get("request_id")
+
+	if !debugCapture {
+		respond(c, http.StatusNotFound, APIResponse{
+			Success:   false,
+			Error:     "Debug capture is disabled",
+			RequestID: fmt.Sprintf("%v", requestID),
+		})
+		return
+	}
+
 	role, exists := c.Get("user_role")
 	if !exists || role != "admin" {
-		requestID, _ := c.Get("request_id")
-		c.JSON(http.StatusForbidden, APIResponse{
+		respond(c, http.StatusForbidden, APIResponse{
 			Success:   false,
 			Error:     "Admin access required",
 			RequestID: fmt.Sprintf("%v", requestID),
@@ -667,26 +3271,136 @@ func getStats(c *gin.Context) {
 		return
 	}
 
-	articlesMutex.RLock()
-	totalArticles := len(articles)
-	articlesMutex.RUnlock()
-
-	stats := map[string]interface{}{
-		"total_articles": totalArticles,
-		"total_authors":  2, // 简化示例
-		"uptime":         "24h",
-		"version":        "1.0.0",
-	}
-
-	requestID, _ := c.Get("request_id")
-	c.JSON(http.StatusOK, APIResponse{
+	respond(c, http.StatusOK, APIResponse{
 		Success:   true,
-		Data:      stats,
-		Message:   "Statistics retrieved successfully",
+		Data:      debugCaptures.recent(func(DebugCapture) bool { return true }),
+		Message:   "Debug captures retrieved successfully",
 		RequestID: fmt.Sprintf("%v", requestID),
 	})
 }
 
+// ============================================================================
+// 分页与排序
+// ============================================================================
+
+// Page 通用的分页响应信封
+type Page[T any] struct {
+	Items []T `json:"items" xml:"items"`
+	Total int `json:"total" xml:"total"`
+	Page  int `json:"page" xml:"page"`
+	Limit int `json:"limit" xml:"limit"`
+}
+
+// sortKeys 可排序字段名到比较函数的映射，比较函数返回负数、0、正数
+type sortKeys[T any] map[string]func(a, b T) int
+
+// sortField 一个排序字段，Desc 为 true 时降序
+type sortField struct {
+	Name string
+	Desc bool
+}
+
+// pageParams 从查询参数解析出的分页与排序参数
+type pageParams struct {
+	Page  int
+	Limit int
+	Sort  []sortField
+}
+
+// parsePageParams 解析 ?page=&limit=&sort=
+// 📌 sort 支持多字段，逗号分隔，前缀 "-" 表示降序，如 sort=-status,timestamp
+func parsePageParams[T any](c *gin.Context, defaultLimit int, keys sortKeys[T]) (pageParams, error) {
+	params := pageParams{Page: 1, Limit: defaultLimit}
+
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
+		params.Page = page
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+		params.Limit = limit
+	}
+
+	if sortParam := c.Query("sort"); sortParam != "" {
+		for _, name := range strings.Split(sortParam, ",") {
+			field := sortField{Name: strings.TrimSpace(name)}
+			if strings.HasPrefix(field.Name, "-") {
+				field.Name = field.Name[1:]
+				field.Desc = true
+			}
+			if _, ok := keys[field.Name]; !ok {
+				return params, fmt.Errorf("cannot sort by %q", field.Name)
+			}
+			params.Sort = append(params.Sort, field)
+		}
+	}
+
+	return params, nil
+}
+
+// paginate 按排序字段依次比较后截取当前页，不修改传入的切片
+// 排序是稳定的，所有字段都相等时保持原有顺序
+func paginate[T any](items []T, params pageParams, keys sortKeys[T]) Page[T] {
+	sorted := make([]T, len(items))
+	copy(sorted, items)
+
+	if len(params.Sort) > 0 {
+		sort.SliceStable(sorted, func(i, j int) bool {
+			for _, field := range params.Sort {
+				result := keys[field.Name](sorted[i], sorted[j])
+				if field.Desc {
+					result = -result
+				}
+				if result != 0 {
+					return result < 0
+				}
+			}
+			return false
+		})
+	}
+
+	// 计算当前页的范围
+	start := (params.Page - 1) * params.Limit
+	if start > len(sorted) {
+		start = len(sorted)
+	}
+	end := start + params.Limit
+	if end > len(sorted) {
+		end = len(sorted)
+	}
+
+	return Page[T]{
+		Items: sorted[start:end],
+		Total: len(sorted),
+		Page:  params.Page,
+		Limit: params.Limit,
+	}
+}
+
+// compareInts 比较两个整数
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// requestLogSortKeys 访问日志可排序的字段
+var requestLogSortKeys = sortKeys[AccessLogEntry]{
+	"timestamp": func(a, b AccessLogEntry) int { return a.Timestamp.Compare(b.Timestamp) },
+	"user":      func(a, b AccessLogEntry) int { return strings.Compare(a.User, b.User) },
+	"status":    func(a, b AccessLogEntry) int { return compareInts(a.Status, b.Status) },
+}
+
+// articleSortKeys 文章列表可排序的字段
+var articleSortKeys = sortKeys[Article]{
+	"id":         func(a, b Article) int { return compareInts(a.ID, b.ID) },
+	"title":      func(a, b Article) int { return strings.Compare(a.Title, b.Title) },
+	"author":     func(a, b Article) int { return strings.Compare(a.Author, b.Author) },
+	"created_at": func(a, b Article) int { return a.CreatedAt.Compare(b.CreatedAt) },
+}
+
 // ============================================================================
 // 辅助函数
 // ============================================================================
@@ -702,19 +3416,243 @@ func findArticleByID(id int) (*Article, int) {
 	return nil, -1
 }
 
-// validateArticle 验证文章数据
-func validateArticle(article Article) error {
+// SanitizeMode 决定如何处理文本中的 HTML 危险字符
+type SanitizeMode int
+
+const (
+	// SanitizeEscape 把 < > & ' " 转义成 HTML 实体，保留原文
+	SanitizeEscape SanitizeMode = iota
+	// SanitizeStrip 直接删除 HTML 标签和剩余的尖括号
+	SanitizeStrip
+)
+
+// sanitizeMode 当前使用的清洗方式，可通过环境变量 SANITIZE_MODE 配置
+var sanitizeMode = SanitizeEscape
+
+var reHTMLTag = regexp.MustCompile(`<[^>]*>`)
+
+// parseSanitizeMode 解析 "escape" 或 "strip"
+func parseSanitizeMode(mode string) (SanitizeMode, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "escape":
+		return SanitizeEscape, nil
+	case "strip":
+		return SanitizeStrip, nil
+	}
+	return 0, fmt.Errorf("unknown sanitize mode %q (want escape or strip)", mode)
+}
+
+// cleanText 去掉控制字符和首尾空白，不处理 HTML
+// 📌 换行和制表符保留，正文需要它们
+func cleanText(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.TrimSpace(s)
+}
+
+// sanitizeText 去掉首尾空白和控制字符，再按 mode 处理 HTML 危险字符
+func sanitizeText(s string, mode SanitizeMode) string {
+	s = cleanText(s)
+
+	switch mode {
+	case SanitizeStrip:
+		s = reHTMLTag.ReplaceAllString(s, "")
+		s = strings.NewReplacer("<", "", ">", "").Replace(s)
+	default:
+		s = html.EscapeString(s)
+	}
+	return strings.TrimSpace(s)
+}
+
+// sanitizeArticle 清洗文章中所有自由文本字段，防止存储型 XSS
+func sanitizeArticle(article *Article, mode SanitizeMode) {
+	article.Title = sanitizeText(article.Title, mode)
+	article.Content = sanitizeText(article.Content, mode)
+	article.Author = sanitizeText(article.Author, mode)
+	for i, tag := range article.Tags {
+		article.Tags[i] = sanitizeText(tag, mode)
+	}
+	article.Tags = normalizeTags(article.Tags)
+}
+
+// cleanArticle 清理文章的自由文本字段并合并重复标签，不转义 HTML
+func cleanArticle(article *Article) {
+	article.Title = cleanText(article.Title)
+	article.Content = cleanText(article.Content)
+	article.Author = cleanText(article.Author)
+	for i, tag := range article.Tags {
+		article.Tags[i] = cleanText(tag)
+	}
+	article.Tags = normalizeTags(article.Tags)
+}
+
+// prepareArticle 校验并清洗客户端提交的文章，返回所有违规项（没有则为 nil）
+// 📌 先按原文校验再转义：转义会把 ' 变成 &#39;，先转义会误拒 "Conan O'Brien" 这样的作者名，
+// 长度也会被转义放大
+// 📌 strip 模式下只含 HTML 标签的字段清洗后为空，所以清洗后再检查一次必填字段
+func prepareArticle(article *Article, mode SanitizeMode) ValidationErrors {
+	cleanArticle(article)
+	if violations := validateArticleFields(*article, articleRules); len(violations) > 0 {
+		return violations
+	}
+
+	sanitizeArticle(article, mode)
+	var violations ValidationErrors
+	for _, field := range []struct{ name, value string }{
+		{"title", article.Title},
+		{"content", article.Content},
+		{"author", article.Author},
+	} {
+		if field.value == "" {
+			violations = append(violations, FieldViolation{Field: field.name, Message: field.name + " is required"})
+		}
+	}
+	return violations
+}
+
+// normalizeTags 去掉空标签，合并重复标签（不区分大小写，保留第一次出现的写法）
+// 📌 在清洗之后调用，清洗会去掉首尾空白，只含 HTML 标签的标签也会变成空的
+// 📌 数量上限由 validateArticleFields 检查，重复的标签合并后才计数
+func normalizeTags(tags []string) []string {
+	var result []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+// hasTag 文章是否有该标签，不区分大小写
+func hasTag(article Article, tag string) bool {
+	for _, t := range article.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterByTag 返回有该标签的文章，保持原来的顺序
+func filterByTag(items []Article, tag string) []Article {
+	result := make([]Article, 0)
+	for _, article := range items {
+		if hasTag(article, tag) {
+			result = append(result, article)
+		}
+	}
+	return result
+}
+
+// removeAt 删除 index 处的元素，返回新切片
+// 📌 不用 append(s[:i], s[i+1:]...)：它会原地移动元素，
+// 持有旧切片的人会看到内容变化，旧的末尾元素也会一直留在底层数组里
+func removeAt[T any](items []T, index int) []T {
+	remaining := make([]T, 0, len(items)-1)
+	remaining = append(remaining, items[:index]...)
+	return append(remaining, items[index+1:]...)
+}
+
+// ArticleRules 文章字段的校验规则，长度按字符（rune）计算
+type ArticleRules struct {
+	TitleMaxLength   int
+	ContentMinLength int
+	ContentMaxLength int
+	AuthorMaxLength  int
+	MaxTags          int
+	TagMaxLength     int
+}
+
+var articleRules = ArticleRules{
+	TitleMaxLength:   200,
+	ContentMinLength: 1,
+	ContentMaxLength: 20000,
+	AuthorMaxLength:  100,
+	MaxTags:          10,
+	TagMaxLength:     30,
+}
+
+// 作者名：以字母开头，只含字母（任意语言）、空格、点、撇号和连字符
+var reAuthorName = regexp.MustCompile(`^\p{L}[\p{L}\p{M} .'-]*$`)
+
+// FieldViolation 单个字段的校验错误
+type FieldViolation struct {
+	Field   string `json:"field" xml:"field"`
+	Message string `json:"message" xml:"message"`
+}
+
+// ValidationErrors 一次校验发现的全部错误
+type ValidationErrors []FieldViolation
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, violation := range v {
+		messages[i] = violation.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// validateArticleFields 按 rules 校验文章，返回所有违规项（没有则为 nil）
+// 📌 不在第一个错误处返回，客户端一次就能看到所有需要修改的字段
+func validateArticleFields(article Article, rules ArticleRules) ValidationErrors {
+	var violations ValidationErrors
+	add := func(field, format string, args ...interface{}) {
+		violations = append(violations, FieldViolation{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
 	if strings.TrimSpace(article.Title) == "" {
-		return errors.New("title is required")
+		add("title", "title is required")
+	} else if utf8.RuneCountInString(article.Title) > rules.TitleMaxLength {
+		add("title", "title must be less than %d characters", rules.TitleMaxLength)
+	}
+
+	contentLength := utf8.RuneCountInString(article.Content)
+	switch {
+	case strings.TrimSpace(article.Content) == "":
+		add("content", "content is required")
+	case contentLength < rules.ContentMinLength:
+		add("content", "content must be at least %d characters", rules.ContentMinLength)
+	case contentLength > rules.ContentMaxLength:
+		add("content", "content must be at most %d characters", rules.ContentMaxLength)
 	}
-	if len(article.Title) > 200 {
-		return errors.New("title must be less than 200 characters")
+
+	switch {
+	case strings.TrimSpace(article.Author) == "":
+		add("author", "author is required")
+	case utf8.RuneCountInString(article.Author) > rules.AuthorMaxLength:
+		add("author", "author must be at most %d characters", rules.AuthorMaxLength)
+	case !reAuthorName.MatchString(article.Author):
+		add("author", "author may only contain letters, spaces, periods, apostrophes and hyphens")
+	}
+
+	if len(article.Tags) > rules.MaxTags {
+		add("tags", "at most %d tags are allowed", rules.MaxTags)
 	}
-	if strings.TrimSpace(article.Content) == "" {
-		return errors.New("content is required")
+	for i, tag := range article.Tags {
+		field := fmt.Sprintf("tags[%d]", i)
+		if strings.TrimSpace(tag) == "" {
+			add(field, "tag must not be empty")
+		} else if utf8.RuneCountInString(tag) > rules.TagMaxLength {
+			add(field, "tag must be at most %d characters", rules.TagMaxLength)
+		}
 	}
-	if strings.TrimSpace(article.Author) == "" {
-		return errors.New("author is required")
+
+	return violations
+}
+
+// validateArticle 验证文章数据，只返回第一个错误
+// 📌 兼容旧调用方；需要完整列表时用 validateArticleFields
+func validateArticle(article Article) error {
+	if violations := validateArticleFields(article, articleRules); len(violations) > 0 {
+		return errors.New(violations[0].Message)
 	}
 	return nil
 }
@@ -0,0 +1,2427 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestRouter resets the article store and builds a router with the same
+// middleware chain and routes as main. The rate limiters' sweepers stop when
+// the test finishes.
+func newTestRouter(t testing.TB) *gin.Engine {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	articles = seedArticles()
+	nextID = 3
+	requestLog = newAccessLog(accessLogCapacity)
+	debugCaptures = newRingBuffer[DebugCapture](debugCaptureCapacity)
+	draining.Store(false)
+	endpointCounts = &endpointCounter{}
+	metrics = newRequestMetrics()
+
+	r := gin.New()
+	r.Use(MetricsMiddleware())
+	r.Use(GzipMiddleware())
+	r.Use(ErrorHandlerMiddleware())
+	r.Use(SecurityHeadersMiddleware())
+	r.Use(RequestIDMiddleware())
+	r.Use(APIVersionMiddleware())
+	r.Use(ContentNegotiationMiddleware())
+	r.Use(LoggingMiddleware())
+	r.Use(EndpointStatsMiddleware())
+	r.Use(BodySizeLimitMiddleware(maxRequestBodyBytes))
+	r.Use(DebugCaptureMiddleware())
+	r.Use(CORSMiddleware())
+	r.Use(DrainMiddleware())
+	r.Use(APIKeyRoleMiddleware())
+	r.Use(RateLimitMiddlewareWithContext(ctx, defaultRateLimitConfig))
+	r.Use(CircuitBreakerMiddleware())
+	r.Use(ContentTypeMiddleware())
+	r.Use(TimeoutMiddleware(requestTimeout))
+	r.Use(Sanitize500Middleware())
+
+	public := r.Group("/")
+	{
+		public.GET("/ping", ping)
+		public.GET("/articles", getArticles)
+		public.GET("/articles/:id", getArticle)
+		public.GET("/articles/authors/top", getTopAuthors)
+		public.GET("/articles/export", streamArticles)
+		public.POST("/test/reset", resetTestData)
+	}
+
+	protected := r.Group("/")
+	protected.Use(AuthMiddleware())
+	{
+		protected.POST("/articles", IdempotencyMiddleware(), createArticle)
+		protected.PUT("/articles/:id", updateArticle)
+		protected.DELETE("/articles/:id", deleteArticle)
+		protected.GET("/admin/stats", getStats)
+		protected.GET("/admin/requests", getRequestLog)
+		protected.GET("/admin/endpoint-stats", getEndpointStats)
+		protected.GET(metricsPath, getMetrics)
+		protected.GET(debugCapturePath, getDebugCaptures)
+		protected.POST("/admin/drain", drainServer)
+		protected.POST("/admin/undrain", undrainServer)
+	}
+
+	return r
+}
+
+// performRequest sends a request and decodes the APIResponse
+func performRequest(router *gin.Engine, method, path string, body interface{}, headers map[string]string) (*httptest.ResponseRecorder, APIResponse) {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response APIResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+// Test API Version Middleware
+func TestAPIVersionMiddleware(t *testing.T) {
+	router := newTestRouter(t)
+
+	t.Run("Version field present", func(t *testing.T) {
+		w, response := performRequest(router, "GET", "/ping", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, apiVersion, response.APIVersion)
+		assert.Equal(t, apiVersion, w.Header().Get("X-API-Version"))
+	})
+
+	t.Run("Supported vendor version", func(t *testing.T) {
+		w, response := performRequest(router, "GET", "/articles", nil,
+			map[string]string{"Accept": "application/vnd.blog.v1+json"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "v1", response.APIVersion)
+	})
+
+	t.Run("Unsupported vendor version", func(t *testing.T) {
+		w, response := performRequest(router, "GET", "/articles", nil,
+			map[string]string{"Accept": "application/vnd.blog.v2+json"})
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.False(t, response.Success)
+		assert.NotEmpty(t, response.RequestID)
+	})
+
+	t.Run("Unsupported vendor version alongside plain JSON", func(t *testing.T) {
+		for _, accept := range []string{
+			"application/vnd.blog.v2+json, application/json",
+			"application/vnd.blog.v2+json, */*;q=0.1",
+		} {
+			w, response := performRequest(router, "GET", "/articles", nil, map[string]string{"Accept": accept})
+			assert.Equal(t, http.StatusOK, w.Code, accept)
+			assert.Equal(t, apiVersion, response.APIVersion, accept)
+		}
+
+		// A range with q=0 is refused, so it doesn't rescue the request
+		w, _ := performRequest(router, "GET", "/articles", nil,
+			map[string]string{"Accept": "application/vnd.blog.v2+json, application/json;q=0"})
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	})
+}
+
+// newBreakerRouter builds a router whose /flaky route fails while *failing is true
+func newBreakerRouter(config CircuitBreakerConfig, failing *bool) *gin.Engine {
+	r := gin.New()
+	r.Use(ErrorHandlerMiddleware())
+	r.Use(RequestIDMiddleware())
+	r.Use(CircuitBreakerMiddlewareWithConfig(config))
+
+	r.GET("/flaky", func(c *gin.Context) {
+		if *failing {
+			c.JSON(http.StatusInternalServerError, APIResponse{Success: false, Error: "backend down"})
+			return
+		}
+		c.JSON(http.StatusOK, APIResponse{Success: true})
+	})
+	r.GET("/healthy", func(c *gin.Context) {
+		c.JSON(http.StatusOK, APIResponse{Success: true})
+	})
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	return r
+}
+
+// Test Circuit Breaker Middleware
+func TestCircuitBreakerMiddleware(t *testing.T) {
+	config := CircuitBreakerConfig{
+		FailureThreshold: 3,
+		Window:           time.Second,
+		Cooldown:         50 * time.Millisecond,
+	}
+
+	t.Run("Opens after threshold and recovers", func(t *testing.T) {
+		failing := true
+		router := newBreakerRouter(config, &failing)
+
+		for i := 0; i < config.FailureThreshold; i++ {
+			w, _ := performRequest(router, "GET", "/flaky", nil, nil)
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+		}
+
+		// Breaker is open: the handler is no longer called
+		w, response := performRequest(router, "GET", "/flaky", nil, nil)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.False(t, response.Success)
+		assert.NotEmpty(t, response.RequestID)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+		// Other routes are unaffected
+		w, _ = performRequest(router, "GET", "/healthy", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// After the cooldown a successful probe closes the breaker
+		failing = false
+		time.Sleep(config.Cooldown + 10*time.Millisecond)
+		w, _ = performRequest(router, "GET", "/flaky", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w, _ = performRequest(router, "GET", "/flaky", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Failed probe reopens", func(t *testing.T) {
+		failing := true
+		router := newBreakerRouter(config, &failing)
+
+		for i := 0; i < config.FailureThreshold; i++ {
+			performRequest(router, "GET", "/flaky", nil, nil)
+		}
+
+		time.Sleep(config.Cooldown + 10*time.Millisecond)
+		w, _ := performRequest(router, "GET", "/flaky", nil, nil)
+		assert.Equal(t, http.StatusInternalServerError, w.Code, "probe should reach the handler")
+
+		w, _ = performRequest(router, "GET", "/flaky", nil, nil)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+
+	t.Run("Panics count as failures", func(t *testing.T) {
+		failing := false
+		router := newBreakerRouter(config, &failing)
+
+		for i := 0; i < config.FailureThreshold; i++ {
+			w, _ := performRequest(router, "GET", "/panic", nil, nil)
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+		}
+
+		w, _ := performRequest(router, "GET", "/panic", nil, nil)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+
+	t.Run("Half-open allows a single probe", func(t *testing.T) {
+		cb := &circuitBreaker{config: config}
+		now := time.Now()
+		for i := 0; i < config.FailureThreshold; i++ {
+			cb.record(now, true)
+		}
+
+		allowed, _ := cb.allow(now)
+		assert.False(t, allowed)
+
+		later := now.Add(config.Cooldown)
+		allowed, _ = cb.allow(later)
+		assert.True(t, allowed)
+		allowed, _ = cb.allow(later)
+		assert.False(t, allowed, "only one probe while half-open")
+	})
+
+	t.Run("Failures outside the window are forgotten", func(t *testing.T) {
+		cb := &circuitBreaker{config: config}
+		now := time.Now()
+		cb.record(now, true)
+		cb.record(now, true)
+		cb.record(now.Add(2*config.Window), true)
+
+		allowed, _ := cb.allow(now.Add(2 * config.Window))
+		assert.True(t, allowed)
+	})
+}
+
+// Test cursor pagination on GET /articles
+func TestGetArticlesCursor(t *testing.T) {
+	router := newTestRouter(t)
+	adminKey := map[string]string{"X-API-Key": "admin-key-123"}
+	for i := 0; i < 3; i++ {
+		article := Article{Title: "Cursor article", Content: "Some content", Author: "Tester"}
+		w, _ := performRequest(router, "POST", "/articles", article, adminKey)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	fetchPage := func(cursor string) ([]Article, string) {
+		w, _ := performRequest(router, "GET", "/articles?limit=2&cursor="+cursor, nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Data struct {
+				Items      []Article `json:"items"`
+				NextCursor string    `json:"next_cursor"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data.Items, response.Data.NextCursor
+	}
+
+	t.Run("Stable iteration with inserts and deletes", func(t *testing.T) {
+		var seen []int
+		cursor := ""
+		for page := 0; ; page++ {
+			items, next := fetchPage(cursor)
+			for _, article := range items {
+				seen = append(seen, article.ID)
+			}
+			if page == 0 {
+				// Deleting an already seen article would shift offsets by one
+				performRequest(router, "DELETE", "/articles/1", nil, adminKey)
+				performRequest(router, "POST", "/articles",
+					Article{Title: "Late article", Content: "Some content", Author: "Tester"}, adminKey)
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+
+		assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, seen)
+	})
+
+	t.Run("Invalid cursor", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/articles?cursor=not-a-cursor", nil, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("No cursor returns everything", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/articles", nil, nil)
+		var response struct {
+			Data []Article `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		assert.Len(t, response.Data, 5)
+	})
+}
+
+// Test the access log and GET /admin/requests
+func TestRequestLog(t *testing.T) {
+	router := newTestRouter(t)
+	adminKey := map[string]string{"X-API-Key": "admin-key-123"}
+
+	performRequest(router, "GET", "/ping", nil, nil)
+	performRequest(router, "GET", "/articles/1", nil, nil)
+	performRequest(router, "GET", "/articles/999", nil, nil)
+	performRequest(router, "GET", "/articles/abc", nil, nil)
+	performRequest(router, "POST", "/articles", Article{Title: "Only a title"}, adminKey)
+
+	fetch := func(query string) Page[AccessLogEntry] {
+		w, _ := performRequest(router, "GET", "/admin/requests"+query, nil, adminKey)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Data Page[AccessLogEntry] `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data
+	}
+
+	t.Run("Filter by status class", func(t *testing.T) {
+		page := fetch("?status_class=4xx")
+		assert.Equal(t, 3, page.Total)
+		for _, entry := range page.Items {
+			assert.Equal(t, 4, entry.Status/100)
+			assert.NotEmpty(t, entry.RequestID)
+		}
+		// Newest first
+		assert.Equal(t, "POST", page.Items[0].Method)
+	})
+
+	t.Run("Filter by path prefix", func(t *testing.T) {
+		page := fetch("?status_class=2xx&path_prefix=/articles")
+		if assert.Equal(t, 1, page.Total) {
+			assert.Equal(t, "/articles/1", page.Items[0].Path)
+			assert.Equal(t, http.StatusOK, page.Items[0].Status)
+		}
+	})
+
+	t.Run("Paging", func(t *testing.T) {
+		page := fetch("?path_prefix=/articles&limit=2&page=2")
+		assert.Equal(t, 4, page.Total)
+		assert.Len(t, page.Items, 2)
+		assert.Equal(t, "/articles/1", page.Items[1].Path)
+	})
+
+	t.Run("Invalid status class", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/admin/requests?status_class=9xx", nil, adminKey)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Admin only", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/admin/requests", nil, map[string]string{"X-API-Key": "user-key-456"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Ring buffer keeps the newest entries", func(t *testing.T) {
+		ring := newAccessLog(3)
+		for i := 1; i <= 5; i++ {
+			ring.add(AccessLogEntry{Status: 200 + i})
+		}
+		entries := ring.recent(func(AccessLogEntry) bool { return true })
+		if assert.Len(t, entries, 3) {
+			assert.Equal(t, []int{205, 204, 203}, []int{entries[0].Status, entries[1].Status, entries[2].Status})
+		}
+	})
+}
+
+// Test ECDSA-signed API keys in AuthMiddleware
+func TestSignedAPIKeys(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Load the public key the same way main does
+	der, _ := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	publicKey, err := parseECDSAPublicKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiKeyPublicKey = publicKey
+	defer func() { apiKeyPublicKey = nil }()
+
+	router := newTestRouter(t)
+	sign := func(role string, expiresAt time.Time) string {
+		key, err := signAPIKey(privateKey, SignedKeyPayload{Role: role, ExpiresAt: expiresAt.Unix()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	t.Run("Valid signed key", func(t *testing.T) {
+		key := sign("admin", time.Now().Add(time.Hour))
+		w, _ := performRequest(router, "GET", "/admin/stats", nil, map[string]string{"X-API-Key": key})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Role comes from the payload", func(t *testing.T) {
+		key := sign("user", time.Now().Add(time.Hour))
+		w, _ := performRequest(router, "GET", "/admin/stats", nil, map[string]string{"X-API-Key": key})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Expired signed key", func(t *testing.T) {
+		key := sign("admin", time.Now().Add(-time.Minute))
+		w, response := performRequest(router, "GET", "/admin/stats", nil, map[string]string{"X-API-Key": key})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, errExpiredSignedKey.Error(), response.Error)
+	})
+
+	t.Run("Tampered payload", func(t *testing.T) {
+		key := sign("user", time.Now().Add(time.Hour))
+		_, sig, _ := strings.Cut(key, ".")
+		forged, _ := json.Marshal(SignedKeyPayload{Role: "admin", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+		tampered := base64.RawURLEncoding.EncodeToString(forged) + "." + sig
+
+		w, _ := performRequest(router, "GET", "/admin/stats", nil, map[string]string{"X-API-Key": tampered})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Signed by another key", func(t *testing.T) {
+		otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		key, _ := signAPIKey(otherKey, SignedKeyPayload{Role: "admin", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+		w, _ := performRequest(router, "GET", "/admin/stats", nil, map[string]string{"X-API-Key": key})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Legacy static key still works", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/admin/stats", nil, map[string]string{"X-API-Key": "admin-key-123"})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+// Test shared paging and multi-field sorting over a seeded request log
+func TestRequestLogSorting(t *testing.T) {
+	router := newTestRouter(t)
+	adminKey := map[string]string{"X-API-Key": "admin-key-123"}
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	seed := []AccessLogEntry{
+		{Timestamp: base.Add(1 * time.Minute), Path: "/seed/a", Status: 404, User: "user"},
+		{Timestamp: base.Add(2 * time.Minute), Path: "/seed/b", Status: 200, User: "admin"},
+		{Timestamp: base.Add(3 * time.Minute), Path: "/seed/c", Status: 500, User: ""},
+		{Timestamp: base.Add(4 * time.Minute), Path: "/seed/d", Status: 200, User: "user"},
+		{Timestamp: base.Add(5 * time.Minute), Path: "/seed/e", Status: 404, User: "admin"},
+	}
+	for _, entry := range seed {
+		requestLog.add(entry)
+	}
+
+	fetchPaths := func(query string) (Page[AccessLogEntry], []string) {
+		w, _ := performRequest(router, "GET", "/admin/requests?path_prefix=/seed&"+query, nil, adminKey)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Data Page[AccessLogEntry] `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		var paths []string
+		for _, entry := range response.Data.Items {
+			paths = append(paths, entry.Path)
+		}
+		return response.Data, paths
+	}
+
+	tests := []struct {
+		query string
+		paths []string
+	}{
+		{"", []string{"/seed/e", "/seed/d", "/seed/c", "/seed/b", "/seed/a"}},
+		{"sort=timestamp", []string{"/seed/a", "/seed/b", "/seed/c", "/seed/d", "/seed/e"}},
+		{"sort=-timestamp", []string{"/seed/e", "/seed/d", "/seed/c", "/seed/b", "/seed/a"}},
+		{"sort=status,timestamp", []string{"/seed/b", "/seed/d", "/seed/a", "/seed/e", "/seed/c"}},
+		{"sort=-status,-timestamp", []string{"/seed/c", "/seed/e", "/seed/a", "/seed/d", "/seed/b"}},
+		{"sort=user,timestamp", []string{"/seed/c", "/seed/b", "/seed/e", "/seed/a", "/seed/d"}},
+	}
+	for _, test := range tests {
+		t.Run("Sort "+test.query, func(t *testing.T) {
+			page, paths := fetchPaths(test.query)
+			assert.Equal(t, test.paths, paths)
+			assert.Equal(t, 5, page.Total)
+		})
+	}
+
+	t.Run("Page math", func(t *testing.T) {
+		page, paths := fetchPaths("sort=timestamp&page=2&limit=2")
+		assert.Equal(t, []string{"/seed/c", "/seed/d"}, paths)
+		assert.Equal(t, Page[AccessLogEntry]{Items: page.Items, Total: 5, Page: 2, Limit: 2}, page)
+
+		_, paths = fetchPaths("sort=timestamp&page=3&limit=2")
+		assert.Equal(t, []string{"/seed/e"}, paths)
+
+		page, paths = fetchPaths("page=4&limit=2")
+		assert.Empty(t, paths)
+		assert.Equal(t, 5, page.Total)
+	})
+
+	t.Run("Unknown sort field", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/admin/requests?sort=path", nil, adminKey)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Authenticated role is recorded", func(t *testing.T) {
+		all := requestLog.recent(func(entry AccessLogEntry) bool { return entry.Path == "/admin/requests" })
+		if assert.NotEmpty(t, all) {
+			assert.Equal(t, "admin", all[0].User)
+		}
+	})
+}
+
+// Test page-based paging and sorting of GET /articles
+func TestGetArticlesPaged(t *testing.T) {
+	router := newTestRouter(t)
+
+	w, _ := performRequest(router, "GET", "/articles?sort=-title&limit=1&page=2", nil, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data Page[Article] `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, 2, response.Data.Total)
+	if assert.Len(t, response.Data.Items, 1) {
+		assert.Equal(t, "Getting Started with Go", response.Data.Items[0].Title)
+	}
+
+	w, _ = performRequest(router, "GET", "/articles?sort=content", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// Test GET /articles/authors/top ranking, tie-breaking and limit
+func TestTopAuthors(t *testing.T) {
+	router := newTestRouter(t)
+	articles = nil
+	for i, author := range []string{"Carol", "Alice", "Bob", "Carol", "Bob", "Dave", "Carol", "Alice"} {
+		articles = append(articles, Article{ID: i + 1, Title: "Article", Content: "Content", Author: author})
+	}
+
+	fetch := func(path string) (int, []AuthorCount) {
+		w, _ := performRequest(router, "GET", path, nil, nil)
+		var response struct {
+			Data []AuthorCount `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response.Data
+	}
+
+	t.Run("Ranking", func(t *testing.T) {
+		code, ranking := fetch("/articles/authors/top")
+		assert.Equal(t, http.StatusOK, code)
+		// Alice and Bob both have two articles and are ordered by name
+		assert.Equal(t, []AuthorCount{
+			{Author: "Carol", Count: 3},
+			{Author: "Alice", Count: 2},
+			{Author: "Bob", Count: 2},
+			{Author: "Dave", Count: 1},
+		}, ranking)
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		code, ranking := fetch("/articles/authors/top?limit=2")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []AuthorCount{{Author: "Carol", Count: 3}, {Author: "Alice", Count: 2}}, ranking)
+
+		_, ranking = fetch("/articles/authors/top?limit=10")
+		assert.Len(t, ranking, 4)
+	})
+
+	t.Run("Invalid Limit", func(t *testing.T) {
+		for _, limit := range []string{"0", "-1", "abc"} {
+			code, _ := fetch("/articles/authors/top?limit=" + limit)
+			assert.Equal(t, http.StatusBadRequest, code, limit)
+		}
+	})
+
+	t.Run("Article IDs Still Route", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/articles/1", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+// Test that article text is sanitized before it is validated and stored
+func TestSanitizeArticle(t *testing.T) {
+	originalMode := sanitizeMode
+	defer func() { sanitizeMode = originalMode }()
+	adminKey := map[string]string{"X-API-Key": "admin-key-123"}
+
+	create := func(router *gin.Engine, article Article) (int, Article) {
+		w, _ := performRequest(router, "POST", "/articles", article, adminKey)
+		var response struct {
+			Data Article `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response.Data
+	}
+
+	t.Run("Escape Script Tag", func(t *testing.T) {
+		router := newTestRouter(t)
+		sanitizeMode = SanitizeEscape
+
+		code, article := create(router, Article{
+			Title:   "  <script>alert('x')</script>Hello  ",
+			Content: "\tBody\x00 text\n",
+			Author:  " Alice ",
+		})
+		assert.Equal(t, http.StatusCreated, code)
+		assert.Equal(t, "&lt;script&gt;alert(&#39;x&#39;)&lt;/script&gt;Hello", article.Title)
+		assert.Equal(t, "Body text", article.Content)
+		assert.Equal(t, "Alice", article.Author)
+		assert.NotContains(t, articles[len(articles)-1].Title, "<script>")
+	})
+
+	t.Run("Strip Script Tag", func(t *testing.T) {
+		router := newTestRouter(t)
+		sanitizeMode = SanitizeStrip
+
+		code, article := create(router, Article{
+			Title:   "  <script>alert(1)</script>Hello  ",
+			Content: "<b>Bold</b> move",
+			Author:  "Bob",
+		})
+		assert.Equal(t, http.StatusCreated, code)
+		assert.Equal(t, "alert(1)Hello", article.Title)
+		assert.Equal(t, "Bold move", article.Content)
+
+		// Update goes through the same sanitizer
+		w, _ := performRequest(router, "PUT", "/articles/1",
+			Article{Title: " <img src=x onerror=alert(1)>Fixed ", Content: "Content", Author: "Bob"}, adminKey)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Fixed", articles[0].Title)
+	})
+
+	t.Run("Tag Only Field Is Still Required", func(t *testing.T) {
+		router := newTestRouter(t)
+		sanitizeMode = SanitizeStrip
+
+		code, _ := create(router, Article{Title: " <script></script> ", Content: "Content", Author: "Bob"})
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("Validation Sees Raw Apostrophe", func(t *testing.T) {
+		router := newTestRouter(t)
+		sanitizeMode = SanitizeEscape
+
+		code, article := create(router, Article{Title: "Late Night", Content: "Content", Author: "Conan O'Brien"})
+		assert.Equal(t, http.StatusCreated, code)
+		assert.Equal(t, "Conan O&#39;Brien", article.Author)
+
+		// Escaping would push this title past the 200 character limit
+		title := strings.Repeat("'", 200)
+		code, _ = create(router, Article{Title: title, Content: "Content", Author: "Conan O'Brien"})
+		assert.Equal(t, http.StatusCreated, code)
+	})
+
+	t.Run("Parse Mode", func(t *testing.T) {
+		mode, err := parseSanitizeMode("Strip")
+		assert.NoError(t, err)
+		assert.Equal(t, SanitizeStrip, mode)
+
+		_, err = parseSanitizeMode("remove")
+		assert.Error(t, err)
+	})
+}
+
+// Test that POST /test/reset restores the seed data only in test mode
+func TestResetTestData(t *testing.T) {
+	originalMode := testMode
+	defer func() { testMode = originalMode }()
+	adminKey := map[string]string{"X-API-Key": "admin-key-123"}
+	// Like every POST route, reset goes through ContentTypeMiddleware
+	jsonHeader := map[string]string{"Content-Type": "application/json"}
+
+	t.Run("Restores Seed Data", func(t *testing.T) {
+		router := newTestRouter(t)
+		testMode = true
+
+		performRequest(router, "POST", "/articles", Article{Title: "Extra", Content: "Content", Author: "Alice"}, adminKey)
+		performRequest(router, "DELETE", "/articles/1", nil, adminKey)
+		assert.Equal(t, 4, nextID)
+
+		w, response := performRequest(router, "POST", "/test/reset", nil, jsonHeader)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, response.Success)
+
+		seed := seedArticles()
+		if assert.Len(t, articles, len(seed)) {
+			for i := range seed {
+				assert.Equal(t, seed[i].ID, articles[i].ID)
+				assert.Equal(t, seed[i].Title, articles[i].Title)
+				assert.Equal(t, seed[i].Author, articles[i].Author)
+			}
+		}
+		assert.Equal(t, 3, nextID)
+
+		// IDs continue from the seed after a reset
+		w, _ = performRequest(router, "POST", "/articles", Article{Title: "Next", Content: "Content", Author: "Bob"}, adminKey)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, 3, articles[2].ID)
+	})
+
+	t.Run("Refused Outside Test Mode", func(t *testing.T) {
+		router := newTestRouter(t)
+		testMode = false
+
+		performRequest(router, "DELETE", "/articles/1", nil, adminKey)
+		w, response := performRequest(router, "POST", "/test/reset", nil, jsonHeader)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.False(t, response.Success)
+		assert.Len(t, articles, 1)
+	})
+}
+
+// Test that rate limit configs that can't work are rejected up front
+func TestRateLimitConfigValidation(t *testing.T) {
+	valid := RateLimitConfig{Limit: 5, Window: time.Minute, Burst: 5, RoleLimits: map[string]int{"admin": 20}}
+	assert.NoError(t, valid.validate())
+	assert.NoError(t, defaultRateLimitConfig.validate())
+
+	invalid := map[string]func(*RateLimitConfig){
+		"Zero Limit":            func(c *RateLimitConfig) { c.Limit = 0 },
+		"Zero Window":           func(c *RateLimitConfig) { c.Window = 0 },
+		"Zero Burst":            func(c *RateLimitConfig) { c.Burst = 0 },
+		"Negative Idle Timeout": func(c *RateLimitConfig) { c.IdleTimeout = -time.Second },
+		"Zero Role Limit":       func(c *RateLimitConfig) { c.RoleLimits = map[string]int{"admin": 0} },
+		"Negative Role Limit":   func(c *RateLimitConfig) { c.RoleLimits = map[string]int{"user": -1} },
+	}
+	for name, mutate := range invalid {
+		t.Run(name, func(t *testing.T) {
+			config := valid
+			mutate(&config)
+			assert.Error(t, config.validate())
+			assert.Panics(t, func() { RateLimitMiddlewareWithConfig(config) })
+		})
+	}
+}
+
+// Test that X-RateLimit-Remaining stays within [0, Limit]
+func TestRateLimitRemaining(t *testing.T) {
+	t.Run("Headers Across A Burst", func(t *testing.T) {
+		config := RateLimitConfig{Limit: 5, Window: time.Minute, Burst: 5}
+		r := gin.New()
+		r.Use(RateLimitMiddlewareWithConfig(config))
+		r.GET("/ping", ping)
+
+		for i := 0; i < 8; i++ {
+			w, _ := performRequest(r, "GET", "/ping", nil, nil)
+			assert.Equal(t, "5", w.Header().Get("X-RateLimit-Limit"))
+
+			reset, err := strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+			assert.NoError(t, err)
+			assert.Greater(t, reset, time.Now().Unix(), "reset is always in the future")
+
+			remaining, err := strconv.Atoi(w.Header().Get("X-RateLimit-Remaining"))
+			assert.NoError(t, err)
+			if i < config.Burst {
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, config.Burst-1-i, remaining)
+			} else {
+				assert.Equal(t, http.StatusTooManyRequests, w.Code)
+				assert.Equal(t, 0, remaining)
+			}
+		}
+	})
+
+	t.Run("Burst Larger Than Limit Is Clamped", func(t *testing.T) {
+		config := RateLimitConfig{Limit: 5, Window: time.Minute, Burst: 8}
+		limiter := newRateLimiter(config)
+		now := time.Now()
+
+		for i := 0; i < 10; i++ {
+			limiter.AllowN(now, 1)
+			remaining := rateLimitRemaining(limiter, config, now)
+			assert.GreaterOrEqual(t, remaining, 0)
+			assert.LessOrEqual(t, remaining, config.Limit)
+		}
+		assert.Equal(t, 0, rateLimitRemaining(limiter, config, now))
+	})
+
+	t.Run("After Refill", func(t *testing.T) {
+		config := RateLimitConfig{Limit: 10, Window: 10 * time.Second, Burst: 10}
+		limiter := newRateLimiter(config)
+		now := time.Now()
+
+		for i := 0; i < config.Burst; i++ {
+			assert.True(t, limiter.AllowN(now, 1))
+		}
+		assert.Equal(t, 0, rateLimitRemaining(limiter, config, now))
+		assert.Equal(t, now.Add(config.Window), rateLimitReset(limiter, config, now))
+
+		// One token per second, so 3.5 seconds later three requests are available
+		later := now.Add(3500 * time.Millisecond)
+		assert.Equal(t, 3, rateLimitRemaining(limiter, config, later))
+
+		// A whole window refills the bucket, but never beyond the limit
+		full := now.Add(2 * config.Window)
+		assert.Equal(t, config.Limit, rateLimitRemaining(limiter, config, full))
+		assert.Equal(t, full, rateLimitReset(limiter, config, full))
+	})
+}
+
+// Test that DELETE /articles/:id returns the deleted article and leaves no stale tail
+func TestDeleteArticleReturnsDeleted(t *testing.T) {
+	router := newTestRouter(t)
+	adminKey := map[string]string{"X-API-Key": "admin-key-123"}
+	performRequest(router, "POST", "/articles", Article{Title: "Third", Content: "Content", Author: "Carol"}, adminKey)
+
+	before := articles
+	original := append([]Article(nil), articles...)
+
+	w, _ := performRequest(router, "DELETE", "/articles/2", nil, adminKey)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data Article `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, 2, response.Data.ID)
+	assert.Equal(t, "Web Development with Gin", response.Data.Title)
+	assert.Equal(t, "Jane Smith", response.Data.Author)
+
+	if assert.Len(t, articles, 2) {
+		assert.Equal(t, 1, articles[0].ID)
+		assert.Equal(t, 3, articles[1].ID)
+	}
+	assert.Equal(t, len(articles), cap(articles), "no stale tail beyond the new length")
+	// The slice held before the delete still sees the old contents
+	assert.Equal(t, original, before)
+}
+
+// Test that GET /articles/export streams every article as a JSON array
+func TestStreamArticles(t *testing.T) {
+	router := newTestRouter(t)
+	articles = nil
+	for i := 1; i <= 2*exportFlushEvery+5; i++ {
+		articles = append(articles, Article{
+			ID:      i,
+			Title:   "Article " + strconv.Itoa(i),
+			Content: "Content",
+			Author:  "Author",
+		})
+	}
+	expected := append([]Article(nil), articles...)
+
+	w, _ := performRequest(router, "GET", "/articles/export", nil, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.True(t, w.Flushed, "the response should be flushed while streaming")
+
+	var streamed []Article
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &streamed))
+	assert.Equal(t, expected, streamed)
+
+	t.Run("Empty List", func(t *testing.T) {
+		articles = nil
+		w, _ := performRequest(router, "GET", "/articles/export", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, "[]", w.Body.String())
+	})
+}
+
+// newCORSRouter serves /ping behind a CORS middleware with the given config
+func newCORSRouter(config CORSConfig) *gin.Engine {
+	r := gin.New()
+	r.Use(CORSMiddlewareWithConfig(config))
+	r.GET("/ping", ping)
+	return r
+}
+
+func TestCORSMiddlewareWithConfig(t *testing.T) {
+	config := CORSConfig{
+		AllowOrigins:     []string{"https://myblog.com", "https://*.myblog.com"},
+		AllowMethods:     []string{"GET", "POST"},
+		AllowHeaders:     []string{"Content-Type"},
+		ExposeHeaders:    []string{"X-Request-ID", "X-RateLimit-Remaining"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+
+	t.Run("Allowed Origins", func(t *testing.T) {
+		r := newCORSRouter(config)
+		for _, origin := range []string{"https://myblog.com", "https://admin.myblog.com"} {
+			w, _ := performRequest(r, "GET", "/ping", nil, map[string]string{"Origin": origin})
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, origin, w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+			assert.Equal(t, "Origin", w.Header().Get("Vary"))
+			assert.Equal(t, "X-Request-ID, X-RateLimit-Remaining", w.Header().Get("Access-Control-Expose-Headers"))
+			assert.Empty(t, w.Header().Get("Access-Control-Max-Age"))
+		}
+	})
+
+	t.Run("Disallowed Origins", func(t *testing.T) {
+		r := newCORSRouter(config)
+		for _, origin := range []string{"https://evil.com", "https://myblog.com.evil.com", "http://myblog.com", "https://.myblog.com"} {
+			w, _ := performRequest(r, "GET", "/ping", nil, map[string]string{"Origin": origin})
+			assert.Equal(t, http.StatusOK, w.Code, origin)
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+			assert.Empty(t, w.Header().Get("Access-Control-Expose-Headers"), origin)
+		}
+
+		w, _ := performRequest(r, "GET", "/ping", nil, nil)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Preflight Short Circuits", func(t *testing.T) {
+		r := newCORSRouter(config)
+		w, _ := performRequest(r, "OPTIONS", "/ping", nil, map[string]string{
+			"Origin":                        "https://admin.myblog.com",
+			"Access-Control-Request-Method": "POST",
+		})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, "https://admin.myblog.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+		assert.Empty(t, w.Header().Get("Access-Control-Expose-Headers"))
+
+		w, _ = performRequest(r, "OPTIONS", "/ping", nil, map[string]string{"Origin": "https://evil.com"})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("Wildcard", func(t *testing.T) {
+		wildcard := CORSConfig{AllowOrigins: []string{"*"}}
+		w, _ := performRequest(newCORSRouter(wildcard), "GET", "/ping", nil, map[string]string{"Origin": "https://any.com"})
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Vary"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+
+		// With credentials the origin is echoed instead of *
+		wildcard.AllowCredentials = true
+		w, _ = performRequest(newCORSRouter(wildcard), "GET", "/ping", nil, map[string]string{"Origin": "https://any.com"})
+		assert.Equal(t, "https://any.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "Origin", w.Header().Get("Vary"))
+	})
+}
+
+func TestParseCORSOrigins(t *testing.T) {
+	origins, err := parseCORSOrigins(" https://myblog.com/, ,https://*.myblog.com ")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://myblog.com", "https://*.myblog.com"}, origins)
+
+	_, err = parseCORSOrigins(" , ")
+	assert.Error(t, err)
+
+	// 启动时配置的来源替换默认列表
+	saved := defaultCORSConfig
+	defer func() { defaultCORSConfig = saved }()
+	defaultCORSConfig.AllowOrigins = origins
+	r := gin.New()
+	r.Use(CORSMiddleware())
+	r.GET("/ping", ping)
+
+	w, _ := performRequest(r, "GET", "/ping", nil, map[string]string{"Origin": "https://admin.myblog.com"})
+	assert.Equal(t, "https://admin.myblog.com", w.Header().Get("Access-Control-Allow-Origin"))
+	w, _ = performRequest(r, "GET", "/ping", nil, map[string]string{"Origin": "http://localhost:3000"})
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestValidateArticleFields(t *testing.T) {
+	rules := ArticleRules{
+		TitleMaxLength:   10,
+		ContentMinLength: 5,
+		ContentMaxLength: 20,
+		AuthorMaxLength:  12,
+		MaxTags:          2,
+		TagMaxLength:     5,
+	}
+	valid := Article{Title: "Title", Content: "Some content", Author: "Anne-Marie O'Neil", Tags: []string{"go"}}
+
+	t.Run("Valid Article", func(t *testing.T) {
+		relaxed := rules
+		relaxed.AuthorMaxLength = 50
+		assert.Nil(t, validateArticleFields(valid, relaxed))
+		assert.Nil(t, validateArticleFields(Article{Title: "标题", Content: "中文的正文内容", Author: "王小明"}, rules))
+	})
+
+	t.Run("Reports Every Violation", func(t *testing.T) {
+		article := Article{
+			Title:   "A title that is too long",
+			Content: "Hi",
+			Author:  "R2-D2",
+			Tags:    []string{"go", " ", "toolong"},
+		}
+		assert.Equal(t, ValidationErrors{
+			{Field: "title", Message: "title must be less than 10 characters"},
+			{Field: "content", Message: "content must be at least 5 characters"},
+			{Field: "author", Message: "author may only contain letters, spaces, periods, apostrophes and hyphens"},
+			{Field: "tags", Message: "at most 2 tags are allowed"},
+			{Field: "tags[1]", Message: "tag must not be empty"},
+			{Field: "tags[2]", Message: "tag must be at most 5 characters"},
+		}, validateArticleFields(article, rules))
+	})
+
+	t.Run("Required And Maximum Lengths", func(t *testing.T) {
+		article := Article{Content: strings.Repeat("x", 21), Author: strings.Repeat("a", 13)}
+		assert.Equal(t, ValidationErrors{
+			{Field: "title", Message: "title is required"},
+			{Field: "content", Message: "content must be at most 20 characters"},
+			{Field: "author", Message: "author must be at most 12 characters"},
+		}, validateArticleFields(article, rules))
+	})
+
+	t.Run("Single Error Shim", func(t *testing.T) {
+		err := validateArticle(Article{Author: "1"})
+		if assert.Error(t, err) {
+			assert.Equal(t, "title is required", err.Error())
+		}
+		assert.NoError(t, validateArticle(Article{Title: "T", Content: "C", Author: "Ann"}))
+	})
+
+	t.Run("Handlers Return Violations", func(t *testing.T) {
+		router := newTestRouter(t)
+		adminKey := map[string]string{"X-API-Key": "admin-key-123"}
+
+		for _, req := range []struct{ method, path string }{{"POST", "/articles"}, {"PUT", "/articles/1"}} {
+			w, response := performRequest(router, req.method, req.path, Article{Author: "42"}, adminKey)
+			assert.Equal(t, http.StatusBadRequest, w.Code, req.path)
+			assert.Equal(t, "title is required; content is required; author may only contain letters, spaces, periods, apostrophes and hyphens", response.Error)
+			data, _ := response.Data.(map[string]interface{})
+			violations, _ := data["violations"].([]interface{})
+			assert.Len(t, violations, 3, req.path)
+		}
+	})
+}
+
+func TestDebugCaptureMiddleware(t *testing.T) {
+	originalCapture := debugCapture
+	defer func() { debugCapture = originalCapture }()
+	adminKey := map[string]string{"X-API-Key": "admin-key-123"}
+
+	body := map[string]interface{}{
+		"title":    "Captured",
+		"content":  "Some content",
+		"author":   "Alice",
+		"password": "hunter2",
+		"meta":     map[string]interface{}{"Access_Token": "abc", "views": 12345678901234567},
+	}
+
+	t.Run("Disabled By Default", func(t *testing.T) {
+		debugCapture = false
+		router := newTestRouter(t)
+
+		performRequest(router, "POST", "/articles", body, adminKey)
+		assert.Empty(t, debugCaptures.recent(func(DebugCapture) bool { return true }))
+
+		w, _ := performRequest(router, "GET", debugCapturePath, nil, adminKey)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Redacts And Round Trips", func(t *testing.T) {
+		debugCapture = true
+		router := newTestRouter(t)
+
+		w, response := performRequest(router, "POST", "/articles", body, adminKey)
+		assert.Equal(t, http.StatusCreated, w.Code, "handler still reads the body")
+		data, _ := response.Data.(map[string]interface{})
+		assert.Equal(t, "Captured", data["title"])
+
+		w, response = performRequest(router, "GET", debugCapturePath, nil, adminKey)
+		assert.Equal(t, http.StatusOK, w.Code)
+		captures, _ := response.Data.([]interface{})
+		if !assert.Len(t, captures, 1, "the captures endpoint is not captured itself") {
+			return
+		}
+		capture := captures[0].(map[string]interface{})
+		assert.Equal(t, "POST", capture["method"])
+		assert.Equal(t, "/articles", capture["path"])
+		assert.Equal(t, float64(http.StatusCreated), capture["status"])
+
+		assert.JSONEq(t, `{
+			"title": "Captured",
+			"content": "Some content",
+			"author": "Alice",
+			"password": "[REDACTED]",
+			"meta": {"Access_Token": "[REDACTED]", "views": 12345678901234567}
+		}`, capture["request_body"].(string))
+
+		var created map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(capture["response_body"].(string)), &created))
+		assert.Equal(t, true, created["success"])
+		assert.Equal(t, "Captured", created["data"].(map[string]interface{})["title"])
+	})
+
+	t.Run("Admin Only", func(t *testing.T) {
+		debugCapture = true
+		router := newTestRouter(t)
+
+		w, _ := performRequest(router, "GET", debugCapturePath, nil, map[string]string{"X-API-Key": "user-key-456"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Bodies That Cannot Be Redacted", func(t *testing.T) {
+		assert.Equal(t, "", redactBody(nil))
+		assert.Equal(t, "[non-JSON body, 25 bytes]", redactBody([]byte("user=bob&password=hunter2")))
+		large := []byte(`"` + strings.Repeat("x", debugCaptureMaxBody) + `"`)
+		assert.Equal(t, "[body over 65536 bytes omitted]", redactBody(large))
+		assert.JSONEq(t, `[{"token":"[REDACTED]"},{"name":"ok"}]`, redactBody([]byte(`[{"token":"t"},{"name":"ok"}]`)))
+	})
+}
+
+func TestConcurrentArticleIDs(t *testing.T) {
+	router := newTestRouter(t)
+	adminKey := map[string]string{"X-API-Key": "admin-key-123"}
+
+	var wg sync.WaitGroup
+	ids := make([]int, 50)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w, response := performRequest(router, "POST", "/articles",
+				Article{Title: "Concurrent " + strconv.Itoa(i), Content: "Content", Author: "Racer"}, adminKey)
+			if assert.Equal(t, http.StatusCreated, w.Code) {
+				data, _ := response.Data.(map[string]interface{})
+				id, _ := data["id"].(float64)
+				ids[i] = int(id)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		assert.False(t, seen[id], "duplicate ID %d", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, len(ids))
+	for i := 1; i < len(articles); i++ {
+		assert.Greater(t, articles[i].ID, articles[i-1].ID, "IDs increase in store order")
+	}
+}
+
+func TestDrainMode(t *testing.T) {
+	router := newTestRouter(t)
+	adminKey := map[string]string{"X-API-Key": "admin-key-123", "Content-Type": "application/json"}
+	article := Article{Title: "During drain", Content: "Content", Author: "Alice"}
+
+	w, _ := performRequest(router, "POST", "/admin/drain", nil, map[string]string{"X-API-Key": "user-key-456", "Content-Type": "application/json"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.False(t, draining.Load())
+
+	w, response := performRequest(router, "POST", "/admin/drain", nil, adminKey)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]interface{}{"draining": true}, response.Data)
+
+	t.Run("Writes Are Rejected", func(t *testing.T) {
+		w, _ := performRequest(router, "POST", "/articles", article, adminKey)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "30", w.Header().Get("Retry-After"))
+
+		w, _ = performRequest(router, "DELETE", "/articles/1", nil, adminKey)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Len(t, articles, 2)
+	})
+
+	t.Run("Reads Continue", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/articles", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w, _ = performRequest(router, "GET", "/admin/stats", nil, adminKey)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Undrain Resumes Writes", func(t *testing.T) {
+		w, response := performRequest(router, "POST", "/admin/undrain", nil, adminKey)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, map[string]interface{}{"draining": false}, response.Data)
+
+		w, _ = performRequest(router, "POST", "/articles", article, adminKey)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get("Retry-After"))
+	})
+}
+
+func TestContentNegotiation(t *testing.T) {
+	router := newTestRouter(t)
+
+	t.Run("JSON", func(t *testing.T) {
+		for _, accept := range []string{"application/json", "application/vnd.blog.v1+json", "text/html, */*;q=0.8"} {
+			w, response := performRequest(router, "GET", "/articles/1", nil, map[string]string{"Accept": accept})
+			assert.Equal(t, http.StatusOK, w.Code, accept)
+			assert.Contains(t, w.Header().Get("Content-Type"), "application/json", accept)
+			assert.Equal(t, "v1", response.APIVersion, accept)
+			assert.Equal(t, "Getting Started with Go", response.Data.(map[string]interface{})["title"], accept)
+		}
+	})
+
+	t.Run("XML", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/articles/1", nil, map[string]string{"Accept": "application/xml"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/xml")
+
+		var response struct {
+			XMLName    xml.Name `xml:"response"`
+			Success    bool     `xml:"success"`
+			Data       Article  `xml:"data"`
+			RequestID  string   `xml:"request_id"`
+			APIVersion string   `xml:"api_version"`
+		}
+		assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.Success)
+		assert.Equal(t, 1, response.Data.ID)
+		assert.Equal(t, "Getting Started with Go", response.Data.Title)
+		assert.Equal(t, "John Doe", response.Data.Author)
+		assert.Equal(t, w.Header().Get("X-Request-ID"), response.RequestID)
+		assert.Equal(t, "v1", response.APIVersion)
+
+		// Map payloads go through gin.H, which can be encoded as XML
+		w, _ = performRequest(router, "GET", "/admin/stats", nil,
+			map[string]string{"Accept": "text/xml", "X-API-Key": "admin-key-123"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "<total_articles>2</total_articles>")
+	})
+
+	t.Run("Unsupported Format", func(t *testing.T) {
+		w, response := performRequest(router, "GET", "/articles/1", nil, map[string]string{"Accept": "text/html"})
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+		assert.Contains(t, response.Error, "Unsupported response format")
+
+		// text/* could mean text/plain or text/html, so it doesn't get XML
+		for _, accept := range []string{"text/plain", "text/*"} {
+			w, _ = performRequest(router, "GET", "/articles/1", nil, map[string]string{"Accept": accept})
+			assert.Equal(t, http.StatusNotAcceptable, w.Code, accept)
+		}
+
+		// Rejected before the handler runs, so nothing is created
+		w, _ = performRequest(router, "POST", "/articles",
+			Article{Title: "Never", Content: "Content", Author: "Alice"},
+			map[string]string{"Accept": "text/html", "X-API-Key": "admin-key-123"})
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Len(t, articles, 2)
+	})
+}
+
+func TestProblemDetails(t *testing.T) {
+	router := newTestRouter(t)
+	originalMode := problemDetails
+	defer func() { problemDetails = originalMode }()
+
+	decode := func(t *testing.T, w *httptest.ResponseRecorder) ProblemDetails {
+		var problem ProblemDetails
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		return problem
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		problemDetails = false
+
+		w, response := performRequest(router, "GET", "/articles/999", nil, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+		assert.False(t, response.Success)
+		assert.Equal(t, "Article not found", response.Error)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		problemDetails = true
+
+		w, _ := performRequest(router, "GET", "/articles/999", nil, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "application/problem+json; charset=utf-8", w.Header().Get("Content-Type"))
+
+		problem := decode(t, w)
+		assert.Equal(t, "about:blank", problem.Type)
+		assert.Equal(t, "Article not found", problem.Title)
+		assert.Equal(t, http.StatusNotFound, problem.Status)
+		assert.Empty(t, problem.Detail)
+		assert.Equal(t, "/articles/999", problem.Instance)
+		assert.Equal(t, w.Header().Get("X-Request-ID"), problem.RequestID)
+	})
+
+	t.Run("Bad Request", func(t *testing.T) {
+		problemDetails = true
+
+		w, _ := performRequest(router, "POST", "/articles", []string{"not", "an", "article"},
+			map[string]string{"X-API-Key": "admin-key-123"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "application/problem+json; charset=utf-8", w.Header().Get("Content-Type"))
+
+		problem := decode(t, w)
+		assert.Equal(t, "about:blank", problem.Type)
+		assert.Equal(t, "Invalid request body", problem.Title)
+		assert.Equal(t, http.StatusBadRequest, problem.Status)
+		assert.Contains(t, problem.Detail, "cannot unmarshal array")
+		assert.Equal(t, "/articles", problem.Instance)
+		assert.Len(t, articles, 2)
+
+		// Clients may ask for the problem media type directly
+		w, _ = performRequest(router, "DELETE", "/articles/abc", nil,
+			map[string]string{"X-API-Key": "admin-key-123", "Accept": "application/problem+json"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "Invalid article ID", decode(t, w).Title)
+	})
+
+	t.Run("XML", func(t *testing.T) {
+		problemDetails = true
+
+		w, _ := performRequest(router, "GET", "/articles/999", nil, map[string]string{"Accept": "application/xml"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "application/problem+xml; charset=utf-8", w.Header().Get("Content-Type"))
+
+		var problem ProblemDetails
+		assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, "urn:ietf:rfc:7807", problem.XMLName.Space)
+		assert.Equal(t, "Article not found", problem.Title)
+		assert.Equal(t, http.StatusNotFound, problem.Status)
+	})
+}
+
+func TestServerOwnedArticleFields(t *testing.T) {
+	router := newTestRouter(t)
+	headers := map[string]string{"X-API-Key": "admin-key-123"}
+	bogus := "2001-02-03T04:05:06Z"
+
+	t.Run("Create ignores client timestamps", func(t *testing.T) {
+		before := time.Now()
+		w, response := performRequest(router, "POST", "/articles", map[string]interface{}{
+			"id":         99,
+			"title":      "Forged",
+			"content":    "Content",
+			"author":     "Alice",
+			"created_at": bogus,
+			"updated_at": bogus,
+		}, headers)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		data := response.Data.(map[string]interface{})
+		assert.Equal(t, float64(3), data["id"])
+		for _, field := range []string{"created_at", "updated_at"} {
+			stamp, err := time.Parse(time.RFC3339Nano, data[field].(string))
+			assert.NoError(t, err)
+			assert.False(t, stamp.Before(before.Truncate(time.Second)), field)
+		}
+
+		stored, _ := findArticleByID(3)
+		assert.True(t, stored.CreatedAt.After(before) || stored.CreatedAt.Equal(before))
+	})
+
+	t.Run("Update keeps the server creation time", func(t *testing.T) {
+		original, _ := findArticleByID(1)
+		createdAt := original.CreatedAt
+
+		w, _ := performRequest(router, "PUT", "/articles/1", map[string]interface{}{
+			"title":      "Updated",
+			"content":    "Updated content",
+			"author":     "Bob",
+			"created_at": bogus,
+			"updated_at": bogus,
+		}, headers)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		updated, _ := findArticleByID(1)
+		assert.True(t, updated.CreatedAt.Equal(createdAt))
+		assert.True(t, updated.UpdatedAt.After(createdAt))
+		assert.Equal(t, "Updated", updated.Title)
+	})
+}
+
+func TestEndpointStats(t *testing.T) {
+	router := newTestRouter(t)
+	admin := map[string]string{"X-API-Key": "admin-key-123"}
+
+	for _, path := range []string{"/articles/1", "/articles/2", "/articles/999", "/articles/abc"} {
+		performRequest(router, "GET", path, nil, nil)
+	}
+	performRequest(router, "GET", "/articles", nil, nil)
+	performRequest(router, "GET", "/articles", nil, nil)
+	performRequest(router, "PUT", "/articles/2", Article{Title: "Updated", Content: "Content", Author: "Alice"}, admin)
+	performRequest(router, "DELETE", "/articles/1", nil, admin)
+	performRequest(router, "GET", "/no-such-route", nil, nil)
+
+	w, response := performRequest(router, "GET", "/admin/endpoint-stats", nil, admin)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	counts := response.Data.(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"GET /articles/:id":    float64(4),
+		"GET /articles":        float64(2),
+		"PUT /articles/:id":    float64(1),
+		"DELETE /articles/:id": float64(1),
+		// the stats request itself is counted before the handler runs
+		"GET /admin/endpoint-stats": float64(1),
+	}, counts)
+
+	t.Run("Admin Only", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/admin/endpoint-stats", nil, map[string]string{"X-API-Key": "user-key-456"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("XML", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/admin/endpoint-stats", nil,
+			map[string]string{"X-API-Key": "admin-key-123", "Accept": "application/xml"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `<endpoint key="GET /articles/:id" count="4"></endpoint>`)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				performRequest(router, "GET", "/ping", nil, nil)
+			}(i)
+		}
+		wg.Wait()
+		assert.Equal(t, int64(50), endpointCounts.snapshot()["GET /ping"])
+	})
+}
+
+func TestServeUntilGracefulShutdown(t *testing.T) {
+	// startSlow serves a route that holds each request until release is closed
+	startSlow := func(ctx context.Context, timeout time.Duration) (string, chan struct{}, chan struct{}, <-chan error) {
+		started, release := make(chan struct{}), make(chan struct{})
+		router := gin.New()
+		router.GET("/slow", func(c *gin.Context) {
+			started <- struct{}{}
+			<-release
+			c.String(http.StatusOK, "done")
+		})
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := make(chan error, 1)
+		go func() {
+			result <- serveUntil(ctx, &http.Server{Handler: router}, listener, timeout)
+		}()
+		return "http://" + listener.Addr().String(), started, release, result
+	}
+
+	t.Run("In-flight request finishes", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		url, started, release, result := startSlow(ctx, 5*time.Second)
+
+		status := make(chan int, 1)
+		go func() {
+			resp, err := http.Get(url + "/slow")
+			if err != nil {
+				status <- 0
+				return
+			}
+			resp.Body.Close()
+			status <- resp.StatusCode
+		}()
+
+		<-started
+		cancel()
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+
+		assert.Equal(t, http.StatusOK, <-status)
+		assert.NoError(t, <-result)
+	})
+
+	t.Run("Shutdown times out", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		url, started, release, result := startSlow(ctx, 50*time.Millisecond)
+		defer close(release)
+
+		go http.Get(url + "/slow")
+		<-started
+		cancel()
+
+		select {
+		case err := <-result:
+			assert.NoError(t, err)
+		case <-time.After(2 * time.Second):
+			t.Fatal("serveUntil did not return after the shutdown timeout")
+		}
+	})
+}
+
+// Test MetricsMiddleware, GET /metrics and the request count in /admin/stats
+func TestMetrics(t *testing.T) {
+	router := newTestRouter(t)
+	adminKey := map[string]string{"X-API-Key": "admin-key-123"}
+
+	performRequest(router, "GET", "/ping", nil, nil)
+	performRequest(router, "GET", "/articles", nil, nil)
+	performRequest(router, "GET", "/articles/999", nil, nil)
+	performRequest(router, "GET", "/no-such-route", nil, nil)
+	performRequest(router, "GET", "/ping", nil, map[string]string{"Accept": "image/png"})
+
+	snapshot := metrics.snapshot()
+	assert.Equal(t, int64(5), snapshot.TotalRequests)
+	assert.Equal(t, map[int]int64{200: 2, 404: 2, 406: 1}, snapshot.ByStatus)
+	assert.Equal(t, int64(5), snapshot.Buckets[len(durationBuckets)])
+
+	t.Run("Text format", func(t *testing.T) {
+		// Prometheus asks for text/plain, which the JSON/XML negotiation would reject
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		req.Header.Set("X-API-Key", "admin-key-123")
+		req.Header.Set("Accept", "text/plain")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+		body := w.Body.String()
+		assert.Contains(t, body, "# TYPE http_requests_total counter\nhttp_requests_total 5\n")
+		assert.Contains(t, body, `http_requests_by_status_total{code="200"} 2`+"\n"+
+			`http_requests_by_status_total{code="404"} 2`+"\n"+
+			`http_requests_by_status_total{code="406"} 1`+"\n")
+		assert.Contains(t, body, `http_request_duration_seconds_bucket{le="+Inf"} 5`)
+		assert.Contains(t, body, "http_request_duration_seconds_count 5\n")
+	})
+
+	t.Run("Counters increment", func(t *testing.T) {
+		w, response := performRequest(router, "GET", "/admin/stats", nil, adminKey)
+		assert.Equal(t, http.StatusOK, w.Code)
+		// the five requests above plus the /metrics scrape
+		assert.Equal(t, float64(6), response.Data.(map[string]interface{})["total_requests"])
+
+		performRequest(router, "GET", "/ping", nil, nil)
+		snapshot := metrics.snapshot()
+		assert.Equal(t, int64(8), snapshot.TotalRequests)
+		assert.Equal(t, int64(5), snapshot.ByStatus[200])
+	})
+
+	t.Run("Admin only", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/metrics", nil, map[string]string{"X-API-Key": "user-key-456"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w, _ = performRequest(router, "GET", "/metrics", nil, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+// Test TimeoutMiddleware together with the middleware around it in main
+func TestTimeoutMiddleware(t *testing.T) {
+	newRouter := func(handler gin.HandlerFunc) *gin.Engine {
+		r := gin.New()
+		r.Use(ErrorHandlerMiddleware())
+		r.Use(RequestIDMiddleware())
+		r.Use(TimeoutMiddleware(50 * time.Millisecond))
+		r.Use(Sanitize500Middleware())
+		r.GET("/test", handler)
+		return r
+	}
+
+	t.Run("Slow handler gets 503 instead of 500", func(t *testing.T) {
+		finished := make(chan struct{})
+		router := newRouter(func(c *gin.Context) {
+			defer close(finished)
+			// a handler that gives up when its context expires and reports a 500
+			<-c.Request.Context().Done()
+			c.JSON(http.StatusInternalServerError, APIResponse{Error: c.Request.Context().Err().Error()})
+		})
+
+		start := time.Now()
+		w, response := performRequest(router, "GET", "/test", nil, nil)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.False(t, response.Success)
+		assert.Contains(t, response.Error, "Service Unavailable")
+		assert.NotEmpty(t, response.RequestID)
+		assert.Equal(t, w.Header().Get("X-Request-ID"), response.RequestID)
+		assert.NotContains(t, w.Body.String(), "deadline exceeded")
+
+		// the middleware only returns once the handler has finished with the context
+		select {
+		case <-finished:
+		default:
+			t.Error("TimeoutMiddleware returned before the handler finished")
+		}
+	})
+
+	t.Run("Fast handler is unchanged", func(t *testing.T) {
+		router := newRouter(func(c *gin.Context) {
+			c.Header("X-Custom", "yes")
+			c.JSON(http.StatusCreated, APIResponse{Success: true, Message: "made it"})
+		})
+
+		w, response := performRequest(router, "GET", "/test", nil, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "yes", w.Header().Get("X-Custom"))
+		assert.Equal(t, "made it", response.Message)
+		assert.NotEmpty(t, w.Header().Get("X-Request-ID"))
+	})
+
+	t.Run("Panic is still recovered", func(t *testing.T) {
+		router := newRouter(func(c *gin.Context) {
+			panic("boom")
+		})
+
+		w, response := performRequest(router, "GET", "/test", nil, nil)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, "Internal server error", response.Error)
+		assert.NotEmpty(t, response.RequestID)
+	})
+
+	t.Run("Full router", func(t *testing.T) {
+		router := newTestRouter(t)
+		w, response := performRequest(router, "GET", "/articles/1", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, response.Success)
+	})
+}
+
+// gunzip decodes a gzip-encoded response body
+func gunzip(t *testing.T, body []byte) []byte {
+	t.Helper()
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("response is not gzip: %v", err)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to decode gzip body: %v", err)
+	}
+	return decoded
+}
+
+func TestGzipMiddleware(t *testing.T) {
+	router := newTestRouter(t)
+	for i := 3; i <= 200; i++ {
+		articles = append(articles, Article{ID: i, Title: "Article " + strconv.Itoa(i), Content: strings.Repeat("Lorem ipsum ", 10), Author: "Author"})
+	}
+	gzipHeaders := map[string]string{"Accept-Encoding": "gzip, deflate"}
+
+	t.Run("Large JSON response is compressed", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/articles", nil, gzipHeaders)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Empty(t, w.Header().Get("Content-Length"))
+		assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+		var response APIResponse
+		decoded := gunzip(t, w.Body.Bytes())
+		assert.Less(t, w.Body.Len(), len(decoded))
+		assert.NoError(t, json.Unmarshal(decoded, &response))
+		assert.True(t, response.Success)
+		assert.Len(t, response.Data, 200)
+	})
+
+	t.Run("Not compressed without Accept-Encoding", func(t *testing.T) {
+		for _, encoding := range []string{"", "deflate", "gzip;q=0"} {
+			w, response := performRequest(router, "GET", "/articles", nil, map[string]string{"Accept-Encoding": encoding})
+			assert.Empty(t, w.Header().Get("Content-Encoding"), encoding)
+			assert.Len(t, response.Data, 200, encoding)
+		}
+	})
+
+	t.Run("Small response is not compressed", func(t *testing.T) {
+		w, response := performRequest(router, "GET", "/ping", nil, gzipHeaders)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "pong", response.Message)
+	})
+
+	t.Run("Streamed export is compressed", func(t *testing.T) {
+		w, _ := performRequest(router, "GET", "/articles/export", nil, gzipHeaders)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+
+		var streamed []Article
+		assert.NoError(t, json.Unmarshal(gunzip(t, w.Body.Bytes()), &streamed))
+		assert.Len(t, streamed, 200)
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		image := bytes.Repeat([]byte{0x89}, 4096)
+		r := gin.New()
+		r.Use(GzipMiddlewareWithConfig(GzipConfig{MinLength: 10, Level: gzip.BestSpeed, ExcludedContentTypes: []string{"image/"}}))
+		r.GET("/image", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", image) })
+		r.GET("/text", func(c *gin.Context) {
+			c.Header("Content-Length", "11")
+			c.String(http.StatusOK, "hello world")
+		})
+
+		w, _ := performRequest(r, "GET", "/image", nil, gzipHeaders)
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, image, w.Body.Bytes())
+
+		// over the lower threshold, and the stale Content-Length is dropped
+		w, _ = performRequest(r, "GET", "/text", nil, gzipHeaders)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		assert.Empty(t, w.Header().Get("Content-Length"))
+		assert.Equal(t, "hello world", string(gunzip(t, w.Body.Bytes())))
+	})
+}
+
+// headerCountingRecorder counts how often the status line is written
+type headerCountingRecorder struct {
+	*httptest.ResponseRecorder
+	writeHeaderCalls int
+}
+
+func (r *headerCountingRecorder) WriteHeader(code int) {
+	r.writeHeaderCalls++
+	r.ResponseRecorder.WriteHeader(code)
+}
+
+func TestSanitize500Writer(t *testing.T) {
+	router := newTestRouter(t)
+	router.GET("/created", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+		assert.Equal(t, http.StatusCreated, c.Writer.Status(), "handlers should see the status they set")
+		c.Writer.WriteHeaderNow()
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		c.Writer.WriteString("first,")
+		c.Writer.Write([]byte("second"))
+	})
+	router.GET("/no-content", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	serve := func(method, path string, body string, headers map[string]string) *headerCountingRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		w := &headerCountingRecorder{ResponseRecorder: httptest.NewRecorder()}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("201 from a handler", func(t *testing.T) {
+		w := serve("GET", "/created", "", nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "first,second", w.Body.String())
+		assert.Equal(t, 1, w.writeHeaderCalls)
+	})
+
+	t.Run("201 from the JSON API", func(t *testing.T) {
+		w := serve("POST", "/articles", `{"title":"New","content":"Content","author":"Alice"}`,
+			map[string]string{"Content-Type": "application/json", "X-API-Key": "admin-key-123"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, 1, w.writeHeaderCalls)
+
+		var response APIResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.Success)
+		assert.Equal(t, "New", response.Data.(map[string]interface{})["title"])
+	})
+
+	t.Run("204 without a body", func(t *testing.T) {
+		w := serve("GET", "/no-content", "", nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, 1, w.writeHeaderCalls)
+	})
+
+	t.Run("Unmatched route keeps 404", func(t *testing.T) {
+		w := serve("GET", "/no-such-route", "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, 1, w.writeHeaderCalls)
+	})
+
+	t.Run("Panic is not swallowed by the buffer", func(t *testing.T) {
+		r := gin.New()
+		r.Use(ErrorHandlerMiddleware())
+		r.Use(RequestIDMiddleware())
+		r.Use(Sanitize500Middleware())
+		r.GET("/panic", func(c *gin.Context) {
+			c.Writer.WriteString("partial")
+			panic("boom")
+		})
+
+		w, response := performRequest(r, "GET", "/panic", nil, nil)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, "Internal server error", response.Error)
+		assert.NotContains(t, w.Body.String(), "partial")
+	})
+}
+
+// Test article tags: normalisation on write and the ?tag= filter
+func TestArticleTags(t *testing.T) {
+	router := newTestRouter(t)
+	adminKey := map[string]string{"X-API-Key": "admin-key-123"}
+
+	create := func(title string, tags ...string) Article {
+		t.Helper()
+		w, response := performRequest(router, "POST", "/articles",
+			ArticleInput{Title: title, Content: "Some content", Author: "Tester", Tags: tags}, adminKey)
+		if !assert.Equal(t, http.StatusCreated, w.Code, w.Body.String()) {
+			return Article{}
+		}
+		data, _ := json.Marshal(response.Data)
+		var article Article
+		json.Unmarshal(data, &article)
+		return article
+	}
+
+	goBasics := create("Go basics", "go", " Go ", "", "web", "go")
+	create("Gin routing", "gin", "GO")
+	create("Cooking", "food")
+	for i := 1; i <= 3; i++ {
+		create("Go part "+strconv.Itoa(i), "go")
+	}
+
+	t.Run("Duplicates and empties are collapsed", func(t *testing.T) {
+		assert.Equal(t, []string{"go", "web"}, goBasics.Tags)
+
+		// eleven tags that collapse to two are within the limit of ten
+		tags := []string{"a", "b"}
+		for i := 0; i < 9; i++ {
+			tags = append(tags, "A")
+		}
+		assert.Equal(t, []string{"a", "b"}, create("Many duplicates", tags...).Tags)
+	})
+
+	t.Run("More than ten distinct tags are rejected", func(t *testing.T) {
+		tags := make([]string, 11)
+		for i := range tags {
+			tags[i] = "tag" + strconv.Itoa(i)
+		}
+		w, _ := performRequest(router, "POST", "/articles",
+			ArticleInput{Title: "Too many", Content: "Some content", Author: "Tester", Tags: tags}, adminKey)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "at most 10 tags")
+	})
+
+	t.Run("Update normalises tags", func(t *testing.T) {
+		w, response := performRequest(router, "PUT", "/articles/"+strconv.Itoa(goBasics.ID),
+			ArticleInput{Title: "Go basics", Content: "Some content", Author: "Tester", Tags: []string{"web", "WEB", " "}}, adminKey)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []interface{}{"web"}, response.Data.(map[string]interface{})["tags"])
+	})
+
+	titles := func(items interface{}) []string {
+		var result []string
+		for _, item := range items.([]interface{}) {
+			result = append(result, item.(map[string]interface{})["title"].(string))
+		}
+		return result
+	}
+
+	t.Run("Filter narrows results", func(t *testing.T) {
+		w, response := performRequest(router, "GET", "/articles?tag=go", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"Gin routing", "Go part 1", "Go part 2", "Go part 3"}, titles(response.Data))
+
+		_, response = performRequest(router, "GET", "/articles?tag=unknown", nil, nil)
+		assert.Empty(t, response.Data)
+	})
+
+	t.Run("Filter combines with pagination", func(t *testing.T) {
+		_, response := performRequest(router, "GET", "/articles?tag=go&page=2&limit=3", nil, nil)
+		page := response.Data.(map[string]interface{})
+		assert.Equal(t, []string{"Go part 3"}, titles(page["items"]))
+		assert.Equal(t, float64(4), page["total"])
+
+		_, response = performRequest(router, "GET", "/articles?tag=go&cursor=&limit=3", nil, nil)
+		page = response.Data.(map[string]interface{})
+		assert.Equal(t, []string{"Gin routing", "Go part 1", "Go part 2"}, titles(page["items"]))
+		assert.NotEmpty(t, page["next_cursor"])
+	})
+}
+
+// Test optimistic concurrency control on PUT /articles/:id
+func TestUpdateArticleVersion(t *testing.T) {
+	router := newTestRouter(t)
+	adminKey := map[string]string{"X-API-Key": "admin-key-123"}
+	update := func(title string, version int) (*httptest.ResponseRecorder, APIResponse) {
+		return performRequest(router, "PUT", "/articles/1",
+			ArticleInput{Title: title, Content: "Some content", Author: "Tester", Version: version}, adminKey)
+	}
+	version := func(response APIResponse) float64 {
+		return response.Data.(map[string]interface{})["version"].(float64)
+	}
+
+	_, response := performRequest(router, "GET", "/articles/1", nil, nil)
+	read := int(version(response))
+	assert.Equal(t, 1, read)
+
+	t.Run("Fresh update bumps the version", func(t *testing.T) {
+		w, response := update("First edit", read)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, float64(2), version(response))
+	})
+
+	t.Run("Stale update is rejected", func(t *testing.T) {
+		// a second client still holding version 1
+		w, response := update("Second edit", read)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.False(t, response.Success)
+		assert.Contains(t, response.Error, "version 2")
+
+		_, response = performRequest(router, "GET", "/articles/1", nil, nil)
+		assert.Equal(t, "First edit", response.Data.(map[string]interface{})["title"])
+		assert.Equal(t, float64(2), version(response))
+	})
+
+	t.Run("Update without a version is refused when required", func(t *testing.T) {
+		requireArticleVersion = true
+		defer func() { requireArticleVersion = false }()
+
+		w, response := update("Blind edit", 0)
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+		assert.Contains(t, response.Error, "version 2")
+
+		w, response = update("Third edit", 2)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, float64(3), version(response))
+	})
+
+	t.Run("Update without a version is unconditional by default", func(t *testing.T) {
+		w, response := update("Fourth edit", 0)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, float64(4), version(response))
+	})
+
+	t.Run("New articles start at version 1", func(t *testing.T) {
+		w, response := performRequest(router, "POST", "/articles",
+			ArticleInput{Title: "New", Content: "Some content", Author: "Tester"}, adminKey)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, float64(1), version(response))
+	})
+}
+
+// Test that limiters for idle IPs are reclaimed
+func TestRateLimitIdleEviction(t *testing.T) {
+	requestFrom := func(r *gin.Engine, ip string) int {
+		req, _ := http.NewRequest("GET", "/ping", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("Idle IP is eventually reclaimed", func(t *testing.T) {
+		// one token every 10ms, so the bucket is full again well before the IP counts as idle
+		config := RateLimitConfig{Limit: 10, Window: 100 * time.Millisecond, Burst: 2, IdleTimeout: 50 * time.Millisecond}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		limiters := startRateLimiters(ctx, config)
+		r := gin.New()
+		r.Use(rateLimitMiddleware(&rateLimitTiers{anonymous: limiters}))
+		r.GET("/ping", ping)
+
+		assert.Equal(t, http.StatusOK, requestFrom(r, "10.0.0.1"))
+		assert.Equal(t, http.StatusOK, requestFrom(r, "10.0.0.2"))
+		assert.Equal(t, 2, limiters.count())
+
+		assert.Eventually(t, func() bool { return limiters.count() == 0 }, time.Second, 10*time.Millisecond)
+
+		// a reclaimed IP starts again with a full bucket
+		assert.Equal(t, http.StatusOK, requestFrom(r, "10.0.0.1"))
+		assert.Equal(t, 1, limiters.count())
+	})
+
+	t.Run("Sweeper stops with its context", func(t *testing.T) {
+		config := RateLimitConfig{Limit: 10, Window: 100 * time.Millisecond, Burst: 2, IdleTimeout: 20 * time.Millisecond}
+		ctx, cancel := context.WithCancel(context.Background())
+		limiters := startRateLimiters(ctx, config)
+		cancel()
+
+		limiters.get("10.0.0.1", time.Now())
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, 1, limiters.count())
+	})
+
+	t.Run("Only idle IPs with a full bucket are swept", func(t *testing.T) {
+		config := RateLimitConfig{Limit: 5, Window: 5 * time.Second, Burst: 5, IdleTimeout: 10 * time.Second}
+		limiters := newRateLimiters(config)
+		start := time.Now()
+
+		limiters.get("idle", start)
+		drained := limiters.get("drained", start)
+		for i := 0; i < config.Burst; i++ {
+			drained.AllowN(start, 1)
+		}
+		limiters.get("active", start.Add(9*time.Second))
+
+		// nothing has been idle for 10s yet; by then the drained bucket has refilled,
+		// so it goes along with the idle one
+		assert.Equal(t, 0, limiters.sweep(start.Add(5*time.Second)))
+		assert.Equal(t, 2, limiters.sweep(start.Add(10*time.Second)))
+		assert.Equal(t, 1, limiters.count())
+
+		limiters = newRateLimiters(RateLimitConfig{Limit: 5, Window: time.Hour, Burst: 5, IdleTimeout: time.Second})
+		slow := limiters.get("slow", start)
+		slow.AllowN(start, 1)
+		// idle, but reclaiming it would hand out a token that hasn't refilled yet
+		assert.Equal(t, 0, limiters.sweep(start.Add(time.Minute)))
+	})
+}
+
+// Test BodySizeLimitMiddleware on the article API
+func TestBodySizeLimit(t *testing.T) {
+	router := newTestRouter(t)
+	oversized := `{"title":"Big","content":"` + strings.Repeat("x", int(maxRequestBodyBytes)) + `","author":"Tester"}`
+
+	post := func(body io.Reader, contentLength int64) (*httptest.ResponseRecorder, APIResponse) {
+		req, _ := http.NewRequest("POST", "/articles", body)
+		req.ContentLength = contentLength
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", "admin-key-123")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response APIResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	t.Run("Content-Length over the limit", func(t *testing.T) {
+		w, response := post(strings.NewReader(oversized), int64(len(oversized)))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.False(t, response.Success)
+		assert.Contains(t, response.Error, "Request body too large")
+		assert.NotEmpty(t, response.RequestID)
+		assert.Equal(t, w.Header().Get("X-Request-ID"), response.RequestID)
+		assert.Len(t, articles, 2)
+	})
+
+	t.Run("Chunked body over the limit", func(t *testing.T) {
+		// no Content-Length, so the limit is only noticed while binding
+		w, response := post(io.MultiReader(strings.NewReader(oversized)), -1)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.NotEmpty(t, response.RequestID)
+		assert.Len(t, articles, 2)
+	})
+
+	t.Run("Debug capture does not hide the limit", func(t *testing.T) {
+		debugCapture = true
+		defer func() { debugCapture = false }()
+
+		w, _ := post(io.MultiReader(strings.NewReader(oversized)), -1)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("Body within the limit", func(t *testing.T) {
+		body := `{"title":"Small","content":"Some content","author":"Tester"}`
+		w, _ := post(strings.NewReader(body), -1)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+}
+
+func TestStructuredAccessLog(t *testing.T) {
+	newLoggingRouter := func(format LogFormat, output io.Writer) *gin.Engine {
+		r := gin.New()
+		r.Use(RequestIDMiddleware())
+		r.Use(LoggingMiddlewareWithConfig(LoggingConfig{Format: format, Output: output}))
+		r.GET("/articles", getArticles)
+		return r
+	}
+
+	t.Run("JSON format", func(t *testing.T) {
+		var buf bytes.Buffer
+		router := newLoggingRouter(LogFormatJSON, &buf)
+
+		w, response := performRequest(router, "GET", "/articles", nil, map[string]string{"User-Agent": "log-test/1.0"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 1, "one line per request")
+
+		var entry map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+		for _, field := range []string{"request_id", "method", "path", "status", "duration_ms", "ip", "user_agent", "bytes"} {
+			assert.Contains(t, entry, field)
+		}
+		assert.Equal(t, response.RequestID, entry["request_id"])
+		assert.Equal(t, "GET", entry["method"])
+		assert.Equal(t, "/articles", entry["path"])
+		assert.Equal(t, float64(http.StatusOK), entry["status"])
+		assert.Equal(t, "log-test/1.0", entry["user_agent"])
+		assert.Equal(t, float64(w.Body.Len()), entry["bytes"])
+		assert.GreaterOrEqual(t, entry["duration_ms"], float64(0))
+	})
+
+	t.Run("No body logs zero bytes", func(t *testing.T) {
+		var buf bytes.Buffer
+		router := newLoggingRouter(LogFormatJSON, &buf)
+
+		performRequest(router, "GET", "/missing", nil, nil)
+		var entry accessLogLine
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+		assert.Equal(t, http.StatusNotFound, entry.Status)
+		assert.GreaterOrEqual(t, entry.Bytes, 0)
+	})
+
+	t.Run("Text format is unchanged", func(t *testing.T) {
+		var buf bytes.Buffer
+		router := newLoggingRouter(LogFormatText, &buf)
+
+		_, response := performRequest(router, "GET", "/articles", nil, nil)
+		line := buf.String()
+		assert.Contains(t, line, "["+response.RequestID+"] GET /articles | Status: 200")
+		assert.False(t, json.Valid([]byte(strings.TrimSpace(line))))
+	})
+
+	t.Run("Parse format", func(t *testing.T) {
+		format, err := parseLogFormat(" JSON ")
+		assert.NoError(t, err)
+		assert.Equal(t, LogFormatJSON, format)
+		format, err = parseLogFormat("text")
+		assert.NoError(t, err)
+		assert.Equal(t, LogFormatText, format)
+		_, err = parseLogFormat("xml")
+		assert.Error(t, err)
+	})
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	assertSecurityHeaders := func(t *testing.T, w *httptest.ResponseRecorder, csp string) {
+		t.Helper()
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+		assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+		assert.Equal(t, csp, w.Header().Get("Content-Security-Policy"))
+	}
+
+	t.Run("Present on a normal response", func(t *testing.T) {
+		router := newTestRouter(t)
+		w, _ := performRequest(router, "GET", "/articles", nil, map[string]string{"Origin": "http://localhost:3000"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assertSecurityHeaders(t, w, defaultContentSecurityPolicy)
+
+		// CORS headers are untouched
+		assert.Equal(t, "http://localhost:3000", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("Present on early returns", func(t *testing.T) {
+		router := newTestRouter(t)
+		w, _ := performRequest(router, "OPTIONS", "/articles", nil, map[string]string{"Origin": "http://localhost:3000"})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assertSecurityHeaders(t, w, defaultContentSecurityPolicy)
+
+		w, _ = performRequest(router, "DELETE", "/articles/1", nil, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assertSecurityHeaders(t, w, defaultContentSecurityPolicy)
+	})
+
+	t.Run("Custom policy", func(t *testing.T) {
+		for _, csp := range []string{"default-src 'self'", ""} {
+			r := gin.New()
+			r.Use(SecurityHeadersMiddlewareWithConfig(SecurityHeadersConfig{ContentSecurityPolicy: csp}))
+			r.GET("/articles", getArticles)
+
+			w, _ := performRequest(r, "GET", "/articles", nil, nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assertSecurityHeaders(t, w, csp)
+		}
+	})
+}
+
+func TestStatsAuthors(t *testing.T) {
+	router := newTestRouter(t)
+	adminKey := map[string]string{"X-API-Key": "admin-key-123"}
+
+	getStatsData := func() map[string]interface{} {
+		w, response := performRequest(router, "GET", "/admin/stats", nil, adminKey)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return response.Data.(map[string]interface{})
+	}
+
+	// the seed data has two articles from two authors
+	for _, input := range []ArticleInput{
+		{Title: "Third", Content: "abcdefghij", Author: "Carol"},
+		{Title: "Fourth", Content: "abcdefghij", Author: "Carol"},
+		{Title: "Fifth", Content: "abcdefghij", Author: "Carol"},
+	} {
+		w, _ := performRequest(router, "POST", "/articles", input, adminKey)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	articlesMutex.RLock()
+	totalContentLength := 0
+	for _, article := range articles {
+		totalContentLength += len([]rune(article.Content))
+	}
+	articlesMutex.RUnlock()
+
+	stats := getStatsData()
+	assert.Equal(t, float64(5), stats["total_articles"])
+	assert.Equal(t, float64(3), stats["total_authors"])
+	assert.Equal(t, map[string]interface{}{"author": "Carol", "count": float64(3)}, stats["top_author"])
+	assert.InDelta(t, float64(totalContentLength)/5, stats["avg_content_length"], 0.001)
+
+	t.Run("No articles", func(t *testing.T) {
+		articlesMutex.Lock()
+		articles = []Article{}
+		articlesMutex.Unlock()
+
+		stats := getStatsData()
+		assert.Equal(t, float64(0), stats["total_authors"])
+		assert.Nil(t, stats["top_author"])
+		assert.Equal(t, float64(0), stats["avg_content_length"])
+	})
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	input := ArticleInput{Title: "Retried", Content: "Posted with a retry", Author: "Tester"}
+	post := func(router *gin.Engine, body interface{}, headers map[string]string) (*httptest.ResponseRecorder, APIResponse) {
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		if headers["X-API-Key"] == "" {
+			headers["X-API-Key"] = "admin-key-123"
+		}
+		return performRequest(router, "POST", "/articles", body, headers)
+	}
+
+	t.Run("Same key creates once and replays the response", func(t *testing.T) {
+		router := newTestRouter(t)
+		key := map[string]string{"Idempotency-Key": "create-1"}
+
+		first, _ := post(router, input, key)
+		assert.Equal(t, http.StatusCreated, first.Code)
+		assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+		second, _ := post(router, input, key)
+		assert.Equal(t, http.StatusCreated, second.Code)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, first.Header().Get("Content-Type"), second.Header().Get("Content-Type"))
+		assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+		assert.Len(t, articles, 3)
+
+		// a new key is a new request
+		w, _ := post(router, input, map[string]string{"Idempotency-Key": "create-2"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Len(t, articles, 4)
+	})
+
+	t.Run("Without the header every request creates", func(t *testing.T) {
+		router := newTestRouter(t)
+		post(router, input, nil)
+		post(router, input, nil)
+		assert.Len(t, articles, 4)
+	})
+
+	t.Run("Failed requests are not cached", func(t *testing.T) {
+		router := newTestRouter(t)
+		key := map[string]string{"Idempotency-Key": "retry-after-fix"}
+
+		w, _ := post(router, ArticleInput{Title: "Missing author", Content: "Some content"}, key)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w, _ = post(router, input, key)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+		assert.Len(t, articles, 3)
+	})
+
+	t.Run("Keys are scoped per API key", func(t *testing.T) {
+		router := newTestRouter(t)
+		post(router, input, map[string]string{"Idempotency-Key": "shared"})
+		w, _ := post(router, input, map[string]string{"Idempotency-Key": "shared", "X-API-Key": "user-key-456"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+		assert.Len(t, articles, 4)
+	})
+
+	t.Run("Key too long", func(t *testing.T) {
+		router := newTestRouter(t)
+		w, _ := post(router, input, map[string]string{"Idempotency-Key": strings.Repeat("k", idempotencyKeyMaxLength+1)})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Len(t, articles, 2)
+	})
+
+	t.Run("Same key with a different body is rejected", func(t *testing.T) {
+		router := newTestRouter(t)
+		key := map[string]string{"Idempotency-Key": "create-3"}
+
+		w, _ := post(router, input, key)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		changed := input
+		changed.Title = "Edited before the retry"
+		w, response := post(router, changed, key)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+		assert.Contains(t, response.Error, "different request body")
+		assert.Len(t, articles, 3)
+
+		// the original body still replays
+		w, _ = post(router, input, key)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	})
+
+	t.Run("Store", func(t *testing.T) {
+		store := newIdempotencyStore(time.Minute)
+		now := time.Now()
+		body := sha256.Sum256([]byte(`{"title":"a"}`))
+		otherBody := sha256.Sum256([]byte(`{"title":"b"}`))
+
+		cached, inProgress, mismatch := store.begin("k", body, now)
+		assert.Nil(t, cached)
+		assert.False(t, inProgress)
+		assert.False(t, mismatch)
+
+		// a concurrent retry while the first request is running
+		_, inProgress, _ = store.begin("k", body, now)
+		assert.True(t, inProgress)
+		// a different body is a mismatch even while the first is running
+		_, inProgress, mismatch = store.begin("k", otherBody, now)
+		assert.False(t, inProgress)
+		assert.True(t, mismatch)
+
+		response := &cachedResponse{status: http.StatusCreated, body: []byte("{}")}
+		store.finish("k", body, response, now)
+		cached, _, _ = store.begin("k", body, now.Add(59*time.Second))
+		assert.Same(t, response, cached)
+		cached, _, mismatch = store.begin("k", otherBody, now.Add(59*time.Second))
+		assert.Nil(t, cached)
+		assert.True(t, mismatch)
+
+		// expired entries are swept and the key starts over, with any body
+		cached, inProgress, mismatch = store.begin("k", otherBody, now.Add(time.Minute))
+		assert.Nil(t, cached)
+		assert.False(t, inProgress)
+		assert.False(t, mismatch)
+
+		// a failed request releases its key
+		store.finish("k", otherBody, nil, now)
+		assert.Empty(t, store.entries)
+	})
+}
+
+func TestRateLimitRoleTiers(t *testing.T) {
+	limitOf := func(w *httptest.ResponseRecorder) int {
+		limit, err := strconv.Atoi(w.Header().Get("X-RateLimit-Limit"))
+		assert.NoError(t, err)
+		return limit
+	}
+
+	t.Run("Default tiers", func(t *testing.T) {
+		router := newTestRouter(t)
+
+		w, _ := performRequest(router, "GET", "/ping", nil, nil)
+		assert.Equal(t, 100, limitOf(w))
+		w, _ = performRequest(router, "GET", "/ping", nil, map[string]string{"X-API-Key": "user-key-456"})
+		assert.Equal(t, 300, limitOf(w))
+		w, _ = performRequest(router, "GET", "/ping", nil, map[string]string{"X-API-Key": "admin-key-123"})
+		assert.Equal(t, 1000, limitOf(w))
+
+		// an invalid key is treated as anonymous
+		w, _ = performRequest(router, "GET", "/ping", nil, map[string]string{"X-API-Key": "bogus"})
+		assert.Equal(t, 100, limitOf(w))
+	})
+
+	t.Run("Admin is not throttled at the anonymous threshold", func(t *testing.T) {
+		config := RateLimitConfig{Limit: 5, Window: time.Minute, Burst: 5, RoleLimits: map[string]int{"admin": 20, "user": 10}}
+		r := gin.New()
+		r.Use(APIKeyRoleMiddleware())
+		r.Use(RateLimitMiddlewareWithConfig(config))
+		r.GET("/ping", ping)
+
+		admin := map[string]string{"X-API-Key": "admin-key-123"}
+		for i := 0; i < 20; i++ {
+			w, _ := performRequest(r, "GET", "/ping", nil, admin)
+			assert.Equal(t, http.StatusOK, w.Code, "admin request %d", i+1)
+			assert.Equal(t, strconv.Itoa(19-i), w.Header().Get("X-RateLimit-Remaining"))
+		}
+		w, _ := performRequest(r, "GET", "/ping", nil, admin)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+		// the same IP still has its own anonymous and user buckets
+		for i := 0; i < 5; i++ {
+			w, _ = performRequest(r, "GET", "/ping", nil, nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+		w, _ = performRequest(r, "GET", "/ping", nil, nil)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, 5, limitOf(w))
+
+		w, _ = performRequest(r, "GET", "/ping", nil, map[string]string{"X-API-Key": "user-key-456"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 10, limitOf(w))
+	})
+
+	t.Run("Roles without a tier are limited by IP", func(t *testing.T) {
+		r := gin.New()
+		r.Use(APIKeyRoleMiddleware())
+		r.Use(RateLimitMiddlewareWithConfig(RateLimitConfig{Limit: 2, Window: time.Minute, Burst: 2}))
+		r.GET("/ping", ping)
+
+		performRequest(r, "GET", "/ping", nil, nil)
+		w, _ := performRequest(r, "GET", "/ping", nil, map[string]string{"X-API-Key": "admin-key-123"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		w, _ = performRequest(r, "GET", "/ping", nil, map[string]string{"X-API-Key": "admin-key-123"})
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
+}
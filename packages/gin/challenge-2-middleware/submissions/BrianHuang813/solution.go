@@ -4,6 +4,7 @@ import (
     "fmt"
 	"log"
 	"net/http"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -44,7 +45,7 @@ func main() {
 	router := gin.New()
 
 	// 全域中介軟體 
-	router.Use(ErrorHandlerMiddleware(), RequestIDMiddleware(), LoggingMiddleware(), CORSMiddleware())
+	router.Use(ErrorHandlerMiddleware(), RequestIDMiddleware(), LoggingMiddleware(), CORSMiddlewareWithOrigins(allowedOriginsFromEnv()))
 
 	// --- 2. 劃分「公開」和「受保護」的區域 ---
 
@@ -152,23 +153,88 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
-// CORSMiddleware handles cross-origin requests (Done)
+// 預設允許的來源，可在啟動時用環境變數 CORS_ALLOWED_ORIGINS 覆蓋
+var defaultAllowedOrigins = []string{"http://localhost:3000", "https://myblog.com"}
+
+const (
+	corsAllowMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	corsAllowHeaders = "Content-Type, X-API-Key, X-Request-ID, Authorization"
+	corsMaxAge       = "600" // 預檢結果快取 10 分鐘
+)
+
+// allowedOriginsFromEnv 讀取 CORS_ALLOWED_ORIGINS（以逗號分隔），沒設定時使用預設清單
+func allowedOriginsFromEnv() []string {
+	value := os.Getenv("CORS_ALLOWED_ORIGINS")
+	if strings.TrimSpace(value) == "" {
+		return defaultAllowedOrigins
+	}
+	return strings.Split(value, ",")
+}
+
+// CORSMiddleware handles cross-origin requests with the default allowlist (Done)
 func CORSMiddleware() gin.HandlerFunc {
+	return CORSMiddlewareWithOrigins(defaultAllowedOrigins)
+}
+
+// CORSMiddlewareWithOrigins 只有請求的 Origin 在允許清單裡才回傳 CORS 標頭
+// 清單裡有 "*" 時允許任何來源並回傳 "*"，這時不能帶憑證（規範不允許 * 搭配 Allow-Credentials）
+// 其他情況回傳請求的 Origin 本身，並允許帶憑證
+func CORSMiddlewareWithOrigins(allowed []string) gin.HandlerFunc {
+	allowAny := false
+	allowedSet := make(map[string]bool)
+	for _, origin := range allowed {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin == "*" {
+			allowAny = true
+		} else if origin != "" {
+			allowedSet[origin] = true
+		}
+	}
+
 	return func(c *gin.Context) {
-		// TODO: Set CORS headers
-		// Allow origins: http://localhost:3000, https://myblog.com
-		c.Header("Access-Control-Allow-Origin", "http://localhost:3000")
-		// Allow methods: GET, POST, PUT, DELETE, OPTIONS
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		// Allow headers: Content-Type, X-API-Key, X-Request-ID
-        c.Header("Access-Control-Allow-Headers", "Content-Type, X-API-Key, X-Request-ID, Authorization")
-		// TODO: Handle preflight OPTIONS requests
-        if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
+		origin := c.GetHeader("Origin")
+		// 回應內容會隨 Origin 改變，告訴快取要依 Origin 區分
+		c.Writer.Header().Add("Vary", "Origin")
+
+		originAllowed := origin != "" && (allowAny || allowedSet[origin])
+		if originAllowed {
+			if allowAny {
+				c.Header("Access-Control-Allow-Origin", "*")
+			} else {
+				c.Header("Access-Control-Allow-Origin", origin)
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if c.Request.Method != http.MethodOptions {
+			if originAllowed {
+				c.Header("Access-Control-Allow-Methods", corsAllowMethods)
+				c.Header("Access-Control-Allow-Headers", corsAllowHeaders)
+			}
+			c.Next()
 			return
 		}
-		c.Next()
+
+		// 預檢請求：只有要求的方法在允許清單裡才回傳允許的方法和標頭，否則瀏覽器會擋下後續請求
+		// 來源不允許時同樣回 204，只是不帶 CORS 標頭
+		requested := c.GetHeader("Access-Control-Request-Method")
+		if originAllowed && (requested == "" || corsMethodAllowed(requested)) {
+			c.Header("Access-Control-Allow-Methods", corsAllowMethods)
+			c.Header("Access-Control-Allow-Headers", corsAllowHeaders)
+			c.Header("Access-Control-Max-Age", corsMaxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// corsMethodAllowed 檢查預檢請求要求的方法是否在允許清單裡
+func corsMethodAllowed(method string) bool {
+	for _, allowed := range strings.Split(corsAllowMethods, ", ") {
+		if strings.EqualFold(method, allowed) {
+			return true
+		}
 	}
+	return false
 }
 
 // RateLimitMiddleware 實作每個 IP 的請求速率限制，並加上回饋標頭
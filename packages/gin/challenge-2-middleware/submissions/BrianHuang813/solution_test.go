@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newCORSRouter serves /articles behind the CORS middleware with the given allowlist
+func newCORSRouter(allowed []string) *gin.Engine {
+	r := gin.New()
+	r.Use(CORSMiddlewareWithOrigins(allowed))
+	r.GET("/articles", getArticles)
+	return r
+}
+
+func corsRequest(r *gin.Engine, method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "/articles", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSAllowlist(t *testing.T) {
+	router := newCORSRouter([]string{"http://localhost:3000", " https://myblog.com/ "})
+
+	t.Run("Allowed origin is reflected", func(t *testing.T) {
+		for _, origin := range []string{"http://localhost:3000", "https://myblog.com"} {
+			w := corsRequest(router, "GET", origin, nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, origin, w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+			assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "GET")
+			assert.Contains(t, w.Header().Values("Vary"), "Origin")
+		}
+	})
+
+	t.Run("Disallowed origin gets no CORS headers", func(t *testing.T) {
+		for _, origin := range []string{"https://evil.com", "http://localhost:3001", ""} {
+			w := corsRequest(router, "GET", origin, nil)
+			assert.Equal(t, http.StatusOK, w.Code, "the request itself is still served")
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"), origin)
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"), origin)
+		}
+	})
+
+	t.Run("Preflight", func(t *testing.T) {
+		w := corsRequest(router, "OPTIONS", "http://localhost:3000", map[string]string{
+			"Access-Control-Request-Method":  "PUT",
+			"Access-Control-Request-Headers": "Content-Type, X-API-Key",
+		})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "http://localhost:3000", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "PUT")
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "X-API-Key")
+		assert.NotEmpty(t, w.Header().Get("Access-Control-Max-Age"))
+		assert.Empty(t, w.Body.String())
+
+		// a method outside the allowlist is not approved
+		w = corsRequest(router, "OPTIONS", "http://localhost:3000", map[string]string{"Access-Control-Request-Method": "PATCH"})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+
+		// nor is a disallowed origin
+		w = corsRequest(router, "OPTIONS", "https://evil.com", map[string]string{"Access-Control-Request-Method": "GET"})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+	})
+
+	t.Run("Wildcard disables credentials", func(t *testing.T) {
+		router := newCORSRouter([]string{"*"})
+		w := corsRequest(router, "GET", "https://anywhere.example", nil)
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+
+		w = corsRequest(router, "OPTIONS", "https://anywhere.example", map[string]string{"Access-Control-Request-Method": "DELETE"})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "DELETE")
+	})
+
+	t.Run("Allowlist from the environment", func(t *testing.T) {
+		t.Setenv("CORS_ALLOWED_ORIGINS", "")
+		assert.Equal(t, defaultAllowedOrigins, allowedOriginsFromEnv())
+
+		t.Setenv("CORS_ALLOWED_ORIGINS", "https://a.example,https://b.example")
+		router := newCORSRouter(allowedOriginsFromEnv())
+		w := corsRequest(router, "GET", "https://b.example", nil)
+		assert.Equal(t, "https://b.example", w.Header().Get("Access-Control-Allow-Origin"))
+		w = corsRequest(router, "GET", "http://localhost:3000", nil)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
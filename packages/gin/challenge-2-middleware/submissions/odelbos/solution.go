@@ -4,6 +4,7 @@ import (
 	"time"
 	"net/http"
 	"log"
+	"os"
 	"strconv"
 	"fmt"
 	"strings"
@@ -51,11 +52,24 @@ var (
 	rateLimitMutex sync.Mutex
 )
 
+// Origins allowed by CORSMiddleware, overridden at startup by the
+// comma-separated CORS_ALLOWED_ORIGINS environment variable
+var allowedOrigins = []string{"http://localhost:3000", "https://myblog.com"}
+
 // ----------------------------------------------------------------
 // Main
 // ----------------------------------------------------------------
 
 func main() {
+	if origins := os.Getenv("CORS_ALLOWED_ORIGINS"); strings.TrimSpace(origins) != "" {
+		allowedOrigins = nil
+		for _, origin := range strings.Split(origins, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				allowedOrigins = append(allowedOrigins, origin)
+			}
+		}
+	}
+
 	r := gin.New()
 
 	r.Use(
@@ -135,12 +149,17 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
-// CORSMiddleware handles cross-origin requests
+// CORSMiddleware handles cross-origin requests, echoing the Origin only
+// when it is in allowedOrigins
 func CORSMiddleware() gin.HandlerFunc {
+	origins := slices.Clone(allowedOrigins)
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "http://localhost:3000")
-		c.Header("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type,X-API-Key,X-Request-ID")
+		c.Writer.Header().Add("Vary", "Origin")
+		if origin := c.GetHeader("Origin"); origin != "" && slices.Contains(origins, origin) {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
+			c.Header("Access-Control-Allow-Headers", "Content-Type,X-API-Key,X-Request-ID")
+		}
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
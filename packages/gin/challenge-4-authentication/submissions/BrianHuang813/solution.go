@@ -2,15 +2,27 @@ package main
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"errors" 
 	"fmt"
+	"io"
+	"log"
+	"math/big"
 	"net/http"
+	"os"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 	"strconv"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 	"github.com/golang-jwt/jwt/v5" 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -36,8 +48,18 @@ type User struct {
 
 // LoginRequest represents login credentials
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required,min=8"`
+	Username        string `json:"username" binding:"required"`
+	Password        string `json:"password" binding:"required,min=8"`
+	ChallengeID     string `json:"challenge_id,omitempty"`
+	ChallengeAnswer string `json:"challenge_answer,omitempty"`
+}
+
+// LoginChallenge represents an arithmetic challenge issued after repeated login failures
+type LoginChallenge struct {
+	ID        string    `json:"challenge_id"`
+	Question  string    `json:"question"`
+	ExpiresAt time.Time `json:"expires_at"`
+	answer    int
 }
 
 // RegisterRequest represents registration data
@@ -69,18 +91,59 @@ type JWTClaims struct {
 
 // APIResponse represents standard API response
 type APIResponse struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Message string      `json:"message,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success    bool        `json:"success"`
+	Data       interface{} `json:"data,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	APIVersion string      `json:"api_version"`
 }
 
+// MarshalJSON fills in the current API version when a response doesn't set one
+func (r APIResponse) MarshalJSON() ([]byte, error) {
+	type plainResponse APIResponse
+	if r.APIVersion == "" {
+		r.APIVersion = apiVersion
+	}
+	return json.Marshal(plainResponse(r))
+}
+
+// apiVersion is the response envelope version, set at build time with
+// -ldflags "-X main.apiVersion=v1"
+var apiVersion = "v1"
+
+// Vendor media type used to request a specific API version
+const apiMediaTypePrefix = "application/vnd.blog."
+
 // Global data stores (in a real app, these would be databases)
 var users = []User{}
 var blacklistedTokens = make(map[string]bool) // Token blacklist for logout
 var refreshTokens = make(map[string]int)      // RefreshToken -> UserID mapping
+var refreshTokenExpiry = make(map[string]time.Time) // RefreshToken -> expiry time
+var refreshTokenFingerprints = make(map[string]string) // RefreshToken -> client fingerprint, if bound
+var refreshMutex sync.Mutex
 var nextUserID = 1
 
+// Indexes from each lookup key to the user's position in users, so lookups
+// don't scan the slice. users stays the source of truth for ordered listing.
+var (
+	usersMutex    sync.Mutex // guards the indexes, nextUserID and changes made through them
+	idIndex       = make(map[int]int)
+	usernameIndex = make(map[string]int)
+	emailIndex    = make(map[string]int)
+	indexedUsers  []User // the users slice the indexes were built for
+)
+
+// userCache holds copies of users read by the read-only request paths
+// (profile and token refresh), keyed by ID and layered on idIndex. It is
+// cleared whenever the indexes are rebuilt and entries are dropped by
+// invalidateUser when a stored user changes.
+var userCache = NewTTLCache[int, User]()
+var userCacheTTL = 5 * time.Minute
+
+// roleMutex serializes role changes so the last-admin check and the update
+// can't interleave with another demotion
+var roleMutex sync.Mutex
+
 // Configuration
 var (
 	jwtSecret         = []byte("your-super-secret-jwt-key")
@@ -88,8 +151,21 @@ var (
 	refreshTokenTTL   = 7 * 24 * time.Hour // 7 days
 	maxFailedAttempts = 5
 	lockoutDuration   = 30 * time.Minute
+	// bcrypt cost for new hashes; older hashes are upgraded on login
+	bcryptCost = 12
+	// Failed attempts after which a login challenge must be solved
+	challengeThreshold = 3
+	challengeTTL       = 5 * time.Minute
+	// Requests per minute, and the burst allowed above that, for each user
+	userRateLimit = 60
+	userRateBurst = 60
+	// Bind new refresh tokens to the client that received them, see clientFingerprint
+	bindRefreshTokens = false
 )
 
+var loginChallenges = make(map[string]*LoginChallenge) // ChallengeID -> challenge
+var challengesMutex sync.Mutex
+
 // User roles
 const (
 	RoleUser      = "user"
@@ -97,41 +173,96 @@ const (
 	RoleModerator = "moderator"
 )
 
-// TODO: Implement password strength validation
+// roleHierarchy maps each role to the roles it directly includes. A role
+// satisfies a requirement for any role reachable from it, so admins pass
+// moderator routes and moderators pass user routes.
+var roleHierarchy = map[string][]string{
+	RoleAdmin:     {RoleModerator},
+	RoleModerator: {RoleUser},
+}
+
+// PasswordPolicy describes the rules a password must meet
+type PasswordPolicy struct {
+	MinLength       int      // Minimum length in characters
+	MaxLength       int      // Maximum length in characters, 0 means no maximum
+	RequireUpper    bool     // At least one uppercase letter A-Z
+	RequireLower    bool     // At least one lowercase letter a-z
+	RequireDigit    bool     // At least one digit 0-9
+	RequireSpecial  bool     // At least one character that is not a letter or digit
+	BannedPasswords []string // Rejected regardless of the other rules, compared case-insensitively
+}
+
+// passwordPolicy is the policy used by register and change-password
+var passwordPolicy = PasswordPolicy{
+	MinLength:      8,
+	MaxLength:      72, // bcrypt ignores anything past 72 bytes
+	RequireUpper:   true,
+	RequireLower:   true,
+	RequireDigit:   true,
+	RequireSpecial: true,
+	BannedPasswords: []string{
+		"P@ssw0rd", "P@ssword1", "Passw0rd!!", "Qwerty123!", "Welcome1!",
+		"Letmein1!", "Admin123!", "Iloveyou1!", "Abcd1234!", "Changeme1!",
+	},
+}
+
+// Validate returns a description of every rule the password fails, or nil if it meets the policy
+func (p PasswordPolicy) Validate(password string) []string {
+	var unmet []string
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		unmet = append(unmet, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		unmet = append(unmet, fmt.Sprintf("must be at most %d characters", p.MaxLength))
+	}
+
+	hasUpper, hasLower, hasDigit, hasSpecial := false, false, false, false
+	for _, char := range password {
+		switch {
+		case 'A' <= char && char <= 'Z':
+			hasUpper = true
+		case 'a' <= char && char <= 'z':
+			hasLower = true
+		case '0' <= char && char <= '9':
+			hasDigit = true
+		default:
+			hasSpecial = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		unmet = append(unmet, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		unmet = append(unmet, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		unmet = append(unmet, "must contain a digit")
+	}
+	if p.RequireSpecial && !hasSpecial {
+		unmet = append(unmet, "must contain a special character")
+	}
+
+	for _, banned := range p.BannedPasswords {
+		if strings.EqualFold(password, banned) {
+			unmet = append(unmet, "is too common")
+			break
+		}
+	}
+
+	return unmet
+}
+
+// isStrongPassword reports whether the password meets passwordPolicy
 func isStrongPassword(password string) bool {
-	// TODO: Validate password strength:
-	// - At least 8 characters
-	// - Contains uppercase letter
-	// - Contains lowercase letter
-	// - Contains number
-	// - Contains special character
-	if len(password) < 8 {
-        return false
-    }
-    
-    hasUpper := false
-    hasLower := false
-    hasDigit := false
-    hasSpecial := false
-    for _, char := range password {
-        switch {
-        case 'A' <= char && char <= 'Z':
-            hasUpper = true
-        case 'a' <= char && char <= 'z':
-            hasLower = true
-        case '0' <= char && char <= '9':
-            hasDigit = true
-        default:
-            hasSpecial = true
-        }
-    }
-    return hasUpper && hasLower && hasDigit && hasSpecial
+	return len(passwordPolicy.Validate(password)) == 0
 }
 
 // TODO: Implement password hashing
 func hashPassword(password string) (string, error) {
 	// TODO: Use bcrypt to hash the password with cost 12
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
     if err != nil {
         return "", err
     }
@@ -145,6 +276,24 @@ func verifyPassword(password, hash string) bool {
 	return err == nil
 }
 
+// upgradePasswordHash re-hashes the password at bcryptCost if the stored hash
+// was made with a lower cost. It is called after a successful login, the only
+// time the plaintext password is available. A failed re-hash keeps the old
+// hash, which still verifies.
+func upgradePasswordHash(user *User, password string) {
+	cost, err := bcrypt.Cost([]byte(user.PasswordHash))
+	if err != nil || cost >= bcryptCost {
+		return
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return
+	}
+	user.PasswordHash = hash
+	user.UpdatedAt = time.Now()
+	invalidateUser(user.ID)
+}
+
 // TODO: Implement JWT token generation
 func generateTokens(userID int, username, role string) (*TokenResponse, error) {
 	// TODO: Generate access token with 15 minute expiry
@@ -173,7 +322,7 @@ func generateTokens(userID int, username, role string) (*TokenResponse, error) {
         return nil, err
     }
     // Store refresh token
-    refreshTokens[refreshToken] = userID
+    storeRefreshToken(refreshToken, userID)
     return &TokenResponse{
         AccessToken:  accessTokenString,
         RefreshToken: refreshToken,
@@ -209,39 +358,259 @@ func validateToken(tokenString string) (*JWTClaims, error) {
 func findUserByUsername(username string) *User {
 	// TODO: Find user by username in users slice
 	if username == "" {
-	    return nil
-	}
-	
-	for i, user := range users {
-	    if user.Username == username {
-	        return &users[i]
-	    }
+		return nil
 	}
-	return nil
+	return lookupUser(usernameIndex, username, func(u *User) bool { return u.Username == username })
 }
 
 func findUserByEmail(email string) *User {
 	// TODO: Find user by email in users slice
 	if email == "" {
-	    return nil
-	}
-	
-	for i, user := range users {
-	    if user.Email == email {
-	        return &users[i]
-	    }
+		return nil
 	}
-	return nil
+	return lookupUser(emailIndex, email, func(u *User) bool { return u.Email == email })
 }
 
 func findUserByID(id int) *User {
 	// TODO: Find user by ID in users slice
-	for i, user := range users {
-	    if user.ID == id {
-	        return &users[i]
-	    }
+	return lookupUser(idIndex, id, func(u *User) bool { return u.ID == id })
+}
+
+// cachedUserByID returns a copy of the user with the given ID, served from
+// userCache when possible. Changes made to the copy are not stored, so
+// handlers that modify a user must use findUserByID.
+func cachedUserByID(id int) (User, bool) {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	// A replaced store rebuilds the indexes, which also clears the cache
+	if userIndexesStale() {
+		rebuildUserIndexes()
+	}
+	if user, ok := userCache.Get(id); ok {
+		return user, true
+	}
+	i, ok := idIndex[id]
+	if !ok || users[i].ID != id {
+		return User{}, false
+	}
+	// Filled under usersMutex, so a concurrent rebuild can't be undone by a stale copy
+	user := users[i]
+	userCache.Set(id, user, userCacheTTL)
+	return user, true
+}
+
+// invalidateUser drops the cached copy of a user after it changes
+func invalidateUser(id int) {
+	userCache.Delete(id)
+}
+
+// lookupUser finds a user through one of the index maps. The indexes are
+// rebuilt first if users was replaced or appended to without going through
+// addUser, and again if the indexed position no longer holds the user.
+func lookupUser[K comparable](index map[K]int, key K, match func(*User) bool) *User {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	if userIndexesStale() {
+		rebuildUserIndexes()
+	}
+	i, ok := index[key]
+	if ok && !match(&users[i]) {
+		rebuildUserIndexes()
+		i, ok = index[key]
+	}
+	if !ok {
+		return nil
+	}
+	return &users[i]
+}
+
+// userIndexesStale reports whether users is no longer the slice the indexes
+// were built for. Caller must hold usersMutex.
+func userIndexesStale() bool {
+	if len(users) != len(indexedUsers) {
+		return true
+	}
+	return len(users) > 0 && &users[0] != &indexedUsers[0]
+}
+
+// rebuildUserIndexes indexes every user by position. Caller must hold usersMutex.
+func rebuildUserIndexes() {
+	clear(idIndex)
+	clear(usernameIndex)
+	clear(emailIndex)
+	userCache.Clear()
+	for i := range users {
+		indexUser(i)
+	}
+	indexedUsers = users
+}
+
+// indexUser adds the user at position i to the indexes, keeping the first
+// position if a key is somehow duplicated. Caller must hold usersMutex.
+func indexUser(i int) {
+	user := &users[i]
+	if _, exists := idIndex[user.ID]; !exists {
+		idIndex[user.ID] = i
+	}
+	if _, exists := usernameIndex[user.Username]; !exists {
+		usernameIndex[user.Username] = i
+	}
+	if _, exists := emailIndex[user.Email]; !exists {
+		emailIndex[user.Email] = i
+	}
+}
+
+// addUser assigns the next user ID, appends the user to the store and its
+// indexes, and returns the stored user. Taking the ID under usersMutex keeps
+// concurrent registrations from sharing one.
+func addUser(user User) *User {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	if userIndexesStale() {
+		rebuildUserIndexes()
+	}
+	user.ID = nextUserID
+	nextUserID++
+	users = append(users, user)
+	indexedUsers = users
+	indexUser(len(users) - 1)
+	return &users[len(users)-1]
+}
+
+// setUserEmail changes a user's email and moves its email index entry
+func setUserEmail(user *User, email string) {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	if userIndexesStale() {
+		rebuildUserIndexes()
+	}
+	if i, ok := emailIndex[user.Email]; ok && &users[i] == user {
+		delete(emailIndex, user.Email)
+		emailIndex[email] = i
+	}
+	user.Email = email
+	invalidateUser(user.ID)
+}
+
+// removeUser deletes the user with the given ID, returning false if there is none.
+// Later users move down one position, so the indexes are rebuilt.
+func removeUser(id int) bool {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	if userIndexesStale() {
+		rebuildUserIndexes()
+	}
+	i, ok := idIndex[id]
+	if !ok {
+		return false
+	}
+	remaining := make([]User, 0, len(users)-1)
+	remaining = append(remaining, users[:i]...)
+	users = append(remaining, users[i+1:]...)
+	rebuildUserIndexes()
+	return true
+}
+
+// ttlEntry is a cached value and the time it expires
+type ttlEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// TTLCache is a mutex-guarded map whose entries expire after a time to live.
+// Expired entries are removed when Get finds them or by StartSweeper.
+type TTLCache[K comparable, V any] struct {
+	mu    sync.Mutex
+	items map[K]ttlEntry[V]
+}
+
+// NewTTLCache creates an empty TTL cache
+func NewTTLCache[K comparable, V any]() *TTLCache[K, V] {
+	return &TTLCache[K, V]{items: make(map[K]ttlEntry[V])}
+}
+
+// Set stores value under key until ttl has passed
+func (c *TTLCache[K, V]) Set(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = ttlEntry[V]{value: value, expiresAt: time.Now().Add(ttl)}
+}
+
+// Get returns the value stored under key, or false if there is none or it has expired
+func (c *TTLCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.items[key]
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.value, true
+	}
+	delete(c.items, key)
+	var zero V
+	return zero, false
+}
+
+// Delete removes the entry stored under key
+func (c *TTLCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.items, key)
+}
+
+// Clear removes every entry
+func (c *TTLCache[K, V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.items)
+}
+
+// Sweep removes every expired entry
+func (c *TTLCache[K, V]) Sweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for key, entry := range c.items {
+		if !now.Before(entry.expiresAt) {
+			delete(c.items, key)
+		}
+	}
+}
+
+// StartSweeper calls Sweep every interval until the returned stop function is called
+func (c *TTLCache[K, V]) StartSweeper(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				c.Sweep()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// countActiveAdmins returns how many active users have the admin role
+func countActiveAdmins() int {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	count := 0
+	for _, user := range users {
+		if user.Role == RoleAdmin && user.IsActive {
+			count++
+		}
 	}
-	return nil
+	return count
 }
 
 // TODO: Implement account lockout check
@@ -264,13 +633,14 @@ func recordFailedAttempt(user *User) {
 	    user.LockedUntil = &unlockTime
 	    user.FailedAttempts = 0 // reset the failed attempts after penalty
 	}
-	
+	invalidateUser(user.ID)
 }
 
 func resetFailedAttempts(user *User) {
 	// TODO: Reset failed attempts counter and unlock account
 	user.FailedAttempts = 0
 	user.LockedUntil = nil
+	invalidateUser(user.ID)
 }
 
 // TODO: Generate secure random token
@@ -284,15 +654,301 @@ func generateRandomToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// storeRefreshToken records a refresh token that expires after refreshTokenTTL
+func storeRefreshToken(token string, userID int) {
+	refreshMutex.Lock()
+	defer refreshMutex.Unlock()
+	refreshTokens[token] = userID
+	refreshTokenExpiry[token] = time.Now().Add(refreshTokenTTL)
+}
+
+// Header a client sends to identify the device it runs on
+const deviceIDHeader = "X-Device-ID"
+
+// clientFingerprint hashes the request's User-Agent and device ID, so a
+// refresh token bound to one client is refused when replayed from another
+func clientFingerprint(c *gin.Context) string {
+	sum := sha256.Sum256([]byte(c.Request.UserAgent() + "\x00" + c.GetHeader(deviceIDHeader)))
+	return hex.EncodeToString(sum[:])
+}
+
+// bindRefreshToken records the fingerprint of the client a refresh token was issued to
+func bindRefreshToken(token, fingerprint string) {
+	refreshMutex.Lock()
+	defer refreshMutex.Unlock()
+	refreshTokenFingerprints[token] = fingerprint
+}
+
+// issueTokens generates tokens for the user, binding the refresh token to
+// the requesting client when bindRefreshTokens is set
+func issueTokens(c *gin.Context, user *User) (*TokenResponse, error) {
+	tokens, err := generateTokens(user.ID, user.Username, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	if bindRefreshTokens {
+		bindRefreshToken(tokens.RefreshToken, clientFingerprint(c))
+	}
+	return tokens, nil
+}
+
+// Errors returned when a refresh token cannot be exchanged
+var (
+	errInvalidRefreshToken     = errors.New("Invalid or expired refresh token")
+	errRefreshTokenOtherClient = errors.New("Refresh token was issued to a different client")
+)
+
+// lookupRefreshToken returns the user ID for a valid refresh token.
+// Expired tokens are removed and reported as invalid.
+func lookupRefreshToken(token string) (int, bool) {
+	refreshMutex.Lock()
+	defer refreshMutex.Unlock()
+	return lookupRefreshTokenLocked(token)
+}
+
+// lookupRefreshTokenLocked is lookupRefreshToken for callers holding refreshMutex
+func lookupRefreshTokenLocked(token string) (int, bool) {
+	userID, exists := refreshTokens[token]
+	if !exists {
+		return 0, false
+	}
+	if expiresAt, ok := refreshTokenExpiry[token]; !ok || time.Now().After(expiresAt) {
+		deleteRefreshTokenLocked(token)
+		return 0, false
+	}
+	return userID, true
+}
+
+// consumeRefreshToken checks a refresh token for the client with the given
+// fingerprint and removes it under the same lock, so two concurrent requests
+// can never both exchange it. Tokens issued without binding can be used by
+// any client; a token presented by another client is left in place.
+func consumeRefreshToken(token, fingerprint string) (int, error) {
+	refreshMutex.Lock()
+	defer refreshMutex.Unlock()
+	userID, ok := lookupRefreshTokenLocked(token)
+	if !ok {
+		return 0, errInvalidRefreshToken
+	}
+	// A token bound to another client was most likely stolen
+	if bound, ok := refreshTokenFingerprints[token]; ok && bound != fingerprint {
+		return 0, errRefreshTokenOtherClient
+	}
+	deleteRefreshTokenLocked(token)
+	return userID, nil
+}
+
+// revokeRefreshToken removes a refresh token from the store
+func revokeRefreshToken(token string) {
+	refreshMutex.Lock()
+	defer refreshMutex.Unlock()
+	deleteRefreshTokenLocked(token)
+}
+
+// deleteRefreshTokenLocked removes a refresh token; the caller holds refreshMutex
+func deleteRefreshTokenLocked(token string) {
+	delete(refreshTokens, token)
+	delete(refreshTokenExpiry, token)
+	delete(refreshTokenFingerprints, token)
+}
+
+// sweepExpiredRefreshTokens removes every expired refresh token
+func sweepExpiredRefreshTokens() {
+	refreshMutex.Lock()
+	defer refreshMutex.Unlock()
+	now := time.Now()
+	for token, expiresAt := range refreshTokenExpiry {
+		if now.After(expiresAt) {
+			delete(refreshTokens, token)
+			delete(refreshTokenExpiry, token)
+			delete(refreshTokenFingerprints, token)
+		}
+	}
+}
+
+// startRefreshTokenSweeper periodically removes expired refresh tokens
+// until the returned stop function is called
+func startRefreshTokenSweeper(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				sweepExpiredRefreshTokens()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// randomInt returns a cryptographically random int in [0, n)
+func randomInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+// newLoginChallenge creates and stores a simple arithmetic challenge
+func newLoginChallenge() (*LoginChallenge, error) {
+	id, err := generateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+	a, err := randomInt(20)
+	if err != nil {
+		return nil, err
+	}
+	b, err := randomInt(20)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := &LoginChallenge{
+		ID:        id,
+		Question:  fmt.Sprintf("What is %d + %d?", a+1, b+1),
+		ExpiresAt: time.Now().Add(challengeTTL),
+		answer:    a + b + 2,
+	}
+
+	challengesMutex.Lock()
+	defer challengesMutex.Unlock()
+	for key, existing := range loginChallenges {
+		if time.Now().After(existing.ExpiresAt) {
+			delete(loginChallenges, key)
+		}
+	}
+	loginChallenges[id] = challenge
+	return challenge, nil
+}
+
+// solveLoginChallenge checks an answer and consumes the challenge, so each
+// challenge can only be used for a single login attempt
+func solveLoginChallenge(id, answer string) bool {
+	challengesMutex.Lock()
+	challenge, exists := loginChallenges[id]
+	delete(loginChallenges, id)
+	challengesMutex.Unlock()
+
+	if !exists || time.Now().After(challenge.ExpiresAt) {
+		return false
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(answer))
+	return err == nil && value == challenge.answer
+}
+
+// GET /auth/challenge - Issue a login challenge
+func getLoginChallenge(c *gin.Context) {
+	challenge, err := newLoginChallenge()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, APIResponse{
+			Success: false,
+			Error:   "Failed to create challenge",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    challenge,
+		Message: "Challenge created",
+	})
+}
+
+// bindJSON binds the request body into obj. On failure it responds 400 and
+// returns false: a body that isn't valid JSON, or has a value of the wrong
+// type, gets "Malformed request body", while a well-formed body that fails
+// the binding rules gets "Validation failed" with a message per field.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "Validation failed",
+			Data:    gin.H{"fields": validationFieldErrors(obj, validationErrs)},
+		})
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, APIResponse{
+		Success: false,
+		Error:   "Malformed request body",
+		Data:    gin.H{"detail": malformedBodyDetail(err)},
+	})
+	return false
+}
+
+// malformedBodyDetail describes why a request body couldn't be decoded
+func malformedBodyDetail(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("invalid JSON at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("field %q must be a %s", typeErr.Field, typeErr.Type)
+	case errors.Is(err, io.EOF):
+		return "request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "request body is truncated"
+	default:
+		return err.Error()
+	}
+}
+
+// validationFieldErrors maps the JSON name of each failing field to a
+// readable message for the rule it broke
+func validationFieldErrors(obj interface{}, errs validator.ValidationErrors) map[string]string {
+	t := reflect.TypeOf(obj)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	fields := make(map[string]string, len(errs))
+	for _, fe := range errs {
+		name := fe.Field()
+		if f, ok := t.FieldByName(fe.StructField()); ok {
+			if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+				name = tag
+			}
+		}
+		fields[name] = validationMessage(fe)
+	}
+	return fields
+}
+
+// validationMessage turns a failed binding rule into a message
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s characters", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters", fe.Param())
+	default:
+		return fmt.Sprintf("failed the %q rule", fe.Tag())
+	}
+}
+
 // POST /auth/register - User registration
 func register(c *gin.Context) {
 	var req RegisterRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, APIResponse{
-			Success: false,
-			Error:   "Invalid input data",
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -306,9 +962,10 @@ func register(c *gin.Context) {
 	}
 
 	// TODO: Validate password strength
-	if !isStrongPassword(req.Password) {
+	if unmet := passwordPolicy.Validate(req.Password); len(unmet) > 0 {
 		c.JSON(400, APIResponse{
 			Success: false,
+			Data:    gin.H{"unmet_rules": unmet},
 			Error:   "Password does not meet strength requirements",
 		})
 		return
@@ -324,7 +981,7 @@ func register(c *gin.Context) {
 	    return
 	}
 	// TODO: Hash password
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	passwordHash, err := hashPassword(req.Password)
 	if err != nil {
 		// This is a server error, not the user's fault.
 		c.JSON(http.StatusInternalServerError, APIResponse{
@@ -335,10 +992,9 @@ func register(c *gin.Context) {
 	}
 	// TODO: Create user and add to users slice
 	newUser := User {
-	    ID:            nextUserID,
 		Username:      req.Username,
 		Email:         req.Email,
-		PasswordHash:  string(passwordHash), 
+		PasswordHash:  passwordHash,
 		FirstName:     req.FirstName,
 		LastName:      req.LastName,
 		Role:          "user",  
@@ -347,8 +1003,7 @@ func register(c *gin.Context) {
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
-	users = append(users, newUser)
-	nextUserID++
+	newUser.ID = addUser(newUser).ID
 
 	c.JSON(201, APIResponse{
 		Success: true,
@@ -361,11 +1016,7 @@ func register(c *gin.Context) {
 func login(c *gin.Context) {
 	var req LoginRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, APIResponse{
-			Success: false,
-			Error:   "Invalid credentials format",
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -388,6 +1039,24 @@ func login(c *gin.Context) {
 		return
 	}
 
+	// Require a solved challenge once too many attempts have failed
+	if user.FailedAttempts >= challengeThreshold && !solveLoginChallenge(req.ChallengeID, req.ChallengeAnswer) {
+		challenge, err := newLoginChallenge()
+		if err != nil {
+			c.JSON(500, APIResponse{
+				Success: false,
+				Error:   "Failed to create challenge",
+			})
+			return
+		}
+		c.JSON(http.StatusPreconditionRequired, APIResponse{
+			Success: false,
+			Data:    challenge,
+			Error:   "Challenge answer required",
+		})
+		return
+	}
+
 	// TODO: Verify password
 	if !verifyPassword(req.Password, user.PasswordHash) {
 		recordFailedAttempt(user)
@@ -400,13 +1069,15 @@ func login(c *gin.Context) {
 
 	// TODO: Reset failed attempts on successful login
 	resetFailedAttempts(user)
+	upgradePasswordHash(user, req.Password)
 
 	// TODO: Update last login time
 	now := time.Now()
 	user.LastLogin = &now
+	invalidateUser(user.ID)
 
 	// TODO: Generate tokens
-	tokens, err := generateTokens(user.ID, user.Username, user.Role)
+	tokens, err := issueTokens(c, user)
 	if err != nil {
 		c.JSON(500, APIResponse{
 			Success: false,
@@ -445,7 +1116,7 @@ func logout(c *gin.Context) {
 	c.ShouldBindJSON(&req)
 	
 	if req.RefreshToken != "" {
-	    delete(refreshTokens, req.RefreshToken)
+	    revokeRefreshToken(req.RefreshToken)
 	}
 
 	c.JSON(200, APIResponse{
@@ -460,27 +1131,24 @@ func refreshToken(c *gin.Context) {
 		RefreshToken string `json:"refresh_token" binding:"required"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, APIResponse{
-			Success: false,
-			Error:   "Refresh token required",
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	// TODO: Validate refresh token
-	userID, exist := refreshTokens[req.RefreshToken]
-	if !exist {
+	// The token is single use: it is revoked here, before new tokens are issued
+	userID, err := consumeRefreshToken(req.RefreshToken, clientFingerprint(c))
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, APIResponse{
 			Success: false,
-			Error:   "Invalid or expired refresh token",
+			Error:   err.Error(),
 		})
 		return
 	}
 	// TODO: Get user ID from refresh token store
 	// TODO: Find user by ID
-	user := findUserByID(userID)
-	if user == nil || !user.IsActive {
+	user, found := cachedUserByID(userID)
+	if !found || !user.IsActive {
 		c.JSON(http.StatusUnauthorized, APIResponse{
 			Success: false,
 			Error:   "User not found or is not active",
@@ -488,9 +1156,7 @@ func refreshToken(c *gin.Context) {
 		return
 	}
 	// TODO: Generate new access token
-	delete(refreshTokens, req.RefreshToken)
-
-    newTokens, err := generateTokens(user.ID, user.Username, user.Role)
+    newTokens, err := issueTokens(c, &user)
     if err != nil {
         c.JSON(http.StatusInternalServerError, APIResponse{Success: false, Error: "Failed to generate new tokens"})
         return
@@ -508,11 +1174,10 @@ func authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(401, APIResponse{
+			c.AbortWithStatusJSON(http.StatusUnauthorized, APIResponse{
 				Success: false,
 				Error:   "Authorization header required",
 			})
-			c.Abort()
 			return
 		}
 
@@ -522,11 +1187,11 @@ func authMiddleware() gin.HandlerFunc {
 		claims, err := validateToken(tokenString)
 		
 		if err != nil {
-		    c.JSON(http.StatusUnauthorized, APIResponse{
-		        Success: false,
-		        Error: "invalid token",
-		    })
-		    return
+			c.AbortWithStatusJSON(http.StatusUnauthorized, APIResponse{
+				Success: false,
+				Error:   "invalid token",
+			})
+			return
 		}
 		// TODO: Set user info in context for route handlers
 		c.Set("claims", claims)
@@ -541,54 +1206,328 @@ func authMiddleware() gin.HandlerFunc {
 	}
 }
 
-// Middleware: Role-based authorization
+// negotiateAPIVersion picks the API version requested by an Accept header.
+// Any media range other than a vendor type, such as application/json or */*,
+// accepts the current version. ok is false only when every range asks for an
+// unsupported vendor version.
+func negotiateAPIVersion(accept string) (version string, ok bool) {
+	onlyUnsupported := false
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(mediaRange, ";", 2)[0])
+		if mediaType == "" {
+			continue
+		}
+		if !strings.HasPrefix(mediaType, apiMediaTypePrefix) || !strings.HasSuffix(mediaType, "+json") {
+			return apiVersion, true
+		}
+		v := strings.TrimSuffix(strings.TrimPrefix(mediaType, apiMediaTypePrefix), "+json")
+		if v == apiVersion {
+			return v, true
+		}
+		onlyUnsupported = true
+	}
+	return apiVersion, !onlyUnsupported
+}
+
+// Middleware: API version negotiation via Accept: application/vnd.blog.v1+json
+func apiVersionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version, ok := negotiateAPIVersion(c.GetHeader("Accept"))
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, APIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Unsupported API version, supported: %s%s+json", apiMediaTypePrefix, apiVersion),
+			})
+			return
+		}
+		c.Set("api_version", version)
+		c.Header("X-API-Version", version)
+		c.Next()
+	}
+}
+
+// CORSConfig configures cross-origin access. AllowOrigins entries are exact
+// origins, "*" for any origin, or a pattern with one wildcard such as
+// "https://*.example.com". With AllowCredentials the request origin is echoed
+// instead of "*", since browsers reject a wildcard on credentialed requests.
+type CORSConfig struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string // response headers readable by browser scripts
+	AllowCredentials bool
+	MaxAge           time.Duration // how long browsers may cache a preflight
+}
+
+// Bearer tokens are sent in a header rather than cookies, so no credentials
+var corsConfig = CORSConfig{
+	AllowOrigins:  []string{"http://localhost:3000"},
+	AllowMethods:  []string{"GET", "POST", "PUT", "OPTIONS"},
+	AllowHeaders:  []string{"Authorization", "Content-Type", "Accept"},
+	ExposeHeaders: []string{"X-API-Version", "Retry-After"},
+	MaxAge:        time.Hour,
+}
+
+// matchOrigin reports whether origin matches an exact, "*" or wildcard pattern
+func matchOrigin(pattern, origin string) bool {
+	if pattern == "*" || pattern == origin {
+		return true
+	}
+	prefix, suffix, found := strings.Cut(pattern, "*")
+	return found &&
+		len(origin) > len(prefix)+len(suffix) &&
+		strings.HasPrefix(origin, prefix) &&
+		strings.HasSuffix(origin, suffix)
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for origin,
+// or ok false if it isn't allowed
+func (cfg CORSConfig) allowedOrigin(origin string) (value string, ok bool) {
+	if origin == "" {
+		return "", false
+	}
+	for _, pattern := range cfg.AllowOrigins {
+		if !matchOrigin(pattern, origin) {
+			continue
+		}
+		if pattern == "*" && !cfg.AllowCredentials {
+			return "*", true
+		}
+		return origin, true
+	}
+	return "", false
+}
+
+// Middleware: CORS. OPTIONS preflights are answered here without reaching
+// the routes; a disallowed origin gets no CORS headers, so the browser
+// blocks the real request.
+func CORSMiddleware(cfg CORSConfig) gin.HandlerFunc {
+	methods := strings.Join(cfg.AllowMethods, ", ")
+	headers := strings.Join(cfg.AllowHeaders, ", ")
+	exposed := strings.Join(cfg.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge / time.Second))
+
+	return func(c *gin.Context) {
+		preflight := c.Request.Method == http.MethodOptions
+
+		if value, ok := cfg.allowedOrigin(c.GetHeader("Origin")); ok {
+			c.Header("Access-Control-Allow-Origin", value)
+			if value != "*" {
+				c.Writer.Header().Add("Vary", "Origin")
+			}
+			if cfg.AllowCredentials {
+				c.Header("Access-Control-Allow-Credentials", "true")
+			}
+			if preflight {
+				c.Header("Access-Control-Allow-Methods", methods)
+				c.Header("Access-Control-Allow-Headers", headers)
+				if cfg.MaxAge > 0 {
+					c.Header("Access-Control-Max-Age", maxAge)
+				}
+			} else if exposed != "" {
+				c.Header("Access-Control-Expose-Headers", exposed)
+			}
+		}
+
+		if preflight {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// UserRateLimiter is a token bucket per authenticated user ID, so one
+// account shares a single budget however many IPs it sends from
+type UserRateLimiter struct {
+	perMinute int
+	burst     int
+
+	mu          sync.Mutex
+	buckets     map[int]*userBucket
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+type userBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewUserRateLimiter(perMinute, burst int) *UserRateLimiter {
+	return &UserRateLimiter{
+		perMinute: perMinute,
+		burst:     burst,
+		buckets:   make(map[int]*userBucket),
+		now:       time.Now,
+	}
+}
+
+// refillTime is how long an empty bucket takes to fill back up to burst
+func (l *UserRateLimiter) refillTime() time.Duration {
+	return time.Duration(float64(l.burst) / float64(l.perMinute) * float64(time.Minute))
+}
+
+// Allow takes a token from the user's bucket. When none is left it returns
+// false and how long until the next one is available.
+func (l *UserRateLimiter) Allow(userID int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.cleanup(now)
+
+	bucket, exists := l.buckets[userID]
+	if !exists {
+		bucket = &userBucket{tokens: float64(l.burst), last: now}
+		l.buckets[userID] = bucket
+	}
+	perSecond := float64(l.perMinute) / 60
+	bucket.tokens += now.Sub(bucket.last).Seconds() * perSecond
+	if bucket.tokens > float64(l.burst) {
+		bucket.tokens = float64(l.burst)
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / perSecond * float64(time.Second))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// cleanup drops buckets that have been idle long enough to refill, since a
+// fresh bucket behaves the same. It runs at most once per refill period.
+// Caller must hold l.mu.
+func (l *UserRateLimiter) cleanup(now time.Time) {
+	idle := l.refillTime()
+	if now.Sub(l.lastCleanup) < idle {
+		return
+	}
+	for id, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= idle {
+			delete(l.buckets, id)
+		}
+	}
+	l.lastCleanup = now
+}
+
+// Len returns the number of users currently being tracked
+func (l *UserRateLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// Middleware: per-user rate limiting, used after authMiddleware
+func userRateLimitMiddleware(limiter *UserRateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// authMiddleware runs first, so a missing user means the request was
+		// never authenticated and must not reach the handler unmetered
+		userCtx, exists := c.Get("user")
+		currentUser, ok := userCtx.(*User)
+		if !exists || !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, APIResponse{
+				Success: false,
+				Error:   "Authentication required",
+			})
+			return
+		}
+
+		allowed, retryAfter := limiter.Allow(currentUser.ID)
+		if !allowed {
+			// Round up so a client waiting Retry-After seconds finds a token
+			seconds := int((retryAfter + time.Second - 1) / time.Second)
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, APIResponse{
+				Success: false,
+				Error:   "Rate limit exceeded, try again later",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// roleSatisfies reports whether userRole is required or includes it through
+// roleHierarchy. The walk tracks visited roles, so a cycle in the config
+// can't loop forever.
+func roleSatisfies(userRole, required string) bool {
+	visited := map[string]bool{userRole: true}
+	queue := []string{userRole}
+	for len(queue) > 0 {
+		role := queue[0]
+		queue = queue[1:]
+		if role == required {
+			return true
+		}
+		for _, included := range roleHierarchy[role] {
+			if !visited[included] {
+				visited[included] = true
+				queue = append(queue, included)
+			}
+		}
+	}
+	return false
+}
+
+// Middleware: Role-based authorization. A user passes if their role
+// satisfies any of roles through the role hierarchy.
 func requireRole(roles ...string) gin.HandlerFunc {
+	return roleAuthorization(roles, roleSatisfies)
+}
+
+// Middleware: Role-based authorization without the hierarchy, for routes
+// that must only admit the listed roles themselves
+func requireExactRole(roles ...string) gin.HandlerFunc {
+	return roleAuthorization(roles, func(userRole, required string) bool {
+		return userRole == required
+	})
+}
+
+// roleAuthorization admits the request if allows(userRole, role) holds for
+// any of roles
+func roleAuthorization(roles []string, allows func(userRole, required string) bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// TODO: Get user role from context (set by authMiddleware)
 		userCtx, exists := c.Get("user")
-        if !exists {
-            c.AbortWithStatusJSON(http.StatusForbidden, APIResponse{
-                Success: false,
-                Error:   "User data not found in context. Access denied.",
-            })
-            return
-        }
-
-        currentUser, ok := userCtx.(*User)
-        if !ok {
-            c.AbortWithStatusJSON(http.StatusForbidden, APIResponse{
-                Success: false,
-                Error:   "Invalid user type in context.",
-            })
-            return
-        }
-        
-        
-        isAllowed := false
-        for _, allowedRole := range roles {
-            if currentUser.Role == allowedRole {
-                isAllowed = true
-                break
-            }
-        }
-        
-		if isAllowed {
-            c.Next()
-        } else {
-            c.AbortWithStatusJSON(http.StatusForbidden, APIResponse{
-                Success: false,
-                Error:   fmt.Sprintf("Access denied. Required roles: %v, your role: %s", roles, currentUser.Role),
-            })
-        }
-	}
-}
-
-// GET /user/profile - Get current user profile
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusForbidden, APIResponse{
+				Success: false,
+				Error:   "User data not found in context. Access denied.",
+			})
+			return
+		}
+
+		currentUser, ok := userCtx.(*User)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, APIResponse{
+				Success: false,
+				Error:   "Invalid user type in context.",
+			})
+			return
+		}
+
+		for _, required := range roles {
+			if allows(currentUser.Role, required) {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, APIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Access denied. Required roles: %v, your role: %s", roles, currentUser.Role),
+		})
+	}
+}
+
+// GET /user/profile, GET /user/me - Get the current user profile from the store
 func getUserProfile(c *gin.Context) {
 	// TODO: Get user ID from context (set by authMiddleware)
 	userCtx, _ := c.Get("user")
 
-	user, ok := userCtx.(*User)
+	ctxUser, ok := userCtx.(*User)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, APIResponse{
 			Success: false,
@@ -597,6 +1536,13 @@ func getUserProfile(c *gin.Context) {
 		return
 	}
 
+	// The token claims may be stale, so return the current stored record
+	user, found := cachedUserByID(ctxUser.ID)
+	if !found || !user.IsActive {
+		c.JSON(http.StatusUnauthorized, APIResponse{Success: false, Error: "User no longer exists or is inactive"})
+		return
+	}
+
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
 		Data:    user,
@@ -612,11 +1558,17 @@ func updateUserProfile(c *gin.Context) {
 		return
 	}
 	// Type assertion to get the *User object
-	currentUser, ok := userCtx.(*User)
+	ctxUser, ok := userCtx.(*User)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, APIResponse{Success: false, Error: "Invalid user type in context"})
 		return
 	}
+	// The context only holds the token claims, so update the stored user
+	currentUser := findUserByID(ctxUser.ID)
+	if currentUser == nil {
+		c.JSON(http.StatusNotFound, APIResponse{Success: false, Error: "User not found"})
+		return
+	}
 
 	// 2. Bind the incoming JSON data
 	var req struct {
@@ -624,8 +1576,7 @@ func updateUserProfile(c *gin.Context) {
 		LastName  string `json:"last_name" binding:"required,min=2,max=50"`
 		Email     string `json:"email" binding:"required,email"`
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, APIResponse{Success: false, Error: "Invalid input data: " + err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -640,8 +1591,9 @@ func updateUserProfile(c *gin.Context) {
 	// 4. Update the user's data
 	currentUser.FirstName = req.FirstName
 	currentUser.LastName = req.LastName
-	currentUser.Email = req.Email
+	setUserEmail(currentUser, req.Email)
 	currentUser.UpdatedAt = time.Now()
+	invalidateUser(currentUser.ID)
 
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
@@ -675,39 +1627,114 @@ func changePassword(c *gin.Context) {
 		CurrentPassword string `json:"current_password" binding:"required"`
 		NewPassword     string `json:"new_password" binding:"required,min=8"`
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, APIResponse{Success: false, Error: "Invalid input data: " + err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
 
 	// 3. Verify the user's CURRENT password
 	//    This is crucial to ensure the person changing the password is the legitimate user.
 	err := bcrypt.CompareHashAndPassword([]byte(currentUser.PasswordHash), []byte(req.CurrentPassword))
-	fmt.Println("CurrentPasswordHash: ", currentUser.PasswordHash)
 	if err != nil {
 		// If err is not nil, it means the password does not match.
 		c.JSON(400, APIResponse{Success: false, Error: "Incorrect current password"})
 		return
 	}
 
-	// 4. (Optional) You can add extra validation for the new password here if needed
-	// if !isStrongPassword(req.NewPassword) { ... }
+	// 4. The new password must meet the same policy as on registration
+	if unmet := passwordPolicy.Validate(req.NewPassword); len(unmet) > 0 {
+		c.JSON(http.StatusBadRequest, APIResponse{
+			Success: false,
+			Data:    gin.H{"unmet_rules": unmet},
+			Error:   "Password does not meet strength requirements",
+		})
+		return
+	}
 
 	// 5. Hash the NEW password and update the user
-	newPasswordHash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	newPasswordHash, err := hashPassword(req.NewPassword)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, APIResponse{Success: false, Error: "Failed to process new password"})
 		return
 	}
-	currentUser.PasswordHash = string(newPasswordHash)
+	currentUser.PasswordHash = newPasswordHash
 	currentUser.UpdatedAt = time.Now()
+	invalidateUser(currentUser.ID)
 
 	c.JSON(http.StatusOK, APIResponse{Success: true, Message: "Password changed successfully"})
 }
 
+// CursorPage is the response data for cursor-based pagination
+type CursorPage struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+// encodeCursor turns the last-seen ID into an opaque cursor
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+// decodeCursor returns the last-seen ID from a cursor, an empty cursor starts from the beginning
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	id, err := strconv.Atoi(string(raw))
+	if err != nil || id < 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return id, nil
+}
+
+// listUsersAfterCursor pages by ID so inserts and deletes between requests
+// don't shift the window the way offsets do
+func listUsersAfterCursor(c *gin.Context, cursor string, limit int) {
+	lastID, err := decodeCursor(cursor)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	// Users are stored in ID order, so the page is the next `limit` users after lastID
+	page := make([]User, 0, limit)
+	hasMore := false
+	for _, u := range users {
+		if u.ID <= lastID {
+			continue
+		}
+		if len(page) == limit {
+			hasMore = true
+			break
+		}
+		page = append(page, u)
+	}
+
+	result := CursorPage{Items: page}
+	if hasMore {
+		result.NextCursor = encodeCursor(page[len(page)-1].ID)
+	}
+
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    result,
+		Message: "Users retrieved successfully",
+	})
+}
+
 // listUsers handles GET /admin/users - Lists all users (admin only)
+// Supports ?page=&limit= offsets, or ?cursor=&limit= for stable iteration
 func listUsers(c *gin.Context) {
-    
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if limit < 1 { limit = 10 }
+		listUsersAfterCursor(c, cursor, limit)
+		return
+	}
+
 	pageStr := c.DefaultQuery("page", "1")
 	limitStr := c.DefaultQuery("limit", "0")
 	page, _ := strconv.Atoi(pageStr)
@@ -749,8 +1776,7 @@ func changeUserRole(c *gin.Context) {
 	var req struct {
 		Role string `json:"role" binding:"required"`
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, APIResponse{Success: false, Error: "Invalid role data"})
+	if !bindJSON(c, &req) {
 		return
 	}
 
@@ -761,6 +1787,9 @@ func changeUserRole(c *gin.Context) {
 		return
 	}
 
+	roleMutex.Lock()
+	defer roleMutex.Unlock()
+
 	// Find the user to be updated
 	user := findUserByID(id)
 	if user == nil {
@@ -768,9 +1797,22 @@ func changeUserRole(c *gin.Context) {
 		return
 	}
 
+	// Never move the last active admin to another role, which would leave
+	// nobody able to reach the /admin routes
+	if user.Role == RoleAdmin && user.IsActive && req.Role != RoleAdmin && countActiveAdmins() <= 1 {
+		message := "Cannot demote the last active admin"
+		claimsVal, _ := c.Get("claims")
+		if claims, ok := claimsVal.(*JWTClaims); ok && claims.UserID == user.ID {
+			message = "Cannot demote yourself: you are the last active admin"
+		}
+		c.JSON(http.StatusConflict, APIResponse{Success: false, Error: message})
+		return
+	}
+
 	// Update the user's role and save it
 	user.Role = req.Role
 	user.UpdatedAt = time.Now()
+	invalidateUser(user.ID)
 
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
@@ -779,31 +1821,102 @@ func changeUserRole(c *gin.Context) {
 	})
 }
 
+// RouteInfo describes one registered route in the route manifest
+type RouteInfo struct {
+	Method       string `json:"method"`
+	Path         string `json:"path"`
+	RequiresAuth bool   `json:"requires_auth"`
+}
+
+// routeManifest records which route groups require authentication, so the
+// engine's routes can be listed with their auth requirement
+type routeManifest struct {
+	engine *gin.Engine
+	groups map[string]bool // group base path -> requires auth
+}
+
+func newRouteManifest(engine *gin.Engine) *routeManifest {
+	return &routeManifest{engine: engine, groups: make(map[string]bool)}
+}
+
+// group creates a route group on the engine and records whether its routes
+// require authentication
+func (m *routeManifest) group(path string, requiresAuth bool, handlers ...gin.HandlerFunc) *gin.RouterGroup {
+	group := m.engine.Group(path, handlers...)
+	m.groups[group.BasePath()] = requiresAuth
+	return group
+}
+
+// requiresAuth reports the auth requirement of the innermost recorded group
+// containing path. Routes outside every group are public.
+func (m *routeManifest) requiresAuth(path string) bool {
+	longest, protected := -1, false
+	for base, auth := range m.groups {
+		if (path == base || strings.HasPrefix(path, strings.TrimSuffix(base, "/")+"/")) && len(base) > longest {
+			longest, protected = len(base), auth
+		}
+	}
+	return protected
+}
+
+// routes lists every registered route, sorted by path and then method
+func (m *routeManifest) routes() []RouteInfo {
+	var routes []RouteInfo
+	for _, route := range m.engine.Routes() {
+		routes = append(routes, RouteInfo{
+			Method:       route.Method,
+			Path:         route.Path,
+			RequiresAuth: m.requiresAuth(route.Path),
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// GET /_routes - List the API's routes for discovery
+func (m *routeManifest) handler(c *gin.Context) {
+	c.JSON(http.StatusOK, APIResponse{
+		Success: true,
+		Data:    m.routes(),
+	})
+}
+
 // Setup router with authentication routes
 func setupRouter() *gin.Engine {
 	router := gin.Default()
+	router.Use(CORSMiddleware(corsConfig))
+	router.Use(apiVersionMiddleware())
+	limiter := NewUserRateLimiter(userRateLimit, userRateBurst)
+	manifest := newRouteManifest(router)
+
+	router.GET("/_routes", manifest.handler)
 
 	// Public routes
-	auth := router.Group("/auth")
+	auth := manifest.group("/auth", false)
 	{
 		auth.POST("/register", register)
 		auth.POST("/login", login)
 		auth.POST("/logout", logout)
 		auth.POST("/refresh", refreshToken)
+		auth.GET("/challenge", getLoginChallenge)
 	}
 
 	// Protected user routes
-	user := router.Group("/user")
-	user.Use(authMiddleware())
+	user := manifest.group("/user", true, authMiddleware(), userRateLimitMiddleware(limiter))
 	{
 		user.GET("/profile", getUserProfile)
+		user.GET("/me", getUserProfile)
 		user.PUT("/profile", updateUserProfile)
 		user.POST("/change-password", changePassword)
 	}
 
 	// Admin routes
-	admin := router.Group("/admin")
-	admin.Use(authMiddleware())
+	admin := manifest.group("/admin", true, authMiddleware(), userRateLimitMiddleware(limiter))
 	admin.Use(requireRole(RoleAdmin))
 	{
 		admin.GET("/users", listUsers)
@@ -813,14 +1926,69 @@ func setupRouter() *gin.Engine {
 	return router
 }
 
-func main() {
-	// Initialize with a default admin user
-	adminHash, _ := hashPassword("admin123")
-	users = append(users, User{
-		ID:            nextUserID,
-		Username:      "admin",
-		Email:         "admin@example.com",
-		PasswordHash:  adminHash,
+// Default bootstrap admin credentials, only accepted outside production
+const (
+	defaultAdminUsername = "admin"
+	defaultAdminPassword = "admin123"
+	defaultAdminEmail    = "admin@example.com"
+)
+
+// AdminBootstrap holds the credentials for the admin created on first start
+type AdminBootstrap struct {
+	Username   string
+	Password   string
+	Email      string
+	Production bool // refuse the default password
+}
+
+// adminBootstrapFromEnv reads ADMIN_USERNAME, ADMIN_PASSWORD and ADMIN_EMAIL,
+// falling back to the defaults for any that are unset. APP_ENV=production
+// marks a production deployment.
+func adminBootstrapFromEnv() AdminBootstrap {
+	getenv := func(key, fallback string) string {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+		return fallback
+	}
+	return AdminBootstrap{
+		Username:   getenv("ADMIN_USERNAME", defaultAdminUsername),
+		Password:   getenv("ADMIN_PASSWORD", defaultAdminPassword),
+		Email:      getenv("ADMIN_EMAIL", defaultAdminEmail),
+		Production: os.Getenv("APP_ENV") == "production",
+	}
+}
+
+// ensureAdmin creates the bootstrap admin unless an admin already exists, so
+// it is safe to run on every start. It reports whether the admin was created.
+// The default password is refused in production, and any other password must
+// meet passwordPolicy.
+func ensureAdmin(cfg AdminBootstrap) (bool, error) {
+	for _, user := range users {
+		if user.Role == RoleAdmin {
+			return false, nil
+		}
+	}
+
+	if cfg.Password == defaultAdminPassword {
+		if cfg.Production {
+			return false, errors.New("refusing to create the admin with the default password in production, set ADMIN_PASSWORD")
+		}
+	} else if unmet := passwordPolicy.Validate(cfg.Password); len(unmet) > 0 {
+		return false, fmt.Errorf("admin password %s", strings.Join(unmet, ", "))
+	}
+	if findUserByUsername(cfg.Username) != nil || findUserByEmail(cfg.Email) != nil {
+		return false, fmt.Errorf("cannot create admin %q: username or email already taken", cfg.Username)
+	}
+
+	hash, err := hashPassword(cfg.Password)
+	if err != nil {
+		return false, err
+	}
+	addUser(User{
+		Username:      cfg.Username,
+		Email:         cfg.Email,
+		PasswordHash:  hash,
 		FirstName:     "Admin",
 		LastName:      "User",
 		Role:          RoleAdmin,
@@ -829,7 +1997,24 @@ func main() {
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	})
-	nextUserID++
+	return true, nil
+}
+
+func main() {
+	// Create the admin on first start
+	cfg := adminBootstrapFromEnv()
+	created, err := ensureAdmin(cfg)
+	if err != nil {
+		log.Fatal("Admin bootstrap failed: ", err)
+	}
+	if created && cfg.Password == defaultAdminPassword {
+		log.Printf("Created admin %q with the default password, set ADMIN_PASSWORD to change it", cfg.Username)
+	}
+
+	stopSweeper := startRefreshTokenSweeper(time.Minute)
+	defer stopSweeper()
+	stopCacheSweeper := userCache.StartSweeper(time.Minute)
+	defer stopCacheSweeper()
 
 	router := setupRouter()
 	router.Run(":8080")
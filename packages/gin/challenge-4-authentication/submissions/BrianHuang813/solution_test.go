@@ -0,0 +1,1374 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// resetTestState resets the global stores and seeds an admin and a regular user
+func resetTestState() *gin.Engine {
+	users = []User{}
+	blacklistedTokens = make(map[string]bool)
+	refreshTokens = make(map[string]int)
+	refreshTokenExpiry = make(map[string]time.Time)
+	refreshTokenFingerprints = make(map[string]string)
+	loginChallenges = make(map[string]*LoginChallenge)
+	nextUserID = 1
+	bcryptCost = bcrypt.MinCost // keep hashing fast, matching addTestUser
+
+	addTestUser("admin", "admin123", RoleAdmin)
+	addTestUser("alice", "Password123!", RoleUser)
+
+	return setupRouter()
+}
+
+// addTestUser stores a user directly, using the minimum bcrypt cost to keep tests fast
+func addTestUser(username, password, role string) *User {
+	hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	users = append(users, User{
+		ID:           nextUserID,
+		Username:     username,
+		Email:        username + "@example.com",
+		PasswordHash: string(hash),
+		FirstName:    "Test",
+		LastName:     "User",
+		Role:         role,
+		IsActive:     true,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	})
+	nextUserID++
+	return &users[len(users)-1]
+}
+
+// performJSON sends a JSON request and decodes the APIResponse
+func performJSON(router *gin.Engine, method, path string, body interface{}, headers map[string]string) (*httptest.ResponseRecorder, APIResponse) {
+	var reader *bytes.Buffer
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewBuffer(data)
+	} else {
+		reader = bytes.NewBuffer(nil)
+	}
+	req, _ := http.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response APIResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func TestLoginChallenge(t *testing.T) {
+	router := resetTestState()
+	wrong := LoginRequest{Username: "alice", Password: "WrongPassword1!"}
+
+	for i := 0; i < challengeThreshold; i++ {
+		w, _ := performJSON(router, "POST", "/auth/login", wrong, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+
+	t.Run("Fourth Attempt Demands Challenge", func(t *testing.T) {
+		w, response := performJSON(router, "POST", "/auth/login",
+			LoginRequest{Username: "alice", Password: "Password123!"}, nil)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+		assert.False(t, response.Success)
+		data, ok := response.Data.(map[string]interface{})
+		assert.True(t, ok)
+		assert.NotEmpty(t, data["challenge_id"])
+		assert.NotEmpty(t, data["question"])
+	})
+
+	t.Run("Wrong Answer Is Rejected", func(t *testing.T) {
+		_, response := performJSON(router, "GET", "/auth/challenge", nil, nil)
+		id := response.Data.(map[string]interface{})["challenge_id"].(string)
+		answer := loginChallenges[id].answer
+
+		w, _ := performJSON(router, "POST", "/auth/login", LoginRequest{
+			Username:        "alice",
+			Password:        "Password123!",
+			ChallengeID:     id,
+			ChallengeAnswer: strconv.Itoa(answer + 1),
+		}, nil)
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	})
+
+	t.Run("Correct Answer Allows Attempt", func(t *testing.T) {
+		w, response := performJSON(router, "GET", "/auth/challenge", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		id := response.Data.(map[string]interface{})["challenge_id"].(string)
+		answer := loginChallenges[id].answer
+
+		req := LoginRequest{
+			Username:        "alice",
+			Password:        "Password123!",
+			ChallengeID:     id,
+			ChallengeAnswer: strconv.Itoa(answer),
+		}
+		w, response = performJSON(router, "POST", "/auth/login", req, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, response.Success)
+
+		// The challenge is single use
+		_, exists := loginChallenges[id]
+		assert.False(t, exists)
+	})
+}
+
+func TestRandomInt(t *testing.T) {
+	// Ranges above 256 used to be impossible with a single random byte
+	seenHigh := false
+	for i := 0; i < 200; i++ {
+		n, err := randomInt(1000)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, n, 0)
+		assert.Less(t, n, 1000)
+		if n >= 256 {
+			seenHigh = true
+		}
+	}
+	assert.True(t, seenHigh, "values above 255 are produced")
+
+	n, err := randomInt(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+// loginTokens logs in and returns the issued tokens
+func loginTokens(t *testing.T, router *gin.Engine, username, password string) *TokenResponse {
+	t.Helper()
+	w, _ := performJSON(router, "POST", "/auth/login", LoginRequest{Username: username, Password: password}, nil)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		t.FailNow()
+	}
+	var response struct {
+		Data TokenResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return &response.Data
+}
+
+func TestRefreshTokenSingleUse(t *testing.T) {
+	router := resetTestState()
+	tokens := loginTokens(t, router, "alice", "Password123!")
+	body := map[string]string{"refresh_token": tokens.RefreshToken}
+
+	// Many clients race to exchange the same token; only one may win
+	const attempts = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	codes := map[int]int{}
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w, _ := performJSON(router, "POST", "/auth/refresh", body, nil)
+			mu.Lock()
+			codes[w.Code]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, codes[http.StatusOK])
+	assert.Equal(t, attempts-1, codes[http.StatusUnauthorized])
+}
+
+func TestRefreshTokenExpiry(t *testing.T) {
+	originalTTL := refreshTokenTTL
+	defer func() { refreshTokenTTL = originalTTL }()
+
+	t.Run("Valid Refresh Before Expiry", func(t *testing.T) {
+		router := resetTestState()
+		refreshTokenTTL = time.Minute
+		tokens := loginTokens(t, router, "alice", "Password123!")
+
+		w, _ := performJSON(router, "POST", "/auth/refresh", map[string]string{"refresh_token": tokens.RefreshToken}, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Refresh After Expiry", func(t *testing.T) {
+		router := resetTestState()
+		refreshTokenTTL = 20 * time.Millisecond
+		tokens := loginTokens(t, router, "alice", "Password123!")
+
+		time.Sleep(40 * time.Millisecond)
+		w, _ := performJSON(router, "POST", "/auth/refresh", map[string]string{"refresh_token": tokens.RefreshToken}, nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		_, exists := refreshTokens[tokens.RefreshToken]
+		assert.False(t, exists, "expired token should be cleaned up")
+	})
+
+	t.Run("Sweeper Removes Expired Tokens", func(t *testing.T) {
+		resetTestState()
+		refreshTokenTTL = 10 * time.Millisecond
+		storeRefreshToken("expiring", 2)
+		refreshTokenTTL = time.Hour
+		storeRefreshToken("fresh", 2)
+
+		stop := startRefreshTokenSweeper(5 * time.Millisecond)
+		defer stop()
+
+		assert.Eventually(t, func() bool {
+			refreshMutex.Lock()
+			defer refreshMutex.Unlock()
+			_, exists := refreshTokens["expiring"]
+			return !exists
+		}, time.Second, 5*time.Millisecond)
+
+		_, ok := lookupRefreshToken("fresh")
+		assert.True(t, ok)
+	})
+}
+
+func TestAPIVersion(t *testing.T) {
+	router := resetTestState()
+
+	t.Run("Version Field Present", func(t *testing.T) {
+		w, response := performJSON(router, "GET", "/auth/challenge", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, apiVersion, response.APIVersion)
+		assert.Equal(t, apiVersion, w.Header().Get("X-API-Version"))
+	})
+
+	t.Run("Supported Vendor Version", func(t *testing.T) {
+		w, response := performJSON(router, "GET", "/auth/challenge", nil,
+			map[string]string{"Accept": "application/vnd.blog.v1+json"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "v1", response.APIVersion)
+	})
+
+	t.Run("Unsupported Vendor Version", func(t *testing.T) {
+		w, response := performJSON(router, "GET", "/auth/challenge", nil,
+			map[string]string{"Accept": "application/vnd.blog.v9+json"})
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.False(t, response.Success)
+	})
+
+	t.Run("Negotiation", func(t *testing.T) {
+		tests := []struct {
+			accept string
+			ok     bool
+		}{
+			{"", true},
+			{"application/json", true},
+			{"*/*", true},
+			{"application/vnd.blog.v2+json, application/vnd.blog.v1+json;q=0.5", true},
+			{"application/vnd.blog.v2+json", false},
+			{"application/vnd.blog.v2+json, application/vnd.blog.v3+json", false},
+			// The client also takes plain JSON, which is the current version
+			{"application/vnd.blog.v2+json, application/json", true},
+			{"application/vnd.blog.v2+json, */*;q=0.1", true},
+		}
+		for _, test := range tests {
+			_, ok := negotiateAPIVersion(test.accept)
+			assert.Equal(t, test.ok, ok, "Accept: %s", test.accept)
+		}
+	})
+}
+
+// fetchUserPage requests one cursor page of /admin/users
+func fetchUserPage(t *testing.T, router *gin.Engine, token, cursor string, limit int) ([]User, string) {
+	t.Helper()
+	path := "/admin/users?limit=" + strconv.Itoa(limit) + "&cursor=" + cursor
+	w, _ := performJSON(router, "GET", path, nil, map[string]string{"Authorization": "Bearer " + token})
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		t.FailNow()
+	}
+	var response struct {
+		Data struct {
+			Items      []User `json:"items"`
+			NextCursor string `json:"next_cursor"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return response.Data.Items, response.Data.NextCursor
+}
+
+func TestListUsersCursor(t *testing.T) {
+	router := resetTestState()
+	for i := 0; i < 3; i++ {
+		addTestUser("user"+strconv.Itoa(i), "Password123!", RoleUser)
+	}
+	token := loginTokens(t, router, "admin", "admin123").AccessToken
+
+	t.Run("Stable Iteration With Inserts", func(t *testing.T) {
+		var seen []int
+		cursor := ""
+		for page := 0; ; page++ {
+			items, next := fetchUserPage(t, router, token, cursor, 2)
+			for _, u := range items {
+				seen = append(seen, u.ID)
+			}
+			if page == 0 {
+				// A user created mid-iteration must not shift later pages
+				addTestUser("latecomer", "Password123!", RoleUser)
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+
+		assert.Equal(t, []int{1, 2, 3, 4, 5, 6}, seen)
+	})
+
+	t.Run("Invalid Cursor", func(t *testing.T) {
+		w, _ := performJSON(router, "GET", "/admin/users?cursor=not-a-cursor", nil,
+			map[string]string{"Authorization": "Bearer " + token})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Offset Pagination Still Works", func(t *testing.T) {
+		w, _ := performJSON(router, "GET", "/admin/users?page=2&limit=2", nil,
+			map[string]string{"Authorization": "Bearer " + token})
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Data []User `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if assert.Len(t, response.Data, 2) {
+			assert.Equal(t, 3, response.Data[0].ID)
+		}
+	})
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength:       8,
+		MaxLength:       16,
+		RequireUpper:    true,
+		RequireLower:    true,
+		RequireDigit:    true,
+		RequireSpecial:  true,
+		BannedPasswords: []string{"Summer2024!"},
+	}
+
+	t.Run("Each Rule", func(t *testing.T) {
+		tests := []struct {
+			password string
+			unmet    string
+		}{
+			{"Sh0rt!", "must be at least 8 characters"},
+			{"Much2Long!Password", "must be at most 16 characters"},
+			{"lower123!", "must contain an uppercase letter"},
+			{"UPPER123!", "must contain a lowercase letter"},
+			{"NoDigits!", "must contain a digit"},
+			{"NoSpecial1", "must contain a special character"},
+		}
+		for _, test := range tests {
+			assert.Equal(t, []string{test.unmet}, policy.Validate(test.password), "Password: %s", test.password)
+		}
+	})
+
+	t.Run("Multiple Rules", func(t *testing.T) {
+		unmet := policy.Validate("abc")
+		assert.Equal(t, []string{
+			"must be at least 8 characters",
+			"must contain an uppercase letter",
+			"must contain a digit",
+			"must contain a special character",
+		}, unmet)
+	})
+
+	t.Run("Banned Password", func(t *testing.T) {
+		assert.Equal(t, []string{"is too common"}, policy.Validate("Summer2024!"))
+		// The banned list ignores case
+		assert.Equal(t, []string{"is too common"}, policy.Validate("sUMMER2024!"))
+		assert.Empty(t, policy.Validate("Autumn2024!"))
+	})
+
+	t.Run("Optional Rules", func(t *testing.T) {
+		lenient := PasswordPolicy{MinLength: 4}
+		assert.Empty(t, lenient.Validate("abcd"))
+		assert.Empty(t, lenient.Validate(strings.Repeat("a", 100)))
+	})
+
+	t.Run("Register Reports Unmet Rules", func(t *testing.T) {
+		router := resetTestState()
+		w, response := performJSON(router, "POST", "/auth/register", RegisterRequest{
+			Username:        "bob",
+			Email:           "bob@example.com",
+			Password:        "Admin123!",
+			ConfirmPassword: "Admin123!",
+			FirstName:       "Bob",
+			LastName:        "Smith",
+		}, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		data, _ := response.Data.(map[string]interface{})
+		assert.Equal(t, []interface{}{"is too common"}, data["unmet_rules"])
+	})
+
+	t.Run("Change Password Uses Policy", func(t *testing.T) {
+		router := resetTestState()
+		token := loginTokens(t, router, "alice", "Password123!").AccessToken
+		headers := map[string]string{"Authorization": "Bearer " + token}
+
+		w, response := performJSON(router, "POST", "/user/change-password", map[string]string{
+			"current_password": "Password123!",
+			"new_password":     "nouppercase1!",
+		}, headers)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		data, _ := response.Data.(map[string]interface{})
+		assert.Equal(t, []interface{}{"must contain an uppercase letter"}, data["unmet_rules"])
+
+		w, _ = performJSON(router, "POST", "/user/change-password", map[string]string{
+			"current_password": "Password123!",
+			"new_password":     "NewPassword1!",
+		}, headers)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+// changeRole sends PUT /admin/users/:id/role as the given admin
+func changeRole(router *gin.Engine, token string, id int, role string) (*httptest.ResponseRecorder, APIResponse) {
+	return performJSON(router, "PUT", "/admin/users/"+strconv.Itoa(id)+"/role", map[string]string{"role": role},
+		map[string]string{"Authorization": "Bearer " + token})
+}
+
+func TestChangeUserRoleLastAdmin(t *testing.T) {
+	t.Run("Demote Another Admin", func(t *testing.T) {
+		router := resetTestState()
+		second := addTestUser("second", "Password123!", RoleAdmin)
+		token := loginTokens(t, router, "admin", "admin123").AccessToken
+
+		w, _ := changeRole(router, token, second.ID, RoleUser)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, RoleUser, findUserByID(second.ID).Role)
+	})
+
+	t.Run("Self Demotion With Other Admins", func(t *testing.T) {
+		router := resetTestState()
+		addTestUser("second", "Password123!", RoleAdmin)
+		token := loginTokens(t, router, "admin", "admin123").AccessToken
+
+		w, _ := changeRole(router, token, 1, RoleModerator)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, RoleModerator, findUserByID(1).Role)
+		assert.Equal(t, 1, countActiveAdmins())
+	})
+
+	t.Run("Self Demotion As Sole Admin", func(t *testing.T) {
+		router := resetTestState()
+		token := loginTokens(t, router, "admin", "admin123").AccessToken
+
+		w, response := changeRole(router, token, 1, RoleUser)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, response.Error, "last active admin")
+		assert.Equal(t, RoleAdmin, findUserByID(1).Role)
+
+		// Keeping the admin role is still allowed
+		w, _ = changeRole(router, token, 1, RoleAdmin)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Sole Admin Cannot Step Down To Moderator", func(t *testing.T) {
+		router := resetTestState()
+		token := loginTokens(t, router, "admin", "admin123").AccessToken
+
+		w, _ := changeRole(router, token, 1, RoleModerator)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, RoleAdmin, findUserByID(1).Role)
+
+		// The /admin routes are still reachable
+		w, _ = performJSON(router, "GET", "/admin/users", nil, map[string]string{"Authorization": "Bearer " + token})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Count Races With Registration", func(t *testing.T) {
+		router := resetTestState()
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				performJSON(router, "POST", "/auth/register", RegisterRequest{
+					Username:        "racer" + strconv.Itoa(i),
+					Email:           "racer" + strconv.Itoa(i) + "@example.com",
+					Password:        "Password123!",
+					ConfirmPassword: "Password123!",
+					FirstName:       "Race",
+					LastName:        "Runner",
+				}, nil)
+			}(i)
+			countActiveAdmins()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, countActiveAdmins())
+	})
+
+	t.Run("Inactive Admins Do Not Count", func(t *testing.T) {
+		router := resetTestState()
+		addTestUser("dormant", "Password123!", RoleAdmin).IsActive = false
+		token := loginTokens(t, router, "admin", "admin123").AccessToken
+
+		w, _ := changeRole(router, token, 1, RoleUser)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+func TestUserCache(t *testing.T) {
+	profileRole := func(router *gin.Engine, token string) string {
+		w, response := performJSON(router, "GET", "/user/profile", nil, map[string]string{"Authorization": "Bearer " + token})
+		assert.Equal(t, http.StatusOK, w.Code)
+		data, _ := response.Data.(map[string]interface{})
+		role, _ := data["role"].(string)
+		return role
+	}
+
+	t.Run("Lookup Is Cached", func(t *testing.T) {
+		resetTestState()
+		user, ok := cachedUserByID(2)
+		assert.True(t, ok)
+		assert.Equal(t, "alice", user.Username)
+
+		cached, ok := userCache.Get(2)
+		assert.True(t, ok)
+		assert.Equal(t, "alice", cached.Username)
+
+		_, ok = cachedUserByID(99)
+		assert.False(t, ok)
+	})
+
+	t.Run("Role Change Invalidates Entry", func(t *testing.T) {
+		router := resetTestState()
+		adminToken := loginTokens(t, router, "admin", "admin123").AccessToken
+		aliceToken := loginTokens(t, router, "alice", "Password123!").AccessToken
+
+		assert.Equal(t, RoleUser, profileRole(router, aliceToken))
+		w, _ := changeRole(router, adminToken, 2, RoleModerator)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		_, cached := userCache.Get(2)
+		assert.False(t, cached)
+		assert.Equal(t, RoleModerator, profileRole(router, aliceToken))
+	})
+
+	t.Run("Password Change Invalidates Entry", func(t *testing.T) {
+		router := resetTestState()
+		token := loginTokens(t, router, "alice", "Password123!").AccessToken
+		cachedUserByID(2)
+
+		w, _ := performJSON(router, "POST", "/user/change-password", map[string]string{
+			"current_password": "Password123!",
+			"new_password":     "NewPassword1!",
+		}, map[string]string{"Authorization": "Bearer " + token})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		_, cached := userCache.Get(2)
+		assert.False(t, cached)
+		user, _ := cachedUserByID(2)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("NewPassword1!")))
+	})
+
+	t.Run("Replaced Store Is Not Served Stale", func(t *testing.T) {
+		resetTestState()
+		cachedUserByID(2)
+
+		users = []User{}
+		nextUserID = 1
+		addTestUser("bob", "Password123!", RoleUser)
+		addTestUser("carol", "Password123!", RoleUser)
+
+		user, ok := cachedUserByID(2)
+		assert.True(t, ok)
+		assert.Equal(t, "carol", user.Username)
+	})
+
+	t.Run("Expired Entry Is Reloaded", func(t *testing.T) {
+		resetTestState()
+		originalTTL := userCacheTTL
+		defer func() { userCacheTTL = originalTTL }()
+		userCacheTTL = time.Millisecond
+
+		cachedUserByID(2)
+		time.Sleep(5 * time.Millisecond)
+
+		_, cached := userCache.Get(2)
+		assert.False(t, cached)
+		user, ok := cachedUserByID(2)
+		assert.True(t, ok)
+		assert.Equal(t, "alice", user.Username)
+	})
+
+	t.Run("Sweeper", func(t *testing.T) {
+		cache := NewTTLCache[string, int]()
+		cache.Set("short", 1, time.Millisecond)
+		cache.Set("long", 2, time.Hour)
+
+		stop := cache.StartSweeper(2 * time.Millisecond)
+		defer stop()
+		assert.Eventually(t, func() bool {
+			cache.mu.Lock()
+			defer cache.mu.Unlock()
+			_, exists := cache.items["short"]
+			return !exists
+		}, time.Second, 2*time.Millisecond)
+
+		value, ok := cache.Get("long")
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+	})
+
+	t.Run("Concurrent Reads And Invalidations", func(t *testing.T) {
+		resetTestState()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					cachedUserByID(1 + j%2)
+					invalidateUser(1 + j%2)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestUserIndexes(t *testing.T) {
+	t.Run("Create Is Indexed", func(t *testing.T) {
+		router := resetTestState()
+		w, _ := performJSON(router, "POST", "/auth/register", RegisterRequest{
+			Username:        "bob",
+			Email:           "bob@example.com",
+			Password:        "Correct-Horse9",
+			ConfirmPassword: "Correct-Horse9",
+			FirstName:       "Bob",
+			LastName:        "Smith",
+		}, nil)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		user := findUserByUsername("bob")
+		if assert.NotNil(t, user) {
+			assert.Same(t, user, findUserByEmail("bob@example.com"))
+			assert.Same(t, user, findUserByID(user.ID))
+			assert.Same(t, &users[len(users)-1], user)
+		}
+		assert.Equal(t, len(users), len(idIndex))
+	})
+
+	t.Run("Email Change Moves Index Entry", func(t *testing.T) {
+		router := resetTestState()
+		token := loginTokens(t, router, "alice", "Password123!").AccessToken
+		headers := map[string]string{"Authorization": "Bearer " + token}
+
+		w, _ := performJSON(router, "PUT", "/user/profile", map[string]string{
+			"first_name": "Alice",
+			"last_name":  "Jones",
+			"email":      "alice.jones@example.com",
+		}, headers)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Nil(t, findUserByEmail("alice@example.com"))
+		user := findUserByEmail("alice.jones@example.com")
+		if assert.NotNil(t, user) {
+			assert.Equal(t, "alice", user.Username)
+		}
+
+		// The old address is free for someone else
+		addUser(User{Username: "carol", Email: "alice@example.com"})
+		assert.Equal(t, "carol", findUserByEmail("alice@example.com").Username)
+	})
+
+	t.Run("Delete Reindexes Later Users", func(t *testing.T) {
+		resetTestState()
+		addTestUser("bob", "Password123!", RoleUser)
+
+		assert.True(t, removeUser(2))
+		assert.False(t, removeUser(2))
+
+		assert.Nil(t, findUserByID(2))
+		assert.Nil(t, findUserByUsername("alice"))
+		assert.Nil(t, findUserByEmail("alice@example.com"))
+		assert.Same(t, &users[1], findUserByUsername("bob"))
+		assert.Equal(t, 3, findUserByEmail("bob@example.com").ID)
+		assert.Len(t, users, 2)
+	})
+
+	t.Run("Role Change Keeps Lookups", func(t *testing.T) {
+		router := resetTestState()
+		token := loginTokens(t, router, "admin", "admin123").AccessToken
+
+		w, _ := changeRole(router, token, 2, RoleModerator)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, RoleModerator, findUserByID(2).Role)
+	})
+
+	t.Run("Replaced Store Is Reindexed", func(t *testing.T) {
+		resetTestState()
+		findUserByID(2)
+
+		// Reorder the store behind the indexes' back
+		users[0], users[1] = users[1], users[0]
+		user := findUserByID(2)
+		if assert.NotNil(t, user) {
+			assert.Equal(t, "alice", user.Username)
+		}
+
+		users = users[:1]
+		assert.Nil(t, findUserByID(1))
+		assert.Nil(t, findUserByUsername("admin"))
+	})
+
+	t.Run("Empty Keys", func(t *testing.T) {
+		resetTestState()
+		addUser(User{Username: "noemail"})
+		assert.Nil(t, findUserByEmail(""))
+		assert.Nil(t, findUserByUsername(""))
+	})
+}
+
+// seedUsers replaces the store with n users without hashing passwords
+func seedUsers(n int) {
+	users = make([]User, 0, n)
+	for i := 1; i <= n; i++ {
+		users = append(users, User{ID: i, Username: "user" + strconv.Itoa(i), Email: "user" + strconv.Itoa(i) + "@example.com"})
+	}
+	nextUserID = n + 1
+}
+
+// scanUserByUsername is the linear lookup the indexes replaced
+func scanUserByUsername(username string) *User {
+	for i := range users {
+		if users[i].Username == username {
+			return &users[i]
+		}
+	}
+	return nil
+}
+
+func BenchmarkUserLookup(b *testing.B) {
+	const n = 100000
+	seedUsers(n)
+	findUserByID(1) // build the indexes outside the timed loop
+
+	b.Run("Index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			findUserByUsername("user" + strconv.Itoa(n-i%100))
+		}
+	})
+
+	b.Run("Scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			scanUserByUsername("user" + strconv.Itoa(n-i%100))
+		}
+	})
+}
+
+// getProfileFrom sends GET /user/profile from the given client IP
+func getProfileFrom(router *gin.Engine, token, ip string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/user/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.RemoteAddr = ip + ":12345"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestUserRateLimit(t *testing.T) {
+	originalLimit, originalBurst := userRateLimit, userRateBurst
+	defer func() { userRateLimit, userRateBurst = originalLimit, originalBurst }()
+	userRateLimit, userRateBurst = 1, 3
+
+	t.Run("One User Across IPs Shares A Budget", func(t *testing.T) {
+		router := resetTestState()
+		token := loginTokens(t, router, "alice", "Password123!").AccessToken
+
+		for i, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.1"} {
+			w := getProfileFrom(router, token, ip)
+			assert.Equal(t, http.StatusOK, w.Code, "Request %d", i+1)
+		}
+		w := getProfileFrom(router, token, "10.0.0.2")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+		assert.NoError(t, err)
+		assert.True(t, retryAfter > 0 && retryAfter <= 60, "Retry-After: %d", retryAfter)
+	})
+
+	t.Run("Different Users Have Separate Budgets", func(t *testing.T) {
+		router := resetTestState()
+		alice := loginTokens(t, router, "alice", "Password123!").AccessToken
+		admin := loginTokens(t, router, "admin", "admin123").AccessToken
+
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, getProfileFrom(router, alice, "10.0.0.1").Code)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, getProfileFrom(router, alice, "10.0.0.1").Code)
+		assert.Equal(t, http.StatusOK, getProfileFrom(router, admin, "10.0.0.1").Code)
+	})
+
+	t.Run("Bad Tokens Stop At Authentication", func(t *testing.T) {
+		router := resetTestState()
+		revoked := loginTokens(t, router, "alice", "Password123!").AccessToken
+		blacklistedTokens[revoked] = true
+
+		for _, token := range []string{"forged.token.value", revoked} {
+			req, _ := http.NewRequest("GET", "/user/me", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+			// Exactly one response body: the handler never ran after the 401
+			decoder := json.NewDecoder(w.Body)
+			var response APIResponse
+			assert.NoError(t, decoder.Decode(&response))
+			assert.Equal(t, "invalid token", response.Error)
+			assert.False(t, decoder.More())
+		}
+	})
+
+	t.Run("Limiter Rejects Requests Without A User", func(t *testing.T) {
+		r := gin.New()
+		r.GET("/limited", userRateLimitMiddleware(NewUserRateLimiter(1, 1)), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/limited", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestUserRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := NewUserRateLimiter(60, 2)
+	limiter.now = func() time.Time { return now }
+
+	t.Run("Refills Over Time", func(t *testing.T) {
+		allowed, _ := limiter.Allow(1)
+		assert.True(t, allowed)
+		allowed, _ = limiter.Allow(1)
+		assert.True(t, allowed)
+
+		allowed, retryAfter := limiter.Allow(1)
+		assert.False(t, allowed)
+		assert.Equal(t, time.Second, retryAfter)
+
+		now = now.Add(time.Second)
+		allowed, _ = limiter.Allow(1)
+		assert.True(t, allowed)
+	})
+
+	t.Run("Idle Users Are Cleaned Up", func(t *testing.T) {
+		limiter.Allow(2)
+		assert.Equal(t, 2, limiter.Len())
+
+		// Both buckets have refilled, so they are dropped on the next call
+		now = now.Add(limiter.refillTime())
+		limiter.Allow(3)
+		assert.Equal(t, 1, limiter.Len())
+	})
+}
+
+func TestCurrentUserProfile(t *testing.T) {
+	t.Run("Returns Fresh Record With Stale Token", func(t *testing.T) {
+		router := resetTestState()
+		token := loginTokens(t, router, "alice", "Password123!").AccessToken
+		headers := map[string]string{"Authorization": "Bearer " + token}
+
+		// Change the stored record without issuing a new token
+		alice := findUserByID(2)
+		setUserEmail(alice, "alice.new@example.com")
+		alice.Role = RoleModerator
+
+		for _, path := range []string{"/user/me", "/user/profile"} {
+			w, response := performJSON(router, "GET", path, nil, headers)
+			assert.Equal(t, http.StatusOK, w.Code, path)
+			data, _ := response.Data.(map[string]interface{})
+			assert.Equal(t, "alice.new@example.com", data["email"], path)
+			assert.Equal(t, RoleModerator, data["role"], path)
+			assert.Equal(t, "Test", data["first_name"], path)
+		}
+	})
+
+	t.Run("Inactive User", func(t *testing.T) {
+		router := resetTestState()
+		token := loginTokens(t, router, "alice", "Password123!").AccessToken
+		findUserByID(2).IsActive = false
+
+		w, _ := performJSON(router, "GET", "/user/me", nil, map[string]string{"Authorization": "Bearer " + token})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Deleted User", func(t *testing.T) {
+		router := resetTestState()
+		token := loginTokens(t, router, "alice", "Password123!").AccessToken
+		removeUser(2)
+
+		w, _ := performJSON(router, "GET", "/user/me", nil, map[string]string{"Authorization": "Bearer " + token})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	router := resetTestState()
+
+	t.Run("Allowed Origin", func(t *testing.T) {
+		w, _ := performJSON(router, "GET", "/auth/challenge", nil, map[string]string{"Origin": "http://localhost:3000"})
+		assert.Equal(t, "http://localhost:3000", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "Origin", w.Header().Get("Vary"))
+		assert.Equal(t, "X-API-Version, Retry-After", w.Header().Get("Access-Control-Expose-Headers"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("Disallowed Origin", func(t *testing.T) {
+		w, _ := performJSON(router, "GET", "/auth/challenge", nil, map[string]string{"Origin": "https://evil.com"})
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Expose-Headers"))
+	})
+
+	t.Run("Preflight Skips Auth", func(t *testing.T) {
+		w, _ := performJSON(router, "OPTIONS", "/user/profile", nil, map[string]string{
+			"Origin":                         "http://localhost:3000",
+			"Access-Control-Request-Method":  "GET",
+			"Access-Control-Request-Headers": "Authorization",
+		})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "http://localhost:3000", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET, POST, PUT, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Authorization, Content-Type, Accept", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "3600", w.Header().Get("Access-Control-Max-Age"))
+		assert.Empty(t, w.Header().Get("Access-Control-Expose-Headers"))
+	})
+
+	t.Run("Wildcard Patterns And Credentials", func(t *testing.T) {
+		cfg := CORSConfig{AllowOrigins: []string{"https://*.example.com", "*"}, AllowCredentials: true}
+		value, ok := cfg.allowedOrigin("https://app.example.com")
+		assert.True(t, ok)
+		assert.Equal(t, "https://app.example.com", value)
+		// Credentials rule out a literal *, so the origin is echoed
+		value, ok = cfg.allowedOrigin("https://other.org")
+		assert.True(t, ok)
+		assert.Equal(t, "https://other.org", value)
+
+		cfg.AllowCredentials = false
+		value, _ = cfg.allowedOrigin("https://other.org")
+		assert.Equal(t, "*", value)
+
+		_, ok = CORSConfig{AllowOrigins: []string{"https://*.example.com"}}.allowedOrigin("https://example.com")
+		assert.False(t, ok)
+	})
+}
+
+func TestConcurrentUserIDs(t *testing.T) {
+	t.Run("Store", func(t *testing.T) {
+		resetTestState()
+		start := nextUserID
+
+		var wg sync.WaitGroup
+		for i := 0; i < 200; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				addUser(User{Username: "user" + strconv.Itoa(i), Email: "user" + strconv.Itoa(i) + "@example.com"})
+			}(i)
+		}
+		wg.Wait()
+
+		assert.Equal(t, start+200, nextUserID)
+		for i := 1; i < len(users); i++ {
+			assert.Greater(t, users[i].ID, users[i-1].ID, "IDs increase in store order")
+		}
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		router := resetTestState()
+
+		var wg sync.WaitGroup
+		ids := make([]int, 5)
+		for i := range ids {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				name := "racer" + strconv.Itoa(i)
+				w, response := performJSON(router, "POST", "/auth/register", RegisterRequest{
+					Username:        name,
+					Email:           name + "@example.com",
+					Password:        "Correct-Horse9",
+					ConfirmPassword: "Correct-Horse9",
+					FirstName:       "Race",
+					LastName:        "Condition",
+				}, nil)
+				if assert.Equal(t, http.StatusCreated, w.Code) {
+					data, _ := response.Data.(map[string]interface{})
+					id, _ := data["id"].(float64)
+					ids[i] = int(id)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		seen := make(map[int]bool)
+		for i, id := range ids {
+			assert.False(t, seen[id], "duplicate ID %d", id)
+			seen[id] = true
+			assert.Equal(t, id, findUserByUsername("racer"+strconv.Itoa(i)).ID)
+		}
+		assert.Len(t, seen, len(ids))
+	})
+}
+
+func TestRoleHierarchy(t *testing.T) {
+	resetTestState()
+	addTestUser("mod", "Password123!", RoleModerator)
+
+	router := gin.New()
+	router.GET("/moderate", authMiddleware(), requireRole(RoleModerator), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/moderators-only", authMiddleware(), requireExactRole(RoleModerator), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	get := func(path string, userID int, username, role string) int {
+		tokens, _ := generateTokens(userID, username, role)
+		w, _ := performJSON(router, "GET", path, nil, map[string]string{"Authorization": "Bearer " + tokens.AccessToken})
+		return w.Code
+	}
+
+	t.Run("Inherited Roles", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get("/moderate", 1, "admin", RoleAdmin))
+		assert.Equal(t, http.StatusOK, get("/moderate", 3, "mod", RoleModerator))
+		assert.Equal(t, http.StatusForbidden, get("/moderate", 2, "alice", RoleUser))
+	})
+
+	t.Run("Exact Match", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, get("/moderators-only", 1, "admin", RoleAdmin))
+		assert.Equal(t, http.StatusOK, get("/moderators-only", 3, "mod", RoleModerator))
+	})
+
+	t.Run("Configured Hierarchy", func(t *testing.T) {
+		original := roleHierarchy
+		defer func() { roleHierarchy = original }()
+		roleHierarchy = map[string][]string{
+			"owner":   {"billing", RoleAdmin},
+			RoleAdmin: {RoleUser},
+			"billing": {"owner"}, // a cycle must not loop
+		}
+
+		assert.True(t, roleSatisfies("owner", RoleUser))
+		assert.True(t, roleSatisfies("billing", RoleAdmin))
+		assert.False(t, roleSatisfies(RoleAdmin, RoleModerator), "admin no longer includes moderator")
+		assert.False(t, roleSatisfies(RoleUser, "owner"))
+		assert.Equal(t, http.StatusForbidden, get("/moderate", 1, "admin", RoleAdmin))
+	})
+}
+
+func TestEnsureAdmin(t *testing.T) {
+	strong := AdminBootstrap{Username: "root", Password: "Bootstrap#2024", Email: "root@example.com", Production: true}
+
+	t.Run("Creates Admin When None Exists", func(t *testing.T) {
+		resetTestState()
+		users = []User{}
+		addTestUser("alice", "Password123!", RoleUser)
+
+		created, err := ensureAdmin(strong)
+		assert.NoError(t, err)
+		assert.True(t, created)
+
+		admin := findUserByUsername("root")
+		if assert.NotNil(t, admin) {
+			assert.Equal(t, RoleAdmin, admin.Role)
+			assert.Equal(t, "root@example.com", admin.Email)
+			assert.True(t, admin.IsActive)
+			assert.True(t, verifyPassword("Bootstrap#2024", admin.PasswordHash))
+		}
+
+		// Running again on the next start is a no-op
+		created, err = ensureAdmin(strong)
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.Len(t, users, 2)
+	})
+
+	t.Run("No-op When Admin Exists", func(t *testing.T) {
+		resetTestState()
+
+		// Even a password that would be rejected isn't checked once an admin exists
+		created, err := ensureAdmin(AdminBootstrap{Username: "other", Password: "weak", Email: "other@example.com"})
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.Len(t, users, 2)
+		assert.Nil(t, findUserByUsername("other"))
+	})
+
+	t.Run("Rejects Weak Password", func(t *testing.T) {
+		resetTestState()
+		users = []User{}
+
+		created, err := ensureAdmin(AdminBootstrap{Username: "root", Password: "password", Email: "root@example.com"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "must contain an uppercase letter")
+		assert.False(t, created)
+		assert.Empty(t, users)
+	})
+
+	t.Run("Default Password", func(t *testing.T) {
+		resetTestState()
+		users = []User{}
+
+		cfg := AdminBootstrap{Username: defaultAdminUsername, Password: defaultAdminPassword, Email: defaultAdminEmail, Production: true}
+		created, err := ensureAdmin(cfg)
+		assert.Error(t, err)
+		assert.False(t, created)
+		assert.Empty(t, users)
+
+		// Still allowed for local development
+		cfg.Production = false
+		created, err = ensureAdmin(cfg)
+		assert.NoError(t, err)
+		assert.True(t, created)
+	})
+
+	t.Run("Reads Environment", func(t *testing.T) {
+		t.Setenv("ADMIN_USERNAME", "")
+		t.Setenv("ADMIN_PASSWORD", "Bootstrap#2024")
+		t.Setenv("ADMIN_EMAIL", "ops@example.com")
+		t.Setenv("APP_ENV", "production")
+
+		assert.Equal(t, AdminBootstrap{
+			Username:   defaultAdminUsername,
+			Password:   "Bootstrap#2024",
+			Email:      "ops@example.com",
+			Production: true,
+		}, adminBootstrapFromEnv())
+	})
+}
+
+func TestPasswordHashUpgrade(t *testing.T) {
+	router := resetTestState()
+	defer func() { bcryptCost = bcrypt.MinCost }()
+	alice := findUserByUsername("alice")
+
+	// The configured cost went up since alice's hash was made
+	bcryptCost = bcrypt.MinCost + 1
+	oldHash := alice.PasswordHash
+
+	w, response := performJSON(router, "POST", "/auth/login", LoginRequest{Username: "alice", Password: "Password123!"}, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, response.Success)
+
+	assert.NotEqual(t, oldHash, alice.PasswordHash)
+	cost, err := bcrypt.Cost([]byte(alice.PasswordHash))
+	assert.NoError(t, err)
+	assert.Equal(t, bcrypt.MinCost+1, cost)
+	assert.True(t, verifyPassword("Password123!", alice.PasswordHash))
+
+	// Logging in with the upgraded hash works and leaves it alone
+	upgradedHash := alice.PasswordHash
+	w, _ = performJSON(router, "POST", "/auth/login", LoginRequest{Username: "alice", Password: "Password123!"}, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, upgradedHash, alice.PasswordHash)
+
+	// A failed login never touches the hash
+	bcryptCost = bcrypt.MinCost + 2
+	w, _ = performJSON(router, "POST", "/auth/login", LoginRequest{Username: "alice", Password: "WrongPass123!"}, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, upgradedHash, alice.PasswordHash)
+}
+
+func TestPasswordHashCost(t *testing.T) {
+	router := resetTestState()
+	defer func() { bcryptCost = bcrypt.MinCost }()
+	bcryptCost = bcrypt.MinCost + 1
+	assertCost := func(hash string) {
+		cost, err := bcrypt.Cost([]byte(hash))
+		assert.NoError(t, err)
+		assert.Equal(t, bcryptCost, cost)
+	}
+
+	// Registration hashes at the configured cost
+	w, _ := performJSON(router, "POST", "/auth/register", RegisterRequest{
+		Username:        "bob",
+		Email:           "bob@example.com",
+		Password:        "Password123!",
+		ConfirmPassword: "Password123!",
+		FirstName:       "Bob",
+		LastName:        "Smith",
+	}, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assertCost(findUserByUsername("bob").PasswordHash)
+
+	// So does changing the password
+	token := loginTokens(t, router, "bob", "Password123!").AccessToken
+	w, _ = performJSON(router, "POST", "/user/change-password", map[string]string{
+		"current_password": "Password123!",
+		"new_password":     "NewPassword1!",
+	}, map[string]string{"Authorization": "Bearer " + token})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assertCost(findUserByUsername("bob").PasswordHash)
+}
+
+func TestRefreshTokenFingerprint(t *testing.T) {
+	router := resetTestState()
+	defer func() { bindRefreshTokens = false }()
+	bindRefreshTokens = true
+
+	phone := map[string]string{"User-Agent": "BlogApp/2.1 (iOS)", deviceIDHeader: "device-1"}
+	login := func(headers map[string]string) string {
+		w, _ := performJSON(router, "POST", "/auth/login", LoginRequest{Username: "alice", Password: "Password123!"}, headers)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Data TokenResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return response.Data.RefreshToken
+	}
+	refresh := func(token string, headers map[string]string) (*httptest.ResponseRecorder, APIResponse) {
+		return performJSON(router, "POST", "/auth/refresh", gin.H{"refresh_token": token}, headers)
+	}
+
+	t.Run("Matching Fingerprint", func(t *testing.T) {
+		w, response := refresh(login(phone), phone)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, response.Success)
+
+		// The rotated token is bound to the same client
+		data := response.Data.(map[string]interface{})
+		w, _ = refresh(data["refresh_token"].(string), map[string]string{"User-Agent": "curl/8.0", deviceIDHeader: "device-1"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Mismatched Fingerprint", func(t *testing.T) {
+		token := login(phone)
+
+		for _, headers := range []map[string]string{
+			{"User-Agent": "BlogApp/2.1 (iOS)", deviceIDHeader: "device-2"},
+			{"User-Agent": "curl/8.0", deviceIDHeader: "device-1"},
+			nil,
+		} {
+			w, response := refresh(token, headers)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Equal(t, "Refresh token was issued to a different client", response.Error)
+		}
+
+		// Rejected replays don't consume the token
+		w, _ := refresh(token, phone)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Legacy Unbound Token", func(t *testing.T) {
+		bindRefreshTokens = false
+		token := login(phone)
+		bindRefreshTokens = true
+
+		w, response := refresh(token, map[string]string{"User-Agent": "curl/8.0"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, response.Success)
+	})
+}
+
+func TestRouteManifest(t *testing.T) {
+	router := resetTestState()
+
+	w, _ := performJSON(router, "GET", "/_routes", nil, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Success bool        `json:"success"`
+		Data    []RouteInfo `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Success)
+
+	expected := []RouteInfo{
+		{Method: "GET", Path: "/_routes", RequiresAuth: false},
+		{Method: "POST", Path: "/auth/login", RequiresAuth: false},
+		{Method: "POST", Path: "/auth/register", RequiresAuth: false},
+		{Method: "POST", Path: "/auth/refresh", RequiresAuth: false},
+		{Method: "GET", Path: "/auth/challenge", RequiresAuth: false},
+		{Method: "GET", Path: "/user/profile", RequiresAuth: true},
+		{Method: "PUT", Path: "/user/profile", RequiresAuth: true},
+		{Method: "POST", Path: "/user/change-password", RequiresAuth: true},
+		{Method: "GET", Path: "/admin/users", RequiresAuth: true},
+		{Method: "PUT", Path: "/admin/users/:id/role", RequiresAuth: true},
+	}
+	for _, route := range expected {
+		assert.Contains(t, response.Data, route)
+	}
+	assert.Len(t, response.Data, len(router.Routes()))
+
+	// The manifest's auth flags match what the routes actually enforce
+	for _, route := range response.Data {
+		if strings.Contains(route.Path, ":") || route.Path == "/auth/logout" {
+			continue
+		}
+		w, _ := performJSON(router, route.Method, route.Path, nil, nil)
+		assert.Equal(t, route.RequiresAuth, w.Code == http.StatusUnauthorized, "%s %s", route.Method, route.Path)
+	}
+}
+
+func TestMalformedBodyVsValidation(t *testing.T) {
+	router := resetTestState()
+
+	postRaw := func(path, body string) (*httptest.ResponseRecorder, APIResponse) {
+		req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response APIResponse
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	t.Run("Malformed JSON", func(t *testing.T) {
+		bodies := map[string]string{
+			"truncated":  `{"username": "alice", "password": "Passw`,
+			"syntax":     `{"username": "alice",, "password": "x"}`,
+			"wrong type": `{"username": 42, "password": "Password123!"}`,
+			"empty":      ``,
+		}
+		for name, body := range bodies {
+			w, response := postRaw("/auth/login", body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+			assert.Equal(t, "Malformed request body", response.Error, name)
+			data, ok := response.Data.(map[string]interface{})
+			if assert.True(t, ok, name) {
+				assert.NotContains(t, data, "fields", name)
+				assert.NotEmpty(t, data["detail"], name)
+			}
+		}
+	})
+
+	t.Run("Validation failure", func(t *testing.T) {
+		before := len(users)
+		w, response := postRaw("/auth/register", `{"username": "al", "email": "not-an-email", "password": "Password123!"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "Validation failed", response.Error)
+
+		data := response.Data.(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{
+			"username":         "must be at least 3 characters",
+			"email":            "must be a valid email address",
+			"confirm_password": "is required",
+			"first_name":       "is required",
+			"last_name":        "is required",
+		}, data["fields"])
+		assert.Len(t, users, before)
+	})
+}